@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,10 +13,22 @@ import (
 	_ "github.com/DmitriyGiryntsev/TODO-API/cmd/docs"
 	"github.com/DmitriyGiryntsev/TODO-API/internal/config"
 	"github.com/DmitriyGiryntsev/TODO-API/internal/db"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/events"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/graph"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/grpcapi"
 	"github.com/DmitriyGiryntsev/TODO-API/internal/handlers"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/mail"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/middleware"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/notify"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/realtime"
 	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
 	"github.com/DmitriyGiryntsev/TODO-API/internal/routes"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/scheduler"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/sessions"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/sso"
 	"github.com/DmitriyGiryntsev/TODO-API/migrations"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/helpers"
 	"github.com/gin-gonic/gin"
 )
 
@@ -41,10 +54,114 @@ func main() {
 	//init repositories
 	userRepo := repository.NewUserRepository(database)
 	taskRepo := repository.NewTaskRepository(database)
+	milestoneRepo := repository.NewMilestoneRepository(database)
+	templateRepo := repository.NewTemplateRepository(database)
+	reminderRepo := repository.NewReminderRepository(database)
+	notificationRepo := repository.NewNotificationRepository(database)
+	eventRepo := repository.NewEventRepository(database)
+	webhookRepo := repository.NewWebhookRepository(database)
+	idempotencyRepo := repository.NewIdempotencyRepository(database)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(database)
+	sessionRepo := repository.NewSessionRepository(database)
+	passwordResetRepo := repository.NewPasswordResetRepository(database)
+	twoFactorRepo := repository.NewTwoFactorRepository(database)
+	loginChallengeRepo := repository.NewLoginChallengeRepository(database)
+	settingsRepo := repository.NewSettingsRepository(database)
+	identityRepo := repository.NewIdentityRepository(database)
+	oidcStateRepo := repository.NewOIDCStateRepository(database)
+	personalTokenRepo := repository.NewPersonalTokenRepository(database)
+
+	// revoked sessions must be known before the first request is checked
+	sessionCache := sessions.NewCache(sessionRepo, cfg.DBURL)
+	if err := sessionCache.Load(); err != nil {
+		log.Fatal("cannot load revoked sessions:", err)
+	}
+
+	signingKeys, err := helpers.LoadKeySet(cfg.JWTKeysDir, cfg.JWTAlgorithm, cfg.JWTKeyRotation)
+	if err != nil {
+		log.Fatal("cannot load signing keys:", err)
+	}
+	tokenSigner := helpers.NewTokenSigner(signingKeys, cfg.JWTIssuer, cfg.JWTAudience)
+
+	var mailer mail.Mailer
+	switch cfg.MailDriver {
+	case "file":
+		mailer = mail.NewFileMailer(cfg.MailDir, cfg.SMTPFrom)
+	case "smtp":
+		mailer = mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	default:
+		log.Fatalf("unknown MAIL_DRIVER %q", cfg.MailDriver)
+	}
+
+	//init services, shared by the REST and gRPC APIs
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, sessionRepo, sessionCache, tokenSigner, passwordResetRepo, twoFactorRepo, loginChallengeRepo, settingsRepo, personalTokenRepo, mailer, cfg.RefreshTokenTTL)
+	authService.RequireVerification = cfg.RequireEmailVerification
+	authService.PublicURL = cfg.PublicURL
+	authService.PasswordResetURL = cfg.PasswordResetURL
+	oidcProviders := make([]*sso.Provider, 0, len(cfg.OIDCProviders))
+	for _, provider := range cfg.OIDCProviders {
+		oidcProviders = append(oidcProviders, sso.NewProvider(sso.ProviderConfig{
+			Name:         provider.Name,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			Scopes:       provider.Scopes,
+		}, cfg.PublicURL+"/api/v1/auth/oidc/"+provider.Name+"/callback"))
+	}
+	ssoService := service.NewSSOService(authService, identityRepo, oidcStateRepo, oidcProviders)
+	taskService := service.NewTaskService(taskRepo, milestoneRepo)
 
 	//init handlers
-	authHandler := handlers.NewAuthHandler(userRepo)
-	taskHendler := handlers.NewTaskHandler(taskRepo)
+	authHandler := handlers.NewAuthHandler(authService)
+	ssoHandler := handlers.NewSSOHandler(ssoService, cfg.OIDCSuccessURL)
+	taskHendler := handlers.NewTaskHandler(taskService, userRepo)
+	milestoneHandler := handlers.NewMilestoneHandler(milestoneRepo)
+	templateHandler := handlers.NewTemplateHandler(templateRepo, taskRepo, milestoneRepo)
+	userHandler := handlers.NewUserHandler(userRepo)
+	reminderHandler := handlers.NewReminderHandler(reminderRepo, taskRepo)
+	notificationHandler := handlers.NewNotificationHandler(notificationRepo)
+	webhookHandler := handlers.NewWebhookHandler(webhookRepo)
+	calendarHandler := handlers.NewCalendarHandler(taskHendler, userRepo, cfg.PublicURL)
+
+	//init background jobs
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	reminderScheduler := scheduler.NewReminderScheduler(reminderRepo, map[string]notify.Notifier{
+		"inbox":   notify.NewInboxNotifier(notificationRepo),
+		"email":   notify.NewEmailNotifier(mailer),
+		"webhook": notify.NewWebhookNotifier(cfg.WebhookAllowPrivate),
+	}, cfg.ReminderInterval)
+	go reminderScheduler.Run(ctx)
+
+	webhookDispatcher := scheduler.NewWebhookDispatcher(webhookRepo, cfg.WebhookInterval, cfg.WebhookAllowPrivate)
+	go webhookDispatcher.Run(ctx)
+
+	janitor := scheduler.NewJanitor(10 * time.Minute)
+	janitor.Add("idempotency keys", idempotencyRepo.PruneExpired)
+	janitor.Add("refresh tokens", refreshTokenRepo.PruneExpired)
+	janitor.Add("sessions", sessionRepo.PruneExpired)
+	janitor.Add("password reset tokens", passwordResetRepo.PruneExpired)
+	janitor.Add("login challenges", loginChallengeRepo.PruneExpired)
+	janitor.Add("OIDC states", oidcStateRepo.PruneExpired)
+	janitor.Add("personal access tokens", personalTokenRepo.PruneExpired)
+	go janitor.Run(ctx)
+
+	go sessionCache.Run(ctx)
+	go signingKeys.Run(ctx)
+
+	eventHub := events.NewHub(eventRepo, cfg.DBURL, cfg.EventRetention)
+	go eventHub.Run(ctx)
+	eventHandler := handlers.NewEventHandler(eventRepo, eventHub)
+
+	graphService, err := graph.NewService(taskRepo, milestoneRepo, reminderRepo, userRepo, taskHendler.Mutator(), eventHub)
+	if err != nil {
+		log.Fatal("cannot build GraphQL schema:", err)
+	}
+	graphQLHandler := handlers.NewGraphQLHandler(graphService)
+
+	realtimeHub := realtime.NewHub(eventHub, taskHendler.Mutator(), taskRepo, sessionCache)
+	wsHandler := handlers.NewWebSocketHandler(realtimeHub, authService)
 
 	//init server
 	router := gin.New()
@@ -52,7 +169,26 @@ func main() {
 	router.Use(gin.Recovery())
 
 	//setup routes
-	routes.SetupRoutes(router, authHandler, taskHendler)
+	routes.SetupRoutes(router, routes.Handlers{
+		Auth:         authHandler,
+		SSO:          ssoHandler,
+		Task:         taskHendler,
+		Milestone:    milestoneHandler,
+		Template:     templateHandler,
+		User:         userHandler,
+		Reminder:     reminderHandler,
+		Notification: notificationHandler,
+		Event:        eventHandler,
+		WebSocket:    wsHandler,
+		Webhook:      webhookHandler,
+		Calendar:     calendarHandler,
+		GraphQL:      graphQLHandler,
+		RequireAuth: func(scopes ...string) gin.HandlerFunc {
+			return middleware.RequireAuth(authService, scopes...)
+		},
+		RequireAdmin: middleware.RequireRole("admin"),
+		Idempotency:  middleware.Idempotency(idempotencyRepo, cfg.IdempotencyTTL),
+	})
 
 	//start server
 	server := &http.Server{
@@ -67,16 +203,47 @@ func main() {
 		}
 	}()
 
+	grpcServer := grpcapi.NewServer(taskService, authService, eventRepo, eventHub)
+	grpcListener, err := net.Listen("tcp", cfg.GRPCAddress)
+	if err != nil {
+		log.Fatal("cannot listen for gRPC:", err)
+	}
+
+	go func() {
+		log.Printf("gRPC server is running on %s", cfg.GRPCAddress)
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			log.Fatalf("gRPC server error %v", err)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
 	log.Println("shutting down server...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
+	// websockets are hijacked connections, server.Shutdown does not wait for them
+	if err := realtimeHub.Shutdown(shutdownCtx); err != nil {
+		log.Println("websocket connections did not drain:", err)
+	}
+	stop()
+
+	// the stopped event hub has ended the task watches, so this only waits for unary calls
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+	}
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Fatal("server forced to shutdown:", err)
 	}
 
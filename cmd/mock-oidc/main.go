@@ -0,0 +1,219 @@
+// Command mock-oidc is an OpenID Connect provider for development and
+// testing. It signs every visitor in as the configured user without asking,
+// so never expose it. Point the API at it with
+//
+//	OIDC_PROVIDERS=mock
+//	OIDC_MOCK_ISSUER=http://localhost:9999
+//	OIDC_MOCK_CLIENT_ID=todo-api
+//
+// and open /api/v1/auth/oidc/mock/login in a browser.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/helpers"
+	"github.com/golang-jwt/jwt"
+)
+
+// grant is an authorization code waiting to be exchanged
+type grant struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+type provider struct {
+	issuer        string
+	subject       string
+	email         string
+	emailVerified bool
+	name          string
+	keys          *helpers.KeySet
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+func main() {
+	addr := flag.String("addr", ":9999", "address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9999", "issuer URL, must be how the API reaches this server")
+	subject := flag.String("sub", "mock-user-1", "subject of the signed in user")
+	email := flag.String("email", "dev@example.com", "email of the signed in user")
+	emailVerified := flag.Bool("email-verified", true, "whether the email is reported as verified")
+	name := flag.String("name", "Dev User", "name of the signed in user")
+	flag.Parse()
+
+	dir, err := os.MkdirTemp("", "mock-oidc-keys")
+	if err != nil {
+		log.Fatal("cannot create key directory:", err)
+	}
+	defer os.RemoveAll(dir)
+
+	keys, err := helpers.LoadKeySet(dir, helpers.AlgorithmRS256, 0)
+	if err != nil {
+		log.Fatal("cannot generate signing key:", err)
+	}
+
+	p := &provider{
+		issuer:        *issuer,
+		subject:       *subject,
+		email:         *email,
+		emailVerified: *emailVerified,
+		name:          *name,
+		keys:          keys,
+		grants:        make(map[string]grant),
+	}
+
+	log.Printf("mock OIDC provider %s signs everyone in as %s", *issuer, *email)
+	log.Fatal(http.ListenAndServe(*addr, p.routes()))
+}
+
+func (p *provider) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/keys", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	return mux
+}
+
+func (p *provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/authorize",
+		"token_endpoint":                        p.issuer + "/token",
+		"jwks_uri":                              p.issuer + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{helpers.AlgorithmRS256},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+func (p *provider) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, p.keys.JWKS())
+}
+
+// authorize approves every request at once and sends the code back
+func (p *provider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || query.Get("redirect_uri") == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if query.Get("response_type") != "code" {
+		http.Error(w, "only response_type=code is supported", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	p.mu.Lock()
+	p.grants[code] = grant{
+		clientID:      query.Get("client_id"),
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	p.mu.Unlock()
+
+	values := redirectURI.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirectURI.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token exchanges a code for an ID token after checking the PKCE verifier
+func (p *provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	clientID, _, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	g, found := p.grants[code]
+	delete(p.grants, code)
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case !found || time.Now().After(g.expiresAt):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "unknown or expired code"})
+		return
+	case g.clientID != clientID || g.redirectURI != r.PostForm.Get("redirect_uri"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "client or redirect_uri mismatch"})
+		return
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != g.codeChallenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "code_verifier does not match"})
+		return
+	}
+
+	key := p.keys.Signing()
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                p.issuer,
+		"sub":                p.subject,
+		"aud":                clientID,
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"nonce":              g.nonce,
+		"email":              p.email,
+		"email_verified":     p.emailVerified,
+		"name":               p.name,
+		"preferred_username": p.name,
+	})
+	idToken.Header["kid"] = key.ID
+
+	signed, err := idToken.SignedString(key.Private)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString() string {
+	buf := make([]byte, 24)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/sso"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/helpers"
+)
+
+const redirectURL = "http://localhost:8080/api/v1/auth/oidc/mock/callback"
+
+// startProvider serves the mock provider and returns the API's client of it
+func startProvider(t *testing.T, emailVerified bool) (*provider, *sso.Provider) {
+	t.Helper()
+
+	keys, err := helpers.LoadKeySet(t.TempDir(), helpers.AlgorithmRS256, 0)
+	if err != nil {
+		t.Fatalf("cannot generate signing key: %v", err)
+	}
+
+	p := &provider{
+		subject:       "mock-user-1",
+		email:         "dev@example.com",
+		emailVerified: emailVerified,
+		name:          "Dev User",
+		keys:          keys,
+		grants:        make(map[string]grant),
+	}
+	server := httptest.NewServer(p.routes())
+	t.Cleanup(server.Close)
+	p.issuer = server.URL
+
+	client := sso.NewProvider(sso.ProviderConfig{Name: "mock", Issuer: server.URL, ClientID: "todo-api"}, redirectURL)
+	return p, client
+}
+
+// authorize follows the sign in link and returns the query of the redirect back to the API
+func authorize(t *testing.T, client *sso.Provider, state string, nonce string, verifier string) url.Values {
+	t.Helper()
+
+	link, err := client.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirect.Get(link)
+	if err != nil {
+		t.Fatalf("GET %s: %v", link, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize answered %d, want a redirect", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), redirectURL+"?") {
+		t.Fatalf("redirected to %q, want the callback", resp.Header.Get("Location"))
+	}
+	return location.Query()
+}
+
+func TestSignIn(t *testing.T) {
+	_, client := startProvider(t, true)
+	verifier := strings.Repeat("v", 43)
+
+	callback := authorize(t, client, "state-1", "nonce-1", verifier)
+	if callback.Get("state") != "state-1" || callback.Get("code") == "" {
+		t.Fatalf("callback query = %v", callback)
+	}
+
+	identity, err := client.Exchange(context.Background(), callback.Get("code"), verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+
+	want := sso.Identity{Subject: "mock-user-1", Email: "dev@example.com", EmailVerified: true, Name: "Dev User", Username: "Dev User"}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+}
+
+func TestSignInUnverifiedEmail(t *testing.T) {
+	_, client := startProvider(t, false)
+	verifier := strings.Repeat("v", 43)
+
+	callback := authorize(t, client, "state", "nonce", verifier)
+	identity, err := client.Exchange(context.Background(), callback.Get("code"), verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if identity.EmailVerified {
+		t.Error("email reported as verified")
+	}
+}
+
+func TestExchangeRefusesWrongVerifier(t *testing.T) {
+	_, client := startProvider(t, true)
+
+	callback := authorize(t, client, "state", "nonce", strings.Repeat("v", 43))
+	if _, err := client.Exchange(context.Background(), callback.Get("code"), strings.Repeat("w", 43), "nonce"); err == nil {
+		t.Error("Exchange() accepted a code_verifier that does not match the challenge")
+	}
+}
+
+func TestCodeWorksOnce(t *testing.T) {
+	_, client := startProvider(t, true)
+	verifier := strings.Repeat("v", 43)
+
+	callback := authorize(t, client, "state", "nonce", verifier)
+	if _, err := client.Exchange(context.Background(), callback.Get("code"), verifier, "nonce"); err != nil {
+		t.Fatalf("first Exchange() error = %v", err)
+	}
+	if _, err := client.Exchange(context.Background(), callback.Get("code"), verifier, "nonce"); err == nil {
+		t.Error("a code was exchanged twice")
+	}
+}
+
+func TestExchangeChecksNonce(t *testing.T) {
+	_, client := startProvider(t, true)
+	verifier := strings.Repeat("v", 43)
+
+	callback := authorize(t, client, "state", "nonce", verifier)
+	if _, err := client.Exchange(context.Background(), callback.Get("code"), verifier, "other nonce"); err == nil {
+		t.Error("Exchange() accepted an ID token with another nonce")
+	}
+}
+
+func TestAuthorizeRequiresPKCE(t *testing.T) {
+	p, _ := startProvider(t, true)
+
+	query := url.Values{
+		"response_type": {"code"},
+		"client_id":     {"todo-api"},
+		"redirect_uri":  {redirectURL},
+		"state":         {"state"},
+	}
+	resp, err := http.Get(p.issuer + "/authorize?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("authorize without code_challenge answered %d, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/handlers"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func (a *app) loginCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "login [email]",
+		Short: "Sign in and store the tokens in the profile",
+		Long: `Sign in to the server of the profile. The password is read from the
+terminal, or from the first line of stdin when it is not a terminal.
+Accounts with two-factor authentication are asked for a code of the
+authenticator app or a recovery code next.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			reader := bufio.NewReader(os.Stdin)
+
+			email := a.profile.Email
+			if len(args) == 1 {
+				email = args[0]
+			}
+			if email == "" {
+				fmt.Fprint(os.Stderr, "Email: ")
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return err
+				}
+				email = strings.TrimSpace(line)
+			}
+
+			password, err := readPassword(reader)
+			if err != nil {
+				return err
+			}
+
+			a.config.SetProfile(a.profileName, a.profile)
+			if a.config.Current == "" {
+				a.config.Current = a.profileName
+			}
+
+			client := a.client()
+			challenge, err := client.Login(email, password)
+			if err != nil {
+				return err
+			}
+
+			if challenge != nil {
+				if err := secondFactor(cmd, client, reader, email, challenge); err != nil {
+					return err
+				}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Logged in to %s as %s (profile %s)\n", a.profile.Server, email, a.profileName)
+			return nil
+		},
+	}
+}
+
+func (a *app) logoutCommand() *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "logout",
+		Short: "Sign out and forget the tokens of the profile",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.client().Logout(all)
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "sign out on every device")
+	return cmd
+}
+
+// secondFactor finishes a sign in that needs a code, enrolling the account
+// first when its role requires 2FA and it has none yet
+func secondFactor(cmd *cobra.Command, client *Client, reader *bufio.Reader, email string, challenge *handlers.TokenResponse) error {
+	if challenge.EnrollmentRequired {
+		enrollment, err := client.EnrollTwoFactor(challenge.ChallengeToken)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Two-factor authentication is required for this account.\nAdd this key to your authenticator app:\n\n  %s\n  %s\n\n", enrollment.Secret, enrollment.URI)
+	}
+
+	fmt.Fprint(os.Stderr, "Authentication code: ")
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return errors.New("no code on stdin")
+	}
+
+	recoveryCodes, err := client.VerifyTwoFactor(email, challenge.ChallengeToken, strings.TrimSpace(line))
+	if err != nil {
+		return err
+	}
+
+	if len(recoveryCodes) > 0 {
+		fmt.Fprintln(cmd.OutOrStdout(), "Two-factor authentication enabled. Keep these recovery codes somewhere safe, each works once:")
+		for _, code := range recoveryCodes {
+			fmt.Fprintln(cmd.OutOrStdout(), "  "+code)
+		}
+	}
+
+	return nil
+}
+
+// readPassword prompts without echo on a terminal and reads a line otherwise, so scripts can pipe it in
+func readPassword(reader *bufio.Reader) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", errors.New("no password on stdin")
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/handlers"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
+)
+
+var errNotLoggedIn = errors.New("not logged in, run todo login")
+
+// Client calls the REST API as the user of a profile. An expired access
+// token is refreshed once per request and the new tokens are saved.
+type Client struct {
+	Config  *Config
+	Profile *Profile
+	HTTP    *http.Client
+}
+
+func NewClient(cfg *Config, profile *Profile) *Client {
+	return &Client{Config: cfg, Profile: profile, HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// APIError is an error answered by the server
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Status)
+}
+
+// Login signs in with the password. When the account has two-factor
+// authentication the challenge is returned and nothing is saved yet,
+// VerifyTwoFactor finishes the sign in.
+func (c *Client) Login(email string, password string) (*handlers.TokenResponse, error) {
+	var tokens handlers.TokenResponse
+	if err := c.call(http.MethodPost, "/api/v1/auth/login", handlers.LoginRequest{Email: email, Password: password}, &tokens, false); err != nil {
+		return nil, err
+	}
+
+	if tokens.TwoFactorRequired {
+		return &tokens, nil
+	}
+
+	return nil, c.saveTokens(email, tokens)
+}
+
+// VerifyTwoFactor finishes a sign in with a code and saves the tokens. It
+// returns the recovery codes when the sign in enrolled the account.
+func (c *Client) VerifyTwoFactor(email string, challengeToken string, code string) ([]string, error) {
+	var tokens handlers.TokenResponse
+	req := handlers.VerifyTwoFactorRequest{ChallengeToken: challengeToken, Code: code}
+	if err := c.call(http.MethodPost, "/api/v1/auth/2fa/verify", req, &tokens, false); err != nil {
+		return nil, err
+	}
+
+	return tokens.RecoveryCodes, c.saveTokens(email, tokens)
+}
+
+// EnrollTwoFactor gets an authenticator secret for an account that must enrol during sign in
+func (c *Client) EnrollTwoFactor(challengeToken string) (*service.TOTPEnrollment, error) {
+	var enrollment service.TOTPEnrollment
+	if err := c.call(http.MethodPost, "/api/v1/auth/2fa/enroll", handlers.ChallengeRequest{ChallengeToken: challengeToken}, &enrollment, false); err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+func (c *Client) saveTokens(email string, tokens handlers.TokenResponse) error {
+	c.Profile.Email = email
+	c.Profile.AccessToken = tokens.AccessToken
+	c.Profile.RefreshToken = tokens.RefreshToken
+	return c.Config.Save()
+}
+
+// Logout revokes the refresh token of the profile, or with all every
+// refresh token of the user, and forgets the tokens
+func (c *Client) Logout(all bool) error {
+	var err error
+	if all {
+		err = c.Do(http.MethodPost, "/api/v1/auth/logout-all", nil, nil)
+	} else if c.Profile.RefreshToken != "" {
+		err = c.call(http.MethodPost, "/api/v1/auth/logout", handlers.RefreshRequest{RefreshToken: c.Profile.RefreshToken}, nil, false)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Profile.AccessToken = ""
+	c.Profile.RefreshToken = ""
+	return c.Config.Save()
+}
+
+func (c *Client) ListTasks(view string, milestoneID int) ([]models.Task, error) {
+	// the terminal shows the Markdown source, the rendered HTML is of no use here
+	query := url.Values{"render": {"markdown"}}
+	if view != "" {
+		query.Set("view", view)
+	}
+	if milestoneID > 0 {
+		query.Set("milestone_id", strconv.Itoa(milestoneID))
+	}
+
+	var tasks []models.Task
+	return tasks, c.Do(http.MethodGet, "/api/v1/tasks/?"+query.Encode(), nil, &tasks)
+}
+
+func (c *Client) GetTask(id int) (*models.Task, error) {
+	var task models.Task
+	return &task, c.Do(http.MethodGet, taskPath(id)+"?render=markdown", nil, &task)
+}
+
+func (c *Client) CreateTask(task *models.Task) (*models.Task, error) {
+	var created models.Task
+	return &created, c.Do(http.MethodPost, "/api/v1/tasks/?render=markdown", task, &created)
+}
+
+// QuickAdd creates a task from a single line such as "Pay rent tomorrow #home !high"
+func (c *Client) QuickAdd(text string) (*handlers.QuickAddResponse, error) {
+	var created handlers.QuickAddResponse
+	return &created, c.Do(http.MethodPost, "/api/v1/tasks/quick", handlers.QuickAddRequest{Text: text}, &created)
+}
+
+func (c *Client) UpdateTask(id int, task *models.Task) error {
+	return c.Do(http.MethodPut, taskPath(id), task, nil)
+}
+
+func (c *Client) DeleteTask(id int) error {
+	return c.Do(http.MethodDelete, taskPath(id), nil, nil)
+}
+
+func taskPath(id int) string {
+	return "/api/v1/tasks/" + strconv.Itoa(id)
+}
+
+// Do sends an authenticated request, body and result are JSON
+func (c *Client) Do(method string, path string, body interface{}, result interface{}) error {
+	if c.Profile.AccessToken == "" {
+		return errNotLoggedIn
+	}
+
+	err := c.call(method, path, body, result, true)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized || c.Profile.RefreshToken == "" {
+		return err
+	}
+
+	if err := c.refresh(); err != nil {
+		return err
+	}
+	return c.call(method, path, body, result, true)
+}
+
+// refresh exchanges the refresh token for new tokens and saves them
+func (c *Client) refresh() error {
+	var tokens handlers.TokenResponse
+	err := c.call(http.MethodPost, "/api/v1/auth/refresh", handlers.RefreshRequest{RefreshToken: c.Profile.RefreshToken}, &tokens, false)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+		return errors.New("session expired, run todo login")
+	} else if err != nil {
+		return err
+	}
+
+	c.Profile.AccessToken = tokens.AccessToken
+	c.Profile.RefreshToken = tokens.RefreshToken
+	return c.Config.Save()
+}
+
+func (c *Client) call(method string, path string, body interface{}, result interface{}, authorized bool) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimRight(c.Profile.Server, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "todo-cli")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorized {
+		req.Header.Set("Authorization", "Bearer "+c.Profile.AccessToken)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var failure handlers.ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&failure); err != nil || failure.Error == "" {
+			failure.Error = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{Status: resp.StatusCode, Message: failure.Error}
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
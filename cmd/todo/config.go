@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	defaultProfile = "default"
+	defaultServer  = "http://localhost:8080"
+)
+
+// Config is the CLI state kept in the user's config directory. It holds
+// tokens, so the file is only readable by its owner.
+type Config struct {
+	Current  string              `json:"current"`
+	Profiles map[string]*Profile `json:"profiles"`
+
+	path string
+}
+
+// Profile is one server together with the tokens of the user signed in to it
+type Profile struct {
+	Server       string `json:"server"`
+	Email        string `json:"email,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// configPath is $TODO_CONFIG or todo/config.json in the user's config directory
+func configPath() (string, error) {
+	if path := os.Getenv("TODO_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todo", "config.json"), nil
+}
+
+func loadConfig() (*Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{Profiles: make(map[string]*Profile), path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]*Profile)
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write and rename, so a failed write does not lose the tokens
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Profile returns the named profile, or a new one for the default server
+// that is only saved once it is added with SetProfile
+func (c *Config) Profile(name string) *Profile {
+	if profile, ok := c.Profiles[name]; ok {
+		return profile
+	}
+	return &Profile{Server: defaultServer}
+}
+
+func (c *Config) SetProfile(name string, profile *Profile) {
+	c.Profiles[name] = profile
+}
+
+// ProfileName picks the profile to use: the flag, $TODO_PROFILE, the current one or "default"
+func (c *Config) ProfileName(flag string) string {
+	for _, name := range []string{flag, os.Getenv("TODO_PROFILE"), c.Current} {
+		if name != "" {
+			return name
+		}
+	}
+	return defaultProfile
+}
+
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
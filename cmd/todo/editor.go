@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+var errUnchanged = errors.New("nothing changed")
+
+// dueLayouts are the date formats accepted for due dates, in local time unless they carry a zone
+var dueLayouts = []string{"2006-01-02 15:04", "2006-01-02", time.RFC3339}
+
+const editorHint = "# Fields end at the first blank line, the Markdown description follows it.\n"
+
+// editTask opens the task in $VISUAL or $EDITOR as a header of fields followed by the description
+func editTask(task *models.Task) (*models.Task, error) {
+	file, err := os.CreateTemp("", "todo-*.md")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	original := formatTaskDocument(task)
+	if _, err := file.WriteString(original); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := runEditor(file.Name()); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nil, err
+	}
+	if string(data) == original {
+		return nil, errUnchanged
+	}
+
+	edited := *task
+	if err := parseTaskDocument(string(data), &edited); err != nil {
+		return nil, err
+	}
+	return &edited, nil
+}
+
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// $EDITOR may carry arguments, e.g. "code --wait"
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s: %w", args[0], err)
+	}
+	return nil
+}
+
+func formatTaskDocument(task *models.Task) string {
+	var b strings.Builder
+
+	b.WriteString(editorHint)
+	fmt.Fprintf(&b, "Title: %s\n", task.Title)
+	fmt.Fprintf(&b, "Status: %s\n", task.Status)
+	fmt.Fprintf(&b, "Priority: %s\n", task.Priority)
+	fmt.Fprintf(&b, "Project: %s\n", task.Project)
+	fmt.Fprintf(&b, "Tags: %s\n", strings.Join(task.Tags, ", "))
+	due := ""
+	if task.DueAt != nil {
+		due = task.DueAt.Local().Format(dueLayouts[0])
+	}
+	fmt.Fprintf(&b, "Due: %s\n", due)
+	milestone := ""
+	if task.MilestoneID != nil {
+		milestone = strconv.Itoa(*task.MilestoneID)
+	}
+	fmt.Fprintf(&b, "Milestone: %s\n", milestone)
+	b.WriteString("\n")
+	b.WriteString(task.Description)
+	if !strings.HasSuffix(task.Description, "\n") {
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// parseTaskDocument reads the fields and description back into task
+func parseTaskDocument(doc string, task *models.Task) error {
+	scanner := bufio.NewScanner(strings.NewReader(doc))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var description strings.Builder
+	inHeader := true
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if !inHeader {
+			description.WriteString(line)
+			description.WriteString("\n")
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			inHeader = false
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("invalid field line %q, expected Name: value", line)
+		}
+		if err := setField(task, strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	task.Description = strings.TrimSpace(description.String())
+	return nil
+}
+
+func setField(task *models.Task, key string, value string) error {
+	switch key {
+	case "title":
+		task.Title = value
+	case "status":
+		task.Status = value
+	case "priority":
+		task.Priority = value
+	case "project":
+		task.Project = value
+	case "tags":
+		task.Tags = splitTags(value)
+	case "due":
+		due, err := parseDue(value)
+		if err != nil {
+			return err
+		}
+		task.DueAt = due
+	case "milestone":
+		if value == "" {
+			task.MilestoneID = nil
+			return nil
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid milestone %q", value)
+		}
+		task.MilestoneID = &id
+	default:
+		return fmt.Errorf("unknown field %q", key)
+	}
+	return nil
+}
+
+// parseDue accepts an empty value or "-" to clear the due date
+func parseDue(value string) (*time.Time, error) {
+	if value == "" || value == "-" {
+		return nil, nil
+	}
+
+	for _, layout := range dueLayouts {
+		if due, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return &due, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid due date %q, use YYYY-MM-DD or YYYY-MM-DD HH:MM", value)
+}
+
+func splitTags(value string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,92 @@
+// Command todo manages tasks of the TODO API from the terminal. Servers
+// and the tokens for them are kept as named profiles, see todo profile.
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// app is the state shared by the commands: global flags and the selected profile
+type app struct {
+	profileFlag string
+	serverFlag  string
+	output      string
+
+	config      *Config
+	profileName string
+	profile     *Profile
+}
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	a := &app{}
+
+	root := &cobra.Command{
+		Use:          "todo",
+		Short:        "Manage your tasks from the terminal",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return a.load()
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVarP(&a.profileFlag, "profile", "p", "", "server profile, $TODO_PROFILE or the current profile by default")
+	flags.StringVar(&a.serverFlag, "server", "", "API server URL, overrides the one of the profile")
+	flags.StringVarP(&a.output, "output", "o", OutputTable, "output format: table, json or plain")
+
+	root.RegisterFlagCompletionFunc("profile", a.completeProfiles)
+	root.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(outputFormats, cobra.ShellCompDirectiveNoFileComp))
+
+	root.AddCommand(
+		a.loginCommand(),
+		a.logoutCommand(),
+		a.profileCommand(),
+		a.listCommand(),
+		a.showCommand(),
+		a.addCommand(),
+		a.editCommand(),
+		a.doneCommand(),
+		a.deleteCommand(),
+	)
+
+	return root
+}
+
+func (a *app) load() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	a.config = cfg
+	a.profileName = cfg.ProfileName(a.profileFlag)
+	a.profile = cfg.Profile(a.profileName)
+	if a.serverFlag != "" {
+		// a copy, the server is only stored when login adds the profile
+		profile := *a.profile
+		profile.Server = a.serverFlag
+		a.profile = &profile
+	}
+
+	return nil
+}
+
+func (a *app) client() *Client {
+	return NewClient(a.config, a.profile)
+}
+
+func (a *app) completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return cfg.Names(), cobra.ShellCompDirectiveNoFileComp
+}
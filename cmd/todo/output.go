@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// Output formats of the --output flag
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputPlain = "plain"
+)
+
+var outputFormats = []string{OutputTable, OutputJSON, OutputPlain}
+
+// printTasks writes the tasks in the given format. Plain is one task per
+// line with tab separated fields, meant for grep, cut and friends.
+func printTasks(w io.Writer, format string, tasks []models.Task) error {
+	switch format {
+	case OutputJSON:
+		return printJSON(w, tasks)
+	case OutputPlain:
+		for _, task := range tasks {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", task.ID, task.Status, task.Priority, formatDue(task.DueAt), task.Title)
+		}
+		return nil
+	case OutputTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tSTATUS\tPRIORITY\tDUE\tTITLE\tTAGS")
+		for _, task := range tasks {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", task.ID, task.Status, task.Priority, formatDue(task.DueAt), truncate(task.Title, 60), strings.Join(task.Tags, ","))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q, use one of %s", format, strings.Join(outputFormats, ", "))
+	}
+}
+
+// printTask writes a single task, the table format shows every field
+func printTask(w io.Writer, format string, task *models.Task) error {
+	switch format {
+	case OutputJSON:
+		return printJSON(w, task)
+	case OutputPlain:
+		return printTasks(w, format, []models.Task{*task})
+	case OutputTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "ID\t%d\n", task.ID)
+		fmt.Fprintf(tw, "Title\t%s\n", task.Title)
+		fmt.Fprintf(tw, "Status\t%s\n", task.Status)
+		fmt.Fprintf(tw, "Priority\t%s\n", task.Priority)
+		fmt.Fprintf(tw, "Due\t%s\n", formatDue(task.DueAt))
+		fmt.Fprintf(tw, "Project\t%s\n", task.Project)
+		fmt.Fprintf(tw, "Tags\t%s\n", strings.Join(task.Tags, ", "))
+		if task.MilestoneID != nil {
+			fmt.Fprintf(tw, "Milestone\t%d\n", *task.MilestoneID)
+		}
+		if task.Pinned {
+			fmt.Fprintln(tw, "Pinned\tyes")
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		if task.Description != "" {
+			fmt.Fprintf(w, "\n%s\n", task.Description)
+		}
+		return nil
+	default:
+		return printTasks(w, format, nil)
+	}
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func formatDue(due *time.Time) string {
+	if due == nil {
+		return "-"
+	}
+	return due.Local().Format("2006-01-02 15:04")
+}
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func (a *app) profileCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage server profiles",
+		Long: `A profile is a server together with the tokens for it. Commands use the
+profile given with --profile, then $TODO_PROFILE, then the current one.`,
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List the profiles, the current one is marked with *",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				fmt.Fprintln(tw, "\tNAME\tSERVER\tUSER")
+				for _, name := range a.config.Names() {
+					profile := a.config.Profiles[name]
+					current := ""
+					if name == a.config.Current {
+						current = "*"
+					}
+					user := profile.Email
+					if profile.AccessToken == "" {
+						user = "-"
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", current, name, profile.Server, user)
+				}
+				return tw.Flush()
+			},
+		},
+		&cobra.Command{
+			Use:               "use <name>",
+			Short:             "Make the profile the current one",
+			Args:              cobra.ExactArgs(1),
+			ValidArgsFunction: a.completeProfiles,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if _, ok := a.config.Profiles[args[0]]; !ok {
+					return fmt.Errorf("no profile %q, create it with todo profile set", args[0])
+				}
+				a.config.Current = args[0]
+				return a.config.Save()
+			},
+		},
+		a.profileSetCommand(),
+		&cobra.Command{
+			Use:               "remove <name>",
+			Short:             "Delete the profile and its tokens",
+			Args:              cobra.ExactArgs(1),
+			ValidArgsFunction: a.completeProfiles,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				delete(a.config.Profiles, args[0])
+				if a.config.Current == args[0] {
+					a.config.Current = ""
+				}
+				return a.config.Save()
+			},
+		},
+	)
+
+	return cmd
+}
+
+func (a *app) profileSetCommand() *cobra.Command {
+	var server string
+
+	cmd := &cobra.Command{
+		Use:   "set <name> --server <url>",
+		Short: "Create the profile or change its server",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile := a.config.Profile(args[0])
+			a.config.SetProfile(args[0], profile)
+			if server != "" && server != profile.Server {
+				// tokens of one server are no good for another
+				profile.Server = server
+				profile.AccessToken = ""
+				profile.RefreshToken = ""
+			}
+			return a.config.Save()
+		},
+	}
+
+	cmd.Flags().StringVar(&server, "server", "", "API server URL, e.g. https://todo.example.com")
+	return cmd
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	views      = []string{repository.ViewMyDay, repository.ViewPinned, repository.ViewSnoozed}
+	statuses   = []string{"pending", "in_progress", "completed"}
+	priorities = []string{"low", "medium", "high"}
+)
+
+func (a *app) listCommand() *cobra.Command {
+	var (
+		view        string
+		milestoneID int
+		status      string
+	)
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List tasks",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tasks, err := a.client().ListTasks(view, milestoneID)
+			if err != nil {
+				return err
+			}
+
+			if status != "" {
+				filtered := tasks[:0]
+				for _, task := range tasks {
+					if task.Status == status {
+						filtered = append(filtered, task)
+					}
+				}
+				tasks = filtered
+			}
+
+			return printTasks(cmd.OutOrStdout(), a.output, tasks)
+		},
+	}
+
+	cmd.Flags().StringVar(&view, "view", "", "my_day, pinned or snoozed, snoozed tasks are hidden by default")
+	cmd.Flags().IntVar(&milestoneID, "milestone", 0, "only tasks of the milestone")
+	cmd.Flags().StringVar(&status, "status", "", "only tasks with the status")
+	cmd.RegisterFlagCompletionFunc("view", cobra.FixedCompletions(views, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("status", cobra.FixedCompletions(statuses, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func (a *app) showCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:               "show <id>",
+		Short:             "Show a task with its description",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: a.completeTaskIDs(false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+
+			task, err := a.client().GetTask(id)
+			if err != nil {
+				return err
+			}
+
+			return printTask(cmd.OutOrStdout(), a.output, task)
+		},
+	}
+}
+
+func (a *app) addCommand() *cobra.Command {
+	var useEditor bool
+
+	cmd := &cobra.Command{
+		Use:   "add [text...]",
+		Short: "Add a task",
+		Long: `Add a task from a single line, which is understood the same way as in
+the apps: "Pay rent tomorrow 9am #home !high every month +flat".
+Without text, or with --editor, the task is written in $EDITOR instead.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := a.client()
+
+			if len(args) > 0 && !useEditor {
+				created, err := client.QuickAdd(strings.Join(args, " "))
+				if err != nil {
+					return err
+				}
+				return printTask(cmd.OutOrStdout(), a.output, &created.Task)
+			}
+
+			draft := &models.Task{Title: strings.Join(args, " "), Status: "pending", Priority: "medium"}
+			task, err := editTask(draft)
+			if errors.Is(err, errUnchanged) {
+				return errors.New("task not added, the editor was closed without changes")
+			} else if err != nil {
+				return err
+			}
+
+			created, err := client.CreateTask(task)
+			if err != nil {
+				return err
+			}
+			return printTask(cmd.OutOrStdout(), a.output, created)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&useEditor, "editor", "e", false, "write the task in $EDITOR, the text becomes its title")
+	return cmd
+}
+
+func (a *app) editCommand() *cobra.Command {
+	var (
+		title       string
+		description string
+		status      string
+		priority    string
+		project     string
+		tags        string
+		due         string
+	)
+
+	fields := []string{"title", "description", "status", "priority", "project", "tags", "due"}
+
+	cmd := &cobra.Command{
+		Use:               "edit <id>",
+		Short:             "Change a task, in $EDITOR unless fields are given as flags",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: a.completeTaskIDs(false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseID(args[0])
+			if err != nil {
+				return err
+			}
+
+			client := a.client()
+			task, err := client.GetTask(id)
+			if err != nil {
+				return err
+			}
+
+			flags := cmd.Flags()
+			changed := false
+			for _, field := range fields {
+				changed = changed || flags.Changed(field)
+			}
+
+			if !changed {
+				task, err = editTask(task)
+				if errors.Is(err, errUnchanged) {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Nothing changed")
+					return nil
+				} else if err != nil {
+					return err
+				}
+			}
+
+			if flags.Changed("title") {
+				task.Title = title
+			}
+			if flags.Changed("description") {
+				task.Description = description
+			}
+			if flags.Changed("status") {
+				task.Status = status
+			}
+			if flags.Changed("priority") {
+				task.Priority = priority
+			}
+			if flags.Changed("project") {
+				task.Project = project
+			}
+			if flags.Changed("tags") {
+				task.Tags = splitTags(tags)
+			}
+			if flags.Changed("due") {
+				if task.DueAt, err = parseDue(due); err != nil {
+					return err
+				}
+			}
+
+			if err := client.UpdateTask(id, task); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Task %d updated\n", id)
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&title, "title", "", "new title")
+	flags.StringVar(&description, "description", "", "new description, Markdown")
+	flags.StringVar(&status, "status", "", "pending, in_progress or completed")
+	flags.StringVar(&priority, "priority", "", "low, medium or high")
+	flags.StringVar(&project, "project", "", "new project")
+	flags.StringVar(&tags, "tags", "", "comma separated tags, replacing the current ones")
+	flags.StringVar(&due, "due", "", `due date as YYYY-MM-DD or "YYYY-MM-DD HH:MM", "-" clears it`)
+	cmd.RegisterFlagCompletionFunc("status", cobra.FixedCompletions(statuses, cobra.ShellCompDirectiveNoFileComp))
+	cmd.RegisterFlagCompletionFunc("priority", cobra.FixedCompletions(priorities, cobra.ShellCompDirectiveNoFileComp))
+
+	return cmd
+}
+
+func (a *app) doneCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:               "done <id>...",
+		Aliases:           []string{"complete"},
+		Short:             "Mark tasks as completed",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: a.completeTaskIDs(true),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return a.eachTask(cmd, args, func(client *Client, id int) error {
+				task, err := client.GetTask(id)
+				if err != nil {
+					return err
+				}
+
+				task.Status = "completed"
+				if err := client.UpdateTask(id, task); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "Task %d completed\n", id)
+				return nil
+			})
+		},
+	}
+}
+
+func (a *app) deleteCommand() *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:               "delete <id>...",
+		Aliases:           []string{"rm"},
+		Short:             "Delete tasks",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: a.completeTaskIDs(false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !yes && term.IsTerminal(int(os.Stdin.Fd())) {
+				fmt.Fprintf(os.Stderr, "Delete %d task(s)? [y/N] ", len(args))
+				answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+				if reply := strings.ToLower(strings.TrimSpace(answer)); reply != "y" && reply != "yes" {
+					return errors.New("aborted")
+				}
+			}
+
+			return a.eachTask(cmd, args, func(client *Client, id int) error {
+				if err := client.DeleteTask(id); err != nil {
+					return err
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "Task %d deleted\n", id)
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "do not ask for confirmation")
+	return cmd
+}
+
+// eachTask runs fn for every ID, carrying on past failures and reporting them at the end
+func (a *app) eachTask(cmd *cobra.Command, args []string, fn func(client *Client, id int) error) error {
+	client := a.client()
+	failed := 0
+
+	for _, arg := range args {
+		id, err := parseID(arg)
+		if err == nil {
+			err = fn(client, id)
+		}
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Task %s: %v\n", arg, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tasks failed", failed, len(args))
+	}
+	return nil
+}
+
+// completeTaskIDs offers the IDs of the user's tasks with their titles as descriptions
+func (a *app) completeTaskIDs(openOnly bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		tasks, err := a.client().ListTasks("", 0)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		given := make(map[string]bool, len(args))
+		for _, arg := range args {
+			given[arg] = true
+		}
+
+		completions := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			if (openOnly && task.Status == "completed") || given[strconv.Itoa(task.ID)] {
+				continue
+			}
+			completions = append(completions, fmt.Sprintf("%d\t%s", task.ID, task.Title))
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func parseID(arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid task ID %q", arg)
+	}
+	return id, nil
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -9,6 +12,49 @@ import (
 type Config struct {
 	DBURL         string
 	ServerAddress string
+	GRPCAddress   string
+
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// MailDriver is smtp, or file to write emails into MailDir instead
+	MailDriver string
+	MailDir    string
+
+	PublicURL                string
+	PasswordResetURL         string
+	RequireEmailVerification bool
+
+	ReminderInterval time.Duration
+	EventRetention   time.Duration
+	WebhookInterval  time.Duration
+	// WebhookAllowPrivate lets webhooks call loopback and private addresses, for development only
+	WebhookAllowPrivate bool
+	IdempotencyTTL      time.Duration
+	RefreshTokenTTL     time.Duration
+
+	JWTKeysDir     string
+	JWTAlgorithm   string
+	JWTKeyRotation time.Duration
+	JWTIssuer      string
+	JWTAudience    string
+
+	OIDCProviders []OIDCProvider
+	// OIDCSuccessURL is a page of the web app that gets the tokens of an
+	// OIDC sign in in its fragment, without it the callback answers JSON
+	OIDCSuccessURL string
+}
+
+// OIDCProvider is an OpenID Connect provider users can sign in with
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -16,8 +62,117 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
+	reminderInterval, err := getDuration("REMINDER_INTERVAL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	eventRetention, err := getDuration("EVENT_RETENTION", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	webhookInterval, err := getDuration("WEBHOOK_INTERVAL", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	idempotencyTTL, err := getDuration("IDEMPOTENCY_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokenTTL, err := getDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	jwtKeyRotation, err := getDuration("JWT_KEY_ROTATION", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProviders, err := getOIDCProviders()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
 		DBURL:         os.Getenv("DB_URL"),
 		ServerAddress: os.Getenv("SERVER_ADDRESS"),
+		GRPCAddress:   getEnv("GRPC_ADDRESS", ":9090"),
+
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnv("SMTP_PORT", "1025"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:     getEnv("SMTP_FROM", "todo-api@localhost"),
+
+		MailDriver: getEnv("MAIL_DRIVER", "smtp"),
+		MailDir:    getEnv("MAIL_DIR", "mail"),
+
+		PublicURL:                getEnv("PUBLIC_URL", "http://localhost:8080"),
+		PasswordResetURL:         os.Getenv("PASSWORD_RESET_URL"),
+		RequireEmailVerification: os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true",
+
+		ReminderInterval:    reminderInterval,
+		EventRetention:      eventRetention,
+		WebhookInterval:     webhookInterval,
+		WebhookAllowPrivate: os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true",
+		IdempotencyTTL:      idempotencyTTL,
+		RefreshTokenTTL:     refreshTokenTTL,
+
+		JWTKeysDir:     getEnv("JWT_KEYS_DIR", "keys"),
+		JWTAlgorithm:   getEnv("JWT_ALGORITHM", "EdDSA"),
+		JWTKeyRotation: jwtKeyRotation,
+		JWTIssuer:      getEnv("JWT_ISSUER", "todo-api"),
+		JWTAudience:    getEnv("JWT_AUDIENCE", "todo-api"),
+
+		OIDCProviders:  oidcProviders,
+		OIDCSuccessURL: os.Getenv("OIDC_SUCCESS_URL"),
 	}, nil
 }
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// getOIDCProviders reads the providers named in OIDC_PROVIDERS, each from
+// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and _SCOPES
+func getOIDCProviders() ([]OIDCProvider, error) {
+	var providers []OIDCProvider
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %s needs %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
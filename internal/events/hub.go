@@ -0,0 +1,237 @@
+// Package events fans task changes out to live clients. Changes are recorded
+// by a database trigger and announced with NOTIFY, so every server instance
+// sees every change no matter which instance made it.
+package events
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/lib/pq"
+)
+
+const channel = "task_events"
+
+// Subscriber receives the events of one user in order, from the first one
+// after it subscribed. C is closed when the subscriber falls too far behind,
+// the client is expected to reconnect and resume.
+type Subscriber struct {
+	UserID int
+	C      chan models.TaskEvent
+
+	// last is the sequence number of the last event sent to C, 0 while unknown
+	last int64
+}
+
+type Hub struct {
+	Repo          *repository.EventRepository
+	DBURL         string
+	BufferSize    int
+	Retention     time.Duration
+	MaxUserEvents int
+
+	mu          sync.RWMutex
+	subscribers map[int]map[*Subscriber]struct{}
+}
+
+func NewHub(repo *repository.EventRepository, dbURL string, retention time.Duration) *Hub {
+	return &Hub{
+		Repo:          repo,
+		DBURL:         dbURL,
+		BufferSize:    64,
+		Retention:     retention,
+		MaxUserEvents: 1000,
+		subscribers:   make(map[int]map[*Subscriber]struct{}),
+	}
+}
+
+func (h *Hub) Subscribe(userID int) *Subscriber {
+	sub := &Subscriber{UserID: userID, C: make(chan models.TaskEvent, h.BufferSize)}
+
+	// the position tells what the subscriber missed when notifications are lost
+	if _, latest, err := h.Repo.GetEventBounds(userID); err == nil {
+		sub.last = latest
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscriber]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+
+	return sub
+}
+
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub.UserID][sub]; ok {
+		delete(h.subscribers[sub.UserID], sub)
+		close(sub.C)
+	}
+	if len(h.subscribers[sub.UserID]) == 0 {
+		delete(h.subscribers, sub.UserID)
+	}
+}
+
+// Run listens for notifications until ctx is cancelled
+func (h *Hub) Run(ctx context.Context) {
+	listener := pq.NewListener(h.DBURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Print("event listener:", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(channel); err != nil {
+		log.Print("cannot listen for task events:", err)
+		return
+	}
+
+	prune := time.NewTicker(10 * time.Minute)
+	defer prune.Stop()
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	log.Println("event hub started")
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.closeAll()
+			log.Println("event hub stopped")
+			return
+		case n := <-listener.Notify:
+			// nil after the connection was re-established, the events in between come from the log
+			if n == nil {
+				h.catchUpAll()
+				continue
+			}
+			h.dispatch(n.Extra)
+		case <-prune.C:
+			h.Repo.PruneEvents(h.Retention, h.MaxUserEvents)
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+func (h *Hub) dispatch(payload string) {
+	userPart, seqPart, _ := strings.Cut(payload, ":")
+
+	userID, err := strconv.Atoi(userPart)
+	if err != nil {
+		log.Print("invalid task event notification:", payload)
+		return
+	}
+	seq, err := strconv.ParseInt(seqPart, 10, 64)
+	if err != nil {
+		log.Print("invalid task event notification:", payload)
+		return
+	}
+
+	h.catchUp(userID, seq)
+}
+
+// catchUp sends the user's subscribers the events from the log they have not
+// had up to seq, or all of them when seq is 0. Reading from the log rather
+// than the notification alone also fills the gaps left by events that were
+// skipped because their task was gone.
+func (h *Hub) catchUp(userID int, seq int64) {
+	from, ok := h.position(userID, seq)
+	if !ok {
+		return
+	}
+
+	events, _, err := h.Repo.GetEventsAfter(userID, from, h.BufferSize)
+	if err != nil {
+		return
+	}
+
+	for _, event := range events {
+		h.Publish(event)
+	}
+}
+
+// catchUpAll catches every subscriber up after notifications may have been lost
+func (h *Hub) catchUpAll() {
+	h.mu.RLock()
+	users := make([]int, 0, len(h.subscribers))
+	for userID := range h.subscribers {
+		users = append(users, userID)
+	}
+	h.mu.RUnlock()
+
+	for _, userID := range users {
+		h.catchUp(userID, 0)
+	}
+}
+
+// position returns the sequence number the log has to be read after for the
+// user's subscribers that lag the furthest behind
+func (h *Hub) position(userID int, seq int64) (int64, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.subscribers[userID]) == 0 {
+		return 0, false
+	}
+
+	from := int64(math.MaxInt64)
+	if seq > 0 {
+		from = seq - 1
+	}
+	for sub := range h.subscribers[userID] {
+		if sub.last > 0 && sub.last < from {
+			from = sub.last
+		}
+	}
+
+	return from, from != math.MaxInt64
+}
+
+// Publish hands the event to every subscriber of its user that has not had
+// it yet, dropping the ones that cannot keep up
+func (h *Hub) Publish(event models.TaskEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers[event.UserID] {
+		if event.ID <= sub.last {
+			continue
+		}
+
+		select {
+		case sub.C <- event:
+			sub.last = event.ID
+		default:
+			log.Printf("dropping slow event subscriber of user %d", sub.UserID)
+			delete(h.subscribers[event.UserID], sub)
+			close(sub.C)
+		}
+	}
+}
+
+// closeAll ends every stream so the server can shut down
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for userID, subs := range h.subscribers {
+		for sub := range subs {
+			close(sub.C)
+		}
+		delete(h.subscribers, userID)
+	}
+}
@@ -0,0 +1,207 @@
+// Package graph serves the GraphQL API. Resolvers read through the same
+// repositories as the REST handlers and write through the validation of
+// TaskHandler, related records are loaded in batches once per query level.
+package graph
+
+import (
+	"context"
+	"errors"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/events"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Mutator creates, updates and deletes tasks with the same validation as the REST API
+type Mutator interface {
+	CreateTask(userID int, task *models.Task) error
+	UpdateTask(userID int, taskID int, task *models.Task) error
+	DeleteTask(userID int, taskID int) error
+}
+
+// Operation types a request can carry
+const (
+	OperationQuery        = ast.OperationTypeQuery
+	OperationMutation     = ast.OperationTypeMutation
+	OperationSubscription = ast.OperationTypeSubscription
+)
+
+type Service struct {
+	Tasks      *repository.TaskRepository
+	Milestones *repository.MilestoneRepository
+	Reminders  *repository.ReminderRepository
+	Users      *repository.UserRepository
+	Mutator    Mutator
+	Events     *events.Hub
+	Queries    *QueryCache
+
+	MaxDepth      int
+	MaxComplexity int
+
+	schema graphql.Schema
+}
+
+func NewService(tasks *repository.TaskRepository, milestones *repository.MilestoneRepository, reminders *repository.ReminderRepository, users *repository.UserRepository, mutator Mutator, hub *events.Hub) (*Service, error) {
+	s := &Service{
+		Tasks:         tasks,
+		Milestones:    milestones,
+		Reminders:     reminders,
+		Users:         users,
+		Mutator:       mutator,
+		Events:        hub,
+		Queries:       NewQueryCache(1000),
+		MaxDepth:      8,
+		MaxComplexity: 5000,
+	}
+
+	schema, err := s.buildSchema()
+	if err != nil {
+		return nil, err
+	}
+	s.schema = schema
+
+	return s, nil
+}
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    struct {
+		PersistedQuery *PersistedQuery `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// Prepare resolves a persisted query into req.Query and checks the
+// operation against the limits. It returns the operation type, or the
+// result to answer with when the request cannot run. A query that does
+// not parse is left for the executor to report.
+func (s *Service) Prepare(req *Request) (string, *graphql.Result) {
+	query, err := s.Queries.Resolve(req.Query, req.Extensions.PersistedQuery)
+	if errors.Is(err, ErrPersistedQueryNotFound) {
+		return "", errorResult(err, "PERSISTED_QUERY_NOT_FOUND")
+	} else if err != nil {
+		return "", errorResult(err, "BAD_REQUEST")
+	}
+	req.Query = query
+
+	if req.Query == "" {
+		return "", errorResult(errors.New("query is required"), "BAD_REQUEST")
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		return OperationQuery, nil
+	}
+
+	operation, fragments := findOperation(doc, req.OperationName)
+	if operation == nil {
+		return OperationQuery, nil
+	}
+
+	if err := checkLimits(measure(operation, fragments, req.Variables), s.MaxDepth, s.MaxComplexity); err != nil {
+		return "", errorResult(err, "QUERY_TOO_COMPLEX")
+	}
+
+	return operation.Operation, nil
+}
+
+// Execute runs a prepared query or mutation for the user
+func (s *Service) Execute(ctx context.Context, userID int, req Request) *graphql.Result {
+	return graphql.Do(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        s.withRequest(ctx, userID),
+	})
+}
+
+// Subscribe runs a prepared subscription, the channel closes when ctx is done
+func (s *Service) Subscribe(ctx context.Context, userID int, req Request) chan *graphql.Result {
+	return graphql.Subscribe(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        s.withRequest(ctx, userID),
+	})
+}
+
+func errorResult(err error, code string) *graphql.Result {
+	formatted := gqlerrors.FormatError(err)
+	formatted.Extensions = map[string]interface{}{"code": code}
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{formatted}}
+}
+
+type contextKey struct{}
+
+// requestState is what resolvers of one request share
+type requestState struct {
+	userID  int
+	loaders *loaders
+}
+
+type loaders struct {
+	task           *Loader[int, *models.Task]
+	subtasks       *Loader[int, []*models.Task]
+	milestone      *Loader[int, *models.Milestone]
+	milestoneTasks *Loader[int, []*models.Task]
+	progress       *Loader[int, *models.MilestoneProgress]
+	reminders      *Loader[int, []*models.Reminder]
+}
+
+func (s *Service) withRequest(ctx context.Context, userID int) context.Context {
+	l := &loaders{
+		task: NewLoader(func(ids []int) (map[int]*models.Task, error) {
+			return s.Tasks.GetTasksByIDs(userID, ids)
+		}),
+		subtasks: NewLoader(func(ids []int) (map[int][]*models.Task, error) {
+			return groupedPointers(s.Tasks.GetSubtasksByParentIDs(userID, ids))
+		}),
+		milestone: NewLoader(func(ids []int) (map[int]*models.Milestone, error) {
+			return s.Milestones.GetMilestonesByIDs(userID, ids)
+		}),
+		milestoneTasks: NewLoader(func(ids []int) (map[int][]*models.Task, error) {
+			return groupedPointers(s.Tasks.GetTasksByMilestoneIDs(userID, ids))
+		}),
+		progress: NewLoader(func(ids []int) (map[int]*models.MilestoneProgress, error) {
+			return s.Milestones.GetMilestoneProgresses(userID, ids)
+		}),
+		reminders: NewLoader(func(ids []int) (map[int][]*models.Reminder, error) {
+			return groupedPointers(s.Reminders.GetRemindersByTaskIDs(userID, ids))
+		}),
+	}
+
+	return context.WithValue(ctx, contextKey{}, &requestState{userID: userID, loaders: l})
+}
+
+func stateFrom(p graphql.ResolveParams) *requestState {
+	return p.Context.Value(contextKey{}).(*requestState)
+}
+
+func userIDFrom(p graphql.ResolveParams) int {
+	return stateFrom(p).userID
+}
+
+func loadersFrom(p graphql.ResolveParams) *loaders {
+	return stateFrom(p).loaders
+}
+
+func groupedPointers[T any](grouped map[int][]T, err error) (map[int][]*T, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[int][]*T, len(grouped))
+	for key, items := range grouped {
+		result[key] = pointers(items)
+	}
+	return result, nil
+}
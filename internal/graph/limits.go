@@ -0,0 +1,162 @@
+package graph
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listFields return lists, their selections are counted once per expected item
+var listFields = map[string]bool{"tasks": true, "subtasks": true, "milestones": true, "reminders": true}
+
+// defaultListSize is the item count assumed for a list without "first"
+const defaultListSize = 20
+
+// maxListSize is the most items a list returns, a larger "first" is cut down to it
+const maxListSize = 1000
+
+// queryCost is how deep the operation nests and roughly how many values it resolves
+type queryCost struct {
+	depth      int
+	complexity int
+}
+
+// findOperation picks the operation to run the way the executor does, nil when it is ambiguous
+func findOperation(doc *ast.Document, operationName string) (*ast.OperationDefinition, map[string]*ast.FragmentDefinition) {
+	var operation *ast.OperationDefinition
+	fragments := make(map[string]*ast.FragmentDefinition)
+	count := 0
+
+	for _, definition := range doc.Definitions {
+		switch d := definition.(type) {
+		case *ast.OperationDefinition:
+			count++
+			if operationName == "" || (d.Name != nil && d.Name.Value == operationName) {
+				operation = d
+			}
+		case *ast.FragmentDefinition:
+			fragments[d.Name.Value] = d
+		}
+	}
+
+	if operationName == "" && count != 1 {
+		return nil, fragments
+	}
+	return operation, fragments
+}
+
+// measure walks the selections of the operation. Introspection fields are
+// left out, tools send deeply nested introspection queries on their own.
+// The cost of a fragment is worked out once however often it is spread, so
+// fragments spreading each other a few times over cannot make the walk
+// itself expensive.
+func measure(operation *ast.OperationDefinition, fragments map[string]*ast.FragmentDefinition, variables map[string]interface{}) queryCost {
+	measured := make(map[string]queryCost)
+	visiting := make(map[string]bool)
+
+	var walk func(set *ast.SelectionSet) queryCost
+	walk = func(set *ast.SelectionSet) queryCost {
+		var total queryCost
+		if set == nil {
+			return total
+		}
+
+		for _, selection := range set.Selections {
+			var cost queryCost
+
+			switch s := selection.(type) {
+			case *ast.Field:
+				if strings.HasPrefix(s.Name.Value, "__") {
+					continue
+				}
+				child := walk(s.SelectionSet)
+				cost.depth = child.depth + 1
+				cost.complexity = saturatingAdd(1, saturatingMul(child.complexity, listSize(s, variables)))
+			case *ast.InlineFragment:
+				cost = walk(s.SelectionSet)
+			case *ast.FragmentSpread:
+				name := s.Name.Value
+				if known, ok := measured[name]; ok {
+					cost = known
+					break
+				}
+				// a fragment spreading itself is refused by validation later on
+				fragment, ok := fragments[name]
+				if !ok || visiting[name] {
+					continue
+				}
+				visiting[name] = true
+				cost = walk(fragment.SelectionSet)
+				delete(visiting, name)
+				measured[name] = cost
+			}
+
+			total.depth = max(total.depth, cost.depth)
+			total.complexity = saturatingAdd(total.complexity, cost.complexity)
+		}
+
+		return total
+	}
+
+	return walk(operation.SelectionSet)
+}
+
+// listSize is how many items the field is expected to return, at most maxListSize
+func listSize(field *ast.Field, variables map[string]interface{}) int {
+	if !listFields[field.Name.Value] {
+		return 1
+	}
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+
+		var first int
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			first, _ = strconv.Atoi(value.Value)
+		case *ast.Variable:
+			switch v := variables[value.Name.Value].(type) {
+			case int:
+				first = v
+			case float64:
+				first = int(min(v, maxListSize))
+			}
+		}
+		if first > 0 {
+			return min(first, maxListSize)
+		}
+	}
+
+	return defaultListSize
+}
+
+// saturatingAdd and saturatingMul stop at math.MaxInt instead of wrapping
+// around, costs are never negative
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+func saturatingMul(a, b int) int {
+	if a != 0 && b > math.MaxInt/a {
+		return math.MaxInt
+	}
+	return a * b
+}
+
+func checkLimits(cost queryCost, maxDepth int, maxComplexity int) error {
+	if maxDepth > 0 && cost.depth > maxDepth {
+		return fmt.Errorf("query is nested %d levels deep, the limit is %d", cost.depth, maxDepth)
+	}
+	if maxComplexity > 0 && cost.complexity > maxComplexity {
+		return fmt.Errorf("query complexity is %d, the limit is %d", cost.complexity, maxComplexity)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package graph
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+func measureQuery(t *testing.T, query string, variables map[string]interface{}) queryCost {
+	t.Helper()
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(query)})})
+	if err != nil {
+		t.Fatalf("cannot parse %q: %v", query, err)
+	}
+	operation, fragments := findOperation(doc, "")
+	if operation == nil {
+		t.Fatalf("no operation in %q", query)
+	}
+	return measure(operation, fragments, variables)
+}
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		variables  map[string]interface{}
+		depth      int
+		complexity int
+	}{
+		{"single field", `{ me { id } }`, nil, 2, 2},
+		{"default list size", `{ tasks { id } }`, nil, 2, 1 + defaultListSize},
+		{"first", `{ tasks(first: 5) { id title } }`, nil, 2, 1 + 5*2},
+		{"first from variable", `query($n: Int) { tasks(first: $n) { id } }`, map[string]interface{}{"n": float64(3)}, 2, 1 + 3},
+		{"first clamped", `{ tasks(first: 1000000) { id } }`, nil, 2, 1 + maxListSize},
+		{"variable clamped", `query($n: Int) { tasks(first: $n) { id } }`, map[string]interface{}{"n": float64(1e300)}, 2, 1 + maxListSize},
+		{"nested lists", `{ tasks(first: 10) { subtasks(first: 10) { id } } }`, nil, 3, 1 + 10*(1+10)},
+		{"introspection ignored", `{ __schema { types { name } } me { id } }`, nil, 2, 2},
+		{"fragment", `{ tasks(first: 2) { ...f } } fragment f on Task { id title }`, nil, 2, 1 + 2*2},
+		{"fragment cycle", `{ me { ...a } } fragment a on User { id ...a }`, nil, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cost := measureQuery(t, tt.query, tt.variables)
+			if cost.depth != tt.depth || cost.complexity != tt.complexity {
+				t.Errorf("measure = depth %d, complexity %d, want depth %d, complexity %d", cost.depth, cost.complexity, tt.depth, tt.complexity)
+			}
+		})
+	}
+}
+
+func TestMeasureSaturates(t *testing.T) {
+	query := `{ ` + strings.Repeat(`tasks(first: 1000) { `, 10) + `id` + strings.Repeat(` }`, 10) + ` }`
+
+	cost := measureQuery(t, query, nil)
+	if cost.complexity != math.MaxInt {
+		t.Errorf("complexity = %d, want it to stop at %d", cost.complexity, math.MaxInt)
+	}
+	if err := checkLimits(cost, 0, 5000); err == nil {
+		t.Error("checkLimits accepted a query that overflows")
+	}
+}
+
+func TestMeasureFragmentsOnce(t *testing.T) {
+	// every fragment spreads the next one twice, walked naively this visits 2^40 fields
+	var query strings.Builder
+	query.WriteString(`{ me { ...f0 } }`)
+	for i := 0; i < 40; i++ {
+		query.WriteString(" fragment f" + strconv.Itoa(i) + " on User { id ...f" + strconv.Itoa(i+1) + " ...f" + strconv.Itoa(i+1) + " }")
+	}
+	query.WriteString(" fragment f40 on User { id }")
+
+	cost := measureQuery(t, query.String(), nil)
+	// f40 costs 1 and every fragment above it 1 + twice the next, me adds 1
+	if cost.complexity != 1<<41 {
+		t.Errorf("complexity = %d, want %d", cost.complexity, 1<<41)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	items := make([]int, 2*maxListSize)
+	for i := range items {
+		items[i] = i
+	}
+
+	tests := []struct {
+		name     string
+		offset   int
+		first    int
+		hasFirst bool
+		want     int
+	}{
+		{"first", 0, 5, true, 5},
+		{"offset", 2*maxListSize - 3, 10, true, 3},
+		{"offset past the end", 3 * maxListSize, 10, true, 0},
+		{"no first", 0, 0, false, maxListSize},
+		{"first clamped", 0, 5 * maxListSize, true, maxListSize},
+		{"negative first", 0, -1, true, maxListSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := window(items, tt.offset, tt.first, tt.hasFirst); len(got) != tt.want {
+				t.Errorf("window returned %d items, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package graph
+
+import "sync"
+
+// Loader batches the lookups of one query. Load only records the key and
+// returns a thunk; the executor resolves a whole level of the query before
+// running its thunks, so the first thunk fetches every key recorded by then
+// in a single call and the rest are served from the cache.
+type Loader[K comparable, V any] struct {
+	fetch func(keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending []K
+	queued  map[K]bool
+	values  map[K]V
+	errs    map[K]error
+}
+
+func NewLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:  fetch,
+		queued: make(map[K]bool),
+		values: make(map[K]V),
+		errs:   make(map[K]error),
+	}
+}
+
+// Load returns a thunk yielding the value for key, the zero value when there is none
+func (l *Loader[K, V]) Load(key K) func() (interface{}, error) {
+	l.mu.Lock()
+	if _, done := l.values[key]; !done && !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.queued[key] {
+			l.flush()
+		}
+		return l.values[key], l.errs[key]
+	}
+}
+
+// flush fetches every pending key, the caller holds mu
+func (l *Loader[K, V]) flush() {
+	keys := l.pending
+	l.pending = nil
+
+	values, err := l.fetch(keys)
+	for _, key := range keys {
+		delete(l.queued, key)
+		l.values[key] = values[key]
+		if err != nil {
+			l.errs[key] = err
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Errors of the automatic persisted query protocol, clients react to the
+// message by sending the full query along with its hash
+var (
+	ErrPersistedQueryNotFound = errors.New("PersistedQueryNotFound")
+	ErrPersistedQueryMismatch = errors.New("provided sha does not match query")
+	ErrPersistedQueryVersion  = errors.New("unsupported persisted query version")
+)
+
+// PersistedQuery is the persistedQuery request extension
+type PersistedQuery struct {
+	Version    int    `json:"version"`
+	SHA256Hash string `json:"sha256Hash"`
+}
+
+// QueryCache keeps the most recently used persisted queries. Each server
+// instance has its own, a client hitting a cold instance just sends the
+// query once more.
+type QueryCache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cachedQuery struct {
+	hash  string
+	query string
+}
+
+func NewQueryCache(size int) *QueryCache {
+	return &QueryCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Resolve returns the query to run. With a query it checks the hash and
+// remembers the query, without one it looks the hash up.
+func (q *QueryCache) Resolve(query string, persisted *PersistedQuery) (string, error) {
+	if persisted == nil {
+		return query, nil
+	}
+	if persisted.Version != 1 {
+		return "", ErrPersistedQueryVersion
+	}
+
+	hash := strings.ToLower(persisted.SHA256Hash)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if query == "" {
+		element, ok := q.entries[hash]
+		if !ok {
+			return "", ErrPersistedQueryNotFound
+		}
+		q.order.MoveToFront(element)
+		return element.Value.(cachedQuery).query, nil
+	}
+
+	sum := sha256.Sum256([]byte(query))
+	if hex.EncodeToString(sum[:]) != hash {
+		return "", ErrPersistedQueryMismatch
+	}
+
+	if element, ok := q.entries[hash]; ok {
+		q.order.MoveToFront(element)
+		return query, nil
+	}
+
+	q.entries[hash] = q.order.PushFront(cachedQuery{hash: hash, query: query})
+	if q.order.Len() > q.size {
+		oldest := q.order.Back()
+		q.order.Remove(oldest)
+		delete(q.entries, oldest.Value.(cachedQuery).hash)
+	}
+
+	return query, nil
+}
@@ -0,0 +1,503 @@
+package graph
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/markdown"
+	"github.com/graphql-go/graphql"
+)
+
+// buildSchema declares the types and wires their resolvers to the service
+func (s *Service) buildSchema() (graphql.Schema, error) {
+	var taskType, milestoneType *graphql.Object
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"username":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"email":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"role":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"timezone":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveUser(func(u *models.User) interface{} { return u.Created_at })},
+		},
+	})
+
+	reminderType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Reminder",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"taskId":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"remindAt":      &graphql.Field{Type: graphql.DateTime},
+			"offsetMinutes": &graphql.Field{Type: graphql.Int},
+			"channel":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"target":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"status":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"attempts":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"lastError":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"sentAt":        &graphql.Field{Type: graphql.DateTime},
+			"createdAt":     &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveReminder(func(r *models.Reminder) interface{} { return r.Created_at })},
+		},
+	})
+
+	progressType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MilestoneProgress",
+		Fields: graphql.Fields{
+			"totalTasks":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"percentComplete": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"overdueTasks":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"pending":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: resolveStatusCount("pending")},
+			"inProgress":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: resolveStatusCount("in_progress")},
+			"completed":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: resolveStatusCount("completed")},
+		},
+	})
+
+	pageArgs := graphql.FieldConfigArgument{
+		"first":  &graphql.ArgumentConfig{Type: graphql.Int},
+		"offset": &graphql.ArgumentConfig{Type: graphql.Int},
+	}
+
+	taskType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Task",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":              &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"title":           &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"description":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"descriptionHtml": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "The Markdown description rendered and sanitised", Resolve: resolveTask(func(t *models.Task) interface{} { return markdown.Render(t.Description) })},
+				"status":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"priority":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"project":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"recurrence":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"tags":            &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), Resolve: resolveTask(func(t *models.Task) interface{} { return nonNilTags(t.Tags) })},
+				"dueAt":           &graphql.Field{Type: graphql.DateTime},
+				"snoozedUntil":    &graphql.Field{Type: graphql.DateTime},
+				"pinned":          &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"clientId":        &graphql.Field{Type: graphql.String},
+				"milestoneId":     &graphql.Field{Type: graphql.ID, Resolve: resolveTask(func(t *models.Task) interface{} { return optionalID(t.MilestoneID) })},
+				"parentId":        &graphql.Field{Type: graphql.ID, Resolve: resolveTask(func(t *models.Task) interface{} { return optionalID(t.ParentID) })},
+				"createdAt":       &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveTask(func(t *models.Task) interface{} { return t.Created_at })},
+				"updatedAt":       &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveTask(func(t *models.Task) interface{} { return t.Updated_at })},
+				"milestone": &graphql.Field{Type: milestoneType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					task := p.Source.(*models.Task)
+					if task.MilestoneID == nil {
+						return nil, nil
+					}
+					return loadersFrom(p).milestone.Load(*task.MilestoneID), nil
+				}},
+				"parent": &graphql.Field{Type: taskType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					task := p.Source.(*models.Task)
+					if task.ParentID == nil {
+						return nil, nil
+					}
+					return loadersFrom(p).task.Load(*task.ParentID), nil
+				}},
+				"subtasks": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(taskType))), Args: pageArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return page(loadersFrom(p).subtasks.Load(p.Source.(*models.Task).ID), p.Args), nil
+				}},
+				"reminders": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(reminderType))), Args: pageArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return page(loadersFrom(p).reminders.Load(p.Source.(*models.Task).ID), p.Args), nil
+				}},
+			}
+		}),
+	})
+
+	milestoneType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Milestone",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"title":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"dueDate":     &graphql.Field{Type: graphql.DateTime},
+				"state":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"createdAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveMilestone(func(m *models.Milestone) interface{} { return m.Created_at })},
+				"updatedAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveMilestone(func(m *models.Milestone) interface{} { return m.Updated_at })},
+				"progress": &graphql.Field{Type: graphql.NewNonNull(progressType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p).progress.Load(p.Source.(*models.Milestone).ID), nil
+				}},
+				"tasks": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(taskType))), Args: pageArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return page(loadersFrom(p).milestoneTasks.Load(p.Source.(*models.Milestone).ID), p.Args), nil
+				}},
+			}
+		}),
+	})
+
+	taskEventType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TaskEvent",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: resolveEvent(func(e *models.TaskEvent) interface{} { return strconv.FormatInt(e.ID, 10) })},
+			"type":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"taskId":    &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"task":      &graphql.Field{Type: taskType, Description: "Empty for deleted tasks"},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveEvent(func(e *models.TaskEvent) interface{} { return e.Created_at })},
+		},
+	})
+
+	taskInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "TaskInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":       &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"description": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"status":      &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"priority":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"project":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"recurrence":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"tags":        &graphql.InputObjectFieldConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"dueAt":       &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+			"milestoneId": &graphql.InputObjectFieldConfig{Type: graphql.ID},
+			"parentId":    &graphql.InputObjectFieldConfig{Type: graphql.ID, Description: "Only taken into account when the task is created"},
+		},
+	})
+
+	idArgs := graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{Type: graphql.NewNonNull(userType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return s.Users.GetUserByID(userIDFrom(p))
+			}},
+			"task": &graphql.Field{Type: taskType, Args: idArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := idArg(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				return loadersFrom(p).task.Load(id), nil
+			}},
+			"tasks": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(taskType))),
+				Args: graphql.FieldConfigArgument{
+					"view":        &graphql.ArgumentConfig{Type: graphql.String, Description: "my_day, pinned or snoozed. Snoozed tasks are hidden by default"},
+					"milestoneId": &graphql.ArgumentConfig{Type: graphql.ID},
+					"first":       &graphql.ArgumentConfig{Type: graphql.Int},
+					"offset":      &graphql.ArgumentConfig{Type: graphql.Int},
+				},
+				Resolve: s.resolveTasks,
+			},
+			"milestone": &graphql.Field{Type: milestoneType, Args: idArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := idArg(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				return loadersFrom(p).milestone.Load(id), nil
+			}},
+			"milestones": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(milestoneType))), Args: pageArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				milestones, err := s.Milestones.GetAllMilestonesByUserID(userIDFrom(p))
+				if err != nil {
+					return nil, err
+				}
+				return page(pointers(milestones), p.Args), nil
+			}},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createTask": &graphql.Field{
+				Type: graphql.NewNonNull(taskType),
+				Args: graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(taskInput)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					task, err := taskFromInput(p.Args["input"])
+					if err != nil {
+						return nil, err
+					}
+					if err := s.Mutator.CreateTask(userIDFrom(p), task); err != nil {
+						return nil, err
+					}
+					return task, nil
+				},
+			},
+			"updateTask": &graphql.Field{
+				Type: graphql.NewNonNull(taskType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(taskInput)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, err := idArg(p.Args["id"])
+					if err != nil {
+						return nil, err
+					}
+					task, err := taskFromInput(p.Args["input"])
+					if err != nil {
+						return nil, err
+					}
+					if err := s.Mutator.UpdateTask(userIDFrom(p), id, task); err != nil {
+						return nil, err
+					}
+					return s.Tasks.GetTaskByID(id, userIDFrom(p))
+				},
+			},
+			"deleteTask": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean), Args: idArgs, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				id, err := idArg(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				if err := s.Mutator.DeleteTask(userIDFrom(p), id); err != nil {
+					return nil, err
+				}
+				return true, nil
+			}},
+			"updateTimezone": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{"timezone": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					timezone := p.Args["timezone"].(string)
+					if _, err := time.LoadLocation(timezone); err != nil || timezone == "" {
+						return nil, errors.New("unknown timezone")
+					}
+
+					user, err := s.Users.GetUserByID(userIDFrom(p))
+					if err != nil {
+						return nil, err
+					}
+					user.Timezone = timezone
+					if err := s.Users.UpdateUser(user); err != nil {
+						return nil, err
+					}
+					return user, nil
+				},
+			},
+		},
+	})
+
+	subscription := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"taskChanged": &graphql.Field{
+				Type:        graphql.NewNonNull(taskEventType),
+				Description: "Changes of the user's tasks, or of a single task when taskId is given",
+				Args:        graphql.FieldConfigArgument{"taskId": &graphql.ArgumentConfig{Type: graphql.ID}},
+				Subscribe:   s.subscribeTaskChanged,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:        query,
+		Mutation:     mutation,
+		Subscription: subscription,
+	})
+}
+
+func (s *Service) resolveTasks(p graphql.ResolveParams) (interface{}, error) {
+	var filter repository.TaskFilter
+
+	if raw, ok := p.Args["milestoneId"]; ok {
+		milestoneID, err := idArg(raw)
+		if err != nil {
+			return nil, err
+		}
+		filter.MilestoneID = &milestoneID
+	}
+
+	switch view, _ := p.Args["view"].(string); view {
+	case repository.ViewDefault, repository.ViewMyDay, repository.ViewPinned, repository.ViewSnoozed:
+		filter.View = view
+	default:
+		return nil, errors.New("invalid view")
+	}
+
+	tasks, err := s.Tasks.GetAllTasksByUserID(userIDFrom(p), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return page(pointers(tasks), p.Args), nil
+}
+
+// subscribeTaskChanged feeds the user's task events to the subscription until the request ends
+func (s *Service) subscribeTaskChanged(p graphql.ResolveParams) (interface{}, error) {
+	var taskID int
+	if raw, ok := p.Args["taskId"]; ok {
+		id, err := idArg(raw)
+		if err != nil {
+			return nil, err
+		}
+		taskID = id
+	}
+
+	sub := s.Events.Subscribe(userIDFrom(p))
+	out := make(chan interface{})
+
+	go func() {
+		defer close(out)
+		defer s.Events.Unsubscribe(sub)
+
+		for {
+			select {
+			case <-p.Context.Done():
+				return
+			case event, ok := <-sub.C:
+				if !ok {
+					// dropped for being too slow
+					return
+				}
+				if taskID != 0 && event.TaskID != taskID {
+					continue
+				}
+
+				select {
+				case out <- &event:
+				case <-p.Context.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// taskFromInput turns the TaskInput argument into a task for the mutator
+func taskFromInput(raw interface{}) (*models.Task, error) {
+	input, _ := raw.(map[string]interface{})
+	task := &models.Task{}
+
+	task.Title, _ = input["title"].(string)
+	task.Description, _ = input["description"].(string)
+	task.Status, _ = input["status"].(string)
+	task.Priority, _ = input["priority"].(string)
+	task.Project, _ = input["project"].(string)
+	task.Recurrence, _ = input["recurrence"].(string)
+
+	if tags, ok := input["tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			if value, ok := tag.(string); ok {
+				task.Tags = append(task.Tags, value)
+			}
+		}
+	}
+
+	if raw, ok := input["dueAt"]; ok && raw != nil {
+		dueAt, ok := raw.(time.Time)
+		if !ok {
+			return nil, errors.New("invalid dueAt")
+		}
+		task.DueAt = &dueAt
+	}
+
+	for name, target := range map[string]**int{"milestoneId": &task.MilestoneID, "parentId": &task.ParentID} {
+		if raw, ok := input[name]; ok && raw != nil {
+			id, err := idArg(raw)
+			if err != nil {
+				return nil, err
+			}
+			*target = &id
+		}
+	}
+
+	return task, nil
+}
+
+func idArg(raw interface{}) (int, error) {
+	value, _ := raw.(string)
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid ID " + strconv.Quote(value))
+	}
+	return id, nil
+}
+
+// page applies first and offset to a list, or to the thunk of one
+func page(list interface{}, args map[string]interface{}) interface{} {
+	first, hasFirst := args["first"].(int)
+	offset, _ := args["offset"].(int)
+
+	cut := func(items interface{}) interface{} {
+		switch items := items.(type) {
+		case []*models.Task:
+			return window(items, offset, first, hasFirst)
+		case []*models.Milestone:
+			return window(items, offset, first, hasFirst)
+		case []*models.Reminder:
+			return window(items, offset, first, hasFirst)
+		}
+		return items
+	}
+
+	if thunk, ok := list.(func() (interface{}, error)); ok {
+		return func() (interface{}, error) {
+			items, err := thunk()
+			return cut(items), err
+		}
+	}
+	return cut(list)
+}
+
+func window[T any](items []T, offset int, first int, hasFirst bool) []T {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	// no list is longer than the query limits assume
+	if !hasFirst || first < 0 || first > maxListSize {
+		first = maxListSize
+	}
+	if first < len(items) {
+		items = items[:first]
+	}
+	return items
+}
+
+// pointers makes every list item addressable the same way resolvers expect
+func pointers[T any](items []T) []*T {
+	result := make([]*T, len(items))
+	for i := range items {
+		result[i] = &items[i]
+	}
+	return result
+}
+
+// optionalID keeps a missing reference null, the ID scalar would print the pointer
+func optionalID(id *int) interface{} {
+	if id == nil {
+		return nil
+	}
+	return *id
+}
+
+func nonNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+func resolveTask(get func(t *models.Task) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) { return get(p.Source.(*models.Task)), nil }
+}
+
+func resolveMilestone(get func(m *models.Milestone) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) { return get(p.Source.(*models.Milestone)), nil }
+}
+
+func resolveReminder(get func(r *models.Reminder) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) { return get(p.Source.(*models.Reminder)), nil }
+}
+
+func resolveUser(get func(u *models.User) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) { return get(p.Source.(*models.User)), nil }
+}
+
+func resolveEvent(get func(e *models.TaskEvent) interface{}) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) { return get(p.Source.(*models.TaskEvent)), nil }
+}
+
+func resolveStatusCount(status string) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		return p.Source.(*models.MilestoneProgress).ByStatus[status], nil
+	}
+}
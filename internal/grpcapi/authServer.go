@@ -0,0 +1,115 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+
+	todov1 "github.com/DmitriyGiryntsev/TODO-API/api/todo/v1"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type AuthServer struct {
+	todov1.UnimplementedAuthServiceServer
+
+	Service *service.AuthService
+}
+
+func NewAuthServer(auth *service.AuthService) *AuthServer {
+	return &AuthServer{Service: auth}
+}
+
+func (s *AuthServer) Register(ctx context.Context, req *todov1.RegisterRequest) (*emptypb.Empty, error) {
+	user := models.User{
+		Username: req.GetUsername(),
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+		Role:     "user",
+		Timezone: req.GetTimezone(),
+	}
+
+	if err := s.Service.Register(&user); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) Login(ctx context.Context, req *todov1.LoginRequest) (*todov1.TokenPair, error) {
+	tokens, challenge, err := s.Service.Login(req.GetEmail(), req.GetPassword(), deviceFrom(ctx))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	if challenge != nil {
+		return &todov1.TokenPair{ChallengeToken: challenge.Token, EnrollmentRequired: challenge.EnrollmentRequired}, nil
+	}
+
+	return &todov1.TokenPair{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+func (s *AuthServer) VerifyTwoFactor(ctx context.Context, req *todov1.VerifyTwoFactorRequest) (*todov1.TokenPair, error) {
+	if req.GetChallengeToken() == "" || req.GetCode() == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid code")
+	}
+
+	tokens, recoveryCodes, err := s.Service.Verify2FA(req.GetChallengeToken(), req.GetCode())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &todov1.TokenPair{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken, RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *AuthServer) RefreshToken(ctx context.Context, req *todov1.RefreshTokenRequest) (*todov1.TokenPair, error) {
+	tokens, err := s.Service.Refresh(req.GetRefreshToken(), deviceFrom(ctx))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &todov1.TokenPair{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+func (s *AuthServer) Logout(ctx context.Context, req *todov1.LogoutRequest) (*emptypb.Empty, error) {
+	if req.GetRefreshToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "invalid refresh token")
+	}
+
+	if err := s.Service.Logout(req.GetRefreshToken()); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) LogoutAll(ctx context.Context, req *emptypb.Empty) (*emptypb.Empty, error) {
+	if err := s.Service.LogoutAll(userIDFrom(ctx)); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// deviceFrom describes the caller for the session list
+func deviceFrom(ctx context.Context) service.Device {
+	var device service.Device
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			device.UserAgent = values[0]
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		device.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(device.IP); err == nil {
+			device.IP = host
+		}
+	}
+
+	return device
+}
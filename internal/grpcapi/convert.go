@@ -0,0 +1,102 @@
+package grpcapi
+
+import (
+	"time"
+
+	todov1 "github.com/DmitriyGiryntsev/TODO-API/api/todo/v1"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toTask(task *models.Task) *todov1.Task {
+	return &todov1.Task{
+		Id:           int64(task.ID),
+		UserId:       int64(task.UserID),
+		Title:        task.Title,
+		Description:  task.Description,
+		Status:       task.Status,
+		Priority:     task.Priority,
+		Project:      task.Project,
+		Recurrence:   task.Recurrence,
+		Tags:         task.Tags,
+		DueAt:        toTimestamp(task.DueAt),
+		MilestoneId:  toID(task.MilestoneID),
+		ParentId:     toID(task.ParentID),
+		SnoozedUntil: toTimestamp(task.SnoozedUntil),
+		Pinned:       task.Pinned,
+		ClientId:     task.ClientID,
+		CreatedAt:    timestamppb.New(task.Created_at),
+		UpdatedAt:    timestamppb.New(task.Updated_at),
+	}
+}
+
+func toTasks(tasks []models.Task) []*todov1.Task {
+	result := make([]*todov1.Task, len(tasks))
+	for i := range tasks {
+		result[i] = toTask(&tasks[i])
+	}
+	return result
+}
+
+// fromTask takes the fields a client may set, IDs and timestamps are the server's
+func fromTask(task *todov1.Task) models.Task {
+	return models.Task{
+		Title:        task.GetTitle(),
+		Description:  task.GetDescription(),
+		Status:       task.GetStatus(),
+		Priority:     task.GetPriority(),
+		Project:      task.GetProject(),
+		Recurrence:   task.GetRecurrence(),
+		Tags:         task.GetTags(),
+		DueAt:        fromTimestamp(task.GetDueAt()),
+		MilestoneID:  fromID(task.MilestoneId),
+		ParentID:     fromID(task.ParentId),
+		SnoozedUntil: fromTimestamp(task.GetSnoozedUntil()),
+		Pinned:       task.GetPinned(),
+		ClientID:     task.ClientId,
+	}
+}
+
+func toTaskEvent(event *models.TaskEvent) *todov1.TaskEvent {
+	result := &todov1.TaskEvent{
+		Id:        event.ID,
+		Type:      event.Type,
+		TaskId:    int64(event.TaskID),
+		CreatedAt: timestamppb.New(event.Created_at),
+	}
+	if event.Task != nil {
+		result.Task = toTask(event.Task)
+	}
+	return result
+}
+
+func toTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func fromTimestamp(t *timestamppb.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+	value := t.AsTime()
+	return &value
+}
+
+func toID(id *int) *int64 {
+	if id == nil {
+		return nil
+	}
+	value := int64(*id)
+	return &value
+}
+
+func fromID(id *int64) *int {
+	if id == nil {
+		return nil
+	}
+	value := int(*id)
+	return &value
+}
@@ -0,0 +1,86 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	todov1 "github.com/DmitriyGiryntsev/TODO-API/api/todo/v1"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicMethods can be called without an access token
+var publicMethods = map[string]bool{
+	todov1.AuthService_Register_FullMethodName:        true,
+	todov1.AuthService_Login_FullMethodName:           true,
+	todov1.AuthService_VerifyTwoFactor_FullMethodName: true,
+	todov1.AuthService_RefreshToken_FullMethodName:    true,
+	todov1.AuthService_Logout_FullMethodName:          true,
+}
+
+type contextKey struct{}
+
+// UnaryAuth checks the access token of unary calls, like RequireAuth does for REST
+func UnaryAuth(auth *service.AuthService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authenticate(ctx, auth, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuth checks the access token of streaming calls
+func StreamAuth(auth *service.AuthService) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), auth, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authenticatedStream hands the context carrying the user ID to the handler
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate reads the "authorization: Bearer <token>" metadata and puts the user ID into the context
+func authenticate(ctx context.Context, auth *service.AuthService, fullMethod string) (context.Context, error) {
+	if publicMethods[fullMethod] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "need token")
+	}
+
+	tokenParts := strings.Split(values[0], " ")
+	if len(tokenParts) != 2 {
+		return nil, status.Error(codes.Unauthenticated, "wrong token format")
+	}
+
+	claims, err := auth.Authenticate(tokenParts[1])
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return context.WithValue(ctx, contextKey{}, claims.ID), nil
+}
+
+// userIDFrom returns the user authenticated by the interceptors
+func userIDFrom(ctx context.Context) int {
+	return ctx.Value(contextKey{}).(int)
+}
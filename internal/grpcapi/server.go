@@ -0,0 +1,49 @@
+// Package grpcapi serves tasks and authentication over gRPC for backend
+// services. It only translates messages and errors, the work is done by
+// the same service layer the REST handlers call.
+package grpcapi
+
+import (
+	todov1 "github.com/DmitriyGiryntsev/TODO-API/api/todo/v1"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/events"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// NewServer registers TaskService and AuthService behind the JWT interceptors
+func NewServer(tasks *service.TaskService, auth *service.AuthService, eventRepo *repository.EventRepository, hub *events.Hub) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(UnaryAuth(auth)),
+		grpc.ChainStreamInterceptor(StreamAuth(auth)),
+	)
+
+	todov1.RegisterTaskServiceServer(server, NewTaskServer(tasks, eventRepo, hub))
+	todov1.RegisterAuthServiceServer(server, NewAuthServer(auth))
+	// lets grpcurl and similar tools list the services without the .proto
+	reflection.Register(server)
+
+	return server
+}
+
+// statusError reports an error of the service layer with the matching gRPC code
+func statusError(err error) error {
+	code := codes.Internal
+	switch service.KindOf(err) {
+	case service.KindInvalid:
+		code = codes.InvalidArgument
+	case service.KindNotFound:
+		code = codes.NotFound
+	case service.KindConflict:
+		code = codes.AlreadyExists
+	case service.KindUnauthenticated:
+		code = codes.Unauthenticated
+	case service.KindForbidden:
+		code = codes.PermissionDenied
+	}
+
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,178 @@
+package grpcapi
+
+import (
+	"context"
+
+	todov1 "github.com/DmitriyGiryntsev/TODO-API/api/todo/v1"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/events"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// maxReplayEvents is how many logged events are read at a time when a watch resumes
+const maxReplayEvents = 1000
+
+type TaskServer struct {
+	todov1.UnimplementedTaskServiceServer
+
+	Service *service.TaskService
+	Events  *repository.EventRepository
+	Hub     *events.Hub
+}
+
+func NewTaskServer(tasks *service.TaskService, eventRepo *repository.EventRepository, hub *events.Hub) *TaskServer {
+	return &TaskServer{Service: tasks, Events: eventRepo, Hub: hub}
+}
+
+func (s *TaskServer) ListTasks(ctx context.Context, req *todov1.ListTasksRequest) (*todov1.ListTasksResponse, error) {
+	filter := repository.TaskFilter{
+		View:        req.GetView(),
+		MilestoneID: fromID(req.MilestoneId),
+		Limit:       int(req.GetLimit()),
+		Offset:      int(req.GetOffset()),
+	}
+
+	tasks, err := s.Service.List(userIDFrom(ctx), filter)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &todov1.ListTasksResponse{Tasks: toTasks(tasks)}, nil
+}
+
+func (s *TaskServer) GetTask(ctx context.Context, req *todov1.GetTaskRequest) (*todov1.Task, error) {
+	task, err := s.Service.Get(userIDFrom(ctx), int(req.GetId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return toTask(task), nil
+}
+
+func (s *TaskServer) CreateTask(ctx context.Context, req *todov1.CreateTaskRequest) (*todov1.Task, error) {
+	if req.GetTask() == nil {
+		return nil, status.Error(codes.InvalidArgument, "task is required")
+	}
+
+	task := fromTask(req.GetTask())
+	if err := s.Service.Create(userIDFrom(ctx), &task); err != nil {
+		return nil, statusError(err)
+	}
+
+	return toTask(&task), nil
+}
+
+// UpdateTask overwrites the task and returns it as stored
+func (s *TaskServer) UpdateTask(ctx context.Context, req *todov1.UpdateTaskRequest) (*todov1.Task, error) {
+	if req.GetTask() == nil {
+		return nil, status.Error(codes.InvalidArgument, "task is required")
+	}
+
+	userID := userIDFrom(ctx)
+	task := fromTask(req.GetTask())
+	if err := s.Service.Update(userID, int(req.GetId()), &task); err != nil {
+		return nil, statusError(err)
+	}
+
+	updated, err := s.Service.Get(userID, int(req.GetId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return toTask(updated), nil
+}
+
+func (s *TaskServer) DeleteTask(ctx context.Context, req *todov1.DeleteTaskRequest) (*emptypb.Empty, error) {
+	if err := s.Service.Delete(userIDFrom(ctx), int(req.GetId())); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// WatchTasks sends the user's task events as they happen, see the .proto for resuming
+func (s *TaskServer) WatchTasks(req *todov1.WatchTasksRequest, stream todov1.TaskService_WatchTasksServer) error {
+	ctx := stream.Context()
+	userID := userIDFrom(ctx)
+
+	watched := make(map[int]bool, len(req.GetTaskIds()))
+	for _, id := range req.GetTaskIds() {
+		watched[int(id)] = true
+	}
+
+	send := func(event *models.TaskEvent) error {
+		if len(watched) > 0 && !watched[event.TaskID] {
+			return nil
+		}
+		return stream.Send(toTaskEvent(event))
+	}
+
+	// subscribe before the replay so nothing slips through in between
+	sub := s.Hub.Subscribe(userID)
+	defer s.Hub.Unsubscribe(sub)
+
+	lastID := req.GetAfterEventId()
+	if lastID > 0 {
+		replayed, err := s.replay(userID, lastID, stream, send)
+		if err != nil {
+			return err
+		}
+		lastID = replayed
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-sub.C:
+			if !ok {
+				// dropped for being too slow or the server is shutting down
+				return status.Error(codes.Unavailable, "task events stream closed, resume with after_event_id")
+			}
+			if event.ID <= lastID {
+				continue
+			}
+			if err := send(&event); err != nil {
+				return err
+			}
+			lastID = event.ID
+		}
+	}
+}
+
+// replay sends the logged events after lastID and returns the ID of the last one sent
+func (s *TaskServer) replay(userID int, lastID int64, stream todov1.TaskService_WatchTasksServer, send func(*models.TaskEvent) error) (int64, error) {
+	oldest, latest, err := s.Events.GetEventBounds(userID)
+	if err != nil {
+		return lastID, status.Error(codes.Internal, "cannot get task events")
+	}
+
+	// the log no longer covers the gap, or the ID is not one of ours, the client has to start over
+	if oldest > lastID+1 || lastID > latest {
+		if err := stream.Send(&todov1.TaskEvent{Type: "reset"}); err != nil {
+			return lastID, err
+		}
+		lastID = oldest - 1
+	}
+
+	for {
+		missed, next, err := s.Events.GetEventsAfter(userID, lastID, maxReplayEvents)
+		if err != nil {
+			return lastID, status.Error(codes.Internal, "cannot get task events")
+		}
+		if next == lastID {
+			return lastID, nil
+		}
+
+		for i := range missed {
+			if err := send(&missed[i]); err != nil {
+				return lastID, err
+			}
+		}
+		lastID = next
+	}
+}
@@ -1,35 +1,64 @@
 package handlers
 
 import (
-	"database/sql"
-	"log"
 	"net/http"
 
 	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
-	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
-	"github.com/DmitriyGiryntsev/TODO-API/pkg/helpers"
-	"github.com/DmitriyGiryntsev/TODO-API/pkg/utils"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
 // AuthHandler defines the handler for authentication routes
 type AuthHandler struct {
-	UserRepo *repository.UserRepository
+	Service *service.AuthService
 }
 
 // NewAuthHandler initializes the AuthHandler
-func NewAuthHandler(userRepo *repository.UserRepository) *AuthHandler {
-	return &AuthHandler{UserRepo: userRepo}
+func NewAuthHandler(auth *service.AuthService) *AuthHandler {
+	return &AuthHandler{Service: auth}
 }
 
 type TokenResponse struct {
-	AccessToken  string `json:"access_token"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// a login needing a second factor answers with a challenge instead of tokens
+	TwoFactorRequired  bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken     string `json:"challenge_token,omitempty"`
+	EnrollmentRequired bool   `json:"enrollment_required,omitempty"`
+	// RecoveryCodes are sent once, when 2FA was enabled while signing in
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type TokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
 // Register godoc
 // @Summary Регистрация пользователя
-// @Description Создает нового пользователя
+// @Description Создает нового пользователя и отправляет письмо со ссылкой для подтверждения email
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -46,119 +75,370 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	userExists, err := h.UserRepo.GetUserByEmail(user.Email)
-	if err != nil && err != sql.ErrNoRows {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "server error"})
+	if err := h.Service.Register(&user); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	if userExists != nil {
-		c.JSON(http.StatusConflict, ErrorResponse{Error: "user already exists"})
+	c.JSON(http.StatusCreated, MessageResponse{Message: "user created successfully"})
+}
+
+// VerifyEmail godoc
+// @Summary Подтверждение email
+// @Description Подтверждает адрес по токену из письма. GET принимает токен в параметре token, чтобы ссылка из письма открывалась в браузере
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token query string false "Verification token"
+// @Param request body TokenRequest false "Verification token"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/verify-email [post]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	req := TokenRequest{Token: c.Query("token")}
+	if req.Token == "" && c.Request.Method == http.MethodPost {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid token"})
+			return
+		}
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid token"})
 		return
 	}
 
-	user.Password, err = utils.HashPassword(user.Password)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot hash password"})
+	if err := h.Service.VerifyEmail(req.Token); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	if err := h.UserRepo.CreateNewUser(&user); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot create user"})
+	c.JSON(http.StatusOK, MessageResponse{Message: "email verified"})
+}
+
+// ResendVerification godoc
+// @Summary Повторить письмо подтверждения
+// @Description Отправляет новое письмо со ссылкой подтверждения. Ответ не зависит от того, зарегистрирован ли адрес
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body EmailRequest true "Email"
+// @Success 202 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/resend-verification [post]
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid email"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, MessageResponse{Message: "user created successfully"})
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid email"})
+		return
+	}
+
+	if err := h.Service.ResendVerification(req.Email); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, MessageResponse{Message: "if the address has an unverified account, an email is on its way"})
 }
 
 // Login godoc
 // @Summary Вход пользователя
-// @Description Позволяет пользователю войти в систему и получить токены
+// @Description Позволяет пользователю войти в систему и получить токены. Если у пользователя включена двухфакторная аутентификация, вместо токенов возвращается challenge_token для /auth/2fa/verify
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param credentials body struct{ Email string `json:"email"`; Password string `json:"password"` } true "User credentials"
+// @Param credentials body LoginRequest true "User credentials"
 // @Success 200 {object} TokenResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
-	var creds struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
+	var creds LoginRequest
 
 	if err := c.ShouldBindJSON(&creds); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid credentials"})
 		return
 	}
 
-	user, err := h.UserRepo.GetUserByEmail(creds.Email)
-	if err == sql.ErrNoRows {
-		log.Println("user not found")
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "wrong email or password"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "server error"})
-		return
-	}
-
-	err = utils.CheckPassword(creds.Password, user.Password)
+	tokens, challenge, err := h.Service.Login(creds.Email, creds.Password, device(c))
 	if err != nil {
-		log.Println("wrong password")
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "wrong email or password"})
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	accessToken, refreshToken, err := helpers.GenerateAllTokens(user.ID, user.Email, user.Username, user.Role)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot generate tokens"})
+	if challenge != nil {
+		c.JSON(http.StatusOK, TokenResponse{
+			TwoFactorRequired:  true,
+			ChallengeToken:     challenge.Token,
+			EnrollmentRequired: challenge.EnrollmentRequired,
+		})
 		return
 	}
 
 	c.JSON(http.StatusOK, TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	})
 }
 
 // RefreshToken godoc
 // @Summary Обновление токена
-// @Description Обновляет refresh токен и выдает новый access токен
+// @Description Выдает новую пару токенов, старый refresh токен перестает действовать. Повторное использование старого токена отзывает весь сеанс
 // @Tags auth
 // @Accept json
 // @Produce json
-// @Param refresh_token body struct{ RefreshToken string `json:"refresh_token"` } true "Refresh token"
+// @Param refresh_token body RefreshRequest true "Refresh token"
 // @Success 200 {object} TokenResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api/v1/auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	var req struct {
-		RefreshToken string `json:"refresh_token"`
-	}
+	var req RefreshRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid refresh token"})
 		return
 	}
 
-	claims, err := helpers.ValidateToken(req.RefreshToken)
+	tokens, err := h.Service.Refresh(req.RefreshToken, device(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "cannot create new token"})
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	})
+}
+
+// ForgotPassword godoc
+// @Summary Забыли пароль
+// @Description Отправляет письмо с одноразовым токеном сброса пароля, действующим час. Ответ не зависит от того, зарегистрирован ли адрес
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body EmailRequest true "Email"
+// @Success 202 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid email"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid email"})
+		return
+	}
+
+	if err := h.Service.ForgotPassword(req.Email); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, MessageResponse{Message: "if the address has an account, an email is on its way"})
+}
+
+// ResetPassword godoc
+// @Summary Сброс пароля
+// @Description Задает новый пароль по токену из письма. Токен одноразовый, все сеансы пользователя завершаются
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Token and new password"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid reset data"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid reset data"})
 		return
 	}
 
-	accessToken, refreshToken, err := helpers.GenerateAllTokens(claims.ID, claims.Email, claims.Username, claims.Role)
+	if err := h.Service.ResetPassword(req.Token, req.Password); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "password reset"})
+}
+
+// ChangePassword godoc
+// @Summary Смена пароля
+// @Description Меняет пароль после проверки текущего. Остальные сеансы пользователя завершаются
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/password [put]
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid password data"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid password data"})
+		return
+	}
+
+	if err := h.Service.ChangePassword(userID.(int), c.GetString("sessionID"), req.CurrentPassword, req.NewPassword); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "password changed"})
+}
+
+// Logout godoc
+// @Summary Выход
+// @Description Завершает сеанс, которому принадлежит refresh токен, его access токены сразу перестают действовать
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param refresh_token body RefreshRequest true "Refresh token"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid refresh token"})
+		return
+	}
+
+	if err := h.Service.Logout(req.RefreshToken); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "logged out"})
+}
+
+// LogoutAll godoc
+// @Summary Выход на всех устройствах
+// @Description Завершает все сеансы пользователя, их access токены сразу перестают действовать
+// @Tags auth
+// @Produce json
+// @Success 200 {object} MessageResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/logout-all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	if err := h.Service.LogoutAll(userID.(int)); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "logged out everywhere"})
+}
+
+// GetSessions godoc
+// @Summary Активные сеансы
+// @Description Возвращает устройства, на которых выполнен вход: user agent, IP, время входа и последнего использования. Текущий сеанс отмечен полем current
+// @Tags users
+// @Produce json
+// @Success 200 {array} models.Session
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/sessions [get]
+func (h *AuthHandler) GetSessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	sessions, err := h.Service.ListSessions(userID.(int), c.GetString("sessionID"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot generate tokens"})
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, TokenResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	})
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary Завершить сеанс
+// @Description Выходит из сеанса на другом устройстве, его токены сразу перестают действовать
+// @Tags users
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} MessageResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	if err := h.Service.RevokeSession(userID.(int), c.Param("id")); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "session revoked"})
+}
+
+// device describes the client of the request for the session list
+func device(c *gin.Context) service.Device {
+	return service.Device{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+// JWKS godoc
+// @Summary Открытые ключи подписи
+// @Description JSON Web Key Set для проверки access токенов другими сервисами. Токен подписан ключом с kid из его заголовка, после ротации старые ключи остаются в наборе, пока их токены не истекут
+// @Tags auth
+// @Produce json
+// @Success 200 {object} helpers.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	// verifiers refetch on an unknown kid, a short max-age is enough
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.Service.Signer.Keys.JWKS())
 }
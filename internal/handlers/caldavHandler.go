@@ -0,0 +1,631 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/ical"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// The CalDAV tree is fixed: one principal with one task calendar.
+const (
+	caldavRoot       = "/caldav/"
+	caldavPrincipal  = "/caldav/principals/me/"
+	caldavHome       = "/caldav/calendars/me/"
+	caldavCollection = "/caldav/calendars/me/tasks/"
+
+	nsDAV       = "DAV:"
+	nsCalDAV    = "urn:ietf:params:xml:ns:caldav"
+	nsCalServer = "http://calendarserver.org/ns/"
+
+	maxCalendarObjectSize = 1 << 20
+)
+
+var davPrefixes = map[string]string{nsDAV: "d", nsCalDAV: "c", nsCalServer: "cs"}
+
+// davProps maps property names to their inner XML
+type davProps map[xml.Name]string
+
+type davResponse struct {
+	href  string
+	props davProps
+}
+
+// ServeCalDAV handles every CalDAV request under /caldav/. Clients sign in with
+// HTTP Basic, the username or email as the user and the calendar token as the password.
+func (h *CalendarHandler) ServeCalDAV(c *gin.Context) {
+	if c.Request.Method == http.MethodOptions {
+		c.Header("DAV", "1, 3, calendar-access")
+		c.Header("Allow", "OPTIONS, GET, PUT, DELETE, PROPFIND, REPORT")
+		c.Status(http.StatusOK)
+		return
+	}
+
+	user, ok := h.authenticate(c)
+	if !ok {
+		c.Header("WWW-Authenticate", `Basic realm="TODO API", charset="UTF-8"`)
+		c.String(http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	path := c.Request.URL.Path
+	if !strings.HasSuffix(path, "/") && !strings.HasSuffix(path, ".ics") {
+		path += "/"
+	}
+
+	switch {
+	case strings.HasPrefix(path, caldavCollection) && path != caldavCollection:
+		h.serveObject(c, user, strings.TrimPrefix(path, caldavCollection))
+	case c.Request.Method == "PROPFIND":
+		h.propfind(c, user, path)
+	case c.Request.Method == "REPORT" && path == caldavCollection:
+		h.report(c, user)
+	default:
+		c.Status(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CalendarHandler) authenticate(c *gin.Context) (*models.User, bool) {
+	username, token, ok := c.Request.BasicAuth()
+	if !ok || token == "" {
+		return nil, false
+	}
+
+	user, err := h.UserRepo.GetUserByCalendarToken(hashCalendarToken(token))
+	if err != nil {
+		return nil, false
+	}
+
+	if !strings.EqualFold(username, user.Username) && !strings.EqualFold(username, user.Email) {
+		return nil, false
+	}
+
+	return user, true
+}
+
+func (h *CalendarHandler) propfind(c *gin.Context, user *models.User, path string) {
+	requested, err := requestedProps(c.Request.Body)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid PROPFIND body")
+		return
+	}
+
+	depth := c.GetHeader("Depth")
+	var responses []davResponse
+
+	switch path {
+	case caldavRoot:
+		responses = append(responses, davResponse{href: caldavRoot, props: rootProps()})
+		if depth == "1" {
+			responses = append(responses, davResponse{href: caldavPrincipal, props: principalProps(user)})
+		}
+	case caldavPrincipal:
+		responses = append(responses, davResponse{href: caldavPrincipal, props: principalProps(user)})
+	case caldavHome:
+		responses = append(responses, davResponse{href: caldavHome, props: homeProps()})
+		if depth == "1" {
+			props, err := h.collectionProps(user)
+			if err != nil {
+				c.Status(http.StatusInternalServerError)
+				return
+			}
+			responses = append(responses, davResponse{href: caldavCollection, props: props})
+		}
+	case caldavCollection:
+		props, err := h.collectionProps(user)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		responses = append(responses, davResponse{href: caldavCollection, props: props})
+
+		if depth == "1" {
+			tasks, err := h.Tasks.Repo.GetVersionedTasks(user.ID)
+			if err != nil {
+				c.Status(http.StatusInternalServerError)
+				return
+			}
+			for _, vt := range tasks {
+				responses = append(responses, davResponse{href: taskHref(&vt.Task), props: objectProps(vt, "")})
+			}
+		}
+	default:
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	writeMultistatus(c, responses, requested)
+}
+
+// report answers calendar-query with every task and calendar-multiget with the asked ones
+func (h *CalendarHandler) report(c *gin.Context, user *models.User) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCalendarObjectSize))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	report, err := parseReport(body)
+	if err != nil {
+		c.String(http.StatusBadRequest, "invalid REPORT body")
+		return
+	}
+
+	if report.kind != "calendar-query" && report.kind != "calendar-multiget" {
+		c.Data(http.StatusForbidden, "application/xml; charset=utf-8", []byte(xml.Header+`<d:error xmlns:d="DAV:"><d:supported-report/></d:error>`))
+		return
+	}
+
+	tasks, err := h.Tasks.Repo.GetVersionedTasks(user.ID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	uids := make(map[int]string, len(tasks))
+	byHref := make(map[string]repository.VersionedTask, len(tasks))
+	for _, vt := range tasks {
+		uids[vt.Task.ID] = taskResourceName(&vt.Task)
+		byHref[taskHref(&vt.Task)] = vt
+	}
+
+	calendarData := func(vt repository.VersionedTask) string {
+		var parentUID string
+		if vt.Task.ParentID != nil {
+			parentUID = uids[*vt.Task.ParentID]
+		}
+		return renderTodo(&vt.Task, uids[vt.Task.ID], parentUID)
+	}
+
+	var responses []davResponse
+	var missing []string
+
+	switch report.kind {
+	case "calendar-query":
+		// only tasks live here, a query for events finds nothing
+		if !report.onlyTodos {
+			break
+		}
+		for _, vt := range tasks {
+			responses = append(responses, davResponse{href: taskHref(&vt.Task), props: objectProps(vt, calendarData(vt))})
+		}
+	case "calendar-multiget":
+		for _, href := range report.hrefs {
+			if u, err := url.Parse(href); err == nil {
+				href = u.Path
+			}
+			name, ok := resourceNameFromPath(strings.TrimPrefix(href, caldavCollection))
+			if !ok {
+				missing = append(missing, href)
+				continue
+			}
+			vt, found := byHref[caldavCollection+url.PathEscape(name)+".ics"]
+			if !found {
+				missing = append(missing, href)
+				continue
+			}
+			responses = append(responses, davResponse{href: taskHref(&vt.Task), props: objectProps(vt, calendarData(vt))})
+		}
+	}
+
+	writeMultistatusWithMissing(c, responses, report.props, missing)
+}
+
+// serveObject handles GET, PUT and DELETE of a single task resource
+func (h *CalendarHandler) serveObject(c *gin.Context, user *models.User, resource string) {
+	name, ok := resourceNameFromPath(resource)
+	if !ok {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	taskID, byID := taskIDFromName(name)
+	var idRef *int
+	if byID {
+		idRef = &taskID
+	}
+
+	current, err := h.Tasks.Repo.GetVersionedTask(user.ID, idRef, name)
+	if err != nil && err != sql.ErrNoRows {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		if current == nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		h.getObject(c, user, current)
+	case http.MethodPut:
+		h.putObject(c, user, name, byID, current)
+	case http.MethodDelete:
+		if current == nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		version, ok := ifMatchVersion(c)
+		if !ok {
+			c.Status(http.StatusPreconditionFailed)
+			return
+		}
+
+		err := h.Tasks.Repo.DeleteTaskAtVersion(current.Task.ID, user.ID, version)
+		if errors.Is(err, repository.ErrVersionMismatch) {
+			c.Status(http.StatusPreconditionFailed)
+			return
+		} else if err == sql.ErrNoRows {
+			c.Status(http.StatusNotFound)
+			return
+		} else if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	default:
+		c.Status(http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *CalendarHandler) getObject(c *gin.Context, user *models.User, current *repository.VersionedTask) {
+	etag := versionETag(current.Version)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	var parentUID string
+	if current.Task.ParentID != nil {
+		if parent, err := h.Tasks.Repo.GetVersionedTask(user.ID, current.Task.ParentID, ""); err == nil {
+			parentUID = taskResourceName(&parent.Task)
+		}
+	}
+
+	c.Header("ETag", etag)
+	c.Data(http.StatusOK, ical.TodoContentType, []byte(renderTodo(&current.Task, taskResourceName(&current.Task), parentUID)))
+}
+
+func (h *CalendarHandler) putObject(c *gin.Context, user *models.User, name string, byID bool, current *repository.VersionedTask) {
+	if c.GetHeader("If-None-Match") == "*" && current != nil {
+		c.Status(http.StatusPreconditionFailed)
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok || (version >= 0 && current == nil) {
+		c.Status(http.StatusPreconditionFailed)
+		return
+	}
+
+	loc, err := h.Tasks.UserRepo.GetUserLocation(user.ID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	todo, err := ical.ParseTodo(http.MaxBytesReader(c.Writer, c.Request.Body, maxCalendarObjectSize), loc)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var task models.Task
+	if current != nil {
+		task = current.Task
+	} else {
+		// task-<id> names belong to tasks made outside CalDAV
+		if byID || len(name) > 64 {
+			c.String(http.StatusConflict, "resource name is not allowed")
+			return
+		}
+		task = models.Task{UserID: user.ID, ClientID: &name}
+		if todo.RelatedTo != "" {
+			parentID, byParentID := taskIDFromName(todo.RelatedTo)
+			var parentRef *int
+			if byParentID {
+				parentRef = &parentID
+			}
+			if parent, err := h.Tasks.Repo.GetVersionedTask(user.ID, parentRef, todo.RelatedTo); err == nil {
+				task.ParentID = &parent.Task.ID
+			}
+		}
+	}
+	todo.ApplyTo(&task)
+
+	// calendar apps rarely write descriptions, so they are not held to the minimum length
+	if err := validate.StructExcept(task, "Description"); err != nil {
+		c.String(http.StatusBadRequest, strings.Join(taskFieldErrors(err), "; "))
+		return
+	}
+
+	if current != nil {
+		newVersion, err := h.Tasks.Repo.UpdateTaskAtVersion(&task, version)
+		if errors.Is(err, repository.ErrVersionMismatch) {
+			c.Status(http.StatusPreconditionFailed)
+			return
+		} else if err == sql.ErrNoRows {
+			c.Status(http.StatusNotFound)
+			return
+		} else if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+
+		c.Header("ETag", versionETag(newVersion))
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if err := h.Tasks.Repo.CreateNewTask(&task); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	if created, err := h.Tasks.Repo.GetVersionedTask(user.ID, &task.ID, ""); err == nil {
+		c.Header("ETag", versionETag(created.Version))
+	}
+	c.Status(http.StatusCreated)
+}
+
+// taskResourceName names the task in CalDAV: the client's own name for tasks
+// it created, task-<id> for the others. It is also the VTODO UID.
+func taskResourceName(task *models.Task) string {
+	if task.ClientID != nil && *task.ClientID != "" {
+		return *task.ClientID
+	}
+	return "task-" + strconv.Itoa(task.ID)
+}
+
+func taskHref(task *models.Task) string {
+	return caldavCollection + url.PathEscape(taskResourceName(task)) + ".ics"
+}
+
+func taskIDFromName(name string) (int, bool) {
+	digits, ok := strings.CutPrefix(name, "task-")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(digits)
+	return id, err == nil && id > 0
+}
+
+func resourceNameFromPath(resource string) (string, bool) {
+	resource, ok := strings.CutSuffix(resource, ".ics")
+	if !ok || resource == "" || strings.Contains(resource, "/") {
+		return "", false
+	}
+	name, err := url.PathUnescape(resource)
+	return name, err == nil && name != ""
+}
+
+func versionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersion reads the version out of If-Match, -1 when the header is absent
+func ifMatchVersion(c *gin.Context) (int64, bool) {
+	value := c.GetHeader("If-Match")
+	if value == "" || value == "*" {
+		return -1, true
+	}
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(value, "W/"), `"`), 10, 64)
+	return version, err == nil
+}
+
+func renderTodo(task *models.Task, uid string, parentUID string) string {
+	var b strings.Builder
+	writer, _ := ical.NewWriter(&b, "")
+	writer.WriteTodo(ical.FromTask(task, uid, parentUID))
+	writer.Close()
+	return b.String()
+}
+
+func davHref(href string) string {
+	return "<d:href>" + xmlEscape(href) + "</d:href>"
+}
+
+func rootProps() davProps {
+	return davProps{
+		{Space: nsDAV, Local: "resourcetype"}:           "<d:collection/>",
+		{Space: nsDAV, Local: "current-user-principal"}: davHref(caldavPrincipal),
+	}
+}
+
+func principalProps(user *models.User) davProps {
+	return davProps{
+		{Space: nsDAV, Local: "resourcetype"}:                 "<d:collection/><d:principal/>",
+		{Space: nsDAV, Local: "displayname"}:                  xmlEscape(user.Username),
+		{Space: nsDAV, Local: "current-user-principal"}:       davHref(caldavPrincipal),
+		{Space: nsDAV, Local: "principal-URL"}:                davHref(caldavPrincipal),
+		{Space: nsCalDAV, Local: "calendar-home-set"}:         davHref(caldavHome),
+		{Space: nsCalDAV, Local: "calendar-user-address-set"}: davHref("mailto:" + user.Email),
+	}
+}
+
+func homeProps() davProps {
+	return davProps{
+		{Space: nsDAV, Local: "resourcetype"}:           "<d:collection/>",
+		{Space: nsDAV, Local: "current-user-principal"}: davHref(caldavPrincipal),
+	}
+}
+
+func (h *CalendarHandler) collectionProps(user *models.User) (davProps, error) {
+	version, err := h.Tasks.Repo.GetSyncVersion(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return davProps{
+		{Space: nsDAV, Local: "resourcetype"}:                        "<d:collection/><c:calendar/>",
+		{Space: nsDAV, Local: "displayname"}:                         "Tasks",
+		{Space: nsDAV, Local: "current-user-principal"}:              davHref(caldavPrincipal),
+		{Space: nsDAV, Local: "owner"}:                               davHref(caldavPrincipal),
+		{Space: nsDAV, Local: "current-user-privilege-set"}:          "<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege><d:privilege><d:write-content/></d:privilege><d:privilege><d:bind/></d:privilege><d:privilege><d:unbind/></d:privilege>",
+		{Space: nsDAV, Local: "supported-report-set"}:                "<d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report><d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report>",
+		{Space: nsCalDAV, Local: "supported-calendar-component-set"}: `<c:comp name="VTODO"/>`,
+		{Space: nsCalDAV, Local: "supported-calendar-data"}:          `<c:calendar-data content-type="text/calendar" version="2.0"/>`,
+		{Space: nsCalServer, Local: "getctag"}:                       strconv.FormatInt(version, 10),
+	}, nil
+}
+
+// objectProps describes a task resource, calendarData is only sent when a report asks for it
+func objectProps(vt repository.VersionedTask, calendarData string) davProps {
+	props := davProps{
+		{Space: nsDAV, Local: "resourcetype"}:    "",
+		{Space: nsDAV, Local: "getetag"}:         xmlEscape(versionETag(vt.Version)),
+		{Space: nsDAV, Local: "getcontenttype"}:  ical.TodoContentType,
+		{Space: nsDAV, Local: "getlastmodified"}: vt.Task.Updated_at.UTC().Format(http.TimeFormat),
+	}
+	if calendarData != "" {
+		props[xml.Name{Space: nsCalDAV, Local: "calendar-data"}] = xmlEscape(calendarData)
+	}
+	return props
+}
+
+// requestedProps reads the property names of a PROPFIND, nil means all of them
+func requestedProps(body io.Reader) ([]xml.Name, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxCalendarObjectSize))
+	if err != nil || len(strings.TrimSpace(string(data))) == 0 {
+		return nil, err
+	}
+
+	report, err := parseReport(data)
+	if err != nil {
+		return nil, err
+	}
+	return report.props, nil
+}
+
+type davReport struct {
+	kind      string
+	props     []xml.Name
+	hrefs     []string
+	onlyTodos bool
+}
+
+// parseReport walks a PROPFIND or REPORT body for the parts this server looks at
+func parseReport(data []byte) (*davReport, error) {
+	report := &davReport{onlyTodos: true}
+	dec := xml.NewDecoder(strings.NewReader(string(data)))
+
+	var stack []xml.Name
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 {
+				report.kind = t.Name.Local
+			} else if parent := stack[len(stack)-1]; parent.Space == nsDAV && parent.Local == "prop" && len(stack) == 2 {
+				report.props = append(report.props, t.Name)
+			}
+			if t.Name.Space == nsCalDAV && t.Name.Local == "comp-filter" {
+				for _, attr := range t.Attr {
+					if attr.Name.Local == "name" && attr.Value != "VCALENDAR" && attr.Value != "VTODO" {
+						report.onlyTodos = false
+					}
+				}
+			}
+			stack = append(stack, t.Name)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 && stack[len(stack)-1].Space == nsDAV && stack[len(stack)-1].Local == "href" {
+				report.hrefs = append(report.hrefs, strings.TrimSpace(string(t)))
+			}
+		}
+	}
+
+	if report.kind == "" {
+		return nil, errors.New("empty document")
+	}
+	return report, nil
+}
+
+func writeMultistatus(c *gin.Context, responses []davResponse, requested []xml.Name) {
+	writeMultistatusWithMissing(c, responses, requested, nil)
+}
+
+// writeMultistatusWithMissing answers with found properties under 200 and
+// requested but unknown ones under 404, missing hrefs get a 404 of their own
+func writeMultistatusWithMissing(c *gin.Context, responses []davResponse, requested []xml.Name, missing []string) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:cs="http://calendarserver.org/ns/">`)
+
+	for _, r := range responses {
+		b.WriteString("<d:response>" + davHref(r.href))
+
+		var found, unknown strings.Builder
+		if requested == nil {
+			for name, value := range r.props {
+				// calendar data is only sent when asked for
+				if name.Local != "calendar-data" {
+					found.WriteString(davElement(name, value, 0))
+				}
+			}
+		} else {
+			for i, name := range requested {
+				if value, ok := r.props[name]; ok {
+					found.WriteString(davElement(name, value, i))
+				} else {
+					unknown.WriteString(davElement(name, "", i))
+				}
+			}
+		}
+
+		if found.Len() > 0 {
+			b.WriteString("<d:propstat><d:prop>" + found.String() + "</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat>")
+		}
+		if unknown.Len() > 0 {
+			b.WriteString("<d:propstat><d:prop>" + unknown.String() + "</d:prop><d:status>HTTP/1.1 404 Not Found</d:status></d:propstat>")
+		}
+		b.WriteString("</d:response>")
+	}
+
+	for _, href := range missing {
+		b.WriteString("<d:response>" + davHref(href) + "<d:status>HTTP/1.1 404 Not Found</d:status></d:response>")
+	}
+
+	b.WriteString("</d:multistatus>")
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", []byte(b.String()))
+}
+
+func davElement(name xml.Name, inner string, i int) string {
+	prefix, known := davPrefixes[name.Space]
+	declaration := ""
+	if !known {
+		prefix = fmt.Sprintf("x%d", i)
+		declaration = ` xmlns:` + prefix + `="` + xmlEscape(name.Space) + `"`
+	}
+
+	tag := prefix + ":" + name.Local
+	if inner == "" {
+		return "<" + tag + declaration + "/>"
+	}
+	return "<" + tag + declaration + ">" + inner + "</" + tag + ">"
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/ical"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// CalendarHandler serves the iCalendar feed and the CalDAV server. Both are
+// opened with the user's calendar token, a secret separate from the password
+// that calendar apps can keep and that can be revoked on its own.
+type CalendarHandler struct {
+	Tasks    *TaskHandler
+	UserRepo *repository.UserRepository
+	// PublicURL is where users reach the API, the links given to calendar apps start with it
+	PublicURL string
+}
+
+func NewCalendarHandler(tasks *TaskHandler, userRepo *repository.UserRepository, publicURL string) *CalendarHandler {
+	return &CalendarHandler{Tasks: tasks, UserRepo: userRepo, PublicURL: publicURL}
+}
+
+// CalendarAccess tells the user how to connect a calendar app
+type CalendarAccess struct {
+	Token     string `json:"token"`
+	FeedURL   string `json:"feed_url"`
+	CalDAVURL string `json:"caldav_url"`
+	Username  string `json:"username"`
+}
+
+// CreateCalendarToken godoc
+// @Summary Выпустить токен календаря
+// @Description Создает новый секрет для iCal-ленты и CalDAV, прежний перестает работать. В CalDAV-клиенте используется имя пользователя и этот токен как пароль
+// @Tags users
+// @Accept json
+// @Produce json
+// @Success 201 {object} CalendarAccess
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/users/me/calendar-token [post]
+func (h *CalendarHandler) CreateCalendarToken(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get user"})
+		return
+	}
+
+	token, err := generateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot generate token"})
+		return
+	}
+
+	tokenHash := hashCalendarToken(token)
+	if err := h.UserRepo.SetCalendarToken(user.ID, &tokenHash); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot save token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CalendarAccess{
+		Token:     token,
+		FeedURL:   h.PublicURL + "/api/v1/calendar/" + token + "/tasks.ics",
+		CalDAVURL: h.PublicURL + caldavRoot,
+		Username:  user.Username,
+	})
+}
+
+// RevokeCalendarToken godoc
+// @Summary Отозвать токен календаря
+// @Description Отключает iCal-ленту и доступ по CalDAV
+// @Tags users
+// @Accept json
+// @Produce json
+// @Success 200 {object} MessageResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/users/me/calendar-token [delete]
+func (h *CalendarHandler) RevokeCalendarToken(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	if err := h.UserRepo.SetCalendarToken(userID.(int), nil); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot revoke token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "calendar token revoked successfully"})
+}
+
+// Feed godoc
+// @Summary iCal-лента задач
+// @Description Отдает все задачи пользователя как VTODO. Доступ по секретному токену в URL, без заголовка Authorization
+// @Tags calendar
+// @Produce text/calendar
+// @Param token path string true "Calendar token"
+// @Success 200 {string} string
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/calendar/{token}/tasks.ics [get]
+func (h *CalendarHandler) Feed(c *gin.Context) {
+	user, err := h.UserRepo.GetUserByCalendarToken(hashCalendarToken(c.Param("token")))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "calendar not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get calendar"})
+		return
+	}
+
+	c.Header("Content-Type", ical.ContentType)
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Status(http.StatusOK)
+
+	writer, err := ical.NewWriter(c.Writer, "Tasks")
+	if err != nil {
+		c.Abort()
+		return
+	}
+
+	// parents come before their subtasks in ID order, so their UIDs are known by then
+	uids := make(map[int]string)
+	err = h.Tasks.Repo.StreamTasks(c.Request.Context(), user.ID, func(task *models.Task) error {
+		uid := taskResourceName(task)
+		uids[task.ID] = uid
+
+		var parentUID string
+		if task.ParentID != nil {
+			parentUID = uids[*task.ParentID]
+		}
+
+		return writer.WriteTodo(ical.FromTask(task, uid, parentUID))
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		c.Abort()
+	}
+}
+
+// hashCalendarToken is what is stored of a calendar token, like the other
+// tokens only its SHA-256 is kept
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
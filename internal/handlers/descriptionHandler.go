@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/markdown"
+	"github.com/gin-gonic/gin"
+)
+
+// Values of ?render= on task responses
+const (
+	RenderBoth     = ""
+	RenderHTML     = "html"
+	RenderMarkdown = "markdown"
+)
+
+// toggleAttempts is how often a checkbox toggle is retried when the task changes underneath it
+const toggleAttempts = 3
+
+// ToggleCheckboxRequest sets the state of a task list checkbox in the description
+type ToggleCheckboxRequest struct {
+	Checked *bool `json:"checked" validate:"required"`
+}
+
+// renderMode reads ?render=, answering 400 itself when it is not known
+func renderMode(c *gin.Context) (string, bool) {
+	switch mode := c.Query("render"); mode {
+	case RenderBoth, RenderHTML, RenderMarkdown:
+		return mode, true
+	default:
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid render mode"})
+		return "", false
+	}
+}
+
+// renderDescriptions fills in the HTML of the descriptions, in html mode
+// the Markdown source is left out of the response
+func renderDescriptions(mode string, tasks ...*models.Task) {
+	if mode == RenderMarkdown {
+		return
+	}
+
+	for _, task := range tasks {
+		task.DescriptionHTML = markdown.Render(task.Description)
+		if mode == RenderHTML {
+			task.Description = ""
+		}
+	}
+}
+
+// ToggleCheckbox godoc
+// @Summary Отметить пункт чек-листа в описании
+// @Description Ставит или снимает отметку у пункта "- [ ]" в Markdown-описании задачи, пункты нумеруются с нуля так же, как data-task-index в description_html
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param index path int true "Checkbox index"
+// @Param render query string false "html or markdown, both by default"
+// @Param request body ToggleCheckboxRequest true "Checkbox state"
+// @Success 200 {object} models.Task
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/tasks/{id}/checkboxes/{index} [put]
+func (h *TaskHandler) ToggleCheckbox(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task ID"})
+		return
+	}
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid checkbox index"})
+		return
+	}
+
+	mode, ok := renderMode(c)
+	if !ok {
+		return
+	}
+
+	var req ToggleCheckboxRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid checkbox data"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// the description is rewritten from what was read, so a concurrent edit is retried on top of it
+	for attempt := 0; attempt < toggleAttempts; attempt++ {
+		current, err := h.Repo.GetVersionedTask(userID.(int), &taskID, "")
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "task not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get task"})
+			return
+		}
+
+		task := current.Task
+		source, err := markdown.ToggleCheckbox(task.Description, index, *req.Checked)
+		if errors.Is(err, markdown.ErrNoCheckbox) {
+			c.JSON(http.StatusNotFound, ErrorResponse{Error: "checkbox not found"})
+			return
+		}
+
+		if source != task.Description {
+			task.Description = source
+			_, err := h.Repo.UpdateTaskAtVersion(&task, current.Version)
+			if errors.Is(err, repository.ErrVersionMismatch) {
+				continue
+			} else if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, ErrorResponse{Error: "task not found"})
+				return
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot update task"})
+				return
+			}
+		}
+
+		renderDescriptions(mode, &task)
+		c.JSON(http.StatusOK, task)
+		return
+	}
+
+	c.JSON(http.StatusConflict, ErrorResponse{Error: "task keeps changing, try again"})
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/events"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	heartbeatInterval = 15 * time.Second
+	maxReplayEvents   = 1000
+)
+
+type EventHandler struct {
+	Repo *repository.EventRepository
+	Hub  *events.Hub
+}
+
+func NewEventHandler(repo *repository.EventRepository, hub *events.Hub) *EventHandler {
+	return &EventHandler{Repo: repo, Hub: hub}
+}
+
+// StreamEvents godoc
+// @Summary Поток изменений задач
+// @Description Server-Sent Events с изменениями задач пользователя: task.created, task.updated, task.deleted. События восстановления нет, удаленные задачи не восстанавливаются. ID событий идут подряд для каждого пользователя. Поддерживает продолжение с Last-Event-ID; если событие уже удалено из журнала, приходит событие reset и клиент должен перечитать задачи
+// @Tags events
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "Last received event ID"
+// @Success 200 {object} models.TaskEvent
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/events [get]
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var lastID int64
+	if raw := c.GetHeader("Last-Event-ID"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid Last-Event-ID"})
+			return
+		}
+		lastID = id
+	}
+
+	// subscribe before the replay so nothing slips through in between
+	sub := h.Hub.Subscribe(userID.(int))
+	defer h.Hub.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if lastID > 0 {
+		replayed, ok := h.replay(c, userID.(int), lastID)
+		if !ok {
+			return
+		}
+		lastID = replayed
+	} else {
+		fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// dropped for being too slow, the client reconnects with Last-Event-ID
+				return
+			}
+			if event.ID <= lastID {
+				continue
+			}
+			if err := writeEvent(c, event); err != nil {
+				return
+			}
+			lastID = event.ID
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// replay sends the logged events after lastID and returns the ID of the last one sent
+func (h *EventHandler) replay(c *gin.Context, userID int, lastID int64) (int64, bool) {
+	oldest, latest, err := h.Repo.GetEventBounds(userID)
+	if err != nil {
+		return lastID, false
+	}
+
+	// the log no longer covers the gap, or the ID is not one of ours, the client has to start over
+	if oldest > lastID+1 || lastID > latest {
+		fmt.Fprint(c.Writer, "event: reset\ndata: {}\n\n")
+		lastID = oldest - 1
+	}
+
+	for {
+		missed, next, err := h.Repo.GetEventsAfter(userID, lastID, maxReplayEvents)
+		if err != nil {
+			return lastID, false
+		}
+		if next == lastID {
+			return lastID, true
+		}
+
+		for _, event := range missed {
+			if err := writeEvent(c, event); err != nil {
+				return lastID, false
+			}
+		}
+		lastID = next
+	}
+}
+
+func writeEvent(c *gin.Context, event models.TaskEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+		return err
+	}
+
+	c.Writer.Flush()
+	return nil
+}
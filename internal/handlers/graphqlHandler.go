@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/graph"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+)
+
+type GraphQLHandler struct {
+	Service *graph.Service
+}
+
+func NewGraphQLHandler(service *graph.Service) *GraphQLHandler {
+	return &GraphQLHandler{Service: service}
+}
+
+// Query godoc
+// @Summary GraphQL
+// @Description Запросы, мутации и подписки над задачами, вехами и пользователем. Поддерживает persisted queries (extensions.persistedQuery с sha256Hash); GET допускает только запросы, мутациям персонального токена нужен scope tasks:write. Подписки отдаются как Server-Sent Events: событие next на каждый результат, complete в конце
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Param request body graph.Request true "GraphQL request"
+// @Success 200 {object} graphql.Result
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /api/v1/graphql [post]
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req graph.Request
+	if c.Request.Method == http.MethodGet {
+		if err := bindGraphQLQuery(c, &req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid GraphQL request"})
+		return
+	}
+
+	operation, failed := h.Service.Prepare(&req)
+	if failed != nil {
+		c.JSON(http.StatusOK, failed)
+		return
+	}
+
+	switch {
+	case operation == graph.OperationSubscription:
+		h.stream(c, userID.(int), req)
+	case operation == graph.OperationMutation && c.Request.Method == http.MethodGet:
+		c.JSON(http.StatusMethodNotAllowed, ErrorResponse{Error: "mutations must be sent with POST"})
+	case operation == graph.OperationMutation && !hasScope(c, service.ScopeTasksWrite):
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "token lacks scope " + service.ScopeTasksWrite})
+	default:
+		c.JSON(http.StatusOK, h.Service.Execute(c.Request.Context(), userID.(int), req))
+	}
+}
+
+// hasScope tells whether the credential of the request may use scope, sessions may do anything
+func hasScope(c *gin.Context, scope string) bool {
+	principal, ok := c.Get("principal")
+	if !ok {
+		return false
+	}
+	return principal.(*service.Principal).MissingScope([]string{scope}) == ""
+}
+
+// bindGraphQLQuery reads a request sent as GET parameters, the way persisted queries are fetched
+func bindGraphQLQuery(c *gin.Context, req *graph.Request) error {
+	req.Query = c.Query("query")
+	req.OperationName = c.Query("operationName")
+
+	if raw := c.Query("variables"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+			return errors.New("invalid variables")
+		}
+	}
+	if raw := c.Query("extensions"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &req.Extensions); err != nil {
+			return errors.New("invalid extensions")
+		}
+	}
+
+	return nil
+}
+
+// stream sends the results of a subscription as Server-Sent Events until the client goes away
+func (h *GraphQLHandler) stream(c *gin.Context, userID int, req graph.Request) {
+	results := h.Service.Subscribe(c.Request.Context(), userID, req)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case result, ok := <-results:
+			if !ok {
+				fmt.Fprint(c.Writer, "event: complete\ndata: {}\n\n")
+				c.Writer.Flush()
+				return
+			}
+			if err := writeResult(c, result); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeResult(c *gin.Context, result *graphql.Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(c.Writer, "event: next\ndata: %s\n\n", data); err != nil {
+		return err
+	}
+
+	c.Writer.Flush()
+	return nil
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/importers"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type ImportFromResponse struct {
+	Source  string              `json:"source"`
+	DryRun  bool                `json:"dry_run"`
+	Tasks   int                 `json:"tasks"`
+	Skipped []importers.Skipped `json:"skipped"`
+}
+
+// ImportFrom godoc
+// @Summary Импорт из другого менеджера задач
+// @Description Импортирует резервную копию Todoist (JSON), экспорт доски Trello (JSON) или файл todo.txt. Проекты, метки, сроки, приоритеты, чек-листы и выполнение переносятся в задачи, все создается в одной транзакции. В skipped перечислено, что не удалось перенести и почему
+// @Tags tasks
+// @Accept json
+// @Accept plain
+// @Produce json
+// @Param source path string true "todoist, trello or todotxt"
+// @Param dry_run query bool false "Only parse the file and report"
+// @Success 200 {object} ImportFromResponse
+// @Success 201 {object} ImportFromResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/tasks/import/{source} [post]
+func (h *TaskHandler) ImportFrom(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	source := c.Param("source")
+	importer, ok := importers.Get(source)
+	if !ok {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "source must be one of " + strings.Join(importers.Names(), ", ")})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	loc, err := h.UserRepo.GetUserLocation(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get user timezone"})
+		return
+	}
+
+	result, err := importer.Parse(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize), time.Now().In(loc))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "cannot read file: " + err.Error()})
+		return
+	}
+
+	tasks := checkImportedTrees(userID.(int), result, result.Tasks)
+	response := ImportFromResponse{Source: source, DryRun: dryRun, Tasks: countTrees(tasks), Skipped: result.Skipped}
+	if response.Skipped == nil {
+		response.Skipped = []importers.Skipped{}
+	}
+
+	if response.Tasks > maxImportRows {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "a file may have at most " + strconv.Itoa(maxImportRows) + " tasks"})
+		return
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if err := h.Repo.CreateTaskTrees(tasks); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot import tasks"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// checkImportedTrees drops the tasks that break the task rules, together with their
+// subtasks, and reports them as skipped. Descriptions come as they are in the source,
+// so they are not held to the minimum length, same as with quick add.
+func checkImportedTrees(userID int, result *importers.Result, trees []repository.TaskTree) []repository.TaskTree {
+	kept := make([]repository.TaskTree, 0, len(trees))
+
+	for _, tree := range trees {
+		tree.Task.UserID = userID
+		if tree.Task.Status == "" {
+			tree.Task.Status = "pending"
+		}
+
+		if err := validate.StructExcept(tree.Task, "Description"); err != nil {
+			reason := strings.Join(taskFieldErrors(err), "; ")
+			if n := countTrees(tree.Children); n > 0 {
+				reason += ", skipped with " + strconv.Itoa(n) + " subtasks"
+			}
+			result.Skip(tree.Task.Title, "%s", reason)
+			continue
+		}
+
+		tree.Children = checkImportedTrees(userID, result, tree.Children)
+		kept = append(kept, tree)
+	}
+
+	return kept
+}
+
+func countTrees(trees []repository.TaskTree) int {
+	n := len(trees)
+	for _, tree := range trees {
+		n += countTrees(tree.Children)
+	}
+	return n
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type MilestoneHandler struct {
+	Repo *repository.MilestoneRepository
+}
+
+func NewMilestoneHandler(repo *repository.MilestoneRepository) *MilestoneHandler {
+	return &MilestoneHandler{Repo: repo}
+}
+
+// MilestoneResponse is a milestone together with its task roll-up
+type MilestoneResponse struct {
+	models.Milestone
+	Progress *models.MilestoneProgress `json:"progress"`
+}
+
+// CloseMilestoneRequest controls what happens to the open tasks of a milestone being closed
+type CloseMilestoneRequest struct {
+	Confirm bool `json:"confirm"`
+	MoveTo  *int `json:"move_to_milestone_id"`
+}
+
+// GetMilestones godoc
+// @Summary Получить список вех
+// @Description Получает все вехи текущего пользователя
+// @Tags milestones
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Milestone
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/milestones/ [get]
+func (h *MilestoneHandler) GetMilestones(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	milestones, err := h.Repo.GetAllMilestonesByUserID(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get milestones"})
+		return
+	}
+
+	c.JSON(http.StatusOK, milestones)
+}
+
+// GetMilestone godoc
+// @Summary Получить веху по ID
+// @Description Получает веху вместе с процентом выполнения, количеством задач по статусам и просроченными задачами
+// @Tags milestones
+// @Accept json
+// @Produce json
+// @Param id path int true "Milestone ID"
+// @Success 200 {object} MilestoneResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/milestones/{id} [get]
+func (h *MilestoneHandler) GetMilestone(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	milestoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid milestone ID"})
+		return
+	}
+
+	milestone, err := h.Repo.GetMilestoneByID(milestoneID, userID.(int))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "milestone not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get milestone"})
+		return
+	}
+
+	progress, err := h.Repo.GetMilestoneProgress(milestoneID, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get milestone progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MilestoneResponse{Milestone: *milestone, Progress: progress})
+}
+
+// CreateMilestone godoc
+// @Summary Создать веху
+// @Description Создает новую веху для текущего пользователя
+// @Tags milestones
+// @Accept json
+// @Produce json
+// @Param milestone body models.Milestone true "Milestone data"
+// @Success 201 {object} models.Milestone
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/milestones/ [post]
+func (h *MilestoneHandler) CreateMilestone(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var milestone models.Milestone
+	if err := c.ShouldBindJSON(&milestone); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid milestone data"})
+		return
+	}
+
+	if err := validate.Struct(milestone); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	milestone.UserID = userID.(int)
+	milestone.State = "open"
+
+	if err := h.Repo.CreateMilestone(&milestone); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot create milestone"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, milestone)
+}
+
+// UpdateMilestone godoc
+// @Summary Обновить веху
+// @Description Обновляет название, описание и срок вехи. Состояние меняется через close/reopen
+// @Tags milestones
+// @Accept json
+// @Produce json
+// @Param id path int true "Milestone ID"
+// @Param milestone body models.Milestone true "Milestone data"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/milestones/{id} [put]
+func (h *MilestoneHandler) UpdateMilestone(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	milestoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid milestone ID"})
+		return
+	}
+
+	var milestone models.Milestone
+	if err := c.ShouldBindJSON(&milestone); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid milestone data"})
+		return
+	}
+
+	if err := validate.Struct(milestone); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	milestone.ID = milestoneID
+	milestone.UserID = userID.(int)
+
+	if err := h.Repo.UpdateMilestone(&milestone); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "cannot update milestone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "milestone updated successfully"})
+}
+
+// CloseMilestone godoc
+// @Summary Закрыть веху
+// @Description Закрывает веху. Если в ней остались незавершенные задачи, нужно либо подтвердить закрытие, либо перенести их в другую веху
+// @Tags milestones
+// @Accept json
+// @Produce json
+// @Param id path int true "Milestone ID"
+// @Param request body CloseMilestoneRequest false "Close options"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /api/v1/milestones/{id}/close [post]
+func (h *MilestoneHandler) CloseMilestone(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	milestoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid milestone ID"})
+		return
+	}
+
+	var req CloseMilestoneRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid close request"})
+			return
+		}
+	}
+
+	if _, err := h.Repo.GetMilestoneByID(milestoneID, userID.(int)); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "milestone not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get milestone"})
+		return
+	}
+
+	if req.MoveTo != nil {
+		if *req.MoveTo == milestoneID {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "cannot move tasks to the milestone being closed"})
+			return
+		}
+
+		target, err := h.Repo.GetMilestoneByID(*req.MoveTo, userID.(int))
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "target milestone not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get milestone"})
+			return
+		}
+
+		if target.State != "open" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "target milestone is closed"})
+			return
+		}
+	} else if !req.Confirm {
+		openTasks, err := h.Repo.CountOpenTasks(milestoneID, userID.(int))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot count open tasks"})
+			return
+		}
+
+		if openTasks > 0 {
+			c.JSON(http.StatusConflict, ErrorResponse{Error: "milestone has " + strconv.Itoa(openTasks) + " open tasks, confirm closing or move them to another milestone"})
+			return
+		}
+	}
+
+	if err := h.Repo.CloseMilestone(milestoneID, userID.(int), req.MoveTo); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot close milestone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "milestone closed successfully"})
+}
+
+// ReopenMilestone godoc
+// @Summary Открыть веху заново
+// @Description Переводит закрытую веху в состояние open
+// @Tags milestones
+// @Accept json
+// @Produce json
+// @Param id path int true "Milestone ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/milestones/{id}/reopen [post]
+func (h *MilestoneHandler) ReopenMilestone(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	milestoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid milestone ID"})
+		return
+	}
+
+	if err := h.Repo.ReopenMilestone(milestoneID, userID.(int)); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "cannot reopen milestone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "milestone reopened successfully"})
+}
+
+// DeleteMilestone godoc
+// @Summary Удалить веху
+// @Description Удаляет веху, задачи остаются без вехи
+// @Tags milestones
+// @Accept json
+// @Produce json
+// @Param id path int true "Milestone ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/milestones/{id} [delete]
+func (h *MilestoneHandler) DeleteMilestone(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	milestoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid milestone ID"})
+		return
+	}
+
+	if err := h.Repo.DeleteMilestone(milestoneID, userID.(int)); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "cannot delete milestone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "milestone deleted successfully"})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	Repo *repository.NotificationRepository
+}
+
+func NewNotificationHandler(repo *repository.NotificationRepository) *NotificationHandler {
+	return &NotificationHandler{Repo: repo}
+}
+
+// GetNotifications godoc
+// @Summary Входящие уведомления
+// @Description Получает последние уведомления пользователя, с unread=true только непрочитанные
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param unread query bool false "Only unread"
+// @Success 200 {array} models.Notification
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/notifications/ [get]
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	unreadOnly, _ := strconv.ParseBool(c.Query("unread"))
+
+	notifications, err := h.Repo.GetNotificationsByUserID(userID.(int), unreadOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, notifications)
+}
+
+// MarkNotificationRead godoc
+// @Summary Отметить уведомление прочитанным
+// @Description Помечает уведомление как прочитанное
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param id path int true "Notification ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/notifications/{id}/read [post]
+func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	notificationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid notification ID"})
+		return
+	}
+
+	if err := h.Repo.MarkNotificationRead(notificationID, userID.(int)); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "cannot update notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "notification marked as read"})
+}
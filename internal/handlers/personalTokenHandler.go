@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+type CreatePersonalTokenRequest struct {
+	Name      string    `json:"name" validate:"required,max=100"`
+	Scopes    []string  `json:"scopes" validate:"required,min=1"`
+	ExpiresAt time.Time `json:"expires_at" validate:"required"`
+}
+
+// PersonalTokenResponse carries the token itself, it is never shown again
+type PersonalTokenResponse struct {
+	models.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// GetPersonalTokens godoc
+// @Summary Персональные токены
+// @Description Возвращает действующие персональные токены пользователя, без самих токенов
+// @Tags users
+// @Produce json
+// @Success 200 {array} models.PersonalAccessToken
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/tokens [get]
+func (h *AuthHandler) GetPersonalTokens(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	tokens, err := h.Service.ListPersonalTokens(userID.(int))
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// CreatePersonalToken godoc
+// @Summary Создать персональный токен
+// @Description Создает токен для скриптов и интеграций, он передается в Authorization: Bearer вместо access токена. Токен показывается только в этом ответе. Области: tasks:read, tasks:write, webhooks, profile:read, admin (только для администраторов). Срок действия обязателен, не больше года
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body CreatePersonalTokenRequest true "Name, scopes and expiry"
+// @Success 201 {object} PersonalTokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/tokens [post]
+func (h *AuthHandler) CreatePersonalToken(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req CreatePersonalTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid token data"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid token data"})
+		return
+	}
+
+	token, plaintext, err := h.Service.CreatePersonalToken(userID.(int), req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, PersonalTokenResponse{PersonalAccessToken: *token, Token: plaintext})
+}
+
+// RevokePersonalToken godoc
+// @Summary Отозвать персональный токен
+// @Description Токен перестает работать сразу
+// @Tags users
+// @Produce json
+// @Param id path int true "Token ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/tokens/{id} [delete]
+func (h *AuthHandler) RevokePersonalToken(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	tokenID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid token ID"})
+		return
+	}
+
+	if err := h.Service.RevokePersonalToken(userID.(int), tokenID); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "token revoked"})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/quickadd"
+	"github.com/gin-gonic/gin"
+)
+
+// QuickAddRequest is a single line describing the task
+type QuickAddRequest struct {
+	Text string `json:"text" validate:"required,max=500"`
+}
+
+// QuickAddResponse returns the created task along with what the parser understood
+type QuickAddResponse struct {
+	Task   models.Task     `json:"task"`
+	Parsed quickadd.Result `json:"parsed"`
+}
+
+// QuickAddTask godoc
+// @Summary Быстро добавить задачу
+// @Description Создает задачу из одной строки, например "Pay rent tomorrow 9am #home !high every month +flat". Понимает английский и русский, даты считаются в часовом поясе пользователя
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param request body QuickAddRequest true "Task line"
+// @Success 201 {object} QuickAddResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/tasks/quick [post]
+func (h *TaskHandler) QuickAddTask(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req QuickAddRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid quick add data"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	loc, err := h.UserRepo.GetUserLocation(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get user timezone"})
+		return
+	}
+
+	parsed, err := quickadd.Parse(req.Text, time.Now().In(loc))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	task := models.Task{
+		Title:       parsed.Title,
+		Description: req.Text,
+		Status:      "pending",
+		Priority:    parsed.Priority,
+		Project:     parsed.Project,
+		Recurrence:  parsed.Recurrence,
+		Tags:        parsed.Tags,
+	}
+	// the parser works in the user's timezone, due dates are stored in UTC
+	if parsed.Due != nil {
+		due := parsed.Due.UTC()
+		task.DueAt = &due
+	}
+
+	if err := h.Service.CreateQuickAdd(userID.(int), &task); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, QuickAddResponse{Task: task, Parsed: *parsed})
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/netguard"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type ReminderHandler struct {
+	Repo     *repository.ReminderRepository
+	TaskRepo *repository.TaskRepository
+}
+
+func NewReminderHandler(repo *repository.ReminderRepository, taskRepo *repository.TaskRepository) *ReminderHandler {
+	return &ReminderHandler{Repo: repo, TaskRepo: taskRepo}
+}
+
+// GetReminders godoc
+// @Summary Получить напоминания задачи
+// @Description Получает все напоминания задачи вместе с состоянием доставки
+// @Tags reminders
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {array} models.Reminder
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/tasks/{id}/reminders [get]
+func (h *ReminderHandler) GetReminders(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task ID"})
+		return
+	}
+
+	reminders, err := h.Repo.GetRemindersByTaskID(taskID, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get reminders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reminders)
+}
+
+// CreateReminder godoc
+// @Summary Создать напоминание
+// @Description Создает напоминание на точное время (remind_at) или со смещением в минутах от срока задачи (offset_minutes, отрицательное значит раньше срока). Канал: inbox, email или webhook
+// @Tags reminders
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param reminder body models.Reminder true "Reminder data"
+// @Success 201 {object} models.Reminder
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/tasks/{id}/reminders [post]
+func (h *ReminderHandler) CreateReminder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task ID"})
+		return
+	}
+
+	var reminder models.Reminder
+	if err := c.ShouldBindJSON(&reminder); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid reminder data"})
+		return
+	}
+
+	if err := validate.Struct(reminder); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if (reminder.RemindAt == nil) == (reminder.OffsetMinutes == nil) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "set either remind_at or offset_minutes"})
+		return
+	}
+
+	switch reminder.Channel {
+	case "email":
+		if reminder.Target != "" && validate.Var(reminder.Target, "email") != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "target must be an email address"})
+			return
+		}
+	case "webhook":
+		if err := netguard.CheckURL(reminder.Target); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "target " + err.Error()})
+			return
+		}
+	default:
+		reminder.Target = ""
+	}
+
+	task, err := h.TaskRepo.GetTaskByID(taskID, userID.(int))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "task not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get task"})
+		return
+	}
+
+	if reminder.OffsetMinutes != nil && task.DueAt == nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "task has no due date to offset from"})
+		return
+	}
+
+	reminder.TaskID = taskID
+	reminder.UserID = userID.(int)
+
+	if err := h.Repo.CreateReminder(&reminder); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot create reminder"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reminder)
+}
+
+// DeleteReminder godoc
+// @Summary Удалить напоминание
+// @Description Удаляет напоминание задачи
+// @Tags reminders
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param reminderID path int true "Reminder ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/tasks/{id}/reminders/{reminderID} [delete]
+func (h *ReminderHandler) DeleteReminder(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task ID"})
+		return
+	}
+
+	reminderID, err := strconv.Atoi(c.Param("reminderID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid reminder ID"})
+		return
+	}
+
+	if err := h.Repo.DeleteReminder(reminderID, taskID, userID.(int)); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "cannot delete reminder"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "reminder deleted successfully"})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// SSOHandler signs users in with external OpenID Connect providers
+type SSOHandler struct {
+	Service *service.SSOService
+	// SuccessURL gets the result of a sign in in its fragment, without it the callback answers JSON
+	SuccessURL string
+}
+
+func NewSSOHandler(sso *service.SSOService, successURL string) *SSOHandler {
+	return &SSOHandler{Service: sso, SuccessURL: successURL}
+}
+
+type ProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+// GetProviders godoc
+// @Summary Провайдеры входа
+// @Description Возвращает имена настроенных провайдеров OpenID Connect
+// @Tags auth
+// @Produce json
+// @Success 200 {object} ProvidersResponse
+// @Router /api/v1/auth/oidc [get]
+func (h *SSOHandler) GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, ProvidersResponse{Providers: h.Service.ProviderNames()})
+}
+
+// Login godoc
+// @Summary Вход через провайдера
+// @Description Перенаправляет на страницу входа провайдера OpenID Connect (authorization code с PKCE). Провайдер возвращает пользователя на /callback
+// @Tags auth
+// @Param provider path string true "Provider name"
+// @Success 302
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/oidc/{provider}/login [get]
+func (h *SSOHandler) Login(c *gin.Context) {
+	authURL, err := h.Service.Begin(c.Param("provider"))
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback godoc
+// @Summary Возврат от провайдера
+// @Description Обменивает код на токены провайдера, проверяет ID токен и выдает наши токены. Внешний аккаунт связывается с пользователем по подтвержденному email, иначе пользователь создается. С OIDC_SUCCESS_URL перенаправляет туда с результатом во фрагменте URL
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/oidc/{provider}/callback [get]
+func (h *SSOHandler) Callback(c *gin.Context) {
+	// the user declined or the provider failed
+	if providerError := c.Query("error"); providerError != "" {
+		message := "identity provider error: " + providerError
+		if description := c.Query("error_description"); description != "" {
+			message += ": " + description
+		}
+		h.fail(c, http.StatusUnauthorized, message)
+		return
+	}
+
+	tokens, challenge, err := h.Service.Complete(c.Param("provider"), c.Query("state"), c.Query("code"), device(c))
+	if err != nil {
+		h.fail(c, errorStatus(err), err.Error())
+		return
+	}
+
+	var resp TokenResponse
+	if challenge != nil {
+		resp = TokenResponse{TwoFactorRequired: true, ChallengeToken: challenge.Token, EnrollmentRequired: challenge.EnrollmentRequired}
+	} else {
+		resp = TokenResponse{AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}
+	}
+
+	if h.SuccessURL == "" {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	// the fragment is not sent to servers, the tokens stay in the browser
+	fragment := url.Values{}
+	if challenge != nil {
+		fragment.Set("two_factor_required", "true")
+		fragment.Set("challenge_token", resp.ChallengeToken)
+		fragment.Set("enrollment_required", strconv.FormatBool(resp.EnrollmentRequired))
+	} else {
+		fragment.Set("access_token", resp.AccessToken)
+		fragment.Set("refresh_token", resp.RefreshToken)
+	}
+	c.Redirect(http.StatusFound, h.SuccessURL+"#"+fragment.Encode())
+}
+
+// GetIdentities godoc
+// @Summary Связанные аккаунты
+// @Description Возвращает аккаунты внешних провайдеров, через которые пользователь входит
+// @Tags users
+// @Produce json
+// @Success 200 {array} models.UserIdentity
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/identities [get]
+func (h *SSOHandler) GetIdentities(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	identities, err := h.Service.ListIdentities(userID.(int))
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// DeleteIdentity godoc
+// @Summary Отвязать аккаунт
+// @Description Отвязывает аккаунт внешнего провайдера от пользователя
+// @Tags users
+// @Produce json
+// @Param id path int true "Identity ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/identities/{id} [delete]
+func (h *SSOHandler) DeleteIdentity(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	identityID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid identity ID"})
+		return
+	}
+
+	if err := h.Service.Unlink(userID.(int), identityID); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "identity unlinked"})
+}
+
+// fail answers an error of the callback, in the fragment of SuccessURL when there is one
+func (h *SSOHandler) fail(c *gin.Context, status int, message string) {
+	if h.SuccessURL == "" {
+		c.JSON(status, ErrorResponse{Error: message})
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.SuccessURL+"#"+url.Values{"error": {message}}.Encode())
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// SyncPullResponse is a page of changes. Token is passed as since on the next pull,
+// when HasMore is set the next page can be fetched right away.
+type SyncPullResponse struct {
+	Tasks   []models.Task          `json:"tasks"`
+	Deleted []models.TaskTombstone `json:"deleted"`
+	Token   string                 `json:"token"`
+	HasMore bool                   `json:"has_more"`
+}
+
+// SyncPushRequest carries offline changes. ClientTime is the client's clock at
+// sending, it is used to move the changed_at times onto the server clock.
+type SyncPushRequest struct {
+	ClientTime time.Time           `json:"client_time" validate:"required"`
+	Changes    []models.SyncChange `json:"changes" validate:"required,min=1,max=500,dive"`
+}
+
+type SyncPushResponse struct {
+	Results []models.SyncResult `json:"results"`
+}
+
+// PullChanges godoc
+// @Summary Получить изменения задач
+// @Description Возвращает задачи, созданные или измененные после токена, и удаленные задачи (deleted). Без токена возвращает все задачи. Токен непрозрачный, его нужно передать в следующем запросе
+// @Tags sync
+// @Accept json
+// @Produce json
+// @Param since query string false "Sync token from the previous pull"
+// @Param limit query int false "Max changes per page, 500 by default, 1000 at most"
+// @Success 200 {object} SyncPullResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/sync [get]
+func (h *TaskHandler) PullChanges(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var since int64
+	if token := c.Query("since"); token != "" {
+		var err error
+		since, err = decodeSyncToken(token, userID.(int))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid sync token"})
+			return
+		}
+	}
+
+	limit := 500
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid limit"})
+			return
+		}
+	}
+
+	changes, err := h.Repo.GetChangesSince(userID.(int), since, limit)
+	if errors.Is(err, repository.ErrSyncVersionAhead) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "sync token is not valid anymore, sync without a token"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get changes"})
+		return
+	}
+
+	response := SyncPullResponse{
+		Tasks:   changes.Tasks,
+		Deleted: changes.Deleted,
+		Token:   encodeSyncToken(userID.(int), changes.Version),
+		HasMore: changes.HasMore,
+	}
+	if response.Tasks == nil {
+		response.Tasks = []models.Task{}
+	}
+	if response.Deleted == nil {
+		response.Deleted = []models.TaskTombstone{}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PushChanges godoc
+// @Summary Отправить офлайн-изменения
+// @Description Применяет пакет изменений клиента (create, update, delete). Новые задачи идентифицируются client_id. Конфликты решаются по каждому полю: побеждает более поздняя запись по времени сервера, отклоненные поля перечислены в rejected_fields
+// @Tags sync
+// @Accept json
+// @Produce json
+// @Param changes body SyncPushRequest true "Client changes"
+// @Success 200 {object} SyncPushResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/sync [post]
+func (h *TaskHandler) PushChanges(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req SyncPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid sync data"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// the database clock stamps every other change, so the client clock is mapped onto it
+	now, err := h.Repo.Now()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot apply changes"})
+		return
+	}
+	skew := now.Sub(req.ClientTime)
+
+	check := func(task *models.Task) error {
+		if err := validate.Struct(task); err != nil {
+			return err
+		}
+		return h.Service.CheckReferences(task)
+	}
+
+	results := make([]models.SyncResult, 0, len(req.Changes))
+	for _, change := range req.Changes {
+		// changes cannot come from the future, whatever the client clock says
+		at := change.ChangedAt.Add(skew)
+		if at.After(now) {
+			at = now
+		}
+
+		result, err := h.Repo.ApplySyncChange(userID.(int), change, at, check)
+		if err != nil {
+			results = append(results, models.SyncResult{ID: derefInt(change.ID), ClientID: change.ClientID, Status: repository.SyncError, Error: "cannot apply change"})
+			continue
+		}
+
+		results = append(results, *result)
+	}
+
+	c.JSON(http.StatusOK, SyncPushResponse{Results: results})
+}
+
+// sync tokens are opaque to clients, they only carry the user's change version
+func encodeSyncToken(userID int, version int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("v1:%d:%d", userID, version)))
+}
+
+func decodeSyncToken(token string, userID int) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+
+	var owner int
+	var version int64
+	if _, err := fmt.Sscanf(string(raw), "v1:%d:%d", &owner, &version); err != nil {
+		return 0, err
+	}
+	if owner != userID || version < 0 {
+		return 0, errors.New("sync token of another user")
+	}
+
+	return version, nil
+}
+
+func derefInt(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
 	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 )
@@ -13,11 +14,14 @@ import (
 var validate = validator.New()
 
 type TaskHandler struct {
-	Repo *repository.TaskRepository
+	Service       *service.TaskService
+	Repo          *repository.TaskRepository
+	MilestoneRepo *repository.MilestoneRepository
+	UserRepo      *repository.UserRepository
 }
 
-func NewTaskHandler(repo *repository.TaskRepository) *TaskHandler {
-	return &TaskHandler{Repo: repo}
+func NewTaskHandler(tasks *service.TaskService, userRepo *repository.UserRepository) *TaskHandler {
+	return &TaskHandler{Service: tasks, Repo: tasks.Tasks, MilestoneRepo: tasks.Milestones, UserRepo: userRepo}
 }
 
 // ErrorResponse структура для ошибок
@@ -36,7 +40,13 @@ type MessageResponse struct {
 // @Tags tasks
 // @Accept json
 // @Produce json
+// @Param milestone_id query int false "Filter by milestone ID"
+// @Param view query string false "my_day, pinned or snoozed. Snoozed tasks are hidden by default"
+// @Param render query string false "html or markdown, both by default"
+// @Param limit query int false "Page size up to 1000, every task when omitted"
+// @Param offset query int false "Number of tasks to skip"
 // @Success 200 {array} models.Task
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Router /api/v1/tasks/ [get]
 func (h *TaskHandler) GetTasks(c *gin.Context) {
@@ -46,12 +56,49 @@ func (h *TaskHandler) GetTasks(c *gin.Context) {
 		return
 	}
 
-	tasks, err := h.Repo.GetAllTasksByUserID(userID.(int))
+	filter := repository.TaskFilter{View: c.Query("view")}
+	if raw := c.Query("milestone_id"); raw != "" {
+		milestoneID, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid milestone ID"})
+			return
+		}
+		filter.MilestoneID = &milestoneID
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid limit"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	if raw := c.Query("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid offset"})
+			return
+		}
+		filter.Offset = offset
+	}
+
+	mode, ok := renderMode(c)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.Service.List(userID.(int), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get tasks"})
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	for i := range tasks {
+		renderDescriptions(mode, &tasks[i])
+	}
+
 	c.JSON(http.StatusOK, tasks)
 }
 
@@ -62,6 +109,7 @@ func (h *TaskHandler) GetTasks(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Task ID"
+// @Param render query string false "html or markdown, both by default"
 // @Success 200 {object} models.Task
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
@@ -80,12 +128,18 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 		return
 	}
 
-	task, err := h.Repo.GetTaskByID(taskID, userID.(int))
+	mode, ok := renderMode(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.Service.Get(userID.(int), taskID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get task"})
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	renderDescriptions(mode, task)
 	c.JSON(http.StatusOK, task)
 }
 
@@ -96,6 +150,7 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param task body models.Task true "Task data"
+// @Param render query string false "html or markdown, both by default"
 // @Success 201 {object} models.Task
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
@@ -107,24 +162,23 @@ func (h *TaskHandler) CreateTask(c *gin.Context) {
 		return
 	}
 
-	var task models.Task
-	if err := c.ShouldBindJSON(&task); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task data"})
+	mode, ok := renderMode(c)
+	if !ok {
 		return
 	}
 
-	if err := validate.Struct(task); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	var task models.Task
+	if err := c.ShouldBindJSON(&task); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task data"})
 		return
 	}
 
-	task.UserID = userID.(int)
-
-	if err := h.Repo.CreateNewTask(&task); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot create task"})
+	if err := h.Service.Create(userID.(int), &task); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	renderDescriptions(mode, &task)
 	c.JSON(http.StatusCreated, task)
 }
 
@@ -160,11 +214,8 @@ func (h *TaskHandler) UpdateTask(c *gin.Context) {
 		return
 	}
 
-	task.ID = taskID
-	task.UserID = userID.(int)
-
-	if err := h.Repo.UpdateTask(&task); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot update task"})
+	if err := h.Service.Update(userID.(int), taskID, &task); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
@@ -196,10 +247,42 @@ func (h *TaskHandler) DeleteTask(c *gin.Context) {
 		return
 	}
 
-	if err := h.Repo.DeleteTask(taskID, userID.(int)); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot delete task"})
+	if err := h.Service.Delete(userID.(int), taskID); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, MessageResponse{Message: "task deleted successfully"})
 }
+
+// TaskError is a failed task operation together with the HTTP status it maps to
+type TaskError struct {
+	Status  int
+	Message string
+}
+
+func (e *TaskError) Error() string {
+	return e.Message
+}
+
+func (e *TaskError) StatusCode() int {
+	return e.Status
+}
+
+// errorStatus is the HTTP status to answer an error of the service layer with
+func errorStatus(err error) int {
+	switch service.KindOf(err) {
+	case service.KindInvalid:
+		return http.StatusBadRequest
+	case service.KindNotFound:
+		return http.StatusNotFound
+	case service.KindConflict:
+		return http.StatusConflict
+	case service.KindUnauthenticated:
+		return http.StatusUnauthorized
+	case service.KindForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,521 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type TemplateHandler struct {
+	Repo          *repository.TemplateRepository
+	TaskRepo      *repository.TaskRepository
+	MilestoneRepo *repository.MilestoneRepository
+}
+
+func NewTemplateHandler(repo *repository.TemplateRepository, taskRepo *repository.TaskRepository, milestoneRepo *repository.MilestoneRepository) *TemplateHandler {
+	return &TemplateHandler{Repo: repo, TaskRepo: taskRepo, MilestoneRepo: milestoneRepo}
+}
+
+// SaveAsTemplateRequest names the template created from an existing task
+type SaveAsTemplateRequest struct {
+	Name        string `json:"name" validate:"required,min=3,max=100"`
+	Description string `json:"description" validate:"max=500"`
+}
+
+// InstantiateTemplateRequest describes how to turn a template into tasks.
+// StartDate defaults to now, due offsets of the items are added to it.
+type InstantiateTemplateRequest struct {
+	StartDate   *time.Time        `json:"start_date"`
+	Variables   map[string]string `json:"variables"`
+	MilestoneID *int              `json:"milestone_id"`
+}
+
+// GetTemplates godoc
+// @Summary Получить список шаблонов
+// @Description Получает все шаблоны задач текущего пользователя без их содержимого
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Template
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/templates/ [get]
+func (h *TemplateHandler) GetTemplates(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	templates, err := h.Repo.GetAllTemplatesByUserID(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplate godoc
+// @Summary Получить шаблон по ID
+// @Description Получает шаблон вместе с деревом задач
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Success 200 {object} models.Template
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/templates/{id} [get]
+func (h *TemplateHandler) GetTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	templateID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid template ID"})
+		return
+	}
+
+	template, err := h.Repo.GetTemplateByID(templateID, userID.(int))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "template not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// CreateTemplate godoc
+// @Summary Создать шаблон
+// @Description Создает шаблон из задачи или небольшого дерева задач
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param template body models.Template true "Template data"
+// @Success 201 {object} models.Template
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/templates/ [post]
+func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var template models.Template
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid template data"})
+		return
+	}
+
+	if err := checkTemplateSize(template.Items); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	defaultItemStatus(template.Items)
+
+	if err := validate.Struct(template); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	template.UserID = userID.(int)
+
+	if err := h.Repo.CreateTemplate(&template); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot create template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// SaveTaskAsTemplate godoc
+// @Summary Сохранить задачу как шаблон
+// @Description Создает шаблон из существующей задачи и всех ее подзадач. Сроки подзадач сохраняются как смещения от срока задачи
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param template body SaveAsTemplateRequest true "Template name"
+// @Success 201 {object} models.Template
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/templates/from-task/{id} [post]
+func (h *TemplateHandler) SaveTaskAsTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task ID"})
+		return
+	}
+
+	var req SaveAsTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid template data"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	tree, err := h.TaskRepo.GetTaskTree(taskID, userID.(int))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "task not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get task"})
+		return
+	}
+
+	base := tree.Task.Created_at
+	if tree.Task.DueAt != nil {
+		base = *tree.Task.DueAt
+	}
+
+	template := models.Template{
+		UserID:      userID.(int),
+		Name:        req.Name,
+		Description: req.Description,
+		Items:       []models.TemplateItem{templateItemFromTask(*tree, base)},
+	}
+
+	if err := checkTemplateSize(template.Items); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := h.Repo.CreateTemplate(&template); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot create template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateTemplate godoc
+// @Summary Обновить шаблон
+// @Description Заменяет название, описание и дерево задач шаблона
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param template body models.Template true "Template data"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/templates/{id} [put]
+func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	templateID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid template ID"})
+		return
+	}
+
+	var template models.Template
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid template data"})
+		return
+	}
+
+	if err := checkTemplateSize(template.Items); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	defaultItemStatus(template.Items)
+
+	if err := validate.Struct(template); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	template.ID = templateID
+	template.UserID = userID.(int)
+
+	if err := h.Repo.UpdateTemplate(&template); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "template not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot update template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "template updated successfully"})
+}
+
+// DeleteTemplate godoc
+// @Summary Удалить шаблон
+// @Description Удаляет шаблон, созданные из него задачи остаются
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/templates/{id} [delete]
+func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	templateID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid template ID"})
+		return
+	}
+
+	if err := h.Repo.DeleteTemplate(templateID, userID.(int)); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "template not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot delete template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "template deleted successfully"})
+}
+
+// InstantiateTemplate godoc
+// @Summary Создать задачи из шаблона
+// @Description Создает все задачи шаблона одной транзакцией. Сроки считаются от start_date, плейсхолдеры вида {{name}} заменяются значениями из variables
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param id path int true "Template ID"
+// @Param request body InstantiateTemplateRequest false "Instantiation options"
+// @Success 201 {array} models.Task
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/templates/{id}/instantiate [post]
+func (h *TemplateHandler) InstantiateTemplate(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	templateID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid template ID"})
+		return
+	}
+
+	var req InstantiateTemplateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid instantiate request"})
+			return
+		}
+	}
+
+	template, err := h.Repo.GetTemplateByID(templateID, userID.(int))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "template not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get template"})
+		return
+	}
+
+	if req.MilestoneID != nil {
+		if _, err := h.MilestoneRepo.GetMilestoneByID(*req.MilestoneID, userID.(int)); err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "milestone not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get milestone"})
+			return
+		}
+	}
+
+	start := time.Now()
+	if req.StartDate != nil {
+		start = *req.StartDate
+	}
+	// due dates are stored in UTC, whatever offset start_date was sent with
+	start = start.UTC()
+
+	inst := instantiation{
+		userID:      userID.(int),
+		start:       start,
+		vars:        req.Variables,
+		milestoneID: req.MilestoneID,
+		missing:     make(map[string]bool),
+	}
+
+	trees := inst.build(template.Items)
+
+	if len(inst.missing) > 0 {
+		var names []string
+		for name := range inst.missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "missing template variables: " + strings.Join(names, ", ")})
+		return
+	}
+
+	if inst.err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: inst.err.Error()})
+		return
+	}
+
+	if err := h.TaskRepo.CreateTaskTrees(trees); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot create tasks"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, flattenTaskTrees(trees, nil))
+}
+
+// instantiation accumulates the state of turning template items into tasks
+type instantiation struct {
+	userID      int
+	start       time.Time
+	vars        map[string]string
+	milestoneID *int
+	missing     map[string]bool
+	err         error
+}
+
+func (inst *instantiation) build(items []models.TemplateItem) []repository.TaskTree {
+	trees := make([]repository.TaskTree, 0, len(items))
+
+	for _, item := range items {
+		task := models.Task{
+			UserID:      inst.userID,
+			Title:       inst.substitute(item.Title),
+			Description: inst.substitute(item.Description),
+			Status:      item.Status,
+			Tags:        item.Tags,
+			MilestoneID: inst.milestoneID,
+		}
+
+		if task.Status == "" {
+			task.Status = "pending"
+		}
+
+		if item.DueOffsetMinutes != nil {
+			due := inst.start.Add(time.Duration(*item.DueOffsetMinutes) * time.Minute)
+			task.DueAt = &due
+		}
+
+		if err := validate.Struct(task); err != nil && inst.err == nil {
+			inst.err = err
+		}
+
+		trees = append(trees, repository.TaskTree{Task: task, Children: inst.build(item.Children)})
+	}
+
+	return trees
+}
+
+func (inst *instantiation) substitute(text string) string {
+	result, missing := utils.SubstitutePlaceholders(text, inst.vars)
+	for _, name := range missing {
+		inst.missing[name] = true
+	}
+	return result
+}
+
+// Limits of the item tree of a template, every item becomes a task when it is instantiated
+const (
+	maxTemplateItems = 200
+	maxTemplateDepth = 5
+)
+
+// checkTemplateSize keeps one instantiation from creating an unbounded number of tasks
+func checkTemplateSize(items []models.TemplateItem) error {
+	count, depth := templateSize(items)
+	if count > maxTemplateItems {
+		return fmt.Errorf("template has %d items, the limit is %d", count, maxTemplateItems)
+	}
+	if depth > maxTemplateDepth {
+		return fmt.Errorf("template items are nested %d levels deep, the limit is %d", depth, maxTemplateDepth)
+	}
+	return nil
+}
+
+func templateSize(items []models.TemplateItem) (count int, depth int) {
+	for _, item := range items {
+		childCount, childDepth := templateSize(item.Children)
+		count += 1 + childCount
+		depth = max(depth, childDepth+1)
+	}
+	return count, depth
+}
+
+// defaultItemStatus makes items saved without a status pending, like new tasks
+func defaultItemStatus(items []models.TemplateItem) {
+	for i := range items {
+		if items[i].Status == "" {
+			items[i].Status = "pending"
+		}
+		defaultItemStatus(items[i].Children)
+	}
+}
+
+func templateItemFromTask(tree repository.TaskTree, base time.Time) models.TemplateItem {
+	item := models.TemplateItem{
+		Title:       tree.Task.Title,
+		Description: tree.Task.Description,
+		Status:      "pending",
+		Tags:        tree.Task.Tags,
+	}
+
+	if tree.Task.DueAt != nil {
+		offset := int(tree.Task.DueAt.Sub(base) / time.Minute)
+		item.DueOffsetMinutes = &offset
+	}
+
+	for _, child := range tree.Children {
+		item.Children = append(item.Children, templateItemFromTask(child, base))
+	}
+
+	return item
+}
+
+func flattenTaskTrees(trees []repository.TaskTree, tasks []models.Task) []models.Task {
+	for _, tree := range trees {
+		tasks = append(tasks, tree.Task)
+		tasks = flattenTaskTrees(tree.Children, tasks)
+	}
+	return tasks
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// nestedItems builds a chain of items depth levels deep, each level with width items
+func nestedItems(depth int, width int) []models.TemplateItem {
+	if depth == 0 {
+		return nil
+	}
+	items := make([]models.TemplateItem, width)
+	for i := range items {
+		items[i] = models.TemplateItem{Title: "item", Children: nestedItems(depth-1, width)}
+	}
+	return items
+}
+
+func TestCheckTemplateSize(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []models.TemplateItem
+		ok    bool
+	}{
+		{"single item", nestedItems(1, 1), true},
+		{"widest allowed", nestedItems(1, maxTemplateItems), true},
+		{"deepest allowed", nestedItems(maxTemplateDepth, 1), true},
+		{"too many items", nestedItems(1, maxTemplateItems+1), false},
+		{"too deep", nestedItems(maxTemplateDepth+1, 1), false},
+		// 50 items per level passes validation, three levels are 127550 tasks
+		{"wide on every level", nestedItems(3, 50), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTemplateSize(tt.items)
+			if (err == nil) != tt.ok {
+				t.Errorf("checkTemplateSize() error = %v, want ok = %t", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/taskio"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	maxImportSize = 10 << 20
+	maxImportRows = 10000
+)
+
+// ImportRowError lists the problems of one record, Row counts records from 1 without the CSV header
+type ImportRowError struct {
+	Row    int      `json:"row"`
+	Errors []string `json:"errors"`
+}
+
+type ImportResponse struct {
+	DryRun   bool             `json:"dry_run"`
+	Total    int              `json:"total"`
+	Valid    int              `json:"valid"`
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// ExportTasks godoc
+// @Summary Экспорт задач
+// @Description Выгружает все задачи пользователя в CSV, JSON или NDJSON. Строки передаются по мере чтения из базы
+// @Tags tasks
+// @Produce json
+// @Produce text/csv
+// @Param format query string false "csv, json or ndjson, json by default"
+// @Success 200 {array} models.Task
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/tasks/export [get]
+func (h *TaskHandler) ExportTasks(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	format := c.DefaultQuery("format", taskio.FormatJSON)
+
+	writer, err := taskio.NewWriter(format, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "format must be csv, json or ndjson"})
+		return
+	}
+
+	c.Header("Content-Type", taskio.ContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="tasks.`+format+`"`)
+	c.Status(http.StatusOK)
+
+	err = h.Repo.StreamTasks(c.Request.Context(), userID.(int), writer.Write)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		// the status is already sent, all that is left is to cut the stream short
+		log.Print("cannot export tasks:", err)
+		c.Abort()
+	}
+}
+
+// ImportTasks godoc
+// @Summary Импорт задач
+// @Description Загружает задачи из CSV, JSON или NDJSON в теле запроса. mapping[колонка]=поле переименовывает колонки файла в поля задачи. С dry_run=true только проверяет файл и возвращает ошибки по строкам. Без него импортирует все строки в одной транзакции или ничего. Подзадачи связываются по колонкам id и parent_id внутри файла
+// @Tags tasks
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Param format query string true "csv, json or ndjson"
+// @Param dry_run query bool false "Only validate the file"
+// @Param mapping query object false "Column mapping, e.g. mapping[Name]=title"
+// @Success 200 {object} ImportResponse
+// @Success 201 {object} ImportResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 422 {object} ImportResponse
+// @Router /api/v1/tasks/import [post]
+func (h *TaskHandler) ImportTasks(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	format := c.Query("format")
+	if format != taskio.FormatCSV && format != taskio.FormatJSON && format != taskio.FormatNDJSON {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "format must be csv, json or ndjson"})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	mapping := c.QueryMap("mapping")
+	for source, field := range mapping {
+		mapping[source] = strings.ToLower(strings.TrimSpace(field))
+	}
+
+	var rows []taskio.Row
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	err := taskio.ReadRows(format, body, mapping, func(row taskio.Row) error {
+		if len(rows) == maxImportRows {
+			return fmt.Errorf("a file may have at most %d tasks", maxImportRows)
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "cannot read file: " + err.Error()})
+		return
+	}
+
+	response := ImportResponse{DryRun: dryRun, Total: len(rows), Errors: []ImportRowError{}}
+	milestones := make(map[int]bool)
+
+	for i := range rows {
+		row := &rows[i]
+		row.Task.UserID = userID.(int)
+		if row.Task.Status == "" {
+			row.Task.Status = "pending"
+		}
+
+		if err := validate.Struct(row.Task); err != nil {
+			row.Errors = append(row.Errors, taskFieldErrors(err)...)
+		}
+
+		if id := row.Task.MilestoneID; id != nil {
+			owned, checked := milestones[*id]
+			if !checked {
+				_, err := h.MilestoneRepo.GetMilestoneByID(*id, userID.(int))
+				owned = err == nil
+				milestones[*id] = owned
+			}
+			if !owned {
+				row.Errors = append(row.Errors, "milestone_id: milestone not found")
+			}
+		}
+	}
+
+	roots := linkImportRows(rows)
+
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			response.Errors = append(response.Errors, ImportRowError{Row: row.Line, Errors: row.Errors})
+		}
+	}
+	response.Valid = response.Total - len(response.Errors)
+
+	if len(response.Errors) > 0 {
+		status := http.StatusUnprocessableEntity
+		if dryRun {
+			status = http.StatusOK
+		}
+		c.JSON(status, response)
+		return
+	}
+
+	if dryRun {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	if err := h.Repo.CreateTaskTrees(roots); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot import tasks"})
+		return
+	}
+	response.Imported = response.Total
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// linkImportRows turns the rows into trees along their file IDs.
+// Broken links are recorded as errors of the rows.
+func linkImportRows(rows []taskio.Row) []repository.TaskTree {
+	byID := make(map[string]int, len(rows))
+	for i, row := range rows {
+		if row.ID == "" {
+			continue
+		}
+		if _, dup := byID[row.ID]; dup {
+			rows[i].Errors = append(rows[i].Errors, "id: "+row.ID+" is used by another row")
+			continue
+		}
+		byID[row.ID] = i
+	}
+
+	children := make(map[int][]int)
+	var roots []int
+	for i, row := range rows {
+		if row.ParentID == "" {
+			roots = append(roots, i)
+			continue
+		}
+		parent, ok := byID[row.ParentID]
+		if !ok {
+			rows[i].Errors = append(rows[i].Errors, "parent_id: "+row.ParentID+" is not in the file")
+			continue
+		}
+		children[parent] = append(children[parent], i)
+	}
+
+	reached := make([]bool, len(rows))
+	var build func(indexes []int) []repository.TaskTree
+	build = func(indexes []int) []repository.TaskTree {
+		trees := make([]repository.TaskTree, 0, len(indexes))
+		for _, i := range indexes {
+			reached[i] = true
+			trees = append(trees, repository.TaskTree{Task: rows[i].Task, Children: build(children[i])})
+		}
+		return trees
+	}
+	trees := build(roots)
+
+	// rows whose parents point at each other never hang off a root
+	for i, row := range rows {
+		if !reached[i] && row.ParentID != "" && len(row.Errors) == 0 {
+			rows[i].Errors = append(rows[i].Errors, "parent_id: tasks form a cycle")
+		}
+	}
+
+	return trees
+}
+
+var taskJSONNames = jsonNames(reflect.TypeOf(models.Task{}))
+
+// taskFieldErrors reports validation errors with the JSON names clients know the fields by
+func taskFieldErrors(err error) []string {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return []string{err.Error()}
+	}
+
+	messages := make([]string, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		name, ok := taskJSONNames[fe.StructField()]
+		if !ok {
+			name = fe.Field()
+		}
+
+		message := name + ": failed on " + fe.Tag()
+		if fe.Param() != "" {
+			message += "=" + fe.Param()
+		}
+		messages = append(messages, message)
+	}
+
+	return messages
+}
+
+func jsonNames(t reflect.Type) map[string]string {
+	names := make(map[string]string, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[field.Name] = name
+		}
+	}
+	return names
+}
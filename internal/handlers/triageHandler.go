@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SnoozeRequest sets the time the task comes back to the default list
+type SnoozeRequest struct {
+	Until time.Time `json:"until" binding:"required"`
+}
+
+// SnoozeTask godoc
+// @Summary Отложить задачу
+// @Description Скрывает задачу из списка по умолчанию до указанного времени
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Param request body SnoozeRequest true "Snooze until"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/tasks/{id}/snooze [post]
+func (h *TaskHandler) SnoozeTask(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task ID"})
+		return
+	}
+
+	var req SnoozeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid snooze data"})
+		return
+	}
+
+	if !req.Until.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "snooze time must be in the future"})
+		return
+	}
+
+	if err := h.Repo.SnoozeTask(taskID, userID.(int), &req.Until); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "cannot snooze task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "task snoozed successfully"})
+}
+
+// UnsnoozeTask godoc
+// @Summary Вернуть отложенную задачу
+// @Description Возвращает отложенную задачу в список по умолчанию
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/tasks/{id}/snooze [delete]
+func (h *TaskHandler) UnsnoozeTask(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task ID"})
+		return
+	}
+
+	if err := h.Repo.SnoozeTask(taskID, userID.(int), nil); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "cannot unsnooze task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "task unsnoozed successfully"})
+}
+
+// PinTask godoc
+// @Summary Закрепить задачу
+// @Description Закрепляет задачу, закрепленные задачи идут первыми в списке
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/tasks/{id}/pin [post]
+func (h *TaskHandler) PinTask(c *gin.Context) {
+	h.setPinned(c, true)
+}
+
+// UnpinTask godoc
+// @Summary Открепить задачу
+// @Description Снимает закрепление с задачи
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/tasks/{id}/pin [delete]
+func (h *TaskHandler) UnpinTask(c *gin.Context) {
+	h.setPinned(c, false)
+}
+
+func (h *TaskHandler) setPinned(c *gin.Context, pinned bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task ID"})
+		return
+	}
+
+	if err := h.Repo.SetTaskPinned(taskID, userID.(int), pinned); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "cannot update task"})
+		return
+	}
+
+	if pinned {
+		c.JSON(http.StatusOK, MessageResponse{Message: "task pinned successfully"})
+	} else {
+		c.JSON(http.StatusOK, MessageResponse{Message: "task unpinned successfully"})
+	}
+}
+
+// AddToMyDay godoc
+// @Summary Добавить задачу в "Мой день"
+// @Description Добавляет задачу в список на сегодня. Список очищается в полночь по часовому поясу пользователя
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/tasks/{id}/my-day [post]
+func (h *TaskHandler) AddToMyDay(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task ID"})
+		return
+	}
+
+	if err := h.Repo.AddTaskToMyDay(taskID, userID.(int)); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "task not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot add task to my day"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "task added to my day"})
+}
+
+// RemoveFromMyDay godoc
+// @Summary Убрать задачу из "Мой день"
+// @Description Убирает задачу из списка на сегодня
+// @Tags tasks
+// @Accept json
+// @Produce json
+// @Param id path int true "Task ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/tasks/{id}/my-day [delete]
+func (h *TaskHandler) RemoveFromMyDay(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid task ID"})
+		return
+	}
+
+	if err := h.Repo.RemoveTaskFromMyDay(taskID, userID.(int)); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "cannot remove task from my day"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "task removed from my day"})
+}
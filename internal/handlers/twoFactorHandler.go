@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type VerifyTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	// Code is one of the authenticator app or a recovery code
+	Code string `json:"code" validate:"required"`
+}
+
+type ChallengeRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorSettings struct {
+	RequireForAdmins bool `json:"require_for_admins"`
+}
+
+// VerifyTwoFactor godoc
+// @Summary Второй фактор входа
+// @Description Завершает вход кодом из приложения-аутентификатора или резервным кодом. На один challenge_token дается 5 попыток за 5 минут. Если вход требовал подключения 2FA, код подтверждает секрет из /auth/2fa/enroll и в ответе приходят резервные коды
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body VerifyTwoFactorRequest true "Challenge token and code"
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/2fa/verify [post]
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req VerifyTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid code"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid code"})
+		return
+	}
+
+	tokens, recoveryCodes, err := h.Service.Verify2FA(req.ChallengeToken, req.Code)
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		AccessToken:   tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// EnrollTwoFactorChallenge godoc
+// @Summary Подключение 2FA при входе
+// @Description Для входа, ответившего enrollment_required: создает секрет TOTP, первый код из него отправляется в /auth/2fa/verify
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body ChallengeRequest true "Challenge token"
+// @Success 200 {object} service.TOTPEnrollment
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/2fa/enroll [post]
+func (h *AuthHandler) EnrollTwoFactorChallenge(c *gin.Context) {
+	var req ChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid challenge token"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid challenge token"})
+		return
+	}
+
+	enrollment, err := h.Service.EnrollChallenge(req.ChallengeToken)
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// GetTwoFactor godoc
+// @Summary Состояние 2FA
+// @Description Показывает, включена ли двухфакторная аутентификация, обязательна ли она и сколько резервных кодов осталось
+// @Tags users
+// @Produce json
+// @Success 200 {object} service.TwoFactorStatus
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/2fa [get]
+func (h *AuthHandler) GetTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	status, err := h.Service.TwoFactorStatus(userID.(int))
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollTwoFactor godoc
+// @Summary Подключение 2FA
+// @Description Создает секрет TOTP и возвращает его вместе с otpauth:// URI и QR-кодом (PNG data URI). 2FA включается после подтверждения первым кодом
+// @Tags users
+// @Produce json
+// @Success 200 {object} service.TOTPEnrollment
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/2fa [post]
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	enrollment, err := h.Service.EnrollTOTP(userID.(int))
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTwoFactor godoc
+// @Summary Подтверждение 2FA
+// @Description Включает двухфакторную аутентификацию первым кодом из приложения и возвращает резервные коды. Коды показываются только один раз
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body CodeRequest true "Code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/2fa/confirm [post]
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid code"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid code"})
+		return
+	}
+
+	codes, err := h.Service.ConfirmTOTP(userID.(int), req.Code)
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor godoc
+// @Summary Отключение 2FA
+// @Description Отключает двухфакторную аутентификацию после проверки кода или резервного кода. Администраторы не могут отключить ее, пока она обязательна
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body CodeRequest true "Code"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/2fa [delete]
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid code"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid code"})
+		return
+	}
+
+	if err := h.Service.DisableTOTP(userID.(int), req.Code); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Новые резервные коды
+// @Description Заменяет резервные коды новыми после проверки кода, старые перестают действовать
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body CodeRequest true "Code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/users/me/2fa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid code"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid code"})
+		return
+	}
+
+	codes, err := h.Service.RegenerateRecoveryCodes(userID.(int), req.Code)
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// GetTwoFactorSettings godoc
+// @Summary Настройки 2FA
+// @Description Показывает, обязательна ли двухфакторная аутентификация для администраторов. Только для администраторов
+// @Tags admin
+// @Produce json
+// @Success 200 {object} TwoFactorSettings
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/settings/2fa [get]
+func (h *AuthHandler) GetTwoFactorSettings(c *gin.Context) {
+	required, err := h.Service.AdminTwoFactorRequired()
+	if err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorSettings{RequireForAdmins: required})
+}
+
+// UpdateTwoFactorSettings godoc
+// @Summary Изменить настройки 2FA
+// @Description Делает двухфакторную аутентификацию обязательной для администраторов или снова необязательной. Включить требование может только администратор с включенной 2FA. Остальные администраторы подключают ее при следующем входе
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorSettings true "Settings"
+// @Success 200 {object} TwoFactorSettings
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/admin/settings/2fa [put]
+func (h *AuthHandler) UpdateTwoFactorSettings(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req TwoFactorSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid settings"})
+		return
+	}
+
+	if err := h.Service.SetAdminTwoFactorRequired(userID.(int), req.RequireForAdmins); err != nil {
+		c.JSON(errorStatus(err), ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, req)
+}
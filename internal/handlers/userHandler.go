@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type UserHandler struct {
+	UserRepo *repository.UserRepository
+}
+
+func NewUserHandler(userRepo *repository.UserRepository) *UserHandler {
+	return &UserHandler{UserRepo: userRepo}
+}
+
+// TimezoneRequest carries an IANA timezone name such as "Europe/Moscow"
+type TimezoneRequest struct {
+	Timezone string `json:"timezone" validate:"required,timezone"`
+}
+
+// GetMe godoc
+// @Summary Текущий пользователь
+// @Description Возвращает профиль текущего пользователя
+// @Tags users
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.User
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/users/me [get]
+func (h *UserHandler) GetMe(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(userID.(int))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "user not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get user"})
+		return
+	}
+
+	user.Password = ""
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateTimezone godoc
+// @Summary Сменить часовой пояс
+// @Description Задает часовой пояс, в котором разбираются относительные даты
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body TimezoneRequest true "Timezone"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/users/me/timezone [put]
+func (h *UserHandler) UpdateTimezone(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var req TimezoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid timezone"})
+		return
+	}
+
+	if err := validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "unknown timezone"})
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get user"})
+		return
+	}
+
+	user.Timezone = req.Timezone
+
+	if err := h.UserRepo.UpdateUser(user); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot update user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "timezone updated successfully"})
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/netguard"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	Repo *repository.WebhookRepository
+}
+
+func NewWebhookHandler(repo *repository.WebhookRepository) *WebhookHandler {
+	return &WebhookHandler{Repo: repo}
+}
+
+// GetWebhooks godoc
+// @Summary Получить список вебхуков
+// @Description Получает все вебхуки текущего пользователя. Секрет не возвращается
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Webhook
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/webhooks/ [get]
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	webhooks, err := h.Repo.GetAllWebhooksByUserID(userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get webhooks"})
+		return
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook godoc
+// @Summary Получить вебхук по ID
+// @Description Получает вебхук текущего пользователя. Секрет не возвращается
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} models.Webhook
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	webhookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid webhook ID"})
+		return
+	}
+
+	webhook, err := h.Repo.GetWebhookByID(webhookID, userID.(int))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "webhook not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get webhook"})
+		return
+	}
+
+	webhook.Secret = ""
+	c.JSON(http.StatusOK, webhook)
+}
+
+// CreateWebhook godoc
+// @Summary Создать вебхук
+// @Description Подписывает URL на события задач: task.created, task.updated, task.deleted или * для всех. Каждый запрос подписывается заголовком X-Signature: sha256=HMAC-SHA256(secret, body). Если секрет не передан, он генерируется и возвращается только в этом ответе
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body models.Webhook true "Webhook data"
+// @Success 201 {object} models.Webhook
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/webhooks/ [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	var webhook models.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid webhook data"})
+		return
+	}
+
+	if err := validateWebhook(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if webhook.Secret == "" {
+		secret, err := generateSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot generate secret"})
+			return
+		}
+		webhook.Secret = secret
+	}
+
+	webhook.UserID = userID.(int)
+	webhook.Active = true
+
+	if err := h.Repo.CreateWebhook(&webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// UpdateWebhook godoc
+// @Summary Обновить вебхук
+// @Description Обновляет URL, события и флаг active. Пустой секрет оставляет прежний. Повторное включение сбрасывает счетчик ошибок
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param webhook body models.Webhook true "Webhook data"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	webhookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid webhook ID"})
+		return
+	}
+
+	var webhook models.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid webhook data"})
+		return
+	}
+
+	if err := validateWebhook(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	existing, err := h.Repo.GetWebhookByID(webhookID, userID.(int))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "webhook not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get webhook"})
+		return
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret = existing.Secret
+	}
+	webhook.ID = webhookID
+	webhook.UserID = userID.(int)
+
+	if err := h.Repo.UpdateWebhook(&webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot update webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "webhook updated successfully"})
+}
+
+// DeleteWebhook godoc
+// @Summary Удалить вебхук
+// @Description Удаляет вебхук вместе с журналом доставок
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} MessageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	webhookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid webhook ID"})
+		return
+	}
+
+	if err := h.Repo.DeleteWebhook(webhookID, userID.(int)); err != nil {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "cannot delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, MessageResponse{Message: "webhook deleted successfully"})
+}
+
+// GetDeliveries godoc
+// @Summary Журнал доставок вебхука
+// @Description Получает последние доставки вебхука: статус (pending, delivered, failed или skipped, если задачу удалили до отправки), число попыток, код ответа и последнюю ошибку. Содержимое заполняется при первой отправке
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param limit query int false "Max deliveries, 50 by default, 200 at most"
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	webhookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid webhook ID"})
+		return
+	}
+
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > 200 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid limit"})
+			return
+		}
+	}
+
+	deliveries, err := h.Repo.GetDeliveriesByWebhookID(webhookID, userID.(int), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot get deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Redeliver godoc
+// @Summary Повторить доставку
+// @Description Ставит в очередь копию доставки с тем же содержимым, исходная запись остается в журнале
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param deliveryID path int true "Delivery ID"
+// @Success 202 {object} models.WebhookDelivery
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/webhooks/{id}/deliveries/{deliveryID}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unauthorized"})
+		return
+	}
+
+	webhookID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid webhook ID"})
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(c.Param("deliveryID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "invalid delivery ID"})
+		return
+	}
+
+	delivery, err := h.Repo.Redeliver(deliveryID, webhookID, userID.(int))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, ErrorResponse{Error: "delivery not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{Error: "cannot redeliver"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+func validateWebhook(webhook *models.Webhook) error {
+	if err := validate.Struct(webhook); err != nil {
+		return err
+	}
+
+	// receivers must be public http(s) urls, validator's url accepts any scheme.
+	// Names are checked again when the deliveries are sent.
+	if err := netguard.CheckURL(webhook.URL); err != nil {
+		return errors.New("url " + err.Error())
+	}
+
+	return nil
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
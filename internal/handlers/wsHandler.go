@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/realtime"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	// CORS is open for the REST API as well
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+type WebSocketHandler struct {
+	Hub  *realtime.Hub
+	Auth *service.AuthService
+}
+
+func NewWebSocketHandler(hub *realtime.Hub, auth *service.AuthService) *WebSocketHandler {
+	return &WebSocketHandler{Hub: hub, Auth: auth}
+}
+
+// Connect godoc
+// @Summary WebSocket для совместной работы
+// @Description Открывает WebSocket. Токен передается в заголовке Authorization или в параметре access_token, так как браузеры не умеют задавать заголовки. Сообщения: subscribe, unsubscribe, typing, task.create, task.update, task.delete, ping
+// @Tags realtime
+// @Param access_token query string false "JWT access token"
+// @Success 101
+// @Failure 401 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /api/v1/ws [get]
+func (h *WebSocketHandler) Connect(c *gin.Context) {
+	token := c.Query("access_token")
+	if header := c.GetHeader("Authorization"); header != "" {
+		parts := strings.Split(header, " ")
+		if len(parts) != 2 {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "wrong token format"})
+			return
+		}
+		token = parts[1]
+	}
+
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "need token"})
+		return
+	}
+
+	claims, err := h.Auth.Authenticate(token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has already replied
+		return
+	}
+
+	h.Hub.Serve(conn, claims.ID, claims.Username, claims.SessionID)
+}
+
+// taskMutator lets the WebSocket API reuse the task service, its errors
+// carry the HTTP status the realtime protocol reports
+type taskMutator struct {
+	h *TaskHandler
+}
+
+// Mutator exposes the task operations to the WebSocket API
+func (h *TaskHandler) Mutator() realtime.Mutator {
+	return taskMutator{h: h}
+}
+
+func (m taskMutator) CreateTask(userID int, task *models.Task) error {
+	return asTaskError(m.h.Service.Create(userID, task))
+}
+
+func (m taskMutator) UpdateTask(userID int, taskID int, task *models.Task) error {
+	return asTaskError(m.h.Service.Update(userID, taskID, task))
+}
+
+func (m taskMutator) DeleteTask(userID int, taskID int) error {
+	return asTaskError(m.h.Service.Delete(userID, taskID))
+}
+
+// asTaskError attaches the HTTP status to an error of the task service
+func asTaskError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TaskError{Status: errorStatus(err), Message: err.Error()}
+}
@@ -0,0 +1,376 @@
+// Package ical reads and writes the subset of iCalendar (RFC 5545) needed to
+// exchange tasks as VTODO components.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+const (
+	dateTimeLayout    = "20060102T150405Z"
+	localTimeLayout   = "20060102T150405"
+	dateLayout        = "20060102"
+	productID         = "-//TODO API//Tasks//EN"
+	projectProperty   = "X-TODO-PROJECT"
+	maxLineOctets     = 75
+	ContentType       = "text/calendar; charset=utf-8"
+	TodoContentType   = "text/calendar; charset=utf-8; component=vtodo"
+	defaultPriority   = 5
+	highestPriorities = 4
+)
+
+var ErrNoTodo = errors.New("no VTODO in the calendar")
+
+// Todo is a task as a VTODO. UID identifies it across clients, RelatedTo is the UID of the parent task.
+type Todo struct {
+	UID          string
+	Summary      string
+	Description  string
+	Status       string
+	Priority     int
+	Due          *time.Time
+	RRule        string
+	Categories   []string
+	Project      string
+	RelatedTo    string
+	Created      time.Time
+	LastModified time.Time
+	Completed    *time.Time
+}
+
+var statusToICal = map[string]string{"pending": "NEEDS-ACTION", "in_progress": "IN-PROCESS", "completed": "COMPLETED"}
+
+var priorityToICal = map[string]int{"high": 1, "medium": 5, "low": 9}
+
+// FromTask describes the task as a VTODO
+func FromTask(task *models.Task, uid string, parentUID string) Todo {
+	todo := Todo{
+		UID:          uid,
+		Summary:      task.Title,
+		Description:  task.Description,
+		Status:       statusToICal[task.Status],
+		Priority:     priorityToICal[task.Priority],
+		Due:          task.DueAt,
+		RRule:        task.Recurrence,
+		Categories:   task.Tags,
+		Project:      task.Project,
+		RelatedTo:    parentUID,
+		Created:      task.Created_at,
+		LastModified: task.Updated_at,
+	}
+	if task.Status == "completed" {
+		completed := task.Updated_at
+		todo.Completed = &completed
+	}
+	return todo
+}
+
+// ApplyTo copies the VTODO onto the task. Fields iCalendar has no place for are left alone.
+func (t *Todo) ApplyTo(task *models.Task) {
+	task.Title = t.Summary
+	task.Description = t.Description
+	task.DueAt = t.Due
+	task.Recurrence = t.RRule
+	task.Tags = t.Categories
+	if t.Project != "" {
+		task.Project = t.Project
+	}
+
+	switch t.Status {
+	case "COMPLETED", "CANCELLED":
+		task.Status = "completed"
+	case "IN-PROCESS":
+		task.Status = "in_progress"
+	default:
+		task.Status = "pending"
+	}
+
+	switch {
+	case t.Priority == 0 || t.Priority == defaultPriority:
+		task.Priority = "medium"
+	case t.Priority <= highestPriorities:
+		task.Priority = "high"
+	default:
+		task.Priority = "low"
+	}
+}
+
+// Writer writes a VCALENDAR holding any number of VTODOs
+type Writer struct {
+	w   *bufio.Writer
+	now time.Time
+}
+
+func NewWriter(w io.Writer, name string) (*Writer, error) {
+	cw := &Writer{w: bufio.NewWriter(w), now: time.Now().UTC()}
+	cw.line("BEGIN:VCALENDAR")
+	cw.line("VERSION:2.0")
+	cw.line("PRODID:" + productID)
+	cw.line("CALSCALE:GREGORIAN")
+	if name != "" {
+		cw.line("X-WR-CALNAME:" + escape(name))
+	}
+	return cw, cw.w.Flush()
+}
+
+func (cw *Writer) WriteTodo(t Todo) error {
+	cw.line("BEGIN:VTODO")
+	cw.line("UID:" + escape(t.UID))
+	cw.line("DTSTAMP:" + cw.now.Format(dateTimeLayout))
+	if !t.Created.IsZero() {
+		cw.line("CREATED:" + t.Created.UTC().Format(dateTimeLayout))
+	}
+	if !t.LastModified.IsZero() {
+		cw.line("LAST-MODIFIED:" + t.LastModified.UTC().Format(dateTimeLayout))
+	}
+	cw.line("SUMMARY:" + escape(t.Summary))
+	if t.Description != "" {
+		cw.line("DESCRIPTION:" + escape(t.Description))
+	}
+	if t.Status != "" {
+		cw.line("STATUS:" + t.Status)
+	}
+	if t.Priority != 0 {
+		cw.line("PRIORITY:" + strconv.Itoa(t.Priority))
+	}
+	if t.Due != nil {
+		cw.line("DUE:" + t.Due.UTC().Format(dateTimeLayout))
+	}
+	if t.Completed != nil {
+		cw.line("COMPLETED:" + t.Completed.UTC().Format(dateTimeLayout))
+	}
+	if t.RRule != "" {
+		cw.line("RRULE:" + t.RRule)
+	}
+	if len(t.Categories) > 0 {
+		escaped := make([]string, len(t.Categories))
+		for i, c := range t.Categories {
+			escaped[i] = escape(c)
+		}
+		cw.line("CATEGORIES:" + strings.Join(escaped, ","))
+	}
+	if t.Project != "" {
+		cw.line(projectProperty + ":" + escape(t.Project))
+	}
+	if t.RelatedTo != "" {
+		cw.line("RELATED-TO;RELTYPE=PARENT:" + escape(t.RelatedTo))
+	}
+	cw.line("END:VTODO")
+	return cw.w.Flush()
+}
+
+func (cw *Writer) Close() error {
+	cw.line("END:VCALENDAR")
+	return cw.w.Flush()
+}
+
+// line writes a content line folded at 75 octets without splitting UTF-8 sequences
+func (cw *Writer) line(s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		cw.w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// the leading space of a continuation counts too
+		limit = maxLineOctets - 1
+	}
+	cw.w.WriteString(s + "\r\n")
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// splitList splits a comma separated value, honouring escaped commas
+func splitList(s string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			items = append(items, unescape(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, unescape(s[start:]))
+}
+
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ParseTodo reads the first VTODO of a calendar. Floating times are taken in
+// loc, the due time is returned in UTC.
+func ParseTodo(r io.Reader, loc *time.Location) (*Todo, error) {
+	props, err := readTodoProperties(r)
+	if err != nil {
+		return nil, err
+	}
+
+	todo := &Todo{}
+	for _, p := range props {
+		switch p.name {
+		case "UID":
+			todo.UID = unescape(p.value)
+		case "SUMMARY":
+			todo.Summary = unescape(p.value)
+		case "DESCRIPTION":
+			todo.Description = unescape(p.value)
+		case "STATUS":
+			todo.Status = strings.ToUpper(p.value)
+		case "PRIORITY":
+			todo.Priority, _ = strconv.Atoi(p.value)
+		case "DUE":
+			due, err := parseTime(p, loc)
+			if err != nil {
+				return nil, err
+			}
+			due = due.UTC()
+			todo.Due = &due
+		case "RRULE":
+			todo.RRule = p.value
+		case "CATEGORIES":
+			for _, c := range splitList(p.value) {
+				if c = strings.TrimSpace(c); c != "" {
+					todo.Categories = append(todo.Categories, c)
+				}
+			}
+		case projectProperty:
+			todo.Project = unescape(p.value)
+		case "RELATED-TO":
+			if rel := p.params["RELTYPE"]; rel == "" || rel == "PARENT" {
+				todo.RelatedTo = unescape(p.value)
+			}
+		}
+	}
+
+	return todo, nil
+}
+
+func readTodoProperties(r io.Reader) ([]property, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// folded lines continue with a space or a tab
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var props []property
+	depth, inTodo := 0, false
+	for _, line := range lines {
+		p, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VTODO") && !inTodo:
+			inTodo, depth = true, 0
+		case !inTodo:
+		case p.name == "BEGIN":
+			// VALARM and other nested components are not ours
+			depth++
+		case p.name == "END" && depth > 0:
+			depth--
+		case p.name == "END":
+			return props, nil
+		case depth == 0:
+			props = append(props, p)
+		}
+	}
+
+	if !inTodo {
+		return nil, ErrNoTodo
+	}
+	return nil, errors.New("VTODO is not closed")
+}
+
+func parseLine(line string) (property, bool) {
+	colon := -1
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		if line[i] == '"' {
+			quoted = !quoted
+		} else if line[i] == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, false
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	p := property{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		p.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+
+	return p, true
+}
+
+func parseTime(p property, loc *time.Location) (time.Time, error) {
+	value := p.value
+
+	if p.params["VALUE"] == "DATE" || len(value) == len(dateLayout) {
+		return time.ParseInLocation(dateLayout, value, loc)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(dateTimeLayout, value)
+	}
+	if tzid := p.params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			loc = tz
+		}
+	}
+
+	t, err := time.ParseInLocation(localTimeLayout, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q", p.name, value)
+	}
+	return t, nil
+}
@@ -0,0 +1,217 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeRoundTrip(t *testing.T) {
+	tests := []struct {
+		value   string
+		escaped string
+	}{
+		{"plain", "plain"},
+		{"a,b;c", `a\,b\;c`},
+		{`C:\temp`, `C:\\temp`},
+		{"two\nlines", `two\nlines`},
+		{"windows\r\nlines", `windows\nlines`},
+		{`\n is not a newline`, `\\n is not a newline`},
+	}
+
+	for _, tt := range tests {
+		if got := escape(tt.value); got != tt.escaped {
+			t.Errorf("escape(%q) = %q, want %q", tt.value, got, tt.escaped)
+		}
+		want := strings.ReplaceAll(tt.value, "\r\n", "\n")
+		if got := unescape(escape(tt.value)); got != want {
+			t.Errorf("unescape(escape(%q)) = %q, want %q", tt.value, got, want)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(`home,work\,office,a\;b`)
+	want := []string{"home", "work,office", "a;b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitList = %q, want %q", got, want)
+	}
+}
+
+func TestWriteFoldsLines(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo := Todo{
+		UID:         "task-1@todo-api",
+		Summary:     strings.Repeat("Длинная задача, ", 10),
+		Description: strings.Repeat("x", 200),
+	}
+	if err := w.WriteTodo(todo); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	output := buf.String()
+	if !strings.HasSuffix(output, "END:VCALENDAR\r\n") {
+		t.Errorf("calendar does not end with END:VCALENDAR and CRLF: %q", output[len(output)-20:])
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("folding split a character: %q", line)
+		}
+	}
+}
+
+func TestWriteParseRoundTrip(t *testing.T) {
+	due := time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC)
+	todo := Todo{
+		UID:         "task-42@todo-api",
+		Summary:     `Call mom; ask about "the garden", bring C:\photos`,
+		Description: strings.Repeat("Первая строка, с запятой;\nвторая строка. ", 8),
+		Status:      "IN-PROCESS",
+		Priority:    1,
+		Due:         &due,
+		RRule:       "FREQ=WEEKLY;BYDAY=MO,TH",
+		Categories:  []string{"family", "to,do", "a;b"},
+		Project:     "Home, garden",
+		RelatedTo:   "task-41@todo-api",
+		Created:     due.Add(-48 * time.Hour),
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteTodo(todo); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseTodo(&buf, time.UTC)
+	if err != nil {
+		t.Fatalf("ParseTodo() error = %v", err)
+	}
+	// ParseTodo does not read the timestamps the server sets
+	todo.Created = time.Time{}
+	if !reflect.DeepEqual(*got, todo) {
+		t.Errorf("parsed todo =\n%+v\nwant\n%+v", *got, todo)
+	}
+}
+
+// a task as Apple Reminders saves it: folded lines, a TZID, an alarm and an
+// X- property the server does not know
+const clientTodo = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Apple Inc.//iOS 17.4//EN\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Europe/Moscow\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VTODO\r\n" +
+	"UID:8C2D3F1A-6E4B-4B7A-9F0E-3B1D2C4E5F60\r\n" +
+	"DTSTAMP:20261014T120000Z\r\n" +
+	"SUMMARY:Buy milk\\, bread\r\n" +
+	"  and eggs\r\n" +
+	"DESCRIPTION:From the shop\\non the corner\r\n" +
+	"DUE;TZID=Europe/Moscow:20261020T183000\r\n" +
+	"STATUS:needs-action\r\n" +
+	"PRIORITY:9\r\n" +
+	"CATEGORIES:shop,home\r\n" +
+	"RELATED-TO;RELTYPE=SIBLING:ignored-uid\r\n" +
+	"X-APPLE-SORT-ORDER:724345823\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"DESCRIPTION:Reminder\r\n" +
+	"TRIGGER:-PT15M\r\n" +
+	"END:VALARM\r\n" +
+	"END:VTODO\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseClientTodo(t *testing.T) {
+	got, err := ParseTodo(strings.NewReader(clientTodo), time.UTC)
+	if err != nil {
+		t.Fatalf("ParseTodo() error = %v", err)
+	}
+
+	due := time.Date(2026, 10, 20, 15, 30, 0, 0, time.UTC)
+	want := Todo{
+		UID:         "8C2D3F1A-6E4B-4B7A-9F0E-3B1D2C4E5F60",
+		Summary:     "Buy milk, bread and eggs",
+		Description: "From the shop\non the corner",
+		Status:      "NEEDS-ACTION",
+		Priority:    9,
+		Due:         &due,
+		Categories:  []string{"shop", "home"},
+	}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("parsed todo =\n%+v\nwant\n%+v", *got, want)
+	}
+	if got.Due.Location() != time.UTC {
+		t.Errorf("due is in %v, want UTC", got.Due.Location())
+	}
+}
+
+func TestParseTimes(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		line string
+		want time.Time
+	}{
+		{"DUE:20261020T090000Z", time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)},
+		{"DUE:20261020T090000", time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC)},
+		{"DUE;VALUE=DATE:20261020", time.Date(2026, 10, 19, 21, 0, 0, 0, time.UTC)},
+		{"DUE;TZID=America/New_York:20261020T090000", time.Date(2026, 10, 20, 13, 0, 0, 0, time.UTC)},
+		{"DUE;TZID=Nowhere/Special:20261020T090000", time.Date(2026, 10, 20, 6, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		calendar := "BEGIN:VTODO\r\nUID:1\r\n" + tt.line + "\r\nEND:VTODO\r\n"
+		got, err := ParseTodo(strings.NewReader(calendar), moscow)
+		if err != nil {
+			t.Errorf("%s: %v", tt.line, err)
+			continue
+		}
+		if !got.Due.Equal(tt.want) || got.Due.Location() != time.UTC {
+			t.Errorf("%s = %v, want %v", tt.line, got.Due, tt.want)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar string
+	}{
+		{"no todo", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:1\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{"not closed", "BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:1\r\n"},
+		{"bad due", "BEGIN:VTODO\r\nUID:1\r\nDUE:tomorrow\r\nEND:VTODO\r\n"},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTodo(strings.NewReader(tt.calendar), time.UTC); err == nil {
+				t.Error("ParseTodo() accepted a malformed calendar")
+			}
+		})
+	}
+
+	if _, err := ParseTodo(strings.NewReader(""), time.UTC); !errors.Is(err, ErrNoTodo) {
+		t.Errorf("empty calendar error = %v, want ErrNoTodo", err)
+	}
+}
@@ -0,0 +1,94 @@
+// Package importers turns exports of other task managers into task trees.
+// Every format is an Importer registered under a name, adding a format is a
+// matter of a new file with its own Register call.
+package importers
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/quickadd"
+)
+
+// Result holds the tasks to create and what was left out of them
+type Result struct {
+	Tasks   []repository.TaskTree
+	Skipped []Skipped
+}
+
+// Skipped is an item of the source that was not imported, or imported only in part
+type Skipped struct {
+	Item   string `json:"item"`
+	Reason string `json:"reason"`
+}
+
+func (r *Result) Skip(item string, format string, args ...interface{}) {
+	r.Skipped = append(r.Skipped, Skipped{Item: item, Reason: fmt.Sprintf(format, args...)})
+}
+
+// Importer parses one export format. now resolves relative dates and
+// recurrence phrases, it should be in the user's timezone.
+type Importer interface {
+	Parse(r io.Reader, now time.Time) (*Result, error)
+}
+
+var registry = make(map[string]Importer)
+
+func Register(name string, importer Importer) {
+	if _, dup := registry[name]; dup {
+		panic("importers: " + name + " is registered twice")
+	}
+	registry[name] = importer
+}
+
+func Get(name string) (Importer, bool) {
+	importer, ok := registry[name]
+	return importer, ok
+}
+
+// Names lists the registered formats in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// parseDate reads the date forms the exports use, floating times are taken in
+// loc. The result is in UTC, the way task times are stored.
+func parseDate(value string, loc *time.Location) (*time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			t = t.UTC()
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown date %q", value)
+}
+
+// parseRecurrence understands phrases like "every monday" through the quick-add parser
+func parseRecurrence(phrase string, now time.Time) (string, bool) {
+	// the parser needs something to keep as the title
+	parsed, err := quickadd.Parse("task "+phrase, now)
+	if err != nil || parsed.Recurrence == "" {
+		return "", false
+	}
+	return parsed.Recurrence, true
+}
+
+// tag makes a label fit the tag rules: no spaces, at most 50 characters
+func tag(label string) string {
+	label = strings.Join(strings.Fields(label), "-")
+	if runes := []rune(label); len(runes) > 50 {
+		label = string(runes[:50])
+	}
+	return label
+}
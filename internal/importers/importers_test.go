@@ -0,0 +1,66 @@
+package importers
+
+import (
+	"testing"
+	"time"
+)
+
+// now is a Moscow afternoon, so floating dates in the fixtures are three hours ahead of UTC
+var now = time.Date(2026, 10, 14, 15, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+func utc(year int, month time.Month, day, hour, min int) *time.Time {
+	t := time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	return &t
+}
+
+func sameTime(got *time.Time, want *time.Time) bool {
+	if got == nil || want == nil {
+		return got == want
+	}
+	return got.Equal(*want) && got.Location() == time.UTC
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  *time.Time
+	}{
+		{"2026-10-20", utc(2026, time.October, 19, 21, 0)},
+		{"2026-10-20T09:30", utc(2026, time.October, 20, 6, 30)},
+		{"2026-10-20T09:30:00", utc(2026, time.October, 20, 6, 30)},
+		{"2026-10-20T09:30:00Z", utc(2026, time.October, 20, 9, 30)},
+		{"2026-10-20T09:30:00-04:00", utc(2026, time.October, 20, 13, 30)},
+		{"20.10.2026", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDate(tt.value, now.Location())
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("parseDate(%q) = %v, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || !sameTime(got, tt.want) {
+			t.Errorf("parseDate(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestTag(t *testing.T) {
+	tests := []struct {
+		label string
+		want  string
+	}{
+		{"work", "work"},
+		{"  To   Do ", "To-Do"},
+		{"абвгдеёжзийклмнопрстуфхцчшщъыьэюяабвгдеёжзийклмнопрстуфхцчшщъыьэюя", "абвгдеёжзийклмнопрстуфхцчшщъыьэюяабвгдеёжзийклмноп"},
+	}
+
+	for _, tt := range tests {
+		if got := tag(tt.label); got != tt.want {
+			t.Errorf("tag(%q) = %q, want %q", tt.label, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+)
+
+func init() {
+	Register("todoist", todoist{})
+}
+
+// todoist reads the JSON backup of the Todoist sync API. Sub-tasks become
+// children, labels become tags and the project name is kept as the project.
+type todoist struct{}
+
+type todoistBackup struct {
+	Projects []struct {
+		ID   todoistID `json:"id"`
+		Name string    `json:"name"`
+	} `json:"projects"`
+	Items []todoistItem `json:"items"`
+	// newer exports call the items tasks
+	Tasks []todoistItem `json:"tasks"`
+}
+
+type todoistItem struct {
+	ID          todoistID `json:"id"`
+	Content     string    `json:"content"`
+	Description string    `json:"description"`
+	ProjectID   todoistID `json:"project_id"`
+	ParentID    todoistID `json:"parent_id"`
+	Labels      []string  `json:"labels"`
+	Priority    int       `json:"priority"`
+	Checked     bool      `json:"checked"`
+	IsDeleted   bool      `json:"is_deleted"`
+	Due         *struct {
+		Date        string `json:"date"`
+		String      string `json:"string"`
+		IsRecurring bool   `json:"is_recurring"`
+	} `json:"due"`
+}
+
+// todoistID is a numeric id in older backups and an opaque string in newer ones
+type todoistID string
+
+func (id *todoistID) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		*id = ""
+	case string:
+		*id = todoistID(v)
+	case json.Number:
+		*id = todoistID(v.String())
+	default:
+		return fmt.Errorf("todoist id must be a string or a number, got %s", data)
+	}
+	return nil
+}
+
+func (id todoistID) String() string {
+	return string(id)
+}
+
+// Todoist priorities run from 1 (none) to 4 (urgent)
+var todoistPriorities = map[int]string{1: "low", 2: "low", 3: "medium", 4: "high"}
+
+func (todoist) Parse(r io.Reader, now time.Time) (*Result, error) {
+	var backup todoistBackup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, err
+	}
+
+	items := append(backup.Items, backup.Tasks...)
+
+	projects := make(map[string]string, len(backup.Projects))
+	for _, p := range backup.Projects {
+		projects[p.ID.String()] = p.Name
+	}
+
+	result := &Result{}
+	nodes := make(map[string]*repository.TaskTree, len(items))
+	var order []string
+
+	for _, item := range items {
+		name := strings.TrimSpace(item.Content)
+		if item.IsDeleted {
+			result.Skip(name, "deleted in Todoist")
+			continue
+		}
+
+		task := models.Task{
+			Title:       name,
+			Description: item.Description,
+			Status:      "pending",
+			Priority:    todoistPriorities[item.Priority],
+			Project:     projects[item.ProjectID.String()],
+		}
+		if item.Checked {
+			task.Status = "completed"
+		}
+		for _, label := range item.Labels {
+			task.Tags = append(task.Tags, tag(label))
+		}
+
+		if item.Due != nil && item.Due.Date != "" {
+			due, err := parseDate(item.Due.Date, now.Location())
+			if err != nil {
+				result.Skip(name, "due date %q is not understood, imported without it", item.Due.Date)
+			} else {
+				task.DueAt = due
+			}
+
+			if item.Due.IsRecurring {
+				if rule, ok := parseRecurrence(item.Due.String, now); ok {
+					task.Recurrence = rule
+				} else {
+					result.Skip(name, "recurrence %q is not understood, imported as a one-off task", item.Due.String)
+				}
+			}
+		}
+
+		id := item.ID.String()
+		nodes[id] = &repository.TaskTree{Task: task}
+		order = append(order, id)
+	}
+
+	// children are attached bottom-up, so a parent gets them before it is copied into its own parent
+	parents := make(map[string]string, len(order))
+	for _, item := range items {
+		if _, ok := nodes[item.ID.String()]; ok && item.ParentID.String() != "" {
+			parents[item.ID.String()] = item.ParentID.String()
+		}
+	}
+
+	var roots []string
+	for _, id := range order {
+		parent, hasParent := parents[id]
+		if !hasParent {
+			roots = append(roots, id)
+			continue
+		}
+		if _, ok := nodes[parent]; !ok {
+			result.Skip(nodes[id].Task.Title, "parent task is not in the backup, imported at the top level")
+			delete(parents, id)
+			roots = append(roots, id)
+		}
+	}
+
+	result.Tasks = buildTrees(result, roots, order, parents, nodes)
+	return result, nil
+}
+
+// buildTrees links the flat nodes along parents, keeping the source order.
+// Nodes whose parents point at each other are never reached and get reported.
+func buildTrees(result *Result, roots []string, order []string, parents map[string]string, nodes map[string]*repository.TaskTree) []repository.TaskTree {
+	children := make(map[string][]string)
+	for _, id := range order {
+		if parent, ok := parents[id]; ok {
+			children[parent] = append(children[parent], id)
+		}
+	}
+
+	reached := make(map[string]bool, len(order))
+	var build func(ids []string) []repository.TaskTree
+	build = func(ids []string) []repository.TaskTree {
+		trees := make([]repository.TaskTree, 0, len(ids))
+		for _, id := range ids {
+			reached[id] = true
+			node := *nodes[id]
+			node.Children = build(children[id])
+			trees = append(trees, node)
+		}
+		return trees
+	}
+	trees := build(roots)
+
+	for _, id := range order {
+		if !reached[id] {
+			result.Skip(nodes[id].Task.Title, "its parent tasks form a cycle")
+		}
+	}
+
+	return trees
+}
@@ -0,0 +1,125 @@
+package importers
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// a trimmed Todoist backup: the second project, a subtask, a recurring task, a
+// deleted one, an orphan and a pair of tasks that are each other's parent
+const todoistBackupFixture = `{
+	"projects": [{"id": "2203306141", "name": "Inbox"}, {"id": "2203306142", "name": "Home"}],
+	"items": [
+		{"id": "6X7rM8997g3RQmvh", "content": "Pay rent", "description": "Transfer to the landlord", "project_id": "2203306142", "priority": 4, "labels": ["money", "to do"], "due": {"date": "2026-10-20T09:00:00", "string": "every month", "is_recurring": true}},
+		{"id": "6X7rfFVPjhvv84XG", "content": "Find the contract", "project_id": "2203306142", "parent_id": "6X7rM8997g3RQmvh", "priority": 1, "checked": true},
+		{"id": "6X7rfEVP8hvv25ZQ", "content": "Water plants", "project_id": "2203306141", "priority": 3, "due": {"date": "2026-10-21", "string": "every second full moon", "is_recurring": true}},
+		{"id": "6X7rfEVP8hvv25ZR", "content": "Old task", "project_id": "2203306141", "is_deleted": true},
+		{"id": "6X7rfEVP8hvv25ZS", "content": "Orphan", "project_id": "2203306141", "parent_id": "gone", "priority": 2, "due": {"date": "tomorrow"}}
+	],
+	"tasks": [
+		{"id": 101, "content": "Loop A", "parent_id": 102, "priority": 2},
+		{"id": 102, "content": "Loop B", "parent_id": 101, "priority": 2}
+	]
+}`
+
+func TestTodoistParse(t *testing.T) {
+	result, err := todoist{}.Parse(strings.NewReader(todoistBackupFixture), now)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if len(result.Tasks) != 3 {
+		t.Fatalf("got %d root tasks, want 3", len(result.Tasks))
+	}
+
+	rent := result.Tasks[0]
+	if rent.Task.Title != "Pay rent" || rent.Task.Description != "Transfer to the landlord" || rent.Task.Project != "Home" {
+		t.Errorf("rent = %+v", rent.Task)
+	}
+	if rent.Task.Priority != "high" || rent.Task.Status != "pending" || rent.Task.Recurrence != "FREQ=MONTHLY" {
+		t.Errorf("rent priority %q, status %q, recurrence %q", rent.Task.Priority, rent.Task.Status, rent.Task.Recurrence)
+	}
+	if !reflect.DeepEqual(rent.Task.Tags, []string{"money", "to-do"}) {
+		t.Errorf("rent tags = %v", rent.Task.Tags)
+	}
+	if !sameTime(rent.Task.DueAt, utc(2026, time.October, 20, 6, 0)) {
+		t.Errorf("rent due = %v, want 06:00 UTC", rent.Task.DueAt)
+	}
+	if len(rent.Children) != 1 || rent.Children[0].Task.Title != "Find the contract" || rent.Children[0].Task.Status != "completed" || rent.Children[0].Task.Priority != "low" {
+		t.Errorf("rent children = %+v", rent.Children)
+	}
+
+	plants := result.Tasks[1].Task
+	if plants.Priority != "medium" || plants.Project != "Inbox" || plants.Recurrence != "" {
+		t.Errorf("plants = %+v", plants)
+	}
+
+	orphan := result.Tasks[2].Task
+	if orphan.Title != "Orphan" || orphan.DueAt != nil {
+		t.Errorf("orphan = %+v", orphan)
+	}
+
+	var reasons []string
+	for _, skipped := range result.Skipped {
+		reasons = append(reasons, skipped.Item+": "+skipped.Reason)
+	}
+	want := []string{
+		`Water plants: recurrence "every second full moon" is not understood, imported as a one-off task`,
+		"Old task: deleted in Todoist",
+		`Orphan: due date "tomorrow" is not understood, imported without it`,
+		"Orphan: parent task is not in the backup, imported at the top level",
+		"Loop A: its parent tasks form a cycle",
+		"Loop B: its parent tasks form a cycle",
+	}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("skipped =\n%s\nwant\n%s", strings.Join(reasons, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTodoistPriorities(t *testing.T) {
+	tests := []struct {
+		priority int
+		want     string
+	}{
+		{0, ""},
+		{1, "low"},
+		{2, "low"},
+		{3, "medium"},
+		{4, "high"},
+		{5, ""},
+	}
+
+	for _, tt := range tests {
+		backup := `{"items": [{"id": "1", "content": "Task", "priority": ` + strconv.Itoa(tt.priority) + `}]}`
+		result, err := todoist{}.Parse(strings.NewReader(backup), now)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if got := result.Tasks[0].Task.Priority; got != tt.want {
+			t.Errorf("priority %d = %q, want %q", tt.priority, got, tt.want)
+		}
+	}
+}
+
+func TestTodoistParseMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		backup string
+	}{
+		{"not json", "project,content\nInbox,Task"},
+		{"truncated", `{"items": [{"id": "1"`},
+		{"wrong type", `{"items": {"id": "1"}}`},
+		{"object id", `{"items": [{"id": {"v2": "1"}, "content": "Task"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (todoist{}).Parse(strings.NewReader(tt.backup), now); err == nil {
+				t.Error("Parse() accepted a malformed backup")
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package importers
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+)
+
+func init() {
+	Register("todotxt", todoTxt{})
+}
+
+// todoTxt reads a todo.txt file, one task per line:
+// "x 2024-05-02 2024-05-01 (A) Call mom +family @phone due:2024-05-03 rec:1w".
+// Contexts become tags and the first project the project. The format has no
+// subtasks, so every task is a root.
+type todoTxt struct{}
+
+var (
+	todoTxtDate     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	todoTxtPriority = regexp.MustCompile(`^\(([A-Z])\)$`)
+	todoTxtKeyValue = regexp.MustCompile(`^([^\s:]+):([^\s:/][^\s]*)$`)
+	todoTxtRec      = regexp.MustCompile(`^\+?(\d+)([dwmy])$`)
+)
+
+var todoTxtFrequencies = map[string]string{"d": "DAILY", "w": "WEEKLY", "m": "MONTHLY", "y": "YEARLY"}
+
+func todoTxtPriorityOf(letter string) string {
+	switch letter {
+	case "A":
+		return "high"
+	case "B":
+		return "medium"
+	}
+	return "low"
+}
+
+func (todoTxt) Parse(r io.Reader, now time.Time) (*Result, error) {
+	result := &Result{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		task := models.Task{Status: "pending"}
+		words := strings.Fields(line)
+
+		if words[0] == "x" {
+			task.Status = "completed"
+			words = words[1:]
+			// completion and creation dates
+			for len(words) > 0 && todoTxtDate.MatchString(words[0]) {
+				words = words[1:]
+			}
+		}
+
+		if len(words) > 0 {
+			if m := todoTxtPriority.FindStringSubmatch(words[0]); m != nil {
+				task.Priority = todoTxtPriorityOf(m[1])
+				words = words[1:]
+			}
+		}
+
+		if len(words) > 0 && todoTxtDate.MatchString(words[0]) {
+			words = words[1:]
+		}
+
+		var title []string
+		for _, word := range words {
+			switch {
+			case len(word) > 1 && word[0] == '+':
+				if task.Project == "" {
+					task.Project = word[1:]
+				} else {
+					task.Tags = append(task.Tags, tag(word[1:]))
+				}
+			case len(word) > 1 && word[0] == '@':
+				task.Tags = append(task.Tags, tag(word[1:]))
+			default:
+				m := todoTxtKeyValue.FindStringSubmatch(word)
+				if m == nil {
+					title = append(title, word)
+					continue
+				}
+				applyTodoTxtTag(result, &task, line, m[1], m[2], now)
+			}
+		}
+
+		task.Title = strings.Join(title, " ")
+		result.Tasks = append(result.Tasks, repository.TaskTree{Task: task})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func applyTodoTxtTag(result *Result, task *models.Task, line string, key string, value string, now time.Time) {
+	switch key {
+	case "due", "t":
+		date, err := parseDate(value, now.Location())
+		if err != nil {
+			result.Skip(line, "%s:%s is not a date, dropped", key, value)
+			return
+		}
+		if key == "due" {
+			task.DueAt = date
+		} else {
+			// the threshold date hides the task until then, like a snooze
+			task.SnoozedUntil = date
+		}
+	case "pri":
+		task.Priority = todoTxtPriorityOf(strings.ToUpper(value))
+	case "rec":
+		m := todoTxtRec.FindStringSubmatch(value)
+		if m == nil {
+			result.Skip(line, "rec:%s is not supported, imported as a one-off task", value)
+			return
+		}
+		task.Recurrence = "FREQ=" + todoTxtFrequencies[m[2]]
+		if n, _ := strconv.Atoi(m[1]); n > 1 {
+			task.Recurrence += ";INTERVAL=" + m[1]
+		}
+	default:
+		result.Skip(line, "%s:%s has no matching field, dropped", key, value)
+	}
+}
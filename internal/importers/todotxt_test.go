@@ -0,0 +1,144 @@
+package importers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+func TestTodoTxtParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    models.Task
+		skipped int
+	}{
+		{
+			name: "plain",
+			line: "Call mom",
+			want: models.Task{Title: "Call mom", Status: "pending"},
+		},
+		{
+			name: "completed with dates",
+			line: "x 2026-10-02 2026-10-01 Call mom",
+			want: models.Task{Title: "Call mom", Status: "completed"},
+		},
+		{
+			name: "completed with priority",
+			line: "x 2026-10-02 (A) Pay rent",
+			want: models.Task{Title: "Pay rent", Status: "completed", Priority: "high"},
+		},
+		{
+			name: "priority and creation date",
+			line: "(B) 2026-10-01 Write report",
+			want: models.Task{Title: "Write report", Status: "pending", Priority: "medium"},
+		},
+		{
+			name: "low priority",
+			line: "(D) Water plants",
+			want: models.Task{Title: "Water plants", Status: "pending", Priority: "low"},
+		},
+		{
+			name: "pri tag",
+			line: "Water plants pri:a",
+			want: models.Task{Title: "Water plants", Status: "pending", Priority: "high"},
+		},
+		{
+			name: "projects and contexts",
+			line: "Call mom +family +weekly @phone @home",
+			want: models.Task{Title: "Call mom", Status: "pending", Project: "family", Tags: []string{"weekly", "phone", "home"}},
+		},
+		{
+			name: "due and threshold",
+			line: "Renew passport due:2026-10-20 t:2026-10-10",
+			want: models.Task{Title: "Renew passport", Status: "pending", DueAt: utc(2026, time.October, 19, 21, 0), SnoozedUntil: utc(2026, time.October, 9, 21, 0)},
+		},
+		{
+			name: "recurrence",
+			line: "Gym rec:2w",
+			want: models.Task{Title: "Gym", Status: "pending", Recurrence: "FREQ=WEEKLY;INTERVAL=2"},
+		},
+		{
+			name: "strict recurrence",
+			line: "Rent rec:+1m",
+			want: models.Task{Title: "Rent", Status: "pending", Recurrence: "FREQ=MONTHLY"},
+		},
+		{
+			name: "url is part of the title",
+			line: "Read https://example.com/post",
+			want: models.Task{Title: "Read https://example.com/post", Status: "pending"},
+		},
+		{
+			name:    "bad due date",
+			line:    "Renew passport due:soon",
+			want:    models.Task{Title: "Renew passport", Status: "pending"},
+			skipped: 1,
+		},
+		{
+			name:    "business days",
+			line:    "Standup rec:1b",
+			want:    models.Task{Title: "Standup", Status: "pending"},
+			skipped: 1,
+		},
+		{
+			name:    "unknown tag",
+			line:    "Standup id:42",
+			want:    models.Task{Title: "Standup", Status: "pending"},
+			skipped: 1,
+		},
+		{
+			name: "x without a space is a title",
+			line: "xylophone lessons",
+			want: models.Task{Title: "xylophone lessons", Status: "pending"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := todoTxt{}.Parse(strings.NewReader(tt.line+"\n"), now)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(result.Tasks) != 1 {
+				t.Fatalf("got %d tasks, want 1", len(result.Tasks))
+			}
+
+			got := result.Tasks[0].Task
+			if !sameTime(got.DueAt, tt.want.DueAt) || !sameTime(got.SnoozedUntil, tt.want.SnoozedUntil) {
+				t.Errorf("due %v, snoozed until %v, want %v and %v", got.DueAt, got.SnoozedUntil, tt.want.DueAt, tt.want.SnoozedUntil)
+			}
+			got.DueAt, got.SnoozedUntil = tt.want.DueAt, tt.want.SnoozedUntil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("task = %+v, want %+v", got, tt.want)
+			}
+			if len(result.Skipped) != tt.skipped {
+				t.Errorf("skipped = %v, want %d items", result.Skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestTodoTxtParseFile(t *testing.T) {
+	file := "(A) Call mom +family\r\n\n   \nx 2026-10-02 Buy milk @shop\n"
+
+	result, err := todoTxt{}.Parse(strings.NewReader(file), now)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(result.Tasks) != 2 {
+		t.Fatalf("got %d tasks, want 2 without the blank lines", len(result.Tasks))
+	}
+	if result.Tasks[0].Task.Title != "Call mom" || result.Tasks[1].Task.Title != "Buy milk" {
+		t.Errorf("titles = %q, %q", result.Tasks[0].Task.Title, result.Tasks[1].Task.Title)
+	}
+}
+
+func TestTodoTxtParseLongLine(t *testing.T) {
+	line := strings.Repeat("a", 2<<20)
+	if _, err := (todoTxt{}).Parse(strings.NewReader(line), now); err == nil {
+		t.Error("Parse() accepted a line over the buffer limit")
+	}
+}
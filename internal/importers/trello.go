@@ -0,0 +1,157 @@
+package importers
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+)
+
+func init() {
+	Register("trello", trello{})
+}
+
+// trello reads a board exported as JSON. The board name becomes the project,
+// list names and labels become tags, and checklist items become subtasks.
+type trello struct{}
+
+type trelloBoard struct {
+	Name  string `json:"name"`
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Cards []struct {
+		ID          string  `json:"id"`
+		Name        string  `json:"name"`
+		Desc        string  `json:"desc"`
+		IDList      string  `json:"idList"`
+		Due         *string `json:"due"`
+		DueComplete bool    `json:"dueComplete"`
+		Closed      bool    `json:"closed"`
+		Pos         float64 `json:"pos"`
+		Labels      []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"labels"`
+	} `json:"cards"`
+	Checklists []struct {
+		IDCard     string `json:"idCard"`
+		Name       string `json:"name"`
+		CheckItems []struct {
+			Name  string  `json:"name"`
+			State string  `json:"state"`
+			Pos   float64 `json:"pos"`
+			Due   *string `json:"due"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+}
+
+func (trello) Parse(r io.Reader, now time.Time) (*Result, error) {
+	var board trelloBoard
+	if err := json.NewDecoder(r).Decode(&board); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+
+	lists := make(map[string]string, len(board.Lists))
+	closedLists := make(map[string]bool)
+	for _, list := range board.Lists {
+		lists[list.ID] = list.Name
+		if list.Closed {
+			closedLists[list.ID] = true
+		}
+	}
+
+	cards := board.Cards
+	sort.SliceStable(cards, func(i, j int) bool { return cards[i].Pos < cards[j].Pos })
+
+	index := make(map[string]int, len(cards))
+
+	for _, card := range cards {
+		name := strings.TrimSpace(card.Name)
+		switch {
+		case card.Closed:
+			result.Skip(name, "card is archived")
+			continue
+		case closedLists[card.IDList]:
+			result.Skip(name, "list %q is archived", lists[card.IDList])
+			continue
+		}
+
+		task := models.Task{
+			Title:       name,
+			Description: card.Desc,
+			Status:      "pending",
+			Project:     board.Name,
+		}
+		if card.DueComplete {
+			task.Status = "completed"
+		}
+		if list := lists[card.IDList]; list != "" {
+			task.Tags = append(task.Tags, tag(list))
+		}
+		for _, label := range card.Labels {
+			switch {
+			case label.Name != "":
+				task.Tags = append(task.Tags, tag(label.Name))
+			case label.Color != "":
+				task.Tags = append(task.Tags, tag(label.Color))
+			}
+		}
+
+		if card.Due != nil {
+			due, err := parseDate(*card.Due, now.Location())
+			if err != nil {
+				result.Skip(name, "due date %q is not understood, imported without it", *card.Due)
+			} else {
+				task.DueAt = due
+			}
+		}
+
+		index[card.ID] = len(result.Tasks)
+		result.Tasks = append(result.Tasks, repository.TaskTree{Task: task})
+	}
+
+	for _, checklist := range board.Checklists {
+		i, ok := index[checklist.IDCard]
+		if !ok {
+			if len(checklist.CheckItems) > 0 {
+				result.Skip(checklist.Name, "checklist belongs to a card that is not imported")
+			}
+			continue
+		}
+
+		items := checklist.CheckItems
+		sort.SliceStable(items, func(a, b int) bool { return items[a].Pos < items[b].Pos })
+
+		for _, item := range items {
+			task := models.Task{
+				Title:   strings.TrimSpace(item.Name),
+				Status:  "pending",
+				Project: board.Name,
+			}
+			if item.State == "complete" {
+				task.Status = "completed"
+			}
+			if checklist.Name != "" {
+				task.Tags = []string{tag(checklist.Name)}
+			}
+			if item.Due != nil {
+				if due, err := parseDate(*item.Due, now.Location()); err == nil {
+					task.DueAt = due
+				}
+			}
+
+			result.Tasks[i].Children = append(result.Tasks[i].Children, repository.TaskTree{Task: task})
+		}
+	}
+
+	return result, nil
+}
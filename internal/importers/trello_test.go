@@ -0,0 +1,114 @@
+package importers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// a trimmed Trello board export with an archived list and card, labels with
+// and without names and checklists on imported and archived cards
+const trelloBoardFixture = `{
+	"name": "Flat move",
+	"lists": [
+		{"id": "l1", "name": "To Do", "closed": false},
+		{"id": "l2", "name": "Done", "closed": false},
+		{"id": "l3", "name": "Old ideas", "closed": true}
+	],
+	"cards": [
+		{"id": "c2", "name": "Book movers", "idList": "l1", "pos": 32768, "due": "2026-10-25T07:00:00.000Z", "labels": [{"name": "", "color": "red"}]},
+		{"id": "c1", "name": " Pack books ", "desc": "Start with the top shelf", "idList": "l1", "pos": 16384, "labels": [{"name": "Weekend", "color": "green"}]},
+		{"id": "c3", "name": "Cancel internet", "idList": "l2", "pos": 49152, "dueComplete": true, "due": "next week"},
+		{"id": "c4", "name": "Paint walls", "idList": "l1", "pos": 65536, "closed": true},
+		{"id": "c5", "name": "Buy a boat", "idList": "l3", "pos": 81920}
+	],
+	"checklists": [
+		{"idCard": "c1", "name": "Shelves", "checkItems": [
+			{"name": "Bottom", "state": "incomplete", "pos": 34000, "due": "2026-10-20T09:00:00"},
+			{"name": "Top", "state": "complete", "pos": 17000}
+		]},
+		{"idCard": "c4", "name": "Colors", "checkItems": [{"name": "White", "state": "incomplete", "pos": 1}]},
+		{"idCard": "c5", "name": "Empty", "checkItems": []}
+	]
+}`
+
+func TestTrelloParse(t *testing.T) {
+	result, err := trello{}.Parse(strings.NewReader(trelloBoardFixture), now)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	var titles []string
+	for _, tree := range result.Tasks {
+		titles = append(titles, tree.Task.Title)
+	}
+	if want := []string{"Pack books", "Book movers", "Cancel internet"}; !reflect.DeepEqual(titles, want) {
+		t.Fatalf("tasks = %q, want %q in card order", titles, want)
+	}
+
+	pack := result.Tasks[0]
+	if pack.Task.Description != "Start with the top shelf" || pack.Task.Project != "Flat move" || pack.Task.Status != "pending" {
+		t.Errorf("pack = %+v", pack.Task)
+	}
+	if !reflect.DeepEqual(pack.Task.Tags, []string{"To-Do", "Weekend"}) {
+		t.Errorf("pack tags = %v", pack.Task.Tags)
+	}
+
+	if len(pack.Children) != 2 {
+		t.Fatalf("pack has %d subtasks, want one per checklist item", len(pack.Children))
+	}
+	top, bottom := pack.Children[0].Task, pack.Children[1].Task
+	if top.Title != "Top" || top.Status != "completed" || top.DueAt != nil {
+		t.Errorf("first subtask = %+v, want the completed Top item", top)
+	}
+	if bottom.Title != "Bottom" || bottom.Status != "pending" || !sameTime(bottom.DueAt, utc(2026, time.October, 20, 6, 0)) {
+		t.Errorf("second subtask = %+v, want Bottom due 06:00 UTC", bottom)
+	}
+	if !reflect.DeepEqual(bottom.Tags, []string{"Shelves"}) || bottom.Project != "Flat move" {
+		t.Errorf("subtask tags %v, project %q", bottom.Tags, bottom.Project)
+	}
+
+	movers := result.Tasks[1].Task
+	if !reflect.DeepEqual(movers.Tags, []string{"To-Do", "red"}) || !sameTime(movers.DueAt, utc(2026, time.October, 25, 7, 0)) {
+		t.Errorf("movers = %+v", movers)
+	}
+
+	internet := result.Tasks[2].Task
+	if internet.Status != "completed" || internet.DueAt != nil {
+		t.Errorf("internet = %+v", internet)
+	}
+
+	var reasons []string
+	for _, skipped := range result.Skipped {
+		reasons = append(reasons, skipped.Item+": "+skipped.Reason)
+	}
+	want := []string{
+		`Cancel internet: due date "next week" is not understood, imported without it`,
+		"Paint walls: card is archived",
+		`Buy a boat: list "Old ideas" is archived`,
+		"Colors: checklist belongs to a card that is not imported",
+	}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("skipped =\n%s\nwant\n%s", strings.Join(reasons, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTrelloParseMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		board string
+	}{
+		{"empty", ""},
+		{"not json", "<html>"},
+		{"cards not a list", `{"name": "Board", "cards": "none"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (trello{}).Parse(strings.NewReader(tt.board), now); err == nil {
+				t.Error("Parse() accepted a malformed board")
+			}
+		})
+	}
+}
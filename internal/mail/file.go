@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes every email as an .eml file into Dir instead of sending
+// it, handy for local development without a mail server
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func NewFileMailer(dir string, from string) *FileMailer {
+	return &FileMailer{Dir: dir, From: from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, email Email) error {
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	path := filepath.Join(m.Dir, time.Now().UTC().Format("20060102T150405.000Z")+"-"+hex.EncodeToString(suffix)+".eml")
+	if err := os.WriteFile(path, message(m.From, email), 0o600); err != nil {
+		return err
+	}
+
+	log.Printf("email to %s written to %s", email.To, path)
+	return nil
+}
@@ -0,0 +1,32 @@
+// Package mail sends the emails of the API, such as address verification
+// and password resets. SMTP is used in production, the file and memory
+// mailers keep the messages for local development and tests.
+package mail
+
+import (
+	"context"
+	"time"
+)
+
+// Email is a plain text message to one recipient
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// message renders the email with the headers a mail server expects
+func message(from string, email Email) []byte {
+	return []byte("From: " + from + "\r\n" +
+		"To: " + email.To + "\r\n" +
+		"Subject: " + email.Subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		email.Body)
+}
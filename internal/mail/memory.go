@@ -0,0 +1,32 @@
+package mail
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryMailer keeps the emails in memory, tests read them back with Sent
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Email
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, email Email) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent = append(m.sent, email)
+	return nil
+}
+
+// Sent returns the emails sent so far, oldest first
+func (m *MemoryMailer) Sent() []Email {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Email(nil), m.sent...)
+}
@@ -0,0 +1,248 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubMessage is what the stub server received in one session
+type stubMessage struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// smtpStub is an SMTP server that accepts everything and keeps the messages
+type smtpStub struct {
+	listener net.Listener
+	// reject answers RCPT TO with 550 for these addresses
+	reject map[string]bool
+
+	mu       sync.Mutex
+	messages []stubMessage
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &smtpStub{listener: listener, reject: make(map[string]bool)}
+	go stub.serve()
+	t.Cleanup(func() { listener.Close() })
+
+	return stub
+}
+
+func (s *smtpStub) addr() (host string, port string) {
+	host, port, _ = net.SplitHostPort(s.listener.Addr().String())
+	return host, port
+}
+
+func (s *smtpStub) received() []stubMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]stubMessage(nil), s.messages...)
+}
+
+func (s *smtpStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(conn)
+	}
+}
+
+func (s *smtpStub) session(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var msg stubMessage
+	reply("220 stub ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO":
+			reply("250-stub")
+			reply("250 AUTH PLAIN")
+		case "HELO":
+			reply("250 stub")
+		case "AUTH":
+			msg.auth = strings.TrimPrefix(line, "AUTH PLAIN ")
+			reply("235 authenticated")
+		case "MAIL":
+			msg.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			reply("250 ok")
+		case "RCPT":
+			to := strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			if s.reject[to] {
+				reply("550 no such user")
+				continue
+			}
+			msg.to = append(msg.to, to)
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			msg.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	stub := newSMTPStub(t)
+	host, port := stub.addr()
+
+	mailer := NewSMTPMailer(host, port, "", "", "todo-api@example.com")
+	err := mailer.Send(context.Background(), Email{To: "user@example.com", Subject: "Reminder: Pay rent", Body: "Due at noon"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	messages := stub.received()
+	if len(messages) != 1 {
+		t.Fatalf("stub received %d messages, want 1", len(messages))
+	}
+
+	msg := messages[0]
+	if msg.from != "todo-api@example.com" || len(msg.to) != 1 || msg.to[0] != "user@example.com" {
+		t.Errorf("envelope = %q -> %q", msg.from, msg.to)
+	}
+	if msg.auth != "" {
+		t.Errorf("mailer authenticated without credentials")
+	}
+	for _, header := range []string{"From: todo-api@example.com\r\n", "To: user@example.com\r\n", "Subject: Reminder: Pay rent\r\n", "Content-Type: text/plain; charset=UTF-8\r\n"} {
+		if !strings.Contains(msg.data, header) {
+			t.Errorf("message lacks header %q:\n%s", header, msg.data)
+		}
+	}
+	if !strings.HasSuffix(msg.data, "\r\n\r\nDue at noon\r\n") {
+		t.Errorf("message body not found:\n%s", msg.data)
+	}
+}
+
+func TestSMTPMailerAuth(t *testing.T) {
+	stub := newSMTPStub(t)
+	_, port := stub.addr()
+
+	// PLAIN auth without TLS is only allowed to localhost
+	mailer := NewSMTPMailer("localhost", port, "bot", "secret", "todo-api@example.com")
+	if err := mailer.Send(context.Background(), Email{To: "user@example.com", Subject: "Hi", Body: "Hello"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	messages := stub.received()
+	if len(messages) != 1 {
+		t.Fatalf("stub received %d messages, want 1", len(messages))
+	}
+
+	credentials, err := base64.StdEncoding.DecodeString(messages[0].auth)
+	if err != nil || string(credentials) != "\x00bot\x00secret" {
+		t.Errorf("AUTH PLAIN = %q, want bot/secret", credentials)
+	}
+}
+
+func TestSMTPMailerRejectedRecipient(t *testing.T) {
+	stub := newSMTPStub(t)
+	stub.reject["nobody@example.com"] = true
+	host, port := stub.addr()
+
+	mailer := NewSMTPMailer(host, port, "", "", "todo-api@example.com")
+	if err := mailer.Send(context.Background(), Email{To: "nobody@example.com", Subject: "Hi", Body: "Hello"}); err == nil {
+		t.Fatal("Send() to a rejected recipient succeeded")
+	}
+
+	if n := len(stub.received()); n != 0 {
+		t.Errorf("stub received %d messages, want 0", n)
+	}
+}
+
+func TestSMTPMailerRefusesHeaderInjection(t *testing.T) {
+	stub := newSMTPStub(t)
+	host, port := stub.addr()
+
+	mailer := NewSMTPMailer(host, port, "", "", "todo-api@example.com")
+	for _, email := range []Email{
+		{To: "user@example.com\r\nBcc: victim@example.com", Subject: "Hi"},
+		{To: "user@example.com", Subject: "Hi\nBcc: victim@example.com"},
+		{To: "", Subject: "Hi"},
+	} {
+		if err := mailer.Send(context.Background(), email); err == nil {
+			t.Errorf("Send(%q, %q) succeeded", email.To, email.Subject)
+		}
+	}
+
+	if n := len(stub.received()); n != 0 {
+		t.Errorf("stub received %d messages, want 0", n)
+	}
+}
+
+func TestSMTPMailerHonoursContext(t *testing.T) {
+	// a server that accepts the connection but never greets
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	mailer := NewSMTPMailer(host, port, "", "", "todo-api@example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := mailer.Send(ctx, Email{To: "user@example.com", Subject: "Hi"}); err != context.DeadlineExceeded {
+		t.Errorf("Send() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send() returned after %s", elapsed)
+	}
+}
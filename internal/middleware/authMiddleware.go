@@ -4,11 +4,14 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/DmitriyGiryntsev/TODO-API/pkg/helpers"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
-func RequireAuth() gin.HandlerFunc {
+// RequireAuth checks the access token or personal access token, tokens of
+// revoked sessions are rejected. Personal access tokens need every one of
+// scopes, routes that declare none are for sessions only.
+func RequireAuth(auth *service.AuthService, scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
 
@@ -25,15 +28,31 @@ func RequireAuth() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := helpers.ValidateToken(tokenParts[1])
+		principal, err := auth.AuthenticateRequest(tokenParts[1])
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		c.Set("userID", claims.ID)
-		c.Set("role", claims.Role)
+		if principal.TokenID != 0 && len(scopes) == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "personal access tokens cannot be used here"})
+			c.Abort()
+			return
+		}
+
+		if scope := principal.MissingScope(scopes); scope != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "token lacks scope " + scope})
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", principal.UserID)
+		c.Set("role", principal.Role)
+		c.Set("sessionID", principal.SessionID)
+		c.Set("tokenID", principal.TokenID)
+		// handlers that check scopes per request, like GraphQL mutations, need the whole principal
+		c.Set("principal", principal)
 
 		c.Next()
 	}
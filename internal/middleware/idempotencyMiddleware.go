@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// responseRecorder keeps a copy of everything written to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes unsafe requests with an Idempotency-Key header safe to retry.
+// The first response for a user and key is stored and replayed for retries with
+// the same method, path and body. It must run after RequireAuth.
+func Idempotency(repo *repository.IdempotencyRepository, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" || !isUnsafe(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		io.WriteString(hash, c.Request.Method+" "+c.Request.URL.RequestURI()+"\n")
+		hash.Write(body)
+		fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+		record, owned, err := repo.Acquire(userID.(int), key, fingerprint, ttl)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cannot check Idempotency-Key"})
+			c.Abort()
+			return
+		}
+
+		if !owned {
+			switch {
+			case record != nil && record.Fingerprint != fingerprint:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			case record == nil || record.Status == repository.IdempotencyInFlight:
+				c.JSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still in progress"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.ResponseCode, record.ContentType, record.ResponseBody)
+			}
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		completed := false
+		// a panic further down must not leave the key in flight
+		defer func() {
+			if !completed {
+				repo.Release(userID.(int), key)
+			}
+		}()
+
+		c.Next()
+
+		// server errors are not final, the client may retry them with the same key
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		if err := repo.Complete(userID.(int), key, status, recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err == nil {
+			completed = true
+		}
+	}
+}
+
+func isUnsafe(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -1,22 +1,274 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
+	ID            int       `json:"id"`
+	Username      string    `json:"username" validate:"required,min=3,max=50"`
+	Email         string    `json:"email" validate:"required,email"`
+	Password      string    `json:"password,omitempty" validate:"required,min=8"`
+	Role          string    `json:"role" validate:"oneof=admin user"`
+	Timezone      string    `json:"timezone" validate:"omitempty,timezone"`
+	EmailVerified bool      `json:"email_verified" validate:"-"`
+	TwoFactor     bool      `json:"two_factor_enabled" validate:"-"`
+	Created_at    time.Time `json:"created_at"`
+}
+
+type Task struct {
+	ID              int        `json:"id"`
+	UserID          int        `json:"user_id"`
+	Title           string     `json:"title" validate:"required,min=3,max=100"`
+	Description     string     `json:"description,omitempty" validate:"required,min=10,max=20000"`
+	DescriptionHTML string     `json:"description_html,omitempty" validate:"-"`
+	Status          string     `json:"status" validate:"oneof=pending in_progress completed"`
+	Priority        string     `json:"priority" validate:"omitempty,oneof=low medium high"`
+	Project         string     `json:"project" validate:"max=100"`
+	Recurrence      string     `json:"recurrence" validate:"max=255"`
+	Tags            []string   `json:"tags" validate:"max=20,dive,min=1,max=50"`
+	DueAt           *time.Time `json:"due_at,omitempty"`
+	MilestoneID     *int       `json:"milestone_id,omitempty"`
+	ParentID        *int       `json:"parent_id,omitempty"`
+	SnoozedUntil    *time.Time `json:"snoozed_until,omitempty"`
+	Pinned          bool       `json:"pinned"`
+	ClientID        *string    `json:"client_id,omitempty" validate:"omitempty,min=1,max=64"`
+	Created_at      time.Time  `json:"created_at"`
+	Updated_at      time.Time  `json:"updated_at"`
+}
+
+type Milestone struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Title       string     `json:"title" validate:"required,min=3,max=100"`
+	Description string     `json:"description" validate:"max=500"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	State       string     `json:"state" validate:"omitempty,oneof=open closed"`
+	Created_at  time.Time  `json:"created_at"`
+	Updated_at  time.Time  `json:"updated_at"`
+}
+
+// MilestoneProgress is the roll-up of the tasks that belong to a milestone
+type MilestoneProgress struct {
+	TotalTasks      int            `json:"total_tasks"`
+	PercentComplete float64        `json:"percent_complete"`
+	ByStatus        map[string]int `json:"by_status"`
+	OverdueTasks    int            `json:"overdue_tasks"`
+}
+
+// Template is a reusable bundle of tasks, e.g. an onboarding or release checklist
+type Template struct {
+	ID          int            `json:"id"`
+	UserID      int            `json:"user_id"`
+	Name        string         `json:"name" validate:"required,min=3,max=100"`
+	Description string         `json:"description" validate:"max=500"`
+	Items       []TemplateItem `json:"items" validate:"required,min=1,max=50,dive"`
+	Created_at  time.Time      `json:"created_at"`
+	Updated_at  time.Time      `json:"updated_at"`
+}
+
+// TemplateItem is a task blueprint. Title and description may contain
+// {{placeholders}}, the due date is an offset from the instantiation start date.
+// Items follow the rules of Task, so a saved template can be instantiated.
+type TemplateItem struct {
+	Title            string         `json:"title" validate:"required,min=3,max=100"`
+	Description      string         `json:"description" validate:"required,min=10,max=20000"`
+	Status           string         `json:"status" validate:"oneof=pending in_progress completed"`
+	Tags             []string       `json:"tags" validate:"max=20,dive,min=1,max=50"`
+	DueOffsetMinutes *int           `json:"due_offset_minutes,omitempty"`
+	Children         []TemplateItem `json:"children,omitempty" validate:"max=50,dive"`
+}
+
+// Reminder fires either at RemindAt or OffsetMinutes relative to the task's due date,
+// negative offsets meaning before it
+type Reminder struct {
+	ID            int        `json:"id"`
+	TaskID        int        `json:"task_id"`
+	UserID        int        `json:"user_id"`
+	RemindAt      *time.Time `json:"remind_at,omitempty"`
+	OffsetMinutes *int       `json:"offset_minutes,omitempty"`
+	Channel       string     `json:"channel" validate:"required,oneof=inbox email webhook"`
+	Target        string     `json:"target,omitempty" validate:"max=500"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	Created_at    time.Time  `json:"created_at"`
+}
+
+// Notification is an entry of the user's in-app inbox
+type Notification struct {
 	ID         int       `json:"id"`
-	Username   string    `json:"username" validate:"required,min=3,max=50"`
-	Email      string    `json:"email" validate:"required,email"`
-	Password   string    `json:"password" validate:"required,min=8"`
-	Role       string    `json:"role" validate:"oneof=admin user"`
+	UserID     int       `json:"user_id"`
+	TaskID     *int      `json:"task_id,omitempty"`
+	Title      string    `json:"title"`
+	Body       string    `json:"body"`
+	Read       bool      `json:"read"`
 	Created_at time.Time `json:"created_at"`
 }
 
-type Task struct {
+// TaskEvent is a change of a task. Task is empty for deleted tasks. ID counts
+// the events of the user, so a client that saw event N missed none before it.
+type TaskEvent struct {
+	ID         int64     `json:"id"`
+	UserID     int       `json:"user_id"`
+	TaskID     int       `json:"task_id"`
+	Type       string    `json:"type"`
+	Task       *Task     `json:"task,omitempty"`
+	Created_at time.Time `json:"created_at"`
+}
+
+// Webhook sends the selected task events to URL, signed with Secret
+type Webhook struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	URL          string    `json:"url" validate:"required,url,max=2000"`
+	Events       []string  `json:"events" validate:"required,min=1,dive,oneof=* task.created task.updated task.deleted"`
+	Secret       string    `json:"secret,omitempty" validate:"omitempty,min=16,max=255"`
+	Active       bool      `json:"active"`
+	FailureCount int       `json:"failure_count"`
+	Created_at   time.Time `json:"created_at"`
+	Updated_at   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one entry of the outbox, kept afterwards as the delivery log
+type WebhookDelivery struct {
+	ID           int64      `json:"id"`
+	WebhookID    int        `json:"webhook_id"`
+	EventType    string     `json:"event_type"`
+	Payload      string     `json:"payload"`
+	Status       string     `json:"status"`
+	Attempts     int        `json:"attempts"`
+	ResponseCode *int       `json:"response_code,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	Created_at   time.Time  `json:"created_at"`
+	DeliveredAt  *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookPayload is the body POSTed to webhook receivers. Data is the task,
+// or only its id for task.deleted.
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// TaskTombstone tells sync clients that a task is gone
+type TaskTombstone struct {
+	ID        int       `json:"id"`
+	ClientID  *string   `json:"client_id,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncChange is one offline change pushed by a client. Fields holds only the
+// fields the client changed, keyed by their JSON names.
+type SyncChange struct {
+	Op             string                     `json:"op" validate:"required,oneof=create update delete"`
+	ID             *int                       `json:"id,omitempty"`
+	ClientID       string                     `json:"client_id" validate:"omitempty,max=64"`
+	ParentClientID string                     `json:"parent_client_id,omitempty" validate:"omitempty,max=64"`
+	ChangedAt      time.Time                  `json:"changed_at" validate:"required"`
+	Fields         map[string]json.RawMessage `json:"fields"`
+}
+
+// SyncResult reports what happened to one pushed change
+type SyncResult struct {
+	ID             int      `json:"id,omitempty"`
+	ClientID       string   `json:"client_id,omitempty"`
+	Status         string   `json:"status"`
+	RejectedFields []string `json:"rejected_fields,omitempty"`
+	Error          string   `json:"error,omitempty"`
+	Task           *Task    `json:"task,omitempty"`
+}
+
+// IdempotencyKey is the stored outcome of an unsafe request, replayed when the client retries it
+type IdempotencyKey struct {
+	UserID       int
+	Key          string
+	Fingerprint  string
+	Status       string
+	ResponseCode int
+	ContentType  string
+	ResponseBody []byte
+	Created_at   time.Time
+	ExpiresAt    time.Time
+}
+
+// RefreshToken is a stored refresh token. Only the SHA-256 of the token is
+// kept. Every refresh replaces the token by a new one of the same family,
+// the family is the session the token belongs to.
+type RefreshToken struct {
+	ID         int64
+	UserID     int
+	FamilyID   string
+	TokenHash  string
+	Created_at time.Time
+	ExpiresAt  time.Time
+	UsedAt     *time.Time
+	RevokedAt  *time.Time
+}
+
+// Session is one sign in of a user, it lasts as long as its refresh tokens
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	Created_at time.Time  `json:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// Current marks the session of the request
+	Current bool `json:"current"`
+}
+
+// LoginChallenge is a password sign in waiting for the second factor, the
+// session is created with its device once the code is verified
+type LoginChallenge struct {
+	TokenHash  string
+	UserID     int
+	UserAgent  string
+	IP         string
+	Attempts   int
+	Created_at time.Time
+	ExpiresAt  time.Time
+}
+
+// UserIdentity links an account of an external identity provider to a user
+type UserIdentity struct {
 	ID          int       `json:"id"`
-	UserID      int       `json:"user_id"`
-	Title       string    `json:"title" validate:"required,min=3,max=100"`
-	Description string    `json:"description" validate:"required,min=10,max=500"`
-	Status      string    `json:"status" validate:"oneof=pending in_progress completed"`
+	UserID      int       `json:"-"`
+	Provider    string    `json:"provider"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email"`
 	Created_at  time.Time `json:"created_at"`
-	Updated_at  time.Time `json:"updated_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+// OIDCLoginState is a sign in sent to an identity provider, found again by
+// the state that comes back with the code. CodeVerifier is the PKCE secret,
+// the provider only got its hash.
+type OIDCLoginState struct {
+	StateHash    string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	Created_at   time.Time
+	ExpiresAt    time.Time
+}
+
+// PersonalAccessToken lets scripts call the API as the user without the
+// password. Only the hash of the token is stored, Prefix identifies it.
+type PersonalAccessToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	Created_at time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"-"`
 }
@@ -0,0 +1,105 @@
+// Package netguard keeps requests to URLs that users supply, such as webhook
+// targets, away from the server's own network. Loopback, private,
+// link-local and other special addresses are refused when connecting, after
+// the name is resolved, so a name that resolves to one is refused too, and
+// so are redirects to one.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenAddress = errors.New("address is not publicly routable")
+
+// blocked lists the special ranges the standard library has no predicate for
+var blocked = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// NAT64 and 6to4 addresses embed IPv4 addresses, private ones included
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Allowed reports whether ip is a public unicast address
+func Allowed(ip netip.Addr) bool {
+	ip = ip.Unmap()
+
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsLinkLocalMulticast() {
+		return false
+	}
+
+	for _, prefix := range blocked {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CheckURL validates a URL users want the server to call: it must be http(s)
+// with a host, and a host given as an address must be public. Names are
+// checked when connecting, they may resolve differently by then.
+func CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("must be an http(s) url")
+	}
+	if u.User != nil {
+		return errors.New("must not contain credentials")
+	}
+
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !Allowed(ip) {
+		return ErrForbiddenAddress
+	}
+
+	return nil
+}
+
+// NewClient returns an HTTP client that connects to public addresses only.
+// allowPrivate turns the check off, e.g. to try webhooks against a receiver
+// on the developer's machine.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = control
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would connect on our behalf, past the check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// control runs before every connection with the resolved address
+func control(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !Allowed(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/mail"
+)
+
+// EmailNotifier sends the message by email to the reminder's target address or the user's
+type EmailNotifier struct {
+	Mailer mail.Mailer
+}
+
+func NewEmailNotifier(mailer mail.Mailer) *EmailNotifier {
+	return &EmailNotifier{Mailer: mailer}
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, msg Message) error {
+	to := msg.Target
+	if to == "" {
+		to = msg.Email
+	}
+	if to == "" {
+		return errors.New("no recipient address")
+	}
+
+	return n.Mailer.Send(ctx, mail.Email{To: to, Subject: msg.Subject, Body: msg.Body})
+}
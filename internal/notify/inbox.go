@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+)
+
+// InboxNotifier adds the message to the user's in-app inbox. A reminder
+// delivered twice still leaves one entry, the repository ignores repeats.
+type InboxNotifier struct {
+	Repo *repository.NotificationRepository
+}
+
+func NewInboxNotifier(repo *repository.NotificationRepository) *InboxNotifier {
+	return &InboxNotifier{Repo: repo}
+}
+
+func (n *InboxNotifier) Notify(ctx context.Context, msg Message) error {
+	taskID := msg.TaskID
+	return n.Repo.CreateReminderNotification(msg.ReminderID, &models.Notification{
+		UserID: msg.UserID,
+		TaskID: &taskID,
+		Title:  msg.Subject,
+		Body:   msg.Body,
+	})
+}
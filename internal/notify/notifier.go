@@ -0,0 +1,25 @@
+// Package notify delivers reminders to users through pluggable channels
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a single notification to deliver
+type Message struct {
+	// ReminderID is the same for every attempt, receivers can drop repeats by it
+	ReminderID int
+	UserID     int
+	TaskID     int
+	Email      string
+	Target     string
+	Subject    string
+	Body       string
+	DueAt      *time.Time
+}
+
+// Notifier delivers a message through one channel, such as email or a webhook
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/netguard"
+)
+
+// WebhookNotifier POSTs the message as JSON to the reminder's target URL.
+// Targets in private networks are refused unless allowPrivate is set.
+type WebhookNotifier struct {
+	Client *http.Client
+}
+
+type webhookPayload struct {
+	ReminderID int        `json:"reminder_id"`
+	UserID     int        `json:"user_id"`
+	TaskID     int        `json:"task_id"`
+	Subject    string     `json:"subject"`
+	Body       string     `json:"body"`
+	DueAt      *time.Time `json:"due_at,omitempty"`
+}
+
+func NewWebhookNotifier(allowPrivate bool) *WebhookNotifier {
+	return &WebhookNotifier{Client: netguard.NewClient(10*time.Second, allowPrivate)}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, msg Message) error {
+	if msg.Target == "" {
+		return errors.New("no webhook url")
+	}
+
+	payload, err := json.Marshal(webhookPayload{
+		ReminderID: msg.ReminderID,
+		UserID:     msg.UserID,
+		TaskID:     msg.TaskID,
+		Subject:    msg.Subject,
+		Body:       msg.Body,
+		DueAt:      msg.DueAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.Target, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/netguard"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var got webhookPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	msg := Message{ReminderID: 7, UserID: 1, TaskID: 2, Target: server.URL, Subject: "Reminder: Pay rent", Body: "Pay rent"}
+	if err := NewWebhookNotifier(true).Notify(context.Background(), msg); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if got.ReminderID != 7 || got.UserID != 1 || got.TaskID != 2 || got.Subject != msg.Subject || got.Body != msg.Body {
+		t.Errorf("payload = %+v", got)
+	}
+}
+
+func TestWebhookNotifierFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	if err := NewWebhookNotifier(true).Notify(context.Background(), Message{Target: server.URL}); err == nil {
+		t.Error("Notify() succeeded on a 502")
+	}
+
+	if err := NewWebhookNotifier(true).Notify(context.Background(), Message{}); err == nil {
+		t.Error("Notify() succeeded without a target")
+	}
+
+	err := NewWebhookNotifier(false).Notify(context.Background(), Message{Target: server.URL})
+	if !errors.Is(err, netguard.ErrForbiddenAddress) {
+		t.Errorf("Notify() to loopback error = %v, want ErrForbiddenAddress", err)
+	}
+}
@@ -0,0 +1,347 @@
+package realtime
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = pongWait * 9 / 10
+	maxMessageSize = 64 * 1024
+	sendBufferSize = 64
+)
+
+var connectionSeq atomic.Int64
+
+// Client is one WebSocket connection of an authenticated user
+type Client struct {
+	hub       *Hub
+	conn      *websocket.Conn
+	id        string
+	userID    int
+	username  string
+	sessionID string
+
+	send      chan []byte
+	closeOnce sync.Once
+	done      chan struct{}
+
+	mu       sync.Mutex
+	tasks    map[int]bool
+	projects map[string]bool
+	// tasks seen through a project subscription, so their deletion is delivered too
+	known map[int]bool
+}
+
+// Serve runs the connection until it is closed or its session is revoked.
+// It blocks, call it from the HTTP handler.
+func (h *Hub) Serve(conn *websocket.Conn, userID int, username string, sessionID string) {
+	c := &Client{
+		hub:       h,
+		conn:      conn,
+		id:        strconv.FormatInt(connectionSeq.Add(1), 10),
+		userID:    userID,
+		username:  username,
+		sessionID: sessionID,
+		send:      make(chan []byte, sendBufferSize),
+		done:      make(chan struct{}),
+		tasks:     make(map[int]bool),
+		projects:  make(map[string]bool),
+		known:     make(map[int]bool),
+	}
+
+	if err := h.register(c); err != nil {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()), time.Now().Add(writeWait))
+		conn.Close()
+		return
+	}
+	defer h.unregister(c)
+
+	sub := h.Events.Subscribe(userID)
+	defer h.Events.Unsubscribe(sub)
+
+	go c.writePump()
+	go func() {
+		for event := range sub.C {
+			if c.revoked() {
+				return
+			}
+			if c.wants(event) {
+				c.enqueue(encode(eventMessage{Type: event.Type, TaskEvent: event}))
+			}
+		}
+		// the event hub dropped us or is stopping
+		c.goAway()
+	}()
+
+	c.readPump()
+}
+
+func (c *Client) viewer() Viewer {
+	return Viewer{ConnectionID: c.id, UserID: c.userID, Username: c.username}
+}
+
+// enqueue queues a message without blocking. A client that cannot keep up is disconnected.
+func (c *Client) enqueue(msg []byte) {
+	select {
+	case <-c.done:
+	case c.send <- msg:
+	default:
+		log.Printf("websocket client %s is too slow, closing", c.id)
+		c.close(websocket.ClosePolicyViolation, "too slow")
+	}
+}
+
+// revoked closes the connection when its session was revoked since it was opened
+func (c *Client) revoked() bool {
+	// tokens issued before sessions existed carry none, they expire within a day
+	if c.sessionID == "" || !c.hub.Sessions.IsRevoked(c.sessionID) {
+		return false
+	}
+
+	c.close(websocket.ClosePolicyViolation, "session revoked")
+	return true
+}
+
+func (c *Client) goAway() {
+	c.close(websocket.CloseGoingAway, "server is shutting down")
+}
+
+func (c *Client) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(writeWait))
+		close(c.done)
+		// unblock readPump
+		c.conn.SetReadDeadline(time.Now().Add(writeWait))
+	})
+}
+
+func (c *Client) readPump() {
+	defer c.conn.Close()
+	defer c.close(websocket.CloseNormalClosure, "")
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var msg ClientMessage
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-c.done:
+			// draining, mutations are no longer accepted
+			return
+		default:
+		}
+
+		if c.revoked() {
+			return
+		}
+
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.enqueue(encode(errorMessage{Type: TypeError, Status: http.StatusBadRequest, Error: "invalid message"}))
+			continue
+		}
+
+		c.handle(msg)
+	}
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			// an idle connection notices a revocation by the next ping
+			if c.revoked() {
+				return
+			}
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) handle(msg ClientMessage) {
+	switch msg.Type {
+	case TypeSubscribe:
+		c.subscribe(msg)
+	case TypeUnsubscribe:
+		c.unsubscribe(msg)
+	case TypeTyping:
+		if msg.State != "start" && msg.State != "stop" {
+			c.replyError(msg.RequestID, http.StatusBadRequest, "state must be start or stop")
+			return
+		}
+		if !c.watching(msg.TaskID) {
+			c.replyError(msg.RequestID, http.StatusBadRequest, "subscribe to the task first")
+			return
+		}
+		c.hub.broadcastTyping(c, msg.TaskID, msg.Field, msg.State)
+	case TypeCreate, TypeUpdate, TypeDelete:
+		c.mutate(msg)
+	case TypePing:
+		c.enqueue(encode(ackMessage{Type: TypePong, RequestID: msg.RequestID}))
+	default:
+		c.replyError(msg.RequestID, http.StatusBadRequest, "unknown message type")
+	}
+}
+
+func (c *Client) subscribe(msg ClientMessage) {
+	// all of the tasks must be the user's, or none is subscribed to
+	for _, id := range msg.TaskIDs {
+		if _, err := c.hub.Tasks.GetTaskByID(id, c.userID); err == sql.ErrNoRows {
+			c.replyError(msg.RequestID, http.StatusNotFound, fmt.Sprintf("task %d not found", id))
+			return
+		} else if err != nil {
+			c.replyError(msg.RequestID, http.StatusInternalServerError, "cannot get task")
+			return
+		}
+	}
+
+	var added []int
+
+	c.mu.Lock()
+	for _, id := range msg.TaskIDs {
+		if !c.tasks[id] {
+			c.tasks[id] = true
+			added = append(added, id)
+		}
+	}
+	for _, project := range msg.Projects {
+		c.projects[project] = true
+	}
+	c.mu.Unlock()
+
+	for _, id := range added {
+		c.hub.watch(c, id)
+	}
+
+	c.enqueue(encode(ackMessage{Type: TypeAck, RequestID: msg.RequestID}))
+}
+
+func (c *Client) unsubscribe(msg ClientMessage) {
+	var removed []int
+
+	c.mu.Lock()
+	for _, id := range msg.TaskIDs {
+		if c.tasks[id] {
+			delete(c.tasks, id)
+			removed = append(removed, id)
+		}
+	}
+	for _, project := range msg.Projects {
+		delete(c.projects, project)
+	}
+	c.mu.Unlock()
+
+	for _, id := range removed {
+		c.hub.unwatch(c, id)
+	}
+
+	c.enqueue(encode(ackMessage{Type: TypeAck, RequestID: msg.RequestID}))
+}
+
+func (c *Client) watching(taskID int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tasks[taskID]
+}
+
+// wants reports whether the event matches one of the subscriptions
+func (c *Client) wants(event models.TaskEvent) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.tasks[event.TaskID] {
+		return true
+	}
+
+	if event.Task != nil && c.projects[event.Task.Project] {
+		c.known[event.TaskID] = true
+		return true
+	}
+
+	if c.known[event.TaskID] {
+		if event.Type == "task.deleted" || event.Task == nil || !c.projects[event.Task.Project] {
+			// the task left the project, this is the last event about it
+			delete(c.known, event.TaskID)
+		}
+		return true
+	}
+
+	return false
+}
+
+func (c *Client) mutate(msg ClientMessage) {
+	var err error
+	var task *models.Task
+
+	switch msg.Type {
+	case TypeCreate:
+		if msg.Task == nil {
+			c.replyError(msg.RequestID, http.StatusBadRequest, "task is required")
+			return
+		}
+		task = msg.Task
+		err = c.hub.Mutator.CreateTask(c.userID, task)
+	case TypeUpdate:
+		if msg.Task == nil || msg.TaskID == 0 {
+			c.replyError(msg.RequestID, http.StatusBadRequest, "task_id and task are required")
+			return
+		}
+		task = msg.Task
+		err = c.hub.Mutator.UpdateTask(c.userID, msg.TaskID, task)
+	case TypeDelete:
+		if msg.TaskID == 0 {
+			c.replyError(msg.RequestID, http.StatusBadRequest, "task_id is required")
+			return
+		}
+		err = c.hub.Mutator.DeleteTask(c.userID, msg.TaskID)
+	}
+
+	if err != nil {
+		status := http.StatusInternalServerError
+		if coded, ok := err.(interface{ StatusCode() int }); ok {
+			status = coded.StatusCode()
+		}
+		c.replyError(msg.RequestID, status, err.Error())
+		return
+	}
+
+	c.enqueue(encode(ackMessage{Type: TypeAck, RequestID: msg.RequestID, Task: task}))
+}
+
+func (c *Client) replyError(requestID string, status int, message string) {
+	c.enqueue(encode(errorMessage{Type: TypeError, RequestID: requestID, Status: status, Error: message}))
+}
@@ -0,0 +1,180 @@
+// Package realtime is the WebSocket API: clients subscribe to tasks or projects
+// and get their changes, see who else is viewing a task, exchange typing
+// indicators and send task mutations over the same connection.
+package realtime
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/events"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// ErrClosing is returned when a connection arrives while the hub is draining
+var ErrClosing = errors.New("server is shutting down")
+
+// Mutator applies task mutations with the same validation as the REST API.
+// Errors may implement StatusCode() int to report an HTTP-like status.
+type Mutator interface {
+	CreateTask(userID int, task *models.Task) error
+	UpdateTask(userID int, taskID int, task *models.Task) error
+	DeleteTask(userID int, taskID int) error
+}
+
+// TaskFinder looks up a task of the user, clients may only subscribe to their own
+type TaskFinder interface {
+	GetTaskByID(taskID int, userID int) (*models.Task, error)
+}
+
+// RevocationChecker tells whether a session was revoked after the connection was opened
+type RevocationChecker interface {
+	IsRevoked(sessionID string) bool
+}
+
+type Hub struct {
+	Events   *events.Hub
+	Mutator  Mutator
+	Tasks    TaskFinder
+	Sessions RevocationChecker
+
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+	viewers map[int]map[*Client]struct{}
+	closing bool
+	wg      sync.WaitGroup
+}
+
+func NewHub(eventHub *events.Hub, mutator Mutator, tasks TaskFinder, sessions RevocationChecker) *Hub {
+	return &Hub{
+		Events:   eventHub,
+		Mutator:  mutator,
+		Tasks:    tasks,
+		Sessions: sessions,
+		clients:  make(map[*Client]struct{}),
+		viewers:  make(map[int]map[*Client]struct{}),
+	}
+}
+
+func (h *Hub) register(c *Client) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closing {
+		return ErrClosing
+	}
+
+	h.clients[c] = struct{}{}
+	h.wg.Add(1)
+	return nil
+}
+
+func (h *Hub) unregister(c *Client) {
+	h.mu.Lock()
+	var changed []int
+	for taskID, viewers := range h.viewers {
+		if _, ok := viewers[c]; ok {
+			delete(viewers, c)
+			changed = append(changed, taskID)
+		}
+	}
+	delete(h.clients, c)
+	h.mu.Unlock()
+
+	for _, taskID := range changed {
+		h.broadcastPresence(taskID)
+	}
+
+	h.wg.Done()
+}
+
+func (h *Hub) watch(c *Client, taskID int) {
+	h.mu.Lock()
+	if h.viewers[taskID] == nil {
+		h.viewers[taskID] = make(map[*Client]struct{})
+	}
+	h.viewers[taskID][c] = struct{}{}
+	h.mu.Unlock()
+
+	h.broadcastPresence(taskID)
+}
+
+func (h *Hub) unwatch(c *Client, taskID int) {
+	h.mu.Lock()
+	delete(h.viewers[taskID], c)
+	if len(h.viewers[taskID]) == 0 {
+		delete(h.viewers, taskID)
+	}
+	h.mu.Unlock()
+
+	h.broadcastPresence(taskID)
+}
+
+func (h *Hub) broadcastPresence(taskID int) {
+	h.mu.Lock()
+	viewers := make([]Viewer, 0, len(h.viewers[taskID]))
+	targets := make([]*Client, 0, len(h.viewers[taskID]))
+	for c := range h.viewers[taskID] {
+		viewers = append(viewers, c.viewer())
+		targets = append(targets, c)
+	}
+	h.mu.Unlock()
+
+	sort.Slice(viewers, func(i, j int) bool { return viewers[i].ConnectionID < viewers[j].ConnectionID })
+	msg := encode(presenceMessage{Type: TypePresence, TaskID: taskID, Viewers: viewers})
+
+	for _, c := range targets {
+		c.enqueue(msg)
+	}
+}
+
+// broadcastTyping tells the other viewers of the task that c is typing
+func (h *Hub) broadcastTyping(from *Client, taskID int, field string, state string) {
+	h.mu.Lock()
+	targets := make([]*Client, 0, len(h.viewers[taskID]))
+	for c := range h.viewers[taskID] {
+		if c != from {
+			targets = append(targets, c)
+		}
+	}
+	h.mu.Unlock()
+
+	msg := encode(typingMessage{Type: TypeTyping, TaskID: taskID, Field: field, State: state, Viewer: from.viewer()})
+	for _, c := range targets {
+		c.enqueue(msg)
+	}
+}
+
+// Shutdown stops accepting connections, asks every client to go away and
+// waits until they are gone or ctx expires
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	clients := make([]*Client, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mu.Unlock()
+
+	for _, c := range clients {
+		c.goAway()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		for _, c := range clients {
+			c.conn.Close()
+		}
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,82 @@
+package realtime
+
+import (
+	"encoding/json"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// Messages a client may send
+const (
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+	TypeTyping      = "typing"
+	TypeCreate      = "task.create"
+	TypeUpdate      = "task.update"
+	TypeDelete      = "task.delete"
+	TypePing        = "ping"
+)
+
+// Messages the server sends besides the task events
+const (
+	TypeAck      = "ack"
+	TypeError    = "error"
+	TypePresence = "presence"
+	TypePong     = "pong"
+)
+
+// ClientMessage is a message received from a client. Which fields are used depends on Type.
+type ClientMessage struct {
+	Type      string       `json:"type"`
+	RequestID string       `json:"request_id,omitempty"`
+	TaskIDs   []int        `json:"task_ids,omitempty"`
+	Projects  []string     `json:"projects,omitempty"`
+	TaskID    int          `json:"task_id,omitempty"`
+	Field     string       `json:"field,omitempty"`
+	State     string       `json:"state,omitempty"`
+	Task      *models.Task `json:"task,omitempty"`
+}
+
+// Viewer is a connection looking at a task
+type Viewer struct {
+	ConnectionID string `json:"connection_id"`
+	UserID       int    `json:"user_id"`
+	Username     string `json:"username"`
+}
+
+type ackMessage struct {
+	Type      string       `json:"type"`
+	RequestID string       `json:"request_id,omitempty"`
+	Task      *models.Task `json:"task,omitempty"`
+}
+
+type errorMessage struct {
+	Type      string `json:"type"`
+	RequestID string `json:"request_id,omitempty"`
+	Status    int    `json:"status"`
+	Error     string `json:"error"`
+}
+
+type presenceMessage struct {
+	Type    string   `json:"type"`
+	TaskID  int      `json:"task_id"`
+	Viewers []Viewer `json:"viewers"`
+}
+
+type typingMessage struct {
+	Type   string `json:"type"`
+	TaskID int    `json:"task_id"`
+	Field  string `json:"field,omitempty"`
+	State  string `json:"state"`
+	Viewer
+}
+
+type eventMessage struct {
+	Type string `json:"type"`
+	models.TaskEvent
+}
+
+func encode(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+type EventRepository struct {
+	DB *sql.DB
+}
+
+func NewEventRepository(db *sql.DB) *EventRepository {
+	return &EventRepository{DB: db}
+}
+
+// GetEventsAfter returns the events of the user that follow sequence number
+// afterID, reading up to limit of them, and the sequence number of the last
+// one read. It can be past the last event returned when tasks are gone.
+func (e *EventRepository) GetEventsAfter(userID int, afterID int64, limit int) ([]models.TaskEvent, int64, error) {
+	rows, err := e.DB.Query(`SELECT seq, userID, taskID, type, createdAt FROM task_events WHERE userID = $1 AND seq > $2 ORDER BY seq LIMIT $3`, userID, afterID, limit)
+	if err != nil {
+		log.Print("cannot execute statement to get events:", err)
+		return nil, afterID, err
+	}
+	defer rows.Close()
+
+	var events []models.TaskEvent
+	last := afterID
+
+	for rows.Next() {
+		var event models.TaskEvent
+		if err := rows.Scan(&event.ID, &event.UserID, &event.TaskID, &event.Type, &event.Created_at); err != nil {
+			log.Print("cannot scan row to get events:", err)
+			return nil, afterID, err
+		}
+		events = append(events, event)
+		last = event.ID
+	}
+	if err := rows.Err(); err != nil {
+		return nil, afterID, err
+	}
+
+	events, err = e.attachTasks(userID, events)
+	if err != nil {
+		return nil, afterID, err
+	}
+
+	return events, last, nil
+}
+
+// GetEventBounds returns the sequence numbers of the first event still kept
+// for the user and of the latest one. The first is latest+1 when none are kept.
+func (e *EventRepository) GetEventBounds(userID int) (oldest int64, latest int64, err error) {
+	err = e.DB.QueryRow(`
+		SELECT COALESCE((SELECT MIN(seq) FROM task_events WHERE userID = $1), eventSeq + 1), eventSeq
+		FROM users WHERE id = $1`, userID).Scan(&oldest, &latest)
+	if err != nil {
+		log.Print("cannot scan row to get event bounds:", err)
+		return 0, 0, err
+	}
+
+	return oldest, latest, nil
+}
+
+// PruneEvents keeps the event log bounded by age and by the number of events per user
+func (e *EventRepository) PruneEvents(maxAge time.Duration, maxPerUser int) error {
+	_, err := e.DB.Exec(`
+		DELETE FROM task_events
+		WHERE createdAt < NOW() - $1 * INTERVAL '1 second'
+			OR id IN (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (PARTITION BY userID ORDER BY seq DESC) AS n FROM task_events
+				) ranked
+				WHERE n > $2
+			)`, maxAge.Seconds(), maxPerUser)
+	if err != nil {
+		log.Print("cannot execute statement to prune events:", err)
+	}
+
+	return err
+}
+
+// attachTasks fills in the current state of the tasks. Events of tasks that
+// are gone by now are dropped, their deletion event follows anyway.
+func (e *EventRepository) attachTasks(userID int, events []models.TaskEvent) ([]models.TaskEvent, error) {
+	var ids []int
+	for _, event := range events {
+		if event.Type != "task.deleted" {
+			ids = append(ids, event.TaskID)
+		}
+	}
+
+	tasks, err := getTasksByIDs(e.DB, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := events[:0]
+	for _, event := range events {
+		if event.Type != "task.deleted" {
+			task, ok := tasks[event.TaskID]
+			if !ok {
+				continue
+			}
+			event.Task = task
+		}
+		result = append(result, event)
+	}
+
+	return result, nil
+}
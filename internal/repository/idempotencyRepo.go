@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// Key states. A key stays in flight while its first request runs.
+const (
+	IdempotencyInFlight  = "in_flight"
+	IdempotencyCompleted = "completed"
+)
+
+// an in-flight key older than this belongs to a request that died with its server
+const abandonedAfter = 5 * time.Minute
+
+type IdempotencyRepository struct {
+	DB *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{DB: db}
+}
+
+// Acquire claims the key for a new request. It returns true when the caller owns
+// the key and should run the request, otherwise the stored record is returned.
+// Expired and abandoned keys are taken over. A nil record with false means the
+// key was released in between, the caller should treat it as still in flight.
+func (i *IdempotencyRepository) Acquire(userID int, key string, fingerprint string, ttl time.Duration) (*models.IdempotencyKey, bool, error) {
+	var record models.IdempotencyKey
+
+	err := i.DB.QueryRow(`
+		INSERT INTO idempotency_keys (userID, key, fingerprint, expiresAt)
+		VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 second')
+		ON CONFLICT (userID, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint, status = 'in_flight', responseCode = NULL, contentType = '',
+			responseBody = NULL, createdAt = NOW(), expiresAt = EXCLUDED.expiresAt
+		WHERE idempotency_keys.expiresAt <= NOW()
+			OR (idempotency_keys.status = 'in_flight' AND idempotency_keys.createdAt < NOW() - $5 * INTERVAL '1 second')
+		RETURNING userID, key, fingerprint, status, createdAt, expiresAt`,
+		userID, key, fingerprint, ttl.Seconds(), abandonedAfter.Seconds()).
+		Scan(&record.UserID, &record.Key, &record.Fingerprint, &record.Status, &record.Created_at, &record.ExpiresAt)
+	if err == nil {
+		return &record, true, nil
+	} else if err != sql.ErrNoRows {
+		log.Print("cannot scan row to acquire idempotency key:", err)
+		return nil, false, err
+	}
+
+	var responseCode sql.NullInt64
+	err = i.DB.QueryRow(`SELECT userID, key, fingerprint, status, responseCode, contentType, responseBody, createdAt, expiresAt FROM idempotency_keys WHERE userID = $1 AND key = $2`, userID, key).
+		Scan(&record.UserID, &record.Key, &record.Fingerprint, &record.Status, &responseCode, &record.ContentType, &record.ResponseBody, &record.Created_at, &record.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		log.Print("cannot scan row to get idempotency key:", err)
+		return nil, false, err
+	}
+	record.ResponseCode = int(responseCode.Int64)
+
+	return &record, false, nil
+}
+
+// Complete stores the response of the request that owns the key
+func (i *IdempotencyRepository) Complete(userID int, key string, responseCode int, contentType string, body []byte) error {
+	_, err := i.DB.Exec(`UPDATE idempotency_keys SET status = 'completed', responseCode = $1, contentType = $2, responseBody = $3 WHERE userID = $4 AND key = $5`,
+		responseCode, contentType, body, userID, key)
+	if err != nil {
+		log.Print("cannot execute statement to complete idempotency key:", err)
+	}
+
+	return err
+}
+
+// Release forgets the key so the request can be retried, used when it failed on the server side
+func (i *IdempotencyRepository) Release(userID int, key string) error {
+	_, err := i.DB.Exec(`DELETE FROM idempotency_keys WHERE userID = $1 AND key = $2 AND status = 'in_flight'`, userID, key)
+	if err != nil {
+		log.Print("cannot execute statement to release idempotency key:", err)
+	}
+
+	return err
+}
+
+func (i *IdempotencyRepository) PruneExpired() error {
+	_, err := i.DB.Exec(`DELETE FROM idempotency_keys WHERE expiresAt <= NOW()`)
+	if err != nil {
+		log.Print("cannot execute statement to prune idempotency keys:", err)
+	}
+
+	return err
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// IdentityRepository keeps the external accounts users sign in with
+type IdentityRepository struct {
+	DB *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{DB: db}
+}
+
+// GetIdentity finds the link of the provider's account, sql.ErrNoRows when there is none
+func (i *IdentityRepository) GetIdentity(provider string, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+
+	err := i.DB.QueryRow(`SELECT id, userID, provider, subject, email, createdAt, lastLoginAt FROM user_identities WHERE provider = $1 AND subject = $2`, provider, subject).
+		Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.Created_at, &identity.LastLoginAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Print("cannot scan row to get identity:", err)
+		}
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (i *IdentityRepository) GetIdentities(userID int) ([]models.UserIdentity, error) {
+	rows, err := i.DB.Query(`SELECT id, userID, provider, subject, email, createdAt, lastLoginAt FROM user_identities WHERE userID = $1 ORDER BY createdAt`, userID)
+	if err != nil {
+		log.Print("cannot query identities:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []models.UserIdentity{}
+	for rows.Next() {
+		var identity models.UserIdentity
+		if err := rows.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.Created_at, &identity.LastLoginAt); err != nil {
+			log.Print("cannot scan row to get identities:", err)
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+// CreateIdentity links the account to the user, linking it twice is a no-op
+func (i *IdentityRepository) CreateIdentity(identity *models.UserIdentity) error {
+	_, err := i.DB.Exec(`INSERT INTO user_identities (userID, provider, subject, email) VALUES ($1, $2, $3, $4) ON CONFLICT (provider, subject) DO NOTHING`,
+		identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		log.Print("cannot execute statement to create identity:", err)
+	}
+
+	return err
+}
+
+// TouchIdentity records a sign in, the email is kept as the provider last reported it
+func (i *IdentityRepository) TouchIdentity(id int, email string) error {
+	_, err := i.DB.Exec(`UPDATE user_identities SET lastLoginAt = NOW(), email = $1 WHERE id = $2`, email, id)
+	if err != nil {
+		log.Print("cannot execute statement to touch identity:", err)
+	}
+
+	return err
+}
+
+// DeleteIdentity unlinks an account of the user, it returns false when the user has no such identity
+func (i *IdentityRepository) DeleteIdentity(userID int, id int) (bool, error) {
+	result, err := i.DB.Exec(`DELETE FROM user_identities WHERE id = $1 AND userID = $2`, id, userID)
+	if err != nil {
+		log.Print("cannot execute statement to delete identity:", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Print("cannot get rows affected to delete identity:", err)
+		return false, err
+	}
+
+	return rows > 0, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+type LoginChallengeRepository struct {
+	DB *sql.DB
+}
+
+func NewLoginChallengeRepository(db *sql.DB) *LoginChallengeRepository {
+	return &LoginChallengeRepository{DB: db}
+}
+
+func (l *LoginChallengeRepository) CreateChallenge(challenge *models.LoginChallenge) error {
+	_, err := l.DB.Exec(`INSERT INTO login_challenges (tokenHash, userID, userAgent, ip, expiresAt) VALUES ($1, $2, $3, $4, $5)`,
+		challenge.TokenHash, challenge.UserID, challenge.UserAgent, challenge.IP, challenge.ExpiresAt)
+	if err != nil {
+		log.Print("cannot execute statement to create login challenge:", err)
+	}
+
+	return err
+}
+
+// GetChallenge returns the challenge while it is valid, sql.ErrNoRows otherwise
+func (l *LoginChallengeRepository) GetChallenge(tokenHash string, maxAttempts int) (*models.LoginChallenge, error) {
+	return l.scanChallenge(`SELECT tokenHash, userID, userAgent, ip, attempts, createdAt, expiresAt FROM login_challenges
+		WHERE tokenHash = $1 AND expiresAt > NOW() AND attempts < $2`, tokenHash, maxAttempts)
+}
+
+// AttemptChallenge counts an attempt at the code before it is checked, so
+// concurrent guesses cannot exceed maxAttempts. It returns sql.ErrNoRows
+// when the challenge is unknown, expired or out of attempts.
+func (l *LoginChallengeRepository) AttemptChallenge(tokenHash string, maxAttempts int) (*models.LoginChallenge, error) {
+	return l.scanChallenge(`UPDATE login_challenges SET attempts = attempts + 1
+		WHERE tokenHash = $1 AND expiresAt > NOW() AND attempts < $2
+		RETURNING tokenHash, userID, userAgent, ip, attempts, createdAt, expiresAt`, tokenHash, maxAttempts)
+}
+
+func (l *LoginChallengeRepository) DeleteChallenge(tokenHash string) error {
+	_, err := l.DB.Exec(`DELETE FROM login_challenges WHERE tokenHash = $1`, tokenHash)
+	if err != nil {
+		log.Print("cannot execute statement to delete login challenge:", err)
+	}
+
+	return err
+}
+
+func (l *LoginChallengeRepository) PruneExpired() error {
+	_, err := l.DB.Exec(`DELETE FROM login_challenges WHERE expiresAt <= NOW()`)
+	if err != nil {
+		log.Print("cannot execute statement to prune login challenges:", err)
+	}
+
+	return err
+}
+
+func (l *LoginChallengeRepository) scanChallenge(query string, args ...interface{}) (*models.LoginChallenge, error) {
+	var challenge models.LoginChallenge
+
+	err := l.DB.QueryRow(query, args...).Scan(&challenge.TokenHash, &challenge.UserID, &challenge.UserAgent, &challenge.IP,
+		&challenge.Attempts, &challenge.Created_at, &challenge.ExpiresAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Print("cannot scan row to get login challenge:", err)
+		}
+		return nil, err
+	}
+
+	return &challenge, nil
+}
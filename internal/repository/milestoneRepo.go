@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/lib/pq"
+)
+
+const milestoneColumns = "id, userID, title, description, dueDate, state, createdAt, updatedAt"
+
+type MilestoneRepository struct {
+	DB *sql.DB
+}
+
+func NewMilestoneRepository(db *sql.DB) *MilestoneRepository {
+	return &MilestoneRepository{DB: db}
+}
+
+func scanMilestone(row rowScanner, milestone *models.Milestone) error {
+	var description sql.NullString
+	err := row.Scan(&milestone.ID, &milestone.UserID, &milestone.Title, &description, &milestone.DueDate, &milestone.State, &milestone.Created_at, &milestone.Updated_at)
+	milestone.Description = description.String
+	return err
+}
+
+func (m *MilestoneRepository) CreateMilestone(milestone *models.Milestone) error {
+	milestone.DueDate = inUTC(milestone.DueDate)
+
+	stmt, err := m.DB.Prepare(`INSERT INTO milestones (userID, title, description, dueDate, state) VALUES ($1, $2, $3, $4, $5) RETURNING id, createdAt, updatedAt`)
+	if err != nil {
+		log.Print("cannot prepare statement to create milestone:", err)
+		return err
+	}
+
+	err = stmt.QueryRow(milestone.UserID, milestone.Title, milestone.Description, milestone.DueDate, milestone.State).Scan(&milestone.ID, &milestone.Created_at, &milestone.Updated_at)
+	if err != nil {
+		log.Print("cannot scan row to create milestone:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (m *MilestoneRepository) GetAllMilestonesByUserID(userID int) ([]models.Milestone, error) {
+	stmt, err := m.DB.Prepare(`SELECT ` + milestoneColumns + ` FROM milestones WHERE userID = $1 ORDER BY dueDate NULLS LAST, id`)
+	if err != nil {
+		log.Print("cannot prepare statement to get all milestones:", err)
+		return nil, err
+	}
+
+	rows, err := stmt.Query(userID)
+	if err != nil {
+		log.Print("cannot execute statement to get all milestones:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var milestones []models.Milestone
+
+	for rows.Next() {
+		var milestone models.Milestone
+		if err := scanMilestone(rows, &milestone); err != nil {
+			log.Print("cannot scan row to get all milestones:", err)
+			return nil, err
+		}
+
+		milestones = append(milestones, milestone)
+	}
+
+	return milestones, nil
+}
+
+func (m *MilestoneRepository) GetMilestoneByID(milestoneID int, userID int) (*models.Milestone, error) {
+	stmt, err := m.DB.Prepare(`SELECT ` + milestoneColumns + ` FROM milestones WHERE id = $1 AND userID = $2`)
+	if err != nil {
+		log.Print("cannot prepare statement to get milestone:", err)
+		return nil, err
+	}
+
+	var milestone models.Milestone
+
+	err = scanMilestone(stmt.QueryRow(milestoneID, userID), &milestone)
+	if err != nil {
+		log.Print("cannot scan row to get milestone:", err)
+		return nil, err
+	}
+
+	return &milestone, nil
+}
+
+// GetMilestoneProgress aggregates the milestone's tasks in a single query
+func (m *MilestoneRepository) GetMilestoneProgress(milestoneID int, userID int) (*models.MilestoneProgress, error) {
+	stmt, err := m.DB.Prepare(`
+		SELECT
+			COUNT(*),
+			COALESCE(ROUND(100.0 * COUNT(*) FILTER (WHERE status = 'completed') / NULLIF(COUNT(*), 0), 2), 0),
+			COUNT(*) FILTER (WHERE status = 'pending'),
+			COUNT(*) FILTER (WHERE status = 'in_progress'),
+			COUNT(*) FILTER (WHERE status = 'completed'),
+			COUNT(*) FILTER (WHERE status <> 'completed' AND dueAt < NOW())
+		FROM tasks
+		WHERE milestoneID = $1 AND userID = $2`)
+	if err != nil {
+		log.Print("cannot prepare statement to get milestone progress:", err)
+		return nil, err
+	}
+
+	var progress models.MilestoneProgress
+	var pending, inProgress, completed int
+
+	err = stmt.QueryRow(milestoneID, userID).Scan(&progress.TotalTasks, &progress.PercentComplete, &pending, &inProgress, &completed, &progress.OverdueTasks)
+	if err != nil {
+		log.Print("cannot scan row to get milestone progress:", err)
+		return nil, err
+	}
+
+	progress.ByStatus = map[string]int{
+		"pending":     pending,
+		"in_progress": inProgress,
+		"completed":   completed,
+	}
+
+	return &progress, nil
+}
+
+func (m *MilestoneRepository) CountOpenTasks(milestoneID int, userID int) (int, error) {
+	var count int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM tasks WHERE milestoneID = $1 AND userID = $2 AND status <> 'completed'`, milestoneID, userID).Scan(&count)
+	if err != nil {
+		log.Print("cannot count open tasks of milestone:", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (m *MilestoneRepository) UpdateMilestone(milestone *models.Milestone) error {
+	milestone.DueDate = inUTC(milestone.DueDate)
+
+	query := "UPDATE milestones SET title = $1, description = $2, dueDate = $3, updatedAt = NOW() WHERE id = $4 AND userID = $5"
+
+	result, err := m.DB.Exec(query, milestone.Title, milestone.Description, milestone.DueDate, milestone.ID, milestone.UserID)
+	if err != nil {
+		log.Print("cannot execute statement to update milestone:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("milestone not found or you don't have permission to update it")
+	}
+
+	return nil
+}
+
+// CloseMilestone closes the milestone; when moveTo is set, its open tasks are
+// reassigned to that milestone in the same transaction
+func (m *MilestoneRepository) CloseMilestone(milestoneID int, userID int, moveTo *int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		log.Print("cannot begin transaction to close milestone:", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if moveTo != nil {
+		_, err = tx.Exec(`UPDATE tasks SET milestoneID = $1, updatedAt = NOW() WHERE milestoneID = $2 AND userID = $3 AND status <> 'completed'`, *moveTo, milestoneID, userID)
+		if err != nil {
+			log.Print("cannot move open tasks of milestone:", err)
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`UPDATE milestones SET state = 'closed', updatedAt = NOW() WHERE id = $1 AND userID = $2`, milestoneID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to close milestone:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("milestone not found or you don't have permission to close it")
+	}
+
+	return tx.Commit()
+}
+
+func (m *MilestoneRepository) ReopenMilestone(milestoneID int, userID int) error {
+	result, err := m.DB.Exec(`UPDATE milestones SET state = 'open', updatedAt = NOW() WHERE id = $1 AND userID = $2`, milestoneID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to reopen milestone:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("milestone not found or you don't have permission to reopen it")
+	}
+
+	return nil
+}
+
+func (m *MilestoneRepository) DeleteMilestone(milestoneID int, userID int) error {
+	stmt, err := m.DB.Prepare("DELETE FROM milestones WHERE id = $1 AND userID = $2")
+	if err != nil {
+		log.Print("cannot prepare statement to delete milestone:", err)
+		return err
+	}
+
+	result, err := stmt.Exec(milestoneID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to delete milestone:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("milestone not found or you don't have permission to delete it")
+	}
+
+	return nil
+}
+
+// GetMilestonesByIDs loads the given milestones of the user in one query, keyed by ID
+func (m *MilestoneRepository) GetMilestonesByIDs(userID int, ids []int) (map[int]*models.Milestone, error) {
+	milestones := make(map[int]*models.Milestone, len(ids))
+	if len(ids) == 0 {
+		return milestones, nil
+	}
+
+	rows, err := m.DB.Query(`SELECT `+milestoneColumns+` FROM milestones WHERE userID = $1 AND id = ANY($2)`, userID, pq.Array(ids))
+	if err != nil {
+		log.Print("cannot execute statement to get milestones by ids:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var milestone models.Milestone
+		if err := scanMilestone(rows, &milestone); err != nil {
+			log.Print("cannot scan row to get milestones by ids:", err)
+			return nil, err
+		}
+		milestones[milestone.ID] = &milestone
+	}
+
+	return milestones, rows.Err()
+}
+
+// GetMilestoneProgresses is GetMilestoneProgress for several milestones in one query
+func (m *MilestoneRepository) GetMilestoneProgresses(userID int, ids []int) (map[int]*models.MilestoneProgress, error) {
+	progresses := make(map[int]*models.MilestoneProgress, len(ids))
+	for _, id := range ids {
+		progresses[id] = &models.MilestoneProgress{ByStatus: map[string]int{"pending": 0, "in_progress": 0, "completed": 0}}
+	}
+	if len(ids) == 0 {
+		return progresses, nil
+	}
+
+	rows, err := m.DB.Query(`
+		SELECT
+			milestoneID,
+			COUNT(*),
+			COALESCE(ROUND(100.0 * COUNT(*) FILTER (WHERE status = 'completed') / NULLIF(COUNT(*), 0), 2), 0),
+			COUNT(*) FILTER (WHERE status = 'pending'),
+			COUNT(*) FILTER (WHERE status = 'in_progress'),
+			COUNT(*) FILTER (WHERE status = 'completed'),
+			COUNT(*) FILTER (WHERE status <> 'completed' AND dueAt < NOW())
+		FROM tasks
+		WHERE milestoneID = ANY($1) AND userID = $2
+		GROUP BY milestoneID`, pq.Array(ids), userID)
+	if err != nil {
+		log.Print("cannot execute statement to get milestone progresses:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var milestoneID, pending, inProgress, completed int
+		var progress models.MilestoneProgress
+
+		if err := rows.Scan(&milestoneID, &progress.TotalTasks, &progress.PercentComplete, &pending, &inProgress, &completed, &progress.OverdueTasks); err != nil {
+			log.Print("cannot scan row to get milestone progresses:", err)
+			return nil, err
+		}
+
+		progress.ByStatus = map[string]int{
+			"pending":     pending,
+			"in_progress": inProgress,
+			"completed":   completed,
+		}
+		progresses[milestoneID] = &progress
+	}
+
+	return progresses, rows.Err()
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+type NotificationRepository struct {
+	DB *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) *NotificationRepository {
+	return &NotificationRepository{DB: db}
+}
+
+func (n *NotificationRepository) CreateNotification(notification *models.Notification) error {
+	err := n.DB.QueryRow(`INSERT INTO notifications (userID, taskID, title, body) VALUES ($1, $2, $3, $4) RETURNING id, createdAt`,
+		notification.UserID, notification.TaskID, notification.Title, notification.Body).Scan(&notification.ID, &notification.Created_at)
+	if err != nil {
+		log.Print("cannot scan row to create notification:", err)
+		return err
+	}
+
+	return nil
+}
+
+// CreateReminderNotification adds the inbox entry of a reminder. The
+// scheduler delivers at least once, an entry the reminder already added is
+// left as it is.
+func (n *NotificationRepository) CreateReminderNotification(reminderID int, notification *models.Notification) error {
+	_, err := n.DB.Exec(`INSERT INTO notifications (userID, taskID, reminderID, title, body) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (reminderID) DO NOTHING`,
+		notification.UserID, notification.TaskID, reminderID, notification.Title, notification.Body)
+	if err != nil {
+		log.Print("cannot execute statement to create reminder notification:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (n *NotificationRepository) GetNotificationsByUserID(userID int, unreadOnly bool) ([]models.Notification, error) {
+	stmt, err := n.DB.Prepare(`SELECT id, userID, taskID, title, body, read, createdAt FROM notifications WHERE userID = $1 AND (NOT $2 OR NOT read) ORDER BY createdAt DESC, id DESC LIMIT 100`)
+	if err != nil {
+		log.Print("cannot prepare statement to get notifications:", err)
+		return nil, err
+	}
+
+	rows, err := stmt.Query(userID, unreadOnly)
+	if err != nil {
+		log.Print("cannot execute statement to get notifications:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+
+	for rows.Next() {
+		var notification models.Notification
+		if err := rows.Scan(&notification.ID, &notification.UserID, &notification.TaskID, &notification.Title, &notification.Body, &notification.Read, &notification.Created_at); err != nil {
+			log.Print("cannot scan row to get notifications:", err)
+			return nil, err
+		}
+
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+func (n *NotificationRepository) MarkNotificationRead(notificationID int, userID int) error {
+	result, err := n.DB.Exec("UPDATE notifications SET read = TRUE WHERE id = $1 AND userID = $2", notificationID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to mark notification read:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("notification not found or you don't have permission to update it")
+	}
+
+	return nil
+}
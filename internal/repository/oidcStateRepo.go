@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+type OIDCStateRepository struct {
+	DB *sql.DB
+}
+
+func NewOIDCStateRepository(db *sql.DB) *OIDCStateRepository {
+	return &OIDCStateRepository{DB: db}
+}
+
+func (o *OIDCStateRepository) CreateState(state *models.OIDCLoginState) error {
+	_, err := o.DB.Exec(`INSERT INTO oidc_login_states (stateHash, provider, nonce, codeVerifier, expiresAt) VALUES ($1, $2, $3, $4, $5)`,
+		state.StateHash, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt)
+	if err != nil {
+		log.Print("cannot execute statement to create OIDC state:", err)
+	}
+
+	return err
+}
+
+// UseState deletes the state and returns it, so a callback can only be
+// answered once. It returns sql.ErrNoRows when the state is unknown,
+// expired or belongs to another provider.
+func (o *OIDCStateRepository) UseState(stateHash string, provider string) (*models.OIDCLoginState, error) {
+	var state models.OIDCLoginState
+
+	err := o.DB.QueryRow(`DELETE FROM oidc_login_states WHERE stateHash = $1 AND provider = $2 AND expiresAt > NOW()
+		RETURNING stateHash, provider, nonce, codeVerifier, createdAt, expiresAt`, stateHash, provider).
+		Scan(&state.StateHash, &state.Provider, &state.Nonce, &state.CodeVerifier, &state.Created_at, &state.ExpiresAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Print("cannot scan row to use OIDC state:", err)
+		}
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func (o *OIDCStateRepository) PruneExpired() error {
+	_, err := o.DB.Exec(`DELETE FROM oidc_login_states WHERE expiresAt <= NOW()`)
+	if err != nil {
+		log.Print("cannot execute statement to prune OIDC states:", err)
+	}
+
+	return err
+}
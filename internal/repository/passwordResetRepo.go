@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+type PasswordResetRepository struct {
+	DB *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{DB: db}
+}
+
+func (p *PasswordResetRepository) CreateResetToken(userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := p.DB.Exec(`INSERT INTO password_reset_tokens (tokenHash, userID, expiresAt) VALUES ($1, $2, $3)`, tokenHash, userID, expiresAt)
+	if err != nil {
+		log.Print("cannot execute statement to create password reset token:", err)
+	}
+
+	return err
+}
+
+// UseResetToken marks the token as used and returns its user. The other
+// tokens of the user are used up as well. It returns sql.ErrNoRows when
+// the token is unknown, expired or already used.
+func (p *PasswordResetRepository) UseResetToken(tokenHash string) (int, error) {
+	var userID int
+
+	err := p.DB.QueryRow(`
+		WITH used AS (
+			UPDATE password_reset_tokens SET usedAt = NOW()
+			WHERE tokenHash = $1 AND usedAt IS NULL AND expiresAt > NOW()
+			RETURNING userID
+		), others AS (
+			UPDATE password_reset_tokens SET usedAt = NOW()
+			WHERE userID IN (SELECT userID FROM used) AND tokenHash <> $1 AND usedAt IS NULL
+		)
+		SELECT userID FROM used`, tokenHash).Scan(&userID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Print("cannot scan row to use password reset token:", err)
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (p *PasswordResetRepository) PruneExpired() error {
+	_, err := p.DB.Exec(`DELETE FROM password_reset_tokens WHERE expiresAt <= NOW()`)
+	if err != nil {
+		log.Print("cannot execute statement to prune password reset tokens:", err)
+	}
+
+	return err
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/lib/pq"
+)
+
+type PersonalTokenRepository struct {
+	DB *sql.DB
+}
+
+func NewPersonalTokenRepository(db *sql.DB) *PersonalTokenRepository {
+	return &PersonalTokenRepository{DB: db}
+}
+
+const personalTokenColumns = `id, userID, name, prefix, tokenHash, scopes, createdAt, expiresAt, lastUsedAt, revokedAt`
+
+func scanPersonalToken(row rowScanner, token *models.PersonalAccessToken, extra ...interface{}) error {
+	dest := []interface{}{&token.ID, &token.UserID, &token.Name, &token.Prefix, &token.TokenHash, pq.Array(&token.Scopes),
+		&token.Created_at, &token.ExpiresAt, &token.LastUsedAt, &token.RevokedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
+func (p *PersonalTokenRepository) CreateToken(token *models.PersonalAccessToken) error {
+	err := p.DB.QueryRow(`INSERT INTO personal_access_tokens (userID, name, prefix, tokenHash, scopes, expiresAt) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, createdAt`,
+		token.UserID, token.Name, token.Prefix, token.TokenHash, pq.Array(token.Scopes), token.ExpiresAt).Scan(&token.ID, &token.Created_at)
+	if err != nil {
+		log.Print("cannot execute statement to create personal access token:", err)
+	}
+
+	return err
+}
+
+// GetTokens returns the tokens of the user that are neither revoked nor expired
+func (p *PersonalTokenRepository) GetTokens(userID int) ([]models.PersonalAccessToken, error) {
+	rows, err := p.DB.Query(`SELECT `+personalTokenColumns+` FROM personal_access_tokens
+		WHERE userID = $1 AND revokedAt IS NULL AND expiresAt > NOW() ORDER BY createdAt DESC`, userID)
+	if err != nil {
+		log.Print("cannot query personal access tokens:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.PersonalAccessToken{}
+	for rows.Next() {
+		var token models.PersonalAccessToken
+		if err := scanPersonalToken(rows, &token); err != nil {
+			log.Print("cannot scan row to get personal access tokens:", err)
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// GetActiveToken finds a valid token by its hash and returns it with the
+// current role of its user. It returns sql.ErrNoRows when the token is
+// unknown, revoked or expired.
+func (p *PersonalTokenRepository) GetActiveToken(tokenHash string) (*models.PersonalAccessToken, string, error) {
+	var token models.PersonalAccessToken
+	var role string
+
+	row := p.DB.QueryRow(`SELECT t.id, t.userID, t.name, t.prefix, t.tokenHash, t.scopes, t.createdAt, t.expiresAt, t.lastUsedAt, t.revokedAt, u.role
+		FROM personal_access_tokens t JOIN users u ON u.id = t.userID
+		WHERE t.tokenHash = $1 AND t.revokedAt IS NULL AND t.expiresAt > NOW()`, tokenHash)
+	if err := scanPersonalToken(row, &token, &role); err != nil {
+		if err != sql.ErrNoRows {
+			log.Print("cannot scan row to get personal access token:", err)
+		}
+		return nil, "", err
+	}
+
+	return &token, role, nil
+}
+
+// TouchToken records a use of the token, at most once a minute so busy
+// scripts do not write on every request
+func (p *PersonalTokenRepository) TouchToken(id int) error {
+	_, err := p.DB.Exec(`UPDATE personal_access_tokens SET lastUsedAt = NOW()
+		WHERE id = $1 AND (lastUsedAt IS NULL OR lastUsedAt < NOW() - INTERVAL '1 minute')`, id)
+	if err != nil {
+		log.Print("cannot execute statement to touch personal access token:", err)
+	}
+
+	return err
+}
+
+// RevokeToken revokes a token of the user, it returns false when the user has no such active token
+func (p *PersonalTokenRepository) RevokeToken(userID int, id int) (bool, error) {
+	result, err := p.DB.Exec(`UPDATE personal_access_tokens SET revokedAt = NOW() WHERE id = $1 AND userID = $2 AND revokedAt IS NULL`, id, userID)
+	if err != nil {
+		log.Print("cannot execute statement to revoke personal access token:", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Print("cannot get rows affected to revoke personal access token:", err)
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// RevokeUserTokens revokes every token of the user
+func (p *PersonalTokenRepository) RevokeUserTokens(userID int) error {
+	_, err := p.DB.Exec(`UPDATE personal_access_tokens SET revokedAt = NOW() WHERE userID = $1 AND revokedAt IS NULL`, userID)
+	if err != nil {
+		log.Print("cannot execute statement to revoke personal access tokens:", err)
+	}
+
+	return err
+}
+
+// PruneExpired deletes tokens that expired or were revoked over a month ago
+func (p *PersonalTokenRepository) PruneExpired() error {
+	_, err := p.DB.Exec(`DELETE FROM personal_access_tokens WHERE COALESCE(revokedAt, expiresAt) <= NOW() - INTERVAL '30 days'`)
+	if err != nil {
+		log.Print("cannot execute statement to prune personal access tokens:", err)
+	}
+
+	return err
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+type RefreshTokenRepository struct {
+	DB *sql.DB
+}
+
+func NewRefreshTokenRepository(db *sql.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{DB: db}
+}
+
+func (r *RefreshTokenRepository) CreateRefreshToken(token *models.RefreshToken) error {
+	err := r.DB.QueryRow(`INSERT INTO refresh_tokens (userID, familyID, tokenHash, expiresAt) VALUES ($1, $2, $3, $4) RETURNING id, createdAt`,
+		token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.Created_at)
+	if err != nil {
+		log.Print("cannot execute statement to create refresh token:", err)
+	}
+
+	return err
+}
+
+// UseRefreshToken marks the token as used and returns it. It returns
+// sql.ErrNoRows when the token is unknown, expired, revoked or already
+// used, only one of concurrent refreshes with the same token gets it.
+func (r *RefreshTokenRepository) UseRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+
+	err := r.DB.QueryRow(`
+		UPDATE refresh_tokens SET usedAt = NOW()
+		WHERE tokenHash = $1 AND usedAt IS NULL AND revokedAt IS NULL AND expiresAt > NOW()
+		RETURNING id, userID, familyID, tokenHash, createdAt, expiresAt, usedAt`, tokenHash).
+		Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.Created_at, &token.ExpiresAt, &token.UsedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Print("cannot scan row to use refresh token:", err)
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *RefreshTokenRepository) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+
+	err := r.DB.QueryRow(`SELECT id, userID, familyID, tokenHash, createdAt, expiresAt, usedAt, revokedAt FROM refresh_tokens WHERE tokenHash = $1`, tokenHash).
+		Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.Created_at, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Print("cannot scan row to get refresh token:", err)
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// PruneExpired deletes expired tokens. Used tokens are kept until then so
+// that a stolen token is still recognized when it is replayed. Tokens are
+// revoked together with their session, see SessionRepository.
+func (r *RefreshTokenRepository) PruneExpired() error {
+	_, err := r.DB.Exec(`DELETE FROM refresh_tokens WHERE expiresAt <= NOW()`)
+	if err != nil {
+		log.Print("cannot execute statement to prune refresh tokens:", err)
+	}
+
+	return err
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/lib/pq"
+)
+
+const reminderColumns = "id, taskID, userID, remindAt, offsetMinutes, channel, target, status, attempts, lastError, sentAt, createdAt"
+
+type ReminderRepository struct {
+	DB *sql.DB
+}
+
+// DueReminder is a reminder whose time has come, with what is needed to deliver it
+type DueReminder struct {
+	Reminder  models.Reminder
+	TaskTitle string
+	DueAt     *time.Time
+	Email     string
+}
+
+func NewReminderRepository(db *sql.DB) *ReminderRepository {
+	return &ReminderRepository{DB: db}
+}
+
+func scanReminder(row rowScanner, reminder *models.Reminder) error {
+	return row.Scan(&reminder.ID, &reminder.TaskID, &reminder.UserID, &reminder.RemindAt, &reminder.OffsetMinutes, &reminder.Channel, &reminder.Target, &reminder.Status, &reminder.Attempts, &reminder.LastError, &reminder.SentAt, &reminder.Created_at)
+}
+
+func (r *ReminderRepository) CreateReminder(reminder *models.Reminder) error {
+	reminder.RemindAt = inUTC(reminder.RemindAt)
+
+	stmt, err := r.DB.Prepare(`INSERT INTO reminders (taskID, userID, remindAt, offsetMinutes, channel, target) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, status, createdAt`)
+	if err != nil {
+		log.Print("cannot prepare statement to create reminder:", err)
+		return err
+	}
+
+	err = stmt.QueryRow(reminder.TaskID, reminder.UserID, reminder.RemindAt, reminder.OffsetMinutes, reminder.Channel, reminder.Target).Scan(&reminder.ID, &reminder.Status, &reminder.Created_at)
+	if err != nil {
+		log.Print("cannot scan row to create reminder:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *ReminderRepository) GetRemindersByTaskID(taskID int, userID int) ([]models.Reminder, error) {
+	stmt, err := r.DB.Prepare(`SELECT ` + reminderColumns + ` FROM reminders WHERE taskID = $1 AND userID = $2 ORDER BY id`)
+	if err != nil {
+		log.Print("cannot prepare statement to get reminders:", err)
+		return nil, err
+	}
+
+	rows, err := stmt.Query(taskID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to get reminders:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []models.Reminder
+
+	for rows.Next() {
+		var reminder models.Reminder
+		if err := scanReminder(rows, &reminder); err != nil {
+			log.Print("cannot scan row to get reminders:", err)
+			return nil, err
+		}
+
+		reminders = append(reminders, reminder)
+	}
+
+	return reminders, nil
+}
+
+func (r *ReminderRepository) DeleteReminder(reminderID int, taskID int, userID int) error {
+	result, err := r.DB.Exec("DELETE FROM reminders WHERE id = $1 AND taskID = $2 AND userID = $3", reminderID, taskID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to delete reminder:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("reminder not found or you don't have permission to delete it")
+	}
+
+	return nil
+}
+
+// ClaimDueReminders picks up to limit due reminders and leases them: they
+// are not due again until lease has passed. The rows are locked with SKIP
+// LOCKED only while they are claimed, so several server instances never pick
+// the same reminder and nothing stays locked while it is delivered. A reminder
+// whose instance died before CompleteReminder or FailReminder is picked up
+// again once its lease runs out.
+func (r *ReminderRepository) ClaimDueReminders(limit int, lease time.Duration) ([]DueReminder, error) {
+	rows, err := r.DB.Query(`
+		WITH due AS (
+			SELECT r.id
+			FROM reminders r
+			JOIN tasks t ON t.id = r.taskID
+			WHERE r.status = 'pending'
+				AND COALESCE(r.nextAttemptAt, r.remindAt, t.dueAt + r.offsetMinutes * INTERVAL '1 minute') <= NOW()
+			ORDER BY r.id
+			LIMIT $1
+			FOR UPDATE OF r SKIP LOCKED
+		)
+		UPDATE reminders r SET nextAttemptAt = NOW() + $2 * INTERVAL '1 second'
+		FROM due, tasks t, users u
+		WHERE r.id = due.id AND t.id = r.taskID AND u.id = r.userID
+		RETURNING r.id, r.taskID, r.userID, r.remindAt, r.offsetMinutes, r.channel, r.target, r.status, r.attempts, r.lastError, r.sentAt, r.createdAt,
+			t.title, t.dueAt, u.email`, limit, lease.Seconds())
+	if err != nil {
+		log.Print("cannot execute statement to claim due reminders:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DueReminder
+	for rows.Next() {
+		var d DueReminder
+		rm := &d.Reminder
+		if err := rows.Scan(&rm.ID, &rm.TaskID, &rm.UserID, &rm.RemindAt, &rm.OffsetMinutes, &rm.Channel, &rm.Target, &rm.Status, &rm.Attempts, &rm.LastError, &rm.SentAt, &rm.Created_at, &d.TaskTitle, &d.DueAt, &d.Email); err != nil {
+			log.Print("cannot scan row to claim due reminders:", err)
+			return nil, err
+		}
+		due = append(due, d)
+	}
+
+	return due, rows.Err()
+}
+
+// CompleteReminder marks a claimed reminder sent. Reminders deleted in the
+// meantime are left alone.
+func (r *ReminderRepository) CompleteReminder(reminderID int) error {
+	_, err := r.DB.Exec(`UPDATE reminders SET attempts = attempts + 1, status = 'sent', lastError = '', sentAt = NOW(), nextAttemptAt = NULL WHERE id = $1 AND status = 'pending'`, reminderID)
+	if err != nil {
+		log.Print("cannot execute statement to complete reminder:", err)
+		return err
+	}
+
+	return nil
+}
+
+// FailReminder records a failed attempt. The reminder is retried with
+// exponential backoff until maxAttempts is reached.
+func (r *ReminderRepository) FailReminder(reminder models.Reminder, maxAttempts int, cause error) error {
+	attempts := reminder.Attempts + 1
+	status := "pending"
+	if attempts >= maxAttempts {
+		status = "failed"
+	}
+
+	_, err := r.DB.Exec(`UPDATE reminders SET attempts = $1, status = $2, lastError = $3, nextAttemptAt = NOW() + $4 * INTERVAL '1 second' WHERE id = $5 AND status = 'pending'`,
+		attempts, status, cause.Error(), retryDelay(attempts).Seconds(), reminder.ID)
+	if err != nil {
+		log.Print("cannot execute statement to fail reminder:", err)
+		return err
+	}
+
+	return nil
+}
+
+// retryDelay doubles the wait after every failed attempt, up to an hour
+func retryDelay(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > time.Hour {
+		delay = time.Hour
+	}
+	return delay
+}
+
+// GetRemindersByTaskIDs loads the reminders of the given tasks in one query, keyed by task ID
+func (r *ReminderRepository) GetRemindersByTaskIDs(userID int, taskIDs []int) (map[int][]models.Reminder, error) {
+	reminders := make(map[int][]models.Reminder, len(taskIDs))
+	if len(taskIDs) == 0 {
+		return reminders, nil
+	}
+
+	rows, err := r.DB.Query(`SELECT `+reminderColumns+` FROM reminders WHERE userID = $1 AND taskID = ANY($2) ORDER BY id`, userID, pq.Array(taskIDs))
+	if err != nil {
+		log.Print("cannot execute statement to get reminders by task ids:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reminder models.Reminder
+		if err := scanReminder(rows, &reminder); err != nil {
+			log.Print("cannot scan row to get reminders by task ids:", err)
+			return nil, err
+		}
+		reminders[reminder.TaskID] = append(reminders[reminder.TaskID], reminder)
+	}
+
+	return reminders, rows.Err()
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		3:  4 * time.Minute,
+		6:  32 * time.Minute,
+		7:  time.Hour,
+		50: time.Hour,
+	}
+
+	for attempts, want := range tests {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
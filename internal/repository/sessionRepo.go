@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/lib/pq"
+)
+
+// SessionRevocationsChannel is notified with the ID of every revoked session
+const SessionRevocationsChannel = "session_revocations"
+
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+func (s *SessionRepository) CreateSession(session *models.Session) error {
+	err := s.DB.QueryRow(`INSERT INTO sessions (id, userID, userAgent, ip, expiresAt) VALUES ($1, $2, $3, $4, $5) RETURNING createdAt, lastUsedAt`,
+		session.ID, session.UserID, session.UserAgent, session.IP, session.ExpiresAt).Scan(&session.Created_at, &session.LastUsedAt)
+	if err != nil {
+		log.Print("cannot execute statement to create session:", err)
+	}
+
+	return err
+}
+
+// GetSessions returns the sessions of the user that are neither revoked nor expired, most recently used first
+func (s *SessionRepository) GetSessions(userID int) ([]models.Session, error) {
+	rows, err := s.DB.Query(`SELECT id, userID, userAgent, ip, createdAt, lastUsedAt, expiresAt FROM sessions
+		WHERE userID = $1 AND revokedAt IS NULL AND expiresAt > NOW() ORDER BY lastUsedAt DESC`, userID)
+	if err != nil {
+		log.Print("cannot query sessions:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.Created_at, &session.LastUsedAt, &session.ExpiresAt); err != nil {
+			log.Print("cannot scan row to get session:", err)
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// ExtendSession records a refresh of the session, expiresAt follows its newest refresh token
+func (s *SessionRepository) ExtendSession(id string, ip string, expiresAt time.Time) error {
+	_, err := s.DB.Exec(`UPDATE sessions SET ip = $1, lastUsedAt = NOW(), expiresAt = $2 WHERE id = $3`, ip, expiresAt, id)
+	if err != nil {
+		log.Print("cannot execute statement to extend session:", err)
+	}
+
+	return err
+}
+
+// TouchSessions sets the last use of sessions seen by the API, times are unix seconds
+func (s *SessionRepository) TouchSessions(ids []string, lastUsed []int64) error {
+	_, err := s.DB.Exec(`
+		UPDATE sessions SET lastUsedAt = to_timestamp(seen.at)
+		FROM unnest($1::text[], $2::bigint[]) AS seen(id, at)
+		WHERE sessions.id = seen.id AND sessions.lastUsedAt < to_timestamp(seen.at)`,
+		pq.Array(ids), pq.Array(lastUsed))
+	if err != nil {
+		log.Print("cannot execute statement to touch sessions:", err)
+	}
+
+	return err
+}
+
+// RevokeSession revokes the session of the user along with its refresh
+// tokens and notifies every server. It returns false when the user has
+// no such active session.
+func (s *SessionRepository) RevokeSession(userID int, id string) (bool, error) {
+	ids, err := s.revoke(`id = $1 AND userID = $2`, id, userID)
+	return len(ids) > 0, err
+}
+
+// RevokeUserSessions revokes every session of the user but exceptID, which may be empty, and returns their IDs
+func (s *SessionRepository) RevokeUserSessions(userID int, exceptID string) ([]string, error) {
+	return s.revoke(`userID = $1 AND id <> $2`, userID, exceptID)
+}
+
+func (s *SessionRepository) revoke(where string, args ...interface{}) ([]string, error) {
+	rows, err := s.DB.Query(`
+		WITH revoked AS (
+			UPDATE sessions SET revokedAt = NOW() WHERE `+where+` AND revokedAt IS NULL RETURNING id
+		), tokens AS (
+			UPDATE refresh_tokens SET revokedAt = NOW() WHERE familyID IN (SELECT id FROM revoked) AND revokedAt IS NULL
+		)
+		SELECT revoked.id FROM revoked, pg_notify('`+SessionRevocationsChannel+`', revoked.id)`, args...)
+	if err != nil {
+		log.Print("cannot query to revoke sessions:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Print("cannot scan row to revoke session:", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetRevokedSessionIDs returns the sessions revoked within the last
+// period, access tokens issued before that have expired anyway
+func (s *SessionRepository) GetRevokedSessionIDs(period time.Duration) ([]string, error) {
+	rows, err := s.DB.Query(`SELECT id FROM sessions WHERE revokedAt > NOW() - $1 * INTERVAL '1 second'`, period.Seconds())
+	if err != nil {
+		log.Print("cannot query revoked sessions:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Print("cannot scan row to get revoked session:", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// PruneExpired deletes sessions whose refresh tokens have all expired, the tokens go with them
+func (s *SessionRepository) PruneExpired() error {
+	_, err := s.DB.Exec(`DELETE FROM sessions WHERE expiresAt <= NOW()`)
+	if err != nil {
+		log.Print("cannot execute statement to prune sessions:", err)
+	}
+
+	return err
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+)
+
+// SettingsRepository stores switches admins change at runtime
+type SettingsRepository struct {
+	DB *sql.DB
+}
+
+func NewSettingsRepository(db *sql.DB) *SettingsRepository {
+	return &SettingsRepository{DB: db}
+}
+
+// GetSetting returns the value of key, empty when it was never set
+func (s *SettingsRepository) GetSetting(key string) (string, error) {
+	var value string
+
+	err := s.DB.QueryRow(`SELECT value FROM settings WHERE key = $1`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		log.Print("cannot scan row to get setting:", err)
+		return "", err
+	}
+
+	return value, nil
+}
+
+func (s *SettingsRepository) SetSetting(key string, value string) error {
+	_, err := s.DB.Exec(`INSERT INTO settings (key, value) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updatedAt = NOW()`, key, value)
+	if err != nil {
+		log.Print("cannot execute statement to set setting:", err)
+	}
+
+	return err
+}
@@ -0,0 +1,489 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/lib/pq"
+)
+
+// Outcomes of a pushed sync change
+const (
+	SyncApplied  = "applied"
+	SyncConflict = "conflict"
+	SyncDeleted  = "deleted"
+	SyncError    = "error"
+)
+
+// SyncFields are the task fields a client may change through sync, by JSON name.
+// parent_id is only taken when a task is created, like in the REST API.
+var SyncFields = map[string]bool{
+	"title":         true,
+	"description":   true,
+	"status":        true,
+	"priority":      true,
+	"project":       true,
+	"recurrence":    true,
+	"tags":          true,
+	"due_at":        true,
+	"milestone_id":  true,
+	"snoozed_until": true,
+	"pinned":        true,
+}
+
+// SyncChanges is one page of changes after a sync version
+type SyncChanges struct {
+	Tasks   []models.Task
+	Deleted []models.TaskTombstone
+	Version int64
+	HasMore bool
+}
+
+// SyncCheckFunc validates a task before a pushed change is written
+type SyncCheckFunc func(task *models.Task) error
+
+// GetChangesSince returns up to limit tasks created, updated or deleted after
+// version since. Version 0 is a full sync and skips tombstones.
+func (t *TaskRepository) GetChangesSince(userID int, since int64, limit int) (*SyncChanges, error) {
+	// one snapshot for the counter and the rows, so nothing up to the counter is missed
+	tx, err := t.DB.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		log.Print("cannot begin transaction to get changes:", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	changes := &SyncChanges{}
+
+	if err := tx.QueryRow(`SELECT syncVersion FROM users WHERE id = $1`, userID).Scan(&changes.Version); err != nil {
+		log.Print("cannot scan row to get sync version:", err)
+		return nil, err
+	}
+
+	if since > changes.Version {
+		return nil, ErrSyncVersionAhead
+	}
+
+	// versions are unique per user, the one past the page tells where to cut it
+	var next sql.NullInt64
+	err = tx.QueryRow(`
+		SELECT v FROM (
+			SELECT syncVersion AS v FROM tasks WHERE userID = $1 AND syncVersion > $2 AND syncVersion <= $3
+			UNION ALL
+			SELECT syncVersion FROM task_tombstones WHERE userID = $1 AND syncVersion > $2 AND syncVersion <= $3 AND $2 > 0
+		) changes ORDER BY v OFFSET $4 LIMIT 1`, userID, since, changes.Version, limit).Scan(&next)
+	if err != nil && err != sql.ErrNoRows {
+		log.Print("cannot scan row to page changes:", err)
+		return nil, err
+	}
+	if next.Valid {
+		changes.Version = next.Int64 - 1
+		changes.HasMore = true
+	}
+
+	rows, err := tx.Query(`SELECT `+taskColumns+` FROM tasks WHERE userID = $1 AND syncVersion > $2 AND syncVersion <= $3 ORDER BY syncVersion`, userID, since, changes.Version)
+	if err != nil {
+		log.Print("cannot execute statement to get changed tasks:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var task models.Task
+		if err := scanTask(rows, &task); err != nil {
+			log.Print("cannot scan row to get changed tasks:", err)
+			return nil, err
+		}
+		changes.Tasks = append(changes.Tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if since == 0 {
+		return changes, nil
+	}
+
+	tombstones, err := tx.Query(`SELECT taskID, clientID, deletedAt FROM task_tombstones WHERE userID = $1 AND syncVersion > $2 AND syncVersion <= $3 ORDER BY syncVersion`, userID, since, changes.Version)
+	if err != nil {
+		log.Print("cannot execute statement to get tombstones:", err)
+		return nil, err
+	}
+	defer tombstones.Close()
+
+	for tombstones.Next() {
+		var tombstone models.TaskTombstone
+		if err := tombstones.Scan(&tombstone.ID, &tombstone.ClientID, &tombstone.DeletedAt); err != nil {
+			log.Print("cannot scan row to get tombstones:", err)
+			return nil, err
+		}
+		changes.Deleted = append(changes.Deleted, tombstone)
+	}
+
+	return changes, tombstones.Err()
+}
+
+// Now returns the database clock. Fields changed outside of sync are stamped
+// with it by the trigger, so sync changes have to be timed on it as well.
+func (t *TaskRepository) Now() (time.Time, error) {
+	var now time.Time
+
+	if err := t.DB.QueryRow(`SELECT NOW()`).Scan(&now); err != nil {
+		log.Print("cannot scan row to get database time:", err)
+		return time.Time{}, err
+	}
+
+	return now, nil
+}
+
+// ErrSyncVersionAhead means the client holds a version the server never reached
+var ErrSyncVersionAhead = errors.New("sync version is ahead of the server")
+
+// ApplySyncChange applies one pushed change in its own transaction. Every field
+// is resolved on its own: the client value wins unless the field was written on
+// the server after the change was made, in which case it is reported as rejected.
+// at is the moment of the change on the database clock, see Now.
+func (t *TaskRepository) ApplySyncChange(userID int, change models.SyncChange, at time.Time, check SyncCheckFunc) (*models.SyncResult, error) {
+	result := &models.SyncResult{ClientID: change.ClientID}
+
+	tx, err := t.DB.Begin()
+	if err != nil {
+		log.Print("cannot begin transaction to apply sync change:", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// the version counter is taken first, the same order the trigger locks in
+	if _, err := tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+		log.Print("cannot lock user to apply sync change:", err)
+		return nil, err
+	}
+
+	task, stamps, err := lockSyncTarget(tx, userID, change)
+	if err != nil {
+		return nil, err
+	}
+
+	if task == nil {
+		deleted, err := isTombstoned(tx, userID, change)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case deleted:
+			result.Status = SyncDeleted
+			return result, nil
+		case change.Op != "create":
+			result.Status = SyncError
+			result.Error = "task not found"
+			return result, nil
+		}
+
+		task = &models.Task{UserID: userID, Status: "pending"}
+		if change.ClientID != "" {
+			clientID := change.ClientID
+			task.ClientID = &clientID
+		}
+	}
+
+	if change.Op == "delete" {
+		if latestStamp(task, stamps).After(at) {
+			result.ID = task.ID
+			result.Status = SyncConflict
+			result.Task = task
+			return result, nil
+		}
+
+		if _, err := tx.Exec(`DELETE FROM tasks WHERE id = $1 AND userID = $2`, task.ID, userID); err != nil {
+			log.Print("cannot execute statement to delete synced task:", err)
+			return nil, err
+		}
+
+		result.ID = task.ID
+		result.Status = SyncApplied
+		return result, tx.Commit()
+	}
+
+	accepted := make(map[string]json.RawMessage, len(change.Fields))
+	applied := make(map[string]string, len(change.Fields))
+	creating := task.ID == 0
+
+	for name, value := range change.Fields {
+		// replays of a create find the task already there, its parent stays as it is
+		if name == "parent_id" {
+			if creating {
+				accepted[name] = value
+			}
+			continue
+		}
+		if !SyncFields[name] {
+			result.Status = SyncError
+			result.Error = "field " + name + " cannot be synced"
+			return result, nil
+		}
+		if !creating && fieldStamp(task, stamps, name).After(at) {
+			result.RejectedFields = append(result.RejectedFields, name)
+			continue
+		}
+
+		accepted[name] = value
+		applied[name] = at.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+	}
+
+	raw, err := json.Marshal(accepted)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, task); err != nil {
+		result.Status = SyncError
+		result.Error = "invalid fields: " + err.Error()
+		return result, nil
+	}
+
+	if creating && change.ParentClientID != "" {
+		var parentID int
+		err := tx.QueryRow(`SELECT id FROM tasks WHERE userID = $1 AND clientID = $2`, userID, change.ParentClientID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			result.Status = SyncError
+			result.Error = "parent task not found"
+			return result, nil
+		} else if err != nil {
+			log.Print("cannot scan row to resolve parent task:", err)
+			return nil, err
+		}
+		task.ParentID = &parentID
+	}
+
+	if err := check(task); err != nil {
+		result.Status = SyncError
+		result.Error = err.Error()
+		return result, nil
+	}
+
+	if creating {
+		if err := insertTask(tx, task); err != nil {
+			return nil, err
+		}
+	}
+
+	appliedJSON, err := json.Marshal(applied)
+	if err != nil {
+		return nil, err
+	}
+
+	normalizeTimes(task)
+	err = scanTask(tx.QueryRow(`
+		UPDATE tasks SET title = $1, description = $2, status = $3, priority = $4, project = $5, recurrence = $6,
+			tags = COALESCE($7, '{}'::TEXT[]), dueAt = $8, milestoneID = $9, snoozedUntil = $10, pinned = $11,
+			fieldUpdatedAt = fieldUpdatedAt || $12::JSONB, updatedAt = NOW()
+		WHERE id = $13 AND userID = $14
+		RETURNING `+taskColumns,
+		task.Title, task.Description, task.Status, task.Priority, task.Project, task.Recurrence, pq.Array(task.Tags),
+		task.DueAt, task.MilestoneID, task.SnoozedUntil, task.Pinned, string(appliedJSON), task.ID, userID), task)
+	if err != nil {
+		log.Print("cannot scan row to apply sync change:", err)
+		return nil, err
+	}
+
+	result.ID = task.ID
+	result.Task = task
+	result.Status = SyncApplied
+	if len(result.RejectedFields) > 0 {
+		result.Status = SyncConflict
+	}
+
+	return result, tx.Commit()
+}
+
+// lockSyncTarget loads the task a change points to, by ID or by client ID, together with its field stamps
+func lockSyncTarget(tx *sql.Tx, userID int, change models.SyncChange) (*models.Task, map[string]time.Time, error) {
+	var row *sql.Row
+	switch {
+	case change.ID != nil:
+		row = tx.QueryRow(`SELECT `+taskColumns+`, fieldUpdatedAt FROM tasks WHERE id = $1 AND userID = $2 FOR UPDATE`, *change.ID, userID)
+	case change.ClientID != "":
+		row = tx.QueryRow(`SELECT `+taskColumns+`, fieldUpdatedAt FROM tasks WHERE clientID = $1 AND userID = $2 FOR UPDATE`, change.ClientID, userID)
+	default:
+		return nil, nil, nil
+	}
+
+	var task models.Task
+	var rawStamps []byte
+
+	err := row.Scan(&task.ID, &task.UserID, &task.Title, &task.Description, &task.Status, &task.Priority, &task.Project, &task.Recurrence, pq.Array(&task.Tags), &task.DueAt, &task.MilestoneID, &task.ParentID, &task.SnoozedUntil, &task.Pinned, &task.ClientID, &task.Created_at, &task.Updated_at, &rawStamps)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	} else if err != nil {
+		log.Print("cannot scan row to get sync target:", err)
+		return nil, nil, err
+	}
+
+	stamps := make(map[string]time.Time)
+	if err := json.Unmarshal(rawStamps, &stamps); err != nil {
+		log.Print("cannot decode field stamps:", err)
+		return nil, nil, err
+	}
+
+	return &task, stamps, nil
+}
+
+func isTombstoned(tx *sql.Tx, userID int, change models.SyncChange) (bool, error) {
+	var deleted bool
+	var err error
+
+	switch {
+	case change.ID != nil:
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM task_tombstones WHERE taskID = $1 AND userID = $2)`, *change.ID, userID).Scan(&deleted)
+	case change.ClientID != "":
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM task_tombstones WHERE clientID = $1 AND userID = $2)`, change.ClientID, userID).Scan(&deleted)
+	}
+	if err != nil {
+		log.Print("cannot scan row to check tombstone:", err)
+	}
+
+	return deleted, err
+}
+
+// fieldStamp is when the field was last written. Tasks created before new
+// tasks were stamped have no stamps for fields never changed since.
+func fieldStamp(task *models.Task, stamps map[string]time.Time, name string) time.Time {
+	if stamp, ok := stamps[name]; ok {
+		return stamp
+	}
+	return task.Created_at
+}
+
+func latestStamp(task *models.Task, stamps map[string]time.Time) time.Time {
+	latest := task.Created_at
+	for _, stamp := range stamps {
+		if stamp.After(latest) {
+			latest = stamp
+		}
+	}
+	return latest
+}
+
+// VersionedTask is a task with its sync version, which doubles as its ETag
+type VersionedTask struct {
+	Task    models.Task
+	Version int64
+}
+
+// ErrVersionMismatch means the task changed since the version the client based its write on
+var ErrVersionMismatch = errors.New("task was changed in the meantime")
+
+// GetSyncVersion returns the version of the user's latest task change
+func (t *TaskRepository) GetSyncVersion(userID int) (int64, error) {
+	var version int64
+
+	if err := t.DB.QueryRow(`SELECT syncVersion FROM users WHERE id = $1`, userID).Scan(&version); err != nil {
+		log.Print("cannot scan row to get sync version:", err)
+		return 0, err
+	}
+
+	return version, nil
+}
+
+func (t *TaskRepository) GetVersionedTasks(userID int) ([]VersionedTask, error) {
+	rows, err := t.DB.Query(`SELECT `+taskColumns+`, syncVersion FROM tasks WHERE userID = $1 ORDER BY id`, userID)
+	if err != nil {
+		log.Print("cannot execute statement to get versioned tasks:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []VersionedTask
+
+	for rows.Next() {
+		var vt VersionedTask
+		if err := scanVersionedTask(rows, &vt); err != nil {
+			log.Print("cannot scan row to get versioned tasks:", err)
+			return nil, err
+		}
+		tasks = append(tasks, vt)
+	}
+
+	return tasks, rows.Err()
+}
+
+// GetVersionedTask finds the task by ID, or by client ID when taskID is nil
+func (t *TaskRepository) GetVersionedTask(userID int, taskID *int, clientID string) (*VersionedTask, error) {
+	var row *sql.Row
+	if taskID != nil {
+		row = t.DB.QueryRow(`SELECT `+taskColumns+`, syncVersion FROM tasks WHERE id = $1 AND userID = $2`, *taskID, userID)
+	} else {
+		row = t.DB.QueryRow(`SELECT `+taskColumns+`, syncVersion FROM tasks WHERE clientID = $1 AND userID = $2`, clientID, userID)
+	}
+
+	var vt VersionedTask
+	if err := scanVersionedTask(row, &vt); err != nil {
+		if err != sql.ErrNoRows {
+			log.Print("cannot scan row to get versioned task:", err)
+		}
+		return nil, err
+	}
+
+	return &vt, nil
+}
+
+// UpdateTaskAtVersion is UpdateTask that only applies while the task is still at
+// version, a negative version skips the check. It returns the new version.
+func (t *TaskRepository) UpdateTaskAtVersion(task *models.Task, version int64) (int64, error) {
+	if task.Priority == "" {
+		task.Priority = "medium"
+	}
+	normalizeTimes(task)
+
+	var newVersion int64
+	err := t.DB.QueryRow(`
+		UPDATE tasks SET title = $1, description = $2, status = $3, priority = $4, project = $5, recurrence = $6,
+			tags = COALESCE($7, '{}'::TEXT[]), dueAt = $8, updatedAt = NOW()
+		WHERE id = $9 AND userID = $10 AND ($11 < 0 OR syncVersion = $11)
+		RETURNING syncVersion`,
+		task.Title, task.Description, task.Status, task.Priority, task.Project, task.Recurrence, pq.Array(task.Tags), task.DueAt, task.ID, task.UserID, version).Scan(&newVersion)
+	if err == sql.ErrNoRows {
+		return 0, t.missingOrChanged(task.ID, task.UserID)
+	} else if err != nil {
+		log.Print("cannot scan row to update task at version:", err)
+		return 0, err
+	}
+
+	return newVersion, nil
+}
+
+// DeleteTaskAtVersion is DeleteTask with the same version check as UpdateTaskAtVersion
+func (t *TaskRepository) DeleteTaskAtVersion(taskID int, userID int, version int64) error {
+	result, err := t.DB.Exec(`DELETE FROM tasks WHERE id = $1 AND userID = $2 AND ($3 < 0 OR syncVersion = $3)`, taskID, userID, version)
+	if err != nil {
+		log.Print("cannot execute statement to delete task at version:", err)
+		return err
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return t.missingOrChanged(taskID, userID)
+	}
+
+	return nil
+}
+
+func (t *TaskRepository) missingOrChanged(taskID int, userID int) error {
+	var exists bool
+	if err := t.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND userID = $2)`, taskID, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return ErrVersionMismatch
+}
+
+func scanVersionedTask(row rowScanner, vt *VersionedTask) error {
+	task := &vt.Task
+	return row.Scan(&task.ID, &task.UserID, &task.Title, &task.Description, &task.Status, &task.Priority, &task.Project, &task.Recurrence, pq.Array(&task.Tags), &task.DueAt, &task.MilestoneID, &task.ParentID, &task.SnoozedUntil, &task.Pinned, &task.ClientID, &task.Created_at, &task.Updated_at, &vt.Version)
+}
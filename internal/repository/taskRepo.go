@@ -1,45 +1,229 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/lib/pq"
 )
 
+// userToday is the current date in the timezone of the user bound to $1
+const userToday = `(NOW() AT TIME ZONE (SELECT timezone FROM users WHERE id = $1))::DATE`
+
+const taskColumns = "id, userID, title, description, status, priority, project, recurrence, tags, dueAt, milestoneID, parentID, snoozedUntil, pinned, clientID, createdAt, updatedAt"
+
 type TaskRepository struct {
 	DB *sql.DB
 }
 
+// Views of the task list. The default view hides snoozed tasks.
+const (
+	ViewDefault = ""
+	ViewMyDay   = "my_day"
+	ViewPinned  = "pinned"
+	ViewSnoozed = "snoozed"
+)
+
+// TaskFilter narrows down the list returned by GetAllTasksByUserID.
+// A Limit of 0 returns every task from Offset on.
+type TaskFilter struct {
+	MilestoneID *int
+	View        string
+	Limit       int
+	Offset      int
+}
+
+// TaskTree is a task together with the subtasks to be created under it
+type TaskTree struct {
+	Task     models.Task
+	Children []TaskTree
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 func NewTaskRepository(db *sql.DB) *TaskRepository {
 	return &TaskRepository{DB: db}
 }
 
-func (t *TaskRepository) CreateNewTask(task *models.Task) error {
-	stmt, err := t.DB.Prepare(`INSERT INTO tasks (userID, title, description, status, createdAt, updatedAt) VALUES ($1, $2, $3, $4, DEFAULT, NOW()) RETURNING id`)
+func scanTask(row rowScanner, task *models.Task) error {
+	return row.Scan(&task.ID, &task.UserID, &task.Title, &task.Description, &task.Status, &task.Priority, &task.Project, &task.Recurrence, pq.Array(&task.Tags), &task.DueAt, &task.MilestoneID, &task.ParentID, &task.SnoozedUntil, &task.Pinned, &task.ClientID, &task.Created_at, &task.Updated_at)
+}
+
+// inUTC converts a time to UTC. The time columns are TIMESTAMP without
+// time zone, Postgres drops the offset of any other time.
+func inUTC(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+// normalizeTimes converts the times of a task to UTC before it is written
+func normalizeTimes(task *models.Task) {
+	task.DueAt = inUTC(task.DueAt)
+	task.SnoozedUntil = inUTC(task.SnoozedUntil)
+}
+
+func insertTask(q queryer, task *models.Task) error {
+	if task.Priority == "" {
+		task.Priority = "medium"
+	}
+	normalizeTimes(task)
+
+	err := q.QueryRow(`INSERT INTO tasks (userID, title, description, status, priority, project, recurrence, tags, dueAt, milestoneID, parentID, snoozedUntil, pinned, clientID, createdAt, updatedAt) VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, '{}'::TEXT[]), $9, $10, $11, $12, $13, $14, DEFAULT, NOW()) RETURNING id, createdAt, updatedAt`,
+		task.UserID, task.Title, task.Description, task.Status, task.Priority, task.Project, task.Recurrence, pq.Array(task.Tags), task.DueAt, task.MilestoneID, task.ParentID, task.SnoozedUntil, task.Pinned, task.ClientID).Scan(&task.ID, &task.Created_at, &task.Updated_at)
 	if err != nil {
-		log.Print("cannot prepare statement to create new task:", err)
+		log.Print("cannot scan row to create new task:", err)
 		return err
 	}
 
-	err = stmt.QueryRow(task.UserID, task.Title, task.Description, task.Status, task.Created_at, task.Updated_at).Scan(&task.ID)
+	return nil
+}
+
+func (t *TaskRepository) CreateNewTask(task *models.Task) error {
+	return insertTask(t.DB, task)
+}
+
+// CreateTaskTrees creates every task of the given trees in one transaction.
+// Parent IDs of the children are filled in as their parents get inserted.
+func (t *TaskRepository) CreateTaskTrees(trees []TaskTree) error {
+	tx, err := t.DB.Begin()
 	if err != nil {
-		log.Print("cannot scan row to create new task:", err)
+		log.Print("cannot begin transaction to create tasks:", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertTaskTrees(tx, trees, nil); err != nil {
 		return err
 	}
 
+	return tx.Commit()
+}
+
+func insertTaskTrees(q queryer, trees []TaskTree, parentID *int) error {
+	for i := range trees {
+		if parentID != nil {
+			trees[i].Task.ParentID = parentID
+		}
+
+		if err := insertTask(q, &trees[i].Task); err != nil {
+			return err
+		}
+
+		id := trees[i].Task.ID
+		if err := insertTaskTrees(q, trees[i].Children, &id); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (t *TaskRepository) GetAllTasksByUserID(userID int) ([]models.Task, error) {
-	stmt, err := t.DB.Prepare(`SELECT id, userID, title, description, status, createdAt, updatedAt FROM tasks WHERE userID = $1`)
+// GetTaskTree returns the task with all of its subtasks, recursively
+func (t *TaskRepository) GetTaskTree(taskID int, userID int) (*TaskTree, error) {
+	rows, err := t.DB.Query(`
+		WITH RECURSIVE subtree AS (
+			SELECT `+taskColumns+` FROM tasks WHERE id = $1 AND userID = $2
+			UNION ALL
+			SELECT t.id, t.userID, t.title, t.description, t.status, t.priority, t.project, t.recurrence, t.tags, t.dueAt, t.milestoneID, t.parentID, t.snoozedUntil, t.pinned, t.clientID, t.createdAt, t.updatedAt
+			FROM tasks t JOIN subtree s ON t.parentID = s.id
+		)
+		SELECT `+taskColumns+` FROM subtree ORDER BY id`, taskID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to get task tree:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes := make(map[int]*TaskTree)
+	var order []int
+
+	for rows.Next() {
+		var task models.Task
+		if err := scanTask(rows, &task); err != nil {
+			log.Print("cannot scan row to get task tree:", err)
+			return nil, err
+		}
+
+		nodes[task.ID] = &TaskTree{Task: task}
+		order = append(order, task.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	root, ok := nodes[taskID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	// children come after their parents because ids grow, so attach bottom-up
+	for i := len(order) - 1; i >= 0; i-- {
+		node := nodes[order[i]]
+		if node == root || node.Task.ParentID == nil {
+			continue
+		}
+		parent := nodes[*node.Task.ParentID]
+		parent.Children = append([]TaskTree{*node}, parent.Children...)
+	}
+
+	return root, nil
+}
+
+func (t *TaskRepository) GetAllTasksByUserID(userID int, filter TaskFilter) ([]models.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE userID = $1`
+	args := []interface{}{userID}
+
+	if filter.MilestoneID != nil {
+		args = append(args, *filter.MilestoneID)
+		query += " AND milestoneID = $" + strconv.Itoa(len(args))
+	}
+
+	switch filter.View {
+	case ViewMyDay:
+		query += ` AND id IN (SELECT taskID FROM my_day WHERE userID = $1 AND day = ` + userToday + `)`
+	case ViewPinned:
+		query += " AND pinned"
+	case ViewSnoozed:
+		query += " AND snoozedUntil > NOW()"
+	default:
+		query += " AND (snoozedUntil IS NULL OR snoozedUntil <= NOW())"
+	}
+
+	query += " ORDER BY pinned DESC, id"
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	if filter.Offset > 0 {
+		args = append(args, filter.Offset)
+		query += " OFFSET $" + strconv.Itoa(len(args))
+	}
+
+	stmt, err := t.DB.Prepare(query)
 	if err != nil {
 		log.Print("cannot prepare statement to get all tasks:", err)
 		return nil, err
 	}
 
-	rows, err := stmt.Query(userID)
+	rows, err := stmt.Query(args...)
 	if err != nil {
 		log.Print("cannot execute statement to get all tasks:", err)
 		return nil, err
@@ -50,7 +234,7 @@ func (t *TaskRepository) GetAllTasksByUserID(userID int) ([]models.Task, error)
 
 	for rows.Next() {
 		var task models.Task
-		if err := rows.Scan(&task.ID, &task.UserID, &task.Title, &task.Description, &task.Status, &task.Created_at, &task.Updated_at); err != nil {
+		if err := scanTask(rows, &task); err != nil {
 			log.Print("cannot scan row to get all tasks:", err)
 			return nil, err
 		}
@@ -61,8 +245,33 @@ func (t *TaskRepository) GetAllTasksByUserID(userID int) ([]models.Task, error)
 	return tasks, nil
 }
 
+// StreamTasks calls fn for every task of the user as the rows arrive, without
+// holding them all in memory. It stops at the first error fn returns.
+func (t *TaskRepository) StreamTasks(ctx context.Context, userID int, fn func(task *models.Task) error) error {
+	rows, err := t.DB.QueryContext(ctx, `SELECT `+taskColumns+` FROM tasks WHERE userID = $1 ORDER BY id`, userID)
+	if err != nil {
+		log.Print("cannot execute statement to stream tasks:", err)
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var task models.Task
+		if err := scanTask(rows, &task); err != nil {
+			log.Print("cannot scan row to stream tasks:", err)
+			return err
+		}
+
+		if err := fn(&task); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
 func (t *TaskRepository) GetTaskByID(taskID int, userID int) (*models.Task, error) {
-	stmt, err := t.DB.Prepare(`SELECT id, userID, title, description, status, createdAt, updatedAt FROM tasks WHERE id = $1 AND userID = $2`)
+	stmt, err := t.DB.Prepare(`SELECT ` + taskColumns + ` FROM tasks WHERE id = $1 AND userID = $2`)
 	if err != nil {
 		log.Print("cannot prepare statement to get task:", err)
 		return nil, err
@@ -70,7 +279,7 @@ func (t *TaskRepository) GetTaskByID(taskID int, userID int) (*models.Task, erro
 
 	var task models.Task
 
-	err = stmt.QueryRow(taskID, userID).Scan(&task.ID, &task.UserID, &task.Title, &task.Description, &task.Status, &task.Created_at, &task.Updated_at)
+	err = scanTask(stmt.QueryRow(taskID, userID), &task)
 	if err != nil {
 		log.Print("cannot scan row to get task:", err)
 		return nil, err
@@ -80,9 +289,14 @@ func (t *TaskRepository) GetTaskByID(taskID int, userID int) (*models.Task, erro
 }
 
 func (t *TaskRepository) UpdateTask(task *models.Task) error {
-	query := "UPDATE tasks SET title = $1, description = $2, status = $3 WHERE id = $4 AND userID = $5"
+	if task.Priority == "" {
+		task.Priority = "medium"
+	}
+	normalizeTimes(task)
+
+	query := "UPDATE tasks SET title = $1, description = $2, status = $3, priority = $4, project = $5, recurrence = $6, tags = COALESCE($7, '{}'::TEXT[]), dueAt = $8, milestoneID = $9, updatedAt = NOW() WHERE id = $10 AND userID = $11"
 
-	result, err := t.DB.Exec(query, task.Title, task.Description, task.Status, task.ID, task.UserID)
+	result, err := t.DB.Exec(query, task.Title, task.Description, task.Status, task.Priority, task.Project, task.Recurrence, pq.Array(task.Tags), task.DueAt, task.MilestoneID, task.ID, task.UserID)
 	if err != nil {
 		log.Print("cannot execute statement to update task:", err)
 		return err
@@ -90,7 +304,7 @@ func (t *TaskRepository) UpdateTask(task *models.Task) error {
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return errors.New("task not found or you don't have permission to update it")
+		return sql.ErrNoRows
 	}
 
 	return nil
@@ -111,8 +325,163 @@ func (t *TaskRepository) DeleteTask(taskID int, userID int) error {
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return errors.New("task not found or you don't have permission to delete it")
+		return sql.ErrNoRows
 	}
 
 	return nil
 }
+
+// SnoozeTask hides the task from the default list until the given time, nil wakes it up
+func (t *TaskRepository) SnoozeTask(taskID int, userID int, until *time.Time) error {
+	until = inUTC(until)
+
+	result, err := t.DB.Exec("UPDATE tasks SET snoozedUntil = $1, updatedAt = NOW() WHERE id = $2 AND userID = $3", until, taskID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to snooze task:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("task not found or you don't have permission to update it")
+	}
+
+	return nil
+}
+
+func (t *TaskRepository) SetTaskPinned(taskID int, userID int, pinned bool) error {
+	result, err := t.DB.Exec("UPDATE tasks SET pinned = $1, updatedAt = NOW() WHERE id = $2 AND userID = $3", pinned, taskID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to pin task:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("task not found or you don't have permission to update it")
+	}
+
+	return nil
+}
+
+// AddTaskToMyDay puts the task on today's list in the user's timezone.
+// Entries of previous days are dropped on the way, the list starts empty every morning.
+func (t *TaskRepository) AddTaskToMyDay(taskID int, userID int) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		log.Print("cannot begin transaction to add task to my day:", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM my_day WHERE userID = $1 AND day < `+userToday, userID); err != nil {
+		log.Print("cannot clear previous days of my day:", err)
+		return err
+	}
+
+	result, err := tx.Exec(`INSERT INTO my_day (userID, taskID, day) SELECT $1, id, `+userToday+` FROM tasks WHERE id = $2 AND userID = $1 ON CONFLICT DO NOTHING`, userID, taskID)
+	if err != nil {
+		log.Print("cannot execute statement to add task to my day:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE id = $1 AND userID = $2)", taskID, userID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (t *TaskRepository) RemoveTaskFromMyDay(taskID int, userID int) error {
+	result, err := t.DB.Exec(`DELETE FROM my_day WHERE userID = $1 AND taskID = $2 AND day = `+userToday, userID, taskID)
+	if err != nil {
+		log.Print("cannot execute statement to remove task from my day:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("task is not in my day")
+	}
+
+	return nil
+}
+
+// getTasksByIDs loads the given tasks of the user, keyed by ID
+func getTasksByIDs(q queryer, userID int, ids []int) (map[int]*models.Task, error) {
+	tasks := make(map[int]*models.Task, len(ids))
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+
+	rows, err := q.Query(`SELECT `+taskColumns+` FROM tasks WHERE userID = $1 AND id = ANY($2)`, userID, pq.Array(ids))
+	if err != nil {
+		log.Print("cannot execute statement to get tasks by ids:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var task models.Task
+		if err := scanTask(rows, &task); err != nil {
+			log.Print("cannot scan row to get tasks by ids:", err)
+			return nil, err
+		}
+		tasks[task.ID] = &task
+	}
+
+	return tasks, rows.Err()
+}
+
+// GetTasksByIDs loads the given tasks of the user in one query, keyed by ID
+func (t *TaskRepository) GetTasksByIDs(userID int, ids []int) (map[int]*models.Task, error) {
+	return getTasksByIDs(t.DB, userID, ids)
+}
+
+// GetSubtasksByParentIDs loads the direct subtasks of the given tasks in one query, keyed by parent ID
+func (t *TaskRepository) GetSubtasksByParentIDs(userID int, parentIDs []int) (map[int][]models.Task, error) {
+	return t.getTasksGroupedBy("parentID", userID, parentIDs)
+}
+
+// GetTasksByMilestoneIDs loads the tasks of the given milestones in one query, keyed by milestone ID
+func (t *TaskRepository) GetTasksByMilestoneIDs(userID int, milestoneIDs []int) (map[int][]models.Task, error) {
+	return t.getTasksGroupedBy("milestoneID", userID, milestoneIDs)
+}
+
+// getTasksGroupedBy is behind the batch lookups, column is never user input
+func (t *TaskRepository) getTasksGroupedBy(column string, userID int, keys []int) (map[int][]models.Task, error) {
+	grouped := make(map[int][]models.Task, len(keys))
+	if len(keys) == 0 {
+		return grouped, nil
+	}
+
+	rows, err := t.DB.Query(`SELECT `+taskColumns+` FROM tasks WHERE userID = $1 AND `+column+` = ANY($2) ORDER BY id`, userID, pq.Array(keys))
+	if err != nil {
+		log.Print("cannot execute statement to get tasks by "+column+":", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var task models.Task
+		if err := scanTask(rows, &task); err != nil {
+			log.Print("cannot scan row to get tasks by "+column+":", err)
+			return nil, err
+		}
+
+		key := task.ParentID
+		if column == "milestoneID" {
+			key = task.MilestoneID
+		}
+		grouped[*key] = append(grouped[*key], task)
+	}
+
+	return grouped, rows.Err()
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+func TestNormalizeTimes(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	due := time.Date(2026, 5, 1, 9, 0, 0, 0, moscow)
+	snoozed := time.Date(2026, 4, 30, 20, 0, 0, 0, moscow)
+
+	task := models.Task{DueAt: &due, SnoozedUntil: &snoozed}
+	normalizeTimes(&task)
+
+	if want := time.Date(2026, 5, 1, 6, 0, 0, 0, time.UTC); *task.DueAt != want {
+		t.Errorf("DueAt = %s, want %s", task.DueAt, want)
+	}
+	if want := time.Date(2026, 4, 30, 17, 0, 0, 0, time.UTC); *task.SnoozedUntil != want {
+		t.Errorf("SnoozedUntil = %s, want %s", task.SnoozedUntil, want)
+	}
+	if due.Location() != moscow {
+		t.Error("the caller's time was changed")
+	}
+
+	empty := models.Task{}
+	normalizeTimes(&empty)
+	if empty.DueAt != nil || empty.SnoozedUntil != nil {
+		t.Error("missing times were filled in")
+	}
+}
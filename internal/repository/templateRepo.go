@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/lib/pq"
+)
+
+type TemplateRepository struct {
+	DB *sql.DB
+}
+
+func NewTemplateRepository(db *sql.DB) *TemplateRepository {
+	return &TemplateRepository{DB: db}
+}
+
+func (r *TemplateRepository) CreateTemplate(template *models.Template) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Print("cannot begin transaction to create template:", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO templates (userID, name, description) VALUES ($1, $2, $3) RETURNING id, createdAt, updatedAt`,
+		template.UserID, template.Name, template.Description).Scan(&template.ID, &template.Created_at, &template.Updated_at)
+	if err != nil {
+		log.Print("cannot scan row to create template:", err)
+		return err
+	}
+
+	if err := insertTemplateItems(tx, template.ID, nil, template.Items); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertTemplateItems(tx *sql.Tx, templateID int, parentID *int, items []models.TemplateItem) error {
+	for i, item := range items {
+		var id int
+		err := tx.QueryRow(`INSERT INTO template_items (templateID, parentID, position, title, description, status, tags, dueOffsetMinutes) VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, '{}'::TEXT[]), $8) RETURNING id`,
+			templateID, parentID, i, item.Title, item.Description, item.Status, pq.Array(item.Tags), item.DueOffsetMinutes).Scan(&id)
+		if err != nil {
+			log.Print("cannot scan row to create template item:", err)
+			return err
+		}
+
+		if err := insertTemplateItems(tx, templateID, &id, item.Children); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *TemplateRepository) GetAllTemplatesByUserID(userID int) ([]models.Template, error) {
+	stmt, err := r.DB.Prepare(`SELECT id, userID, name, COALESCE(description, ''), createdAt, updatedAt FROM templates WHERE userID = $1 ORDER BY name`)
+	if err != nil {
+		log.Print("cannot prepare statement to get all templates:", err)
+		return nil, err
+	}
+
+	rows, err := stmt.Query(userID)
+	if err != nil {
+		log.Print("cannot execute statement to get all templates:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var templates []models.Template
+
+	for rows.Next() {
+		var template models.Template
+		if err := rows.Scan(&template.ID, &template.UserID, &template.Name, &template.Description, &template.Created_at, &template.Updated_at); err != nil {
+			log.Print("cannot scan row to get all templates:", err)
+			return nil, err
+		}
+
+		templates = append(templates, template)
+	}
+
+	return templates, nil
+}
+
+// GetTemplateByID returns the template with its item tree
+func (r *TemplateRepository) GetTemplateByID(templateID int, userID int) (*models.Template, error) {
+	var template models.Template
+
+	err := r.DB.QueryRow(`SELECT id, userID, name, COALESCE(description, ''), createdAt, updatedAt FROM templates WHERE id = $1 AND userID = $2`, templateID, userID).
+		Scan(&template.ID, &template.UserID, &template.Name, &template.Description, &template.Created_at, &template.Updated_at)
+	if err != nil {
+		log.Print("cannot scan row to get template:", err)
+		return nil, err
+	}
+
+	rows, err := r.DB.Query(`SELECT id, parentID, title, COALESCE(description, ''), status, tags, dueOffsetMinutes FROM template_items WHERE templateID = $1 ORDER BY position, id`, templateID)
+	if err != nil {
+		log.Print("cannot execute statement to get template items:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	type row struct {
+		id       int
+		parentID *int
+		item     models.TemplateItem
+	}
+
+	var all []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.parentID, &r.item.Title, &r.item.Description, &r.item.Status, pq.Array(&r.item.Tags), &r.item.DueOffsetMinutes); err != nil {
+			log.Print("cannot scan row to get template items:", err)
+			return nil, err
+		}
+		all = append(all, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var build func(parentID *int) []models.TemplateItem
+	build = func(parentID *int) []models.TemplateItem {
+		var items []models.TemplateItem
+		for _, r := range all {
+			if (parentID == nil && r.parentID == nil) || (parentID != nil && r.parentID != nil && *parentID == *r.parentID) {
+				id := r.id
+				r.item.Children = build(&id)
+				items = append(items, r.item)
+			}
+		}
+		return items
+	}
+	template.Items = build(nil)
+
+	return &template, nil
+}
+
+// UpdateTemplate replaces the template's fields and its whole item tree
+func (r *TemplateRepository) UpdateTemplate(template *models.Template) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		log.Print("cannot begin transaction to update template:", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE templates SET name = $1, description = $2, updatedAt = NOW() WHERE id = $3 AND userID = $4`,
+		template.Name, template.Description, template.ID, template.UserID)
+	if err != nil {
+		log.Print("cannot execute statement to update template:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(`DELETE FROM template_items WHERE templateID = $1`, template.ID); err != nil {
+		log.Print("cannot delete template items:", err)
+		return err
+	}
+
+	if err := insertTemplateItems(tx, template.ID, nil, template.Items); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TemplateRepository) DeleteTemplate(templateID int, userID int) error {
+	result, err := r.DB.Exec("DELETE FROM templates WHERE id = $1 AND userID = $2", templateID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to delete template:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
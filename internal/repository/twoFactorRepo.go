@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+)
+
+// TwoFactorRepository keeps the TOTP secrets of users and their recovery codes
+type TwoFactorRepository struct {
+	DB *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{DB: db}
+}
+
+// GetTOTP returns the secret of the user, empty before enrolment, and whether it is confirmed
+func (t *TwoFactorRepository) GetTOTP(userID int) (string, bool, error) {
+	var secret sql.NullString
+	var enabled bool
+
+	err := t.DB.QueryRow(`SELECT totpSecret, totpEnabledAt IS NOT NULL FROM users WHERE id = $1`, userID).Scan(&secret, &enabled)
+	if err != nil {
+		log.Print("cannot scan row to get TOTP secret:", err)
+		return "", false, err
+	}
+
+	return secret.String, enabled, nil
+}
+
+// SetTOTPSecret starts an enrolment. It returns false when the user has
+// confirmed a secret already, that one must be disabled first.
+func (t *TwoFactorRepository) SetTOTPSecret(userID int, secret string) (bool, error) {
+	result, err := t.DB.Exec(`UPDATE users SET totpSecret = $1, totpLastStep = NULL WHERE id = $2 AND totpEnabledAt IS NULL`, secret, userID)
+	if err != nil {
+		log.Print("cannot execute statement to set TOTP secret:", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Print("cannot get rows affected to set TOTP secret:", err)
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// EnableTOTP confirms the secret with the time step of the first code and
+// replaces the recovery codes. It returns false when 2FA was enabled already.
+func (t *TwoFactorRepository) EnableTOTP(userID int, step int64, codeHashes []string) (bool, error) {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		log.Print("cannot begin transaction to enable TOTP:", err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE users SET totpEnabledAt = NOW(), totpLastStep = $1 WHERE id = $2 AND totpSecret IS NOT NULL AND totpEnabledAt IS NULL`, step, userID)
+	if err != nil {
+		log.Print("cannot execute statement to enable TOTP:", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Print("cannot get rows affected to enable TOTP:", err)
+		return false, err
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Print("cannot commit transaction to enable TOTP:", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DisableTOTP forgets the secret and the recovery codes of the user
+func (t *TwoFactorRepository) DisableTOTP(userID int) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		log.Print("cannot begin transaction to disable TOTP:", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET totpSecret = NULL, totpEnabledAt = NULL, totpLastStep = NULL WHERE id = $1`, userID); err != nil {
+		log.Print("cannot execute statement to disable TOTP:", err)
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE userID = $1`, userID); err != nil {
+		log.Print("cannot execute statement to delete recovery codes:", err)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Print("cannot commit transaction to disable TOTP:", err)
+		return err
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code. It returns false
+// when a code of this or a later step was accepted before, the code is
+// being replayed.
+func (t *TwoFactorRepository) UseTOTPStep(userID int, step int64) (bool, error) {
+	result, err := t.DB.Exec(`UPDATE users SET totpLastStep = $1 WHERE id = $2 AND totpEnabledAt IS NOT NULL AND (totpLastStep IS NULL OR totpLastStep < $1)`, step, userID)
+	if err != nil {
+		log.Print("cannot execute statement to use TOTP step:", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Print("cannot get rows affected to use TOTP step:", err)
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// ReplaceRecoveryCodes throws away the codes of the user, used or not, and stores new ones
+func (t *TwoFactorRepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	tx, err := t.DB.Begin()
+	if err != nil {
+		log.Print("cannot begin transaction to replace recovery codes:", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Print("cannot commit transaction to replace recovery codes:", err)
+		return err
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused code of the user as used, it returns false when there is none
+func (t *TwoFactorRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	result, err := t.DB.Exec(`UPDATE recovery_codes SET usedAt = NOW() WHERE userID = $1 AND codeHash = $2 AND usedAt IS NULL`, userID, codeHash)
+	if err != nil {
+		log.Print("cannot execute statement to use recovery code:", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Print("cannot get rows affected to use recovery code:", err)
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// CountRecoveryCodes returns how many unused codes the user has left
+func (t *TwoFactorRepository) CountRecoveryCodes(userID int) (int, error) {
+	var count int
+
+	err := t.DB.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE userID = $1 AND usedAt IS NULL`, userID).Scan(&count)
+	if err != nil {
+		log.Print("cannot scan row to count recovery codes:", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE userID = $1`, userID); err != nil {
+		log.Print("cannot execute statement to delete recovery codes:", err)
+		return err
+	}
+
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (userID, codeHash) VALUES ($1, $2)`, userID, hash); err != nil {
+			log.Print("cannot execute statement to create recovery code:", err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"log"
+	"time"
 
 	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
 )
@@ -18,13 +19,13 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 func (u *UserRepository) GetUserByID(id int) (*models.User, error) {
 	var user models.User
 
-	stmt, err := u.DB.Prepare("SELECT id, username, email, password, role, created_at FROM users WHERE id = $1")
+	stmt, err := u.DB.Prepare("SELECT id, username, email, password, role, timezone, emailVerifiedAt IS NOT NULL, totpEnabledAt IS NOT NULL, createdAt FROM users WHERE id = $1")
 	if err != nil {
 		log.Print("cannot prepare statement to get user:", err)
 		return nil, err
 	}
 
-	err = stmt.QueryRow(id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Created_at)
+	err = stmt.QueryRow(id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Timezone, &user.EmailVerified, &user.TwoFactor, &user.Created_at)
 	if err != nil {
 		log.Print("cannot scan row to get user:", err)
 		return nil, err
@@ -34,13 +35,17 @@ func (u *UserRepository) GetUserByID(id int) (*models.User, error) {
 }
 
 func (u *UserRepository) CreateNewUser(user *models.User) error {
-	stmt, err := u.DB.Prepare("INSERT INTO users (username, email, password, role, createdAt) VALUES ($1, $2, $3, $4, DEFAULT) RETURNING id, createdAt")
+	if user.Timezone == "" {
+		user.Timezone = "UTC"
+	}
+
+	stmt, err := u.DB.Prepare("INSERT INTO users (username, email, password, role, timezone, createdAt) VALUES ($1, $2, $3, $4, $5, DEFAULT) RETURNING id, createdAt")
 	if err != nil {
 		log.Print("cannot prepare statement to create new user:", err)
 		return err
 	}
 
-	_, err = stmt.Exec(user.Username, user.Email, user.Password, user.Role)
+	err = stmt.QueryRow(user.Username, user.Email, user.Password, user.Role, user.Timezone).Scan(&user.ID, &user.Created_at)
 	if err != nil {
 		log.Print("cannot execute statement to create new user:", err)
 		return err
@@ -50,13 +55,13 @@ func (u *UserRepository) CreateNewUser(user *models.User) error {
 }
 
 func (u *UserRepository) UpdateUser(user *models.User) error {
-	stmt, err := u.DB.Prepare("UPDATE users SET username = $1, email = $2, password = $3, role = $4, createdAt = $5 WHERE id = $6")
+	stmt, err := u.DB.Prepare("UPDATE users SET username = $1, email = $2, password = $3, role = $4, timezone = $5, createdAt = $6 WHERE id = $7")
 	if err != nil {
 		log.Print("cannot prepare statement to update user:", err)
 		return err
 	}
 
-	_, err = stmt.Exec(user.Username, user.Email, user.Password, user.Role, user.Created_at, user.ID)
+	_, err = stmt.Exec(user.Username, user.Email, user.Password, user.Role, user.Timezone, user.Created_at, user.ID)
 	if err != nil {
 		log.Print("cannot execute statement to update user:", err)
 		return err
@@ -68,13 +73,13 @@ func (u *UserRepository) UpdateUser(user *models.User) error {
 func (u *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	var user models.User
 
-	stmt, err := u.DB.Prepare("SELECT id, username, email, password, role, createdAt FROM users WHERE email = $1")
+	stmt, err := u.DB.Prepare("SELECT id, username, email, password, role, timezone, emailVerifiedAt IS NOT NULL, totpEnabledAt IS NOT NULL, createdAt FROM users WHERE email = $1")
 	if err != nil {
 		log.Print("cannot prepare statement to get user:", err)
 		return nil, err
 	}
 
-	err = stmt.QueryRow(email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Created_at)
+	err = stmt.QueryRow(email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Timezone, &user.EmailVerified, &user.TwoFactor, &user.Created_at)
 	if err != nil {
 		log.Print("cannot scan row to get user:", err)
 		return nil, err
@@ -82,3 +87,71 @@ func (u *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 
 	return &user, nil
 }
+
+// GetUserLocation returns the user's timezone, falling back to UTC when it is unknown
+func (u *UserRepository) GetUserLocation(id int) (*time.Location, error) {
+	var timezone string
+
+	err := u.DB.QueryRow("SELECT timezone FROM users WHERE id = $1", id).Scan(&timezone)
+	if err != nil {
+		log.Print("cannot scan row to get user timezone:", err)
+		return nil, err
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Print("cannot load user timezone:", err)
+		return time.UTC, nil
+	}
+
+	return loc, nil
+}
+
+// SetCalendarToken replaces the hash of the secret of the user's calendar feed and CalDAV access, nil revokes it
+func (u *UserRepository) SetCalendarToken(id int, tokenHash *string) error {
+	_, err := u.DB.Exec("UPDATE users SET calendarTokenHash = $1 WHERE id = $2", tokenHash, id)
+	if err != nil {
+		log.Print("cannot execute statement to set calendar token:", err)
+	}
+
+	return err
+}
+
+func (u *UserRepository) GetUserByCalendarToken(tokenHash string) (*models.User, error) {
+	var user models.User
+
+	err := u.DB.QueryRow("SELECT id, username, email, password, role, timezone, emailVerifiedAt IS NOT NULL, totpEnabledAt IS NOT NULL, createdAt FROM users WHERE calendarTokenHash = $1", tokenHash).
+		Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Timezone, &user.EmailVerified, &user.TwoFactor, &user.Created_at)
+	if err != nil {
+		log.Print("cannot scan row to get user by calendar token:", err)
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// MarkEmailVerified verifies the address unless the user has changed it since the email was sent
+func (u *UserRepository) MarkEmailVerified(id int, email string) (bool, error) {
+	result, err := u.DB.Exec("UPDATE users SET emailVerifiedAt = COALESCE(emailVerifiedAt, NOW()) WHERE id = $1 AND email = $2", id, email)
+	if err != nil {
+		log.Print("cannot execute statement to verify email:", err)
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		log.Print("cannot get rows affected to verify email:", err)
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (u *UserRepository) SetPassword(id int, passwordHash string) error {
+	_, err := u.DB.Exec("UPDATE users SET password = $1 WHERE id = $2", passwordHash, id)
+	if err != nil {
+		log.Print("cannot execute statement to set password:", err)
+	}
+
+	return err
+}
@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/lib/pq"
+)
+
+const webhookColumns = "id, userID, url, events, secret, active, failureCount, createdAt, updatedAt"
+
+const deliveryColumns = "id, webhookID, eventType, payload, status, attempts, responseCode, lastError, createdAt, deliveredAt"
+
+type WebhookRepository struct {
+	DB *sql.DB
+}
+
+// DueDelivery is a claimed delivery together with where and how to send it
+type DueDelivery struct {
+	Delivery models.WebhookDelivery
+	URL      string
+	Secret   string
+	UserID   int
+	TaskID   *int
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{DB: db}
+}
+
+func scanWebhook(row rowScanner, webhook *models.Webhook) error {
+	return row.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, pq.Array(&webhook.Events), &webhook.Secret, &webhook.Active, &webhook.FailureCount, &webhook.Created_at, &webhook.Updated_at)
+}
+
+func scanDelivery(row rowScanner, delivery *models.WebhookDelivery) error {
+	return row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventType, &delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.ResponseCode, &delivery.LastError, &delivery.Created_at, &delivery.DeliveredAt)
+}
+
+func (w *WebhookRepository) CreateWebhook(webhook *models.Webhook) error {
+	err := w.DB.QueryRow(`INSERT INTO webhooks (userID, url, events, secret, active) VALUES ($1, $2, $3, $4, $5) RETURNING id, failureCount, createdAt, updatedAt`,
+		webhook.UserID, webhook.URL, pq.Array(webhook.Events), webhook.Secret, webhook.Active).Scan(&webhook.ID, &webhook.FailureCount, &webhook.Created_at, &webhook.Updated_at)
+	if err != nil {
+		log.Print("cannot scan row to create webhook:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (w *WebhookRepository) GetAllWebhooksByUserID(userID int) ([]models.Webhook, error) {
+	rows, err := w.DB.Query(`SELECT `+webhookColumns+` FROM webhooks WHERE userID = $1 ORDER BY id`, userID)
+	if err != nil {
+		log.Print("cannot execute statement to get webhooks:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			log.Print("cannot scan row to get webhooks:", err)
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+func (w *WebhookRepository) GetWebhookByID(webhookID int, userID int) (*models.Webhook, error) {
+	var webhook models.Webhook
+
+	err := scanWebhook(w.DB.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1 AND userID = $2`, webhookID, userID), &webhook)
+	if err != nil {
+		log.Print("cannot scan row to get webhook:", err)
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// UpdateWebhook changes the subscription. Reactivating a webhook clears its failure count.
+func (w *WebhookRepository) UpdateWebhook(webhook *models.Webhook) error {
+	result, err := w.DB.Exec(`
+		UPDATE webhooks SET url = $1, events = $2, secret = $3, active = $4,
+			failureCount = CASE WHEN $4 AND NOT active THEN 0 ELSE failureCount END, updatedAt = NOW()
+		WHERE id = $5 AND userID = $6`,
+		webhook.URL, pq.Array(webhook.Events), webhook.Secret, webhook.Active, webhook.ID, webhook.UserID)
+	if err != nil {
+		log.Print("cannot execute statement to update webhook:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("webhook not found or you don't have permission to update it")
+	}
+
+	return nil
+}
+
+func (w *WebhookRepository) DeleteWebhook(webhookID int, userID int) error {
+	result, err := w.DB.Exec("DELETE FROM webhooks WHERE id = $1 AND userID = $2", webhookID, userID)
+	if err != nil {
+		log.Print("cannot execute statement to delete webhook:", err)
+		return err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("webhook not found or you don't have permission to delete it")
+	}
+
+	return nil
+}
+
+func (w *WebhookRepository) GetDeliveriesByWebhookID(webhookID int, userID int, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := w.DB.Query(`
+		SELECT d.id, d.webhookID, d.eventType, d.payload, d.status, d.attempts, d.responseCode, d.lastError, d.createdAt, d.deliveredAt
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhookID
+		WHERE d.webhookID = $1 AND w.userID = $2
+		ORDER BY d.id DESC LIMIT $3`, webhookID, userID, limit)
+	if err != nil {
+		log.Print("cannot execute statement to get webhook deliveries:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := scanDelivery(rows, &delivery); err != nil {
+			log.Print("cannot scan row to get webhook deliveries:", err)
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver queues a fresh copy of an earlier delivery, the original stays in the log
+func (w *WebhookRepository) Redeliver(deliveryID int64, webhookID int, userID int) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+
+	err := scanDelivery(w.DB.QueryRow(`
+		INSERT INTO webhook_deliveries (webhookID, eventType, payload, taskID)
+		SELECT d.webhookID, d.eventType, d.payload, d.taskID
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhookID
+		WHERE d.id = $1 AND d.webhookID = $2 AND w.userID = $3
+		RETURNING `+deliveryColumns, deliveryID, webhookID, userID), &delivery)
+	if err != nil {
+		log.Print("cannot scan row to redeliver webhook:", err)
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// ClaimDueDeliveries picks up to limit due deliveries of active webhooks and
+// leases them: they are not due again until lease has passed. The rows are
+// locked only while they are claimed, so nothing stays locked while the
+// deliveries are sent. A delivery whose instance died before
+// CompleteDelivery or FailDelivery is sent again once its lease runs out.
+func (w *WebhookRepository) ClaimDueDeliveries(limit int, lease time.Duration) ([]DueDelivery, error) {
+	rows, err := w.DB.Query(`
+		WITH due AS (
+			SELECT d.id
+			FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhookID
+			WHERE d.status = 'pending' AND d.nextAttemptAt <= NOW() AND w.active
+			ORDER BY d.nextAttemptAt, d.id
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET nextAttemptAt = NOW() + $2 * INTERVAL '1 second'
+		FROM due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhookID
+		RETURNING d.id, d.webhookID, d.eventType, d.payload, d.status, d.attempts, d.responseCode, d.lastError, d.createdAt, d.deliveredAt,
+			w.url, w.secret, w.userID, d.taskID`, limit, lease.Seconds())
+	if err != nil {
+		log.Print("cannot execute statement to claim due webhook deliveries:", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DueDelivery
+	for rows.Next() {
+		var d DueDelivery
+		dl := &d.Delivery
+		if err := rows.Scan(&dl.ID, &dl.WebhookID, &dl.EventType, &dl.Payload, &dl.Status, &dl.Attempts, &dl.ResponseCode, &dl.LastError, &dl.Created_at, &dl.DeliveredAt, &d.URL, &d.Secret, &d.UserID, &d.TaskID); err != nil {
+			log.Print("cannot scan row to claim due webhook deliveries:", err)
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return w.renderPayloads(due)
+}
+
+// renderPayloads fills in the payloads of deliveries sent for the first time
+// with the task as it is now, and keeps them for the retries. Deliveries of
+// tasks deleted in the meantime are skipped, their deletion is delivered anyway.
+func (w *WebhookRepository) renderPayloads(due []DueDelivery) ([]DueDelivery, error) {
+	ids := make(map[int][]int)
+	for _, d := range due {
+		if d.Delivery.Payload == "" && d.TaskID != nil && d.Delivery.EventType != "task.deleted" {
+			ids[d.UserID] = append(ids[d.UserID], *d.TaskID)
+		}
+	}
+
+	tasks := make(map[int]*models.Task)
+	for userID, taskIDs := range ids {
+		found, err := getTasksByIDs(w.DB, userID, taskIDs)
+		if err != nil {
+			return nil, err
+		}
+		for id, task := range found {
+			tasks[id] = task
+		}
+	}
+
+	result := due[:0]
+	for _, d := range due {
+		if d.Delivery.Payload != "" || d.TaskID == nil {
+			result = append(result, d)
+			continue
+		}
+
+		var data interface{} = map[string]int{"id": *d.TaskID}
+		if d.Delivery.EventType != "task.deleted" {
+			task, ok := tasks[*d.TaskID]
+			if !ok {
+				if _, err := w.DB.Exec(`UPDATE webhook_deliveries SET status = 'skipped', lastError = 'task was deleted before delivery' WHERE id = $1`, d.Delivery.ID); err != nil {
+					log.Print("cannot execute statement to skip webhook delivery:", err)
+				}
+				continue
+			}
+			data = task
+		}
+
+		payload, err := json.Marshal(models.WebhookPayload{Event: d.Delivery.EventType, CreatedAt: d.Delivery.Created_at.UTC(), Data: data})
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := w.DB.Exec(`UPDATE webhook_deliveries SET payload = $1 WHERE id = $2`, string(payload), d.Delivery.ID); err != nil {
+			log.Print("cannot execute statement to render webhook payload:", err)
+			return nil, err
+		}
+		d.Delivery.Payload = string(payload)
+
+		result = append(result, d)
+	}
+
+	return result, nil
+}
+
+// CompleteDelivery records a delivery the receiver accepted
+func (w *WebhookRepository) CompleteDelivery(delivery models.WebhookDelivery, responseCode int) error {
+	tx, err := w.DB.Begin()
+	if err != nil {
+		log.Print("cannot begin transaction to complete webhook delivery:", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + 1, responseCode = $1, lastError = '', deliveredAt = NOW() WHERE id = $2 AND status = 'pending'`,
+		responseCode, delivery.ID)
+	if err != nil {
+		log.Print("cannot execute statement to complete webhook delivery:", err)
+		return err
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(`UPDATE webhooks SET failureCount = 0 WHERE id = $1`, delivery.WebhookID); err != nil {
+		log.Print("cannot execute statement to reset webhook failures:", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FailDelivery records a failed attempt. The delivery is retried with
+// exponential backoff until maxAttempts is reached, and the webhook is
+// switched off after disableAfter failures in a row.
+func (w *WebhookRepository) FailDelivery(delivery models.WebhookDelivery, responseCode int, maxAttempts int, disableAfter int, cause error) error {
+	var code *int
+	if responseCode != 0 {
+		code = &responseCode
+	}
+
+	attempts := delivery.Attempts + 1
+	status := "pending"
+	if attempts >= maxAttempts {
+		status = "failed"
+	}
+
+	tx, err := w.DB.Begin()
+	if err != nil {
+		log.Print("cannot begin transaction to fail webhook delivery:", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE webhook_deliveries SET status = $1, attempts = $2, responseCode = $3, lastError = $4, nextAttemptAt = NOW() + $5 * INTERVAL '1 second' WHERE id = $6 AND status = 'pending'`,
+		status, attempts, code, cause.Error(), retryDelay(attempts).Seconds(), delivery.ID)
+	if err != nil {
+		log.Print("cannot execute statement to fail webhook delivery:", err)
+		return err
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(`UPDATE webhooks SET failureCount = failureCount + 1, active = (failureCount + 1 < $1) WHERE id = $2`, disableAfter, delivery.WebhookID); err != nil {
+		log.Print("cannot execute statement to count webhook failure:", err)
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -1,45 +1,214 @@
 package routes
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/DmitriyGiryntsev/TODO-API/internal/handlers"
-	"github.com/DmitriyGiryntsev/TODO-API/internal/middleware"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/service"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func SetupRoutes(r *gin.Engine, authHandler *handlers.AuthHandler, taskHandler *handlers.TaskHandler) {
+// Handlers groups every handler the router needs
+type Handlers struct {
+	Auth         *handlers.AuthHandler
+	SSO          *handlers.SSOHandler
+	Task         *handlers.TaskHandler
+	Milestone    *handlers.MilestoneHandler
+	Template     *handlers.TemplateHandler
+	User         *handlers.UserHandler
+	Reminder     *handlers.ReminderHandler
+	Notification *handlers.NotificationHandler
+	Event        *handlers.EventHandler
+	WebSocket    *handlers.WebSocketHandler
+	Webhook      *handlers.WebhookHandler
+	Calendar     *handlers.CalendarHandler
+	GraphQL      *handlers.GraphQLHandler
+
+	// RequireAuth checks the access token of protected routes, personal
+	// access tokens must carry every one of scopes. Routes without scopes
+	// are for signed in sessions only.
+	RequireAuth func(scopes ...string) gin.HandlerFunc
+	// RequireAdmin lets only admins through, it runs after RequireAuth
+	RequireAdmin gin.HandlerFunc
+	// Idempotency replays stored responses of retried requests, it runs right after RequireAuth
+	Idempotency gin.HandlerFunc
+}
+
+func SetupRoutes(r *gin.Engine, h Handlers) {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Authorization", "Content-Type", "Last-Event-ID", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r.GET("/.well-known/jwks.json", h.Auth.JWKS)
+
+	requireAuth := func(scopes ...string) []gin.HandlerFunc {
+		chain := []gin.HandlerFunc{h.RequireAuth(scopes...)}
+		if h.Idempotency != nil {
+			chain = append(chain, h.Idempotency)
+		}
+		return chain
+	}
+
+	// CalDAV clients use WebDAV methods and sign in with HTTP Basic and the calendar token
+	r.GET("/.well-known/caldav", func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, "/caldav/")
+	})
+	for _, method := range []string{"OPTIONS", "PROPFIND", "REPORT", "GET", "HEAD", "PUT", "DELETE"} {
+		r.Handle(method, "/caldav/*path", h.Calendar.ServeCalDAV)
+	}
 
 	api := r.Group("/api/v1")
 	{
 		auth := api.Group("/auth")
 		{
-			auth.POST("/register", authHandler.Register)
-			auth.POST("/login", authHandler.Login)
-			auth.POST("/refresh", authHandler.RefreshToken)
+			auth.POST("/register", h.Auth.Register)
+			auth.POST("/login", h.Auth.Login)
+			auth.POST("/2fa/verify", h.Auth.VerifyTwoFactor)
+			auth.POST("/2fa/enroll", h.Auth.EnrollTwoFactorChallenge)
+			auth.POST("/refresh", h.Auth.RefreshToken)
+			auth.POST("/logout", h.Auth.Logout)
+			// GET serves the link in the verification email
+			auth.GET("/verify-email", h.Auth.VerifyEmail)
+			auth.POST("/verify-email", h.Auth.VerifyEmail)
+			auth.POST("/resend-verification", h.Auth.ResendVerification)
+			auth.POST("/forgot-password", h.Auth.ForgotPassword)
+			auth.POST("/reset-password", h.Auth.ResetPassword)
+			auth.POST("/logout-all", h.RequireAuth(), h.Auth.LogoutAll)
+
+			// OpenID Connect sign in, the browser is sent to the provider and back
+			auth.GET("/oidc", h.SSO.GetProviders)
+			auth.GET("/oidc/:provider/login", h.SSO.Login)
+			auth.GET("/oidc/:provider/callback", h.SSO.Callback)
+		}
+
+		api.GET("/users/me", append(requireAuth(service.ScopeProfileRead), h.User.GetMe)...)
+
+		// the account itself is managed from sessions only
+		users := api.Group("/users")
+		users.Use(requireAuth()...)
+		{
+			users.PUT("/me/timezone", h.User.UpdateTimezone)
+			users.PUT("/me/password", h.Auth.ChangePassword)
+			users.POST("/me/calendar-token", h.Calendar.CreateCalendarToken)
+			users.DELETE("/me/calendar-token", h.Calendar.RevokeCalendarToken)
+			users.GET("/me/sessions", h.Auth.GetSessions)
+			users.DELETE("/me/sessions/:id", h.Auth.RevokeSession)
+			users.GET("/me/2fa", h.Auth.GetTwoFactor)
+			users.POST("/me/2fa", h.Auth.EnrollTwoFactor)
+			users.DELETE("/me/2fa", h.Auth.DisableTwoFactor)
+			users.POST("/me/2fa/confirm", h.Auth.ConfirmTwoFactor)
+			users.POST("/me/2fa/recovery-codes", h.Auth.RegenerateRecoveryCodes)
+			users.GET("/me/identities", h.SSO.GetIdentities)
+			users.DELETE("/me/identities/:id", h.SSO.DeleteIdentity)
+			users.GET("/me/tokens", h.Auth.GetPersonalTokens)
+			users.POST("/me/tokens", h.Auth.CreatePersonalToken)
+			users.DELETE("/me/tokens/:id", h.Auth.RevokePersonalToken)
+		}
+
+		admin := api.Group("/admin")
+		admin.Use(requireAuth(service.ScopeAdmin)...)
+		admin.Use(h.RequireAdmin)
+		{
+			admin.GET("/settings/2fa", h.Auth.GetTwoFactorSettings)
+			admin.PUT("/settings/2fa", h.Auth.UpdateTwoFactorSettings)
+		}
+
+		// reading needs tasks:read, everything that changes data tasks:write
+		scoped := func(group *gin.RouterGroup) (read *gin.RouterGroup, write *gin.RouterGroup) {
+			return group.Group("", requireAuth(service.ScopeTasksRead)...), group.Group("", requireAuth(service.ScopeTasksWrite)...)
+		}
+
+		tasks, editTasks := scoped(api.Group("/tasks"))
+		{
+			tasks.GET("/", h.Task.GetTasks)
+			editTasks.POST("/", h.Task.CreateTask)
+			editTasks.POST("/quick", h.Task.QuickAddTask)
+			tasks.GET("/export", h.Task.ExportTasks)
+			editTasks.POST("/import", h.Task.ImportTasks)
+			editTasks.POST("/import/:source", h.Task.ImportFrom)
+			tasks.GET("/:id", h.Task.GetTask)
+			editTasks.PUT("/:id", h.Task.UpdateTask)
+			editTasks.DELETE("/:id", h.Task.DeleteTask)
+			editTasks.PUT("/:id/checkboxes/:index", h.Task.ToggleCheckbox)
+
+			editTasks.POST("/:id/snooze", h.Task.SnoozeTask)
+			editTasks.DELETE("/:id/snooze", h.Task.UnsnoozeTask)
+			editTasks.POST("/:id/pin", h.Task.PinTask)
+			editTasks.DELETE("/:id/pin", h.Task.UnpinTask)
+			editTasks.POST("/:id/my-day", h.Task.AddToMyDay)
+			editTasks.DELETE("/:id/my-day", h.Task.RemoveFromMyDay)
+
+			tasks.GET("/:id/reminders", h.Reminder.GetReminders)
+			editTasks.POST("/:id/reminders", h.Reminder.CreateReminder)
+			editTasks.DELETE("/:id/reminders/:reminderID", h.Reminder.DeleteReminder)
+		}
+
+		sync, editSync := scoped(api.Group("/sync"))
+		{
+			sync.GET("", h.Task.PullChanges)
+			editSync.POST("", h.Task.PushChanges)
+		}
+
+		// the feed URL carries its own secret, calendar apps cannot send a bearer token
+		api.GET("/calendar/:token/tasks.ics", h.Calendar.Feed)
+
+		// GET runs queries only, POST may carry mutations
+		api.GET("/graphql", append(requireAuth(service.ScopeTasksRead), h.GraphQL.Query)...)
+		// mutations also need tasks:write, the handler checks it once it knows the operation
+		api.POST("/graphql", append(requireAuth(service.ScopeTasksRead), h.GraphQL.Query)...)
+
+		api.GET("/events", h.RequireAuth(service.ScopeTasksRead), h.Event.StreamEvents)
+		// the WebSocket authenticates on its own, browsers cannot send headers with it
+		api.GET("/ws", h.WebSocket.Connect)
+
+		notifications, editNotifications := scoped(api.Group("/notifications"))
+		{
+			notifications.GET("/", h.Notification.GetNotifications)
+			editNotifications.POST("/:id/read", h.Notification.MarkNotificationRead)
+		}
+
+		milestones, editMilestones := scoped(api.Group("/milestones"))
+		{
+			milestones.GET("/", h.Milestone.GetMilestones)
+			editMilestones.POST("/", h.Milestone.CreateMilestone)
+			milestones.GET("/:id", h.Milestone.GetMilestone)
+			editMilestones.PUT("/:id", h.Milestone.UpdateMilestone)
+			editMilestones.DELETE("/:id", h.Milestone.DeleteMilestone)
+			editMilestones.POST("/:id/close", h.Milestone.CloseMilestone)
+			editMilestones.POST("/:id/reopen", h.Milestone.ReopenMilestone)
+		}
+
+		templates, editTemplates := scoped(api.Group("/templates"))
+		{
+			templates.GET("/", h.Template.GetTemplates)
+			editTemplates.POST("/", h.Template.CreateTemplate)
+			templates.GET("/:id", h.Template.GetTemplate)
+			editTemplates.PUT("/:id", h.Template.UpdateTemplate)
+			editTemplates.DELETE("/:id", h.Template.DeleteTemplate)
+			editTemplates.POST("/:id/instantiate", h.Template.InstantiateTemplate)
+			editTemplates.POST("/from-task/:id", h.Template.SaveTaskAsTemplate)
 		}
 
-		tasks := api.Group("/tasks")
-		tasks.Use(middleware.RequireAuth())
+		webhooks := api.Group("/webhooks")
+		webhooks.Use(requireAuth(service.ScopeWebhooks)...)
 		{
-			tasks.GET("/", taskHandler.GetTasks)
-			tasks.POST("/", taskHandler.CreateTask)
-			tasks.GET("/:id", taskHandler.GetTask)
-			tasks.PUT("/:id", taskHandler.UpdateTask)
-			tasks.DELETE("/:id", taskHandler.DeleteTask)
+			webhooks.GET("/", h.Webhook.GetWebhooks)
+			webhooks.POST("/", h.Webhook.CreateWebhook)
+			webhooks.GET("/:id", h.Webhook.GetWebhook)
+			webhooks.PUT("/:id", h.Webhook.UpdateWebhook)
+			webhooks.DELETE("/:id", h.Webhook.DeleteWebhook)
+			webhooks.GET("/:id/deliveries", h.Webhook.GetDeliveries)
+			webhooks.POST("/:id/deliveries/:deliveryID/redeliver", h.Webhook.Redeliver)
 		}
 	}
 }
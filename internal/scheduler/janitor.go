@@ -0,0 +1,41 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Janitor periodically deletes rows that are only kept for a while, like
+// expired idempotency keys. Jobs are keyed by a name used in the logs.
+type Janitor struct {
+	Interval time.Duration
+	Jobs     map[string]func() error
+}
+
+func NewJanitor(interval time.Duration) *Janitor {
+	return &Janitor{Interval: interval, Jobs: make(map[string]func() error)}
+}
+
+func (j *Janitor) Add(name string, job func() error) {
+	j.Jobs[name] = job
+}
+
+// Run blocks until ctx is cancelled
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for name, job := range j.Jobs {
+				if err := job(); err != nil {
+					log.Printf("cleanup of %s failed: %v", name, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package scheduler runs background jobs inside the server process
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/notify"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+)
+
+// ReminderScheduler polls the reminders table and delivers the due ones.
+// The state lives in the database, so reminders survive restarts, and a
+// lease keeps several instances from sending the same reminder twice.
+// Inbox reminders are written together with their completion, the other
+// channels go through Notifiers. Those are delivered at least once: an
+// instance that dies between sending and recording it sends again.
+type ReminderScheduler struct {
+	Repo        *repository.ReminderRepository
+	Notifiers   map[string]notify.Notifier
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	SendTimeout time.Duration
+}
+
+func NewReminderScheduler(repo *repository.ReminderRepository, notifiers map[string]notify.Notifier, interval time.Duration) *ReminderScheduler {
+	return &ReminderScheduler{
+		Repo:        repo,
+		Notifiers:   notifiers,
+		Interval:    interval,
+		BatchSize:   50,
+		MaxAttempts: 5,
+		SendTimeout: 15 * time.Second,
+	}
+}
+
+// Run blocks until ctx is cancelled
+func (s *ReminderScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	log.Printf("reminder scheduler started, polling every %s", s.Interval)
+
+	for {
+		s.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Println("reminder scheduler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ReminderScheduler) tick(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := s.Repo.ClaimDueReminders(s.BatchSize, s.lease())
+		if err != nil {
+			log.Print("cannot claim due reminders:", err)
+			return
+		}
+
+		for _, d := range due {
+			s.process(ctx, d)
+		}
+
+		// a full batch means there may be more waiting
+		if len(due) < s.BatchSize {
+			return
+		}
+	}
+}
+
+// lease outlasts the delivery of a whole batch, one reminder after another
+func (s *ReminderScheduler) lease() time.Duration {
+	return time.Duration(s.BatchSize)*s.SendTimeout + time.Minute
+}
+
+func (s *ReminderScheduler) process(ctx context.Context, due repository.DueReminder) {
+	var err error
+	if err = s.deliver(ctx, due.Reminder.Channel, message(due)); err != nil {
+		err = s.Repo.FailReminder(due.Reminder, s.MaxAttempts, err)
+	} else {
+		err = s.Repo.CompleteReminder(due.Reminder.ID)
+	}
+
+	if err != nil {
+		log.Printf("cannot record delivery of reminder %d: %v", due.Reminder.ID, err)
+	}
+}
+
+func (s *ReminderScheduler) deliver(ctx context.Context, channel string, msg notify.Message) error {
+	notifier, ok := s.Notifiers[channel]
+	if !ok {
+		return fmt.Errorf("channel %q is not configured", channel)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.SendTimeout)
+	defer cancel()
+
+	return notifier.Notify(ctx, msg)
+}
+
+func message(due repository.DueReminder) notify.Message {
+	body := "Reminder: " + due.TaskTitle
+	if due.DueAt != nil {
+		body += "\nDue at " + due.DueAt.Format(time.RFC1123)
+	}
+
+	return notify.Message{
+		ReminderID: due.Reminder.ID,
+		UserID:     due.Reminder.UserID,
+		TaskID:     due.Reminder.TaskID,
+		Email:      due.Email,
+		Target:     due.Reminder.Target,
+		Subject:    "Reminder: " + due.TaskTitle,
+		Body:       body,
+		DueAt:      due.DueAt,
+	}
+}
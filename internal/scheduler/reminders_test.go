@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/notify"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+)
+
+func TestReminderLeaseOutlastsBatch(t *testing.T) {
+	s := NewReminderScheduler(nil, nil, time.Minute)
+
+	if lease := s.lease(); lease <= time.Duration(s.BatchSize)*s.SendTimeout {
+		t.Errorf("lease %s does not cover %d sends of %s", lease, s.BatchSize, s.SendTimeout)
+	}
+}
+
+func TestReminderMessage(t *testing.T) {
+	due := time.Date(2026, time.October, 15, 9, 0, 0, 0, time.UTC)
+	msg := message(repository.DueReminder{
+		Reminder:  models.Reminder{ID: 3, TaskID: 4, UserID: 5, Target: "https://example.com/hook"},
+		TaskTitle: "Pay rent",
+		DueAt:     &due,
+		Email:     "user@example.com",
+	})
+
+	if msg.ReminderID != 3 || msg.TaskID != 4 || msg.UserID != 5 || msg.Target != "https://example.com/hook" || msg.Email != "user@example.com" {
+		t.Errorf("message = %+v", msg)
+	}
+	if msg.Subject != "Reminder: Pay rent" || msg.Body != "Reminder: Pay rent\nDue at Thu, 15 Oct 2026 09:00:00 UTC" {
+		t.Errorf("subject = %q, body = %q", msg.Subject, msg.Body)
+	}
+}
+
+type recordingNotifier struct {
+	sent []notify.Message
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func TestReminderDeliverPicksChannel(t *testing.T) {
+	inbox, email := &recordingNotifier{}, &recordingNotifier{}
+	s := NewReminderScheduler(nil, map[string]notify.Notifier{"inbox": inbox, "email": email}, time.Minute)
+
+	if err := s.deliver(context.Background(), "inbox", notify.Message{ReminderID: 1}); err != nil {
+		t.Fatalf("deliver(inbox) error = %v", err)
+	}
+	if len(inbox.sent) != 1 || len(email.sent) != 0 {
+		t.Errorf("inbox got %d messages, email %d", len(inbox.sent), len(email.sent))
+	}
+
+	if err := s.deliver(context.Background(), "sms", notify.Message{ReminderID: 2}); err == nil {
+		t.Error("deliver accepted a channel without notifier")
+	}
+}
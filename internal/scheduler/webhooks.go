@@ -0,0 +1,135 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/netguard"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+)
+
+// WebhookDispatcher drains the webhook outbox. Deliveries are retried with
+// exponential backoff, and a webhook that keeps failing is switched off.
+// A delivery is sent at least once: when the instance sending it dies before
+// the outcome is recorded it is sent again, receivers can tell the copies
+// apart by X-Webhook-Delivery.
+type WebhookDispatcher struct {
+	Repo         *repository.WebhookRepository
+	Client       *http.Client
+	Interval     time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	DisableAfter int
+}
+
+// NewWebhookDispatcher sends deliveries to public addresses only, unless
+// allowPrivate is set, see netguard
+func NewWebhookDispatcher(repo *repository.WebhookRepository, interval time.Duration, allowPrivate bool) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		Repo:         repo,
+		Client:       netguard.NewClient(10*time.Second, allowPrivate),
+		Interval:     interval,
+		BatchSize:    50,
+		MaxAttempts:  8,
+		DisableAfter: 20,
+	}
+}
+
+// Run blocks until ctx is cancelled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	log.Printf("webhook dispatcher started, polling every %s", d.Interval)
+
+	for {
+		d.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Println("webhook dispatcher stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *WebhookDispatcher) tick(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := d.Repo.ClaimDueDeliveries(d.BatchSize, d.lease())
+		if err != nil {
+			log.Print("cannot claim webhook deliveries:", err)
+			return
+		}
+
+		for _, delivery := range due {
+			d.process(ctx, delivery)
+		}
+
+		// a full batch means there may be more waiting
+		if len(due) < d.BatchSize {
+			return
+		}
+	}
+}
+
+// lease outlasts the delivery of a whole batch, one delivery after another
+func (d *WebhookDispatcher) lease() time.Duration {
+	return time.Duration(d.BatchSize)*d.Client.Timeout + time.Minute
+}
+
+func (d *WebhookDispatcher) process(ctx context.Context, due repository.DueDelivery) {
+	code, err := d.send(ctx, due)
+	if err != nil {
+		err = d.Repo.FailDelivery(due.Delivery, code, d.MaxAttempts, d.DisableAfter, err)
+	} else {
+		err = d.Repo.CompleteDelivery(due.Delivery, code)
+	}
+
+	if err != nil {
+		log.Printf("cannot record webhook delivery %d: %v", due.Delivery.ID, err)
+	}
+}
+
+// Sign returns the X-Signature value of body, receivers compute the same HMAC to check it
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *WebhookDispatcher) send(ctx context.Context, due repository.DueDelivery) (int, error) {
+	body := []byte(due.Delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, due.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TODO-API-Webhooks")
+	req.Header.Set("X-Signature", Sign(due.Secret, body))
+	req.Header.Set("X-Webhook-Event", due.Delivery.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(due.Delivery.ID, 10))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+)
+
+func TestWebhookLeaseOutlastsBatch(t *testing.T) {
+	d := NewWebhookDispatcher(nil, time.Minute, false)
+
+	if lease := d.lease(); lease <= time.Duration(d.BatchSize)*d.Client.Timeout {
+		t.Errorf("lease %s does not cover %d sends of %s", lease, d.BatchSize, d.Client.Timeout)
+	}
+}
+
+func TestWebhookSendSignsPayload(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	d := NewWebhookDispatcher(nil, time.Minute, true)
+	due := repository.DueDelivery{
+		Delivery: models.WebhookDelivery{ID: 7, EventType: "task.created", Payload: `{"event":"task.created"}`},
+		URL:      server.URL,
+		Secret:   "secret",
+	}
+
+	code, err := d.send(context.Background(), due)
+	if err != nil || code != http.StatusNoContent {
+		t.Fatalf("send() = %d, %v", code, err)
+	}
+
+	if string(body) != due.Delivery.Payload {
+		t.Errorf("body = %s", body)
+	}
+	if sig := got.Header.Get("X-Signature"); sig != Sign("secret", body) {
+		t.Errorf("X-Signature = %q", sig)
+	}
+	if got.Header.Get("X-Webhook-Event") != "task.created" || got.Header.Get("X-Webhook-Delivery") != "7" {
+		t.Errorf("headers = %v", got.Header)
+	}
+}
+
+func TestWebhookSendReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	due := repository.DueDelivery{Delivery: models.WebhookDelivery{ID: 1, Payload: "{}"}, URL: server.URL, Secret: "secret"}
+
+	if code, err := NewWebhookDispatcher(nil, time.Minute, true).send(context.Background(), due); err == nil || code != http.StatusBadGateway {
+		t.Errorf("send() = %d, %v, want the 502 as an error", code, err)
+	}
+
+	// the test server listens on loopback, which receivers may not
+	if _, err := NewWebhookDispatcher(nil, time.Minute, false).send(context.Background(), due); err == nil {
+		t.Error("send() to a loopback address succeeded")
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/mail"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/utils"
+)
+
+const (
+	verifyEmailPurpose = "verify-email"
+	verificationTTL    = 48 * time.Hour
+	passwordResetTTL   = time.Hour
+	minPasswordLength  = 8
+	mailTimeout        = 30 * time.Second
+)
+
+// VerifyEmail marks the address of the token as verified. The token stays
+// valid until it expires, opening the link twice does no harm.
+func (s *AuthService) VerifyEmail(token string) error {
+	claims, err := s.Signer.ValidateActionToken(verifyEmailPurpose, token)
+	if err != nil {
+		return invalid("invalid or expired token")
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return invalid("invalid or expired token")
+	}
+
+	verified, err := s.Users.MarkEmailVerified(userID, claims.Email)
+	if err != nil {
+		return internal("cannot verify email")
+	}
+	// the user has changed the address since
+	if !verified {
+		return invalid("invalid or expired token")
+	}
+
+	return nil
+}
+
+// ResendVerification sends another verification email. Unknown and already
+// verified addresses are ignored silently, the answer must not tell which
+// addresses have an account.
+func (s *AuthService) ResendVerification(email string) error {
+	user, err := s.Users.GetUserByEmail(email)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return internal("server error")
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	if err := s.sendVerification(user); err != nil {
+		log.Print("cannot send verification email:", err)
+		return internal("cannot send email")
+	}
+
+	return nil
+}
+
+// ForgotPassword emails a single-use reset token. Like ResendVerification
+// it answers the same whether the address has an account or not.
+func (s *AuthService) ForgotPassword(email string) error {
+	user, err := s.Users.GetUserByEmail(email)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return internal("server error")
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return internal("cannot generate token")
+	}
+
+	if err := s.Resets.CreateResetToken(user.ID, hashToken(token), time.Now().Add(passwordResetTTL)); err != nil {
+		return internal("cannot save token")
+	}
+
+	body := "Someone asked to reset the password of your TODO account. If it was not you, ignore this email.\n\n"
+	if s.PasswordResetURL != "" {
+		body += "Choose a new password here within an hour:\n\n" + s.PasswordResetURL + "?token=" + url.QueryEscape(token) + "\n"
+	} else {
+		body += "Send this token with your new password to POST /api/v1/auth/reset-password within an hour:\n\n" + token + "\n"
+	}
+
+	if err := s.send(mail.Email{To: user.Email, Subject: "Reset your password", Body: body}); err != nil {
+		log.Print("cannot send password reset email:", err)
+		return internal("cannot send email")
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. Every
+// session is signed out and every personal access token revoked, whoever
+// knew the old password is locked out.
+func (s *AuthService) ResetPassword(token string, password string) error {
+	if len(password) < minPasswordLength {
+		return invalid("password must be at least 8 characters")
+	}
+
+	userID, err := s.Resets.UseResetToken(hashToken(token))
+	if err == sql.ErrNoRows {
+		return invalid("invalid or expired token")
+	} else if err != nil {
+		return internal("server error")
+	}
+
+	if err := s.setPassword(userID, password); err != nil {
+		return err
+	}
+
+	// following the link proves the user owns the address
+	if user, err := s.Users.GetUserByID(userID); err == nil {
+		s.Users.MarkEmailVerified(user.ID, user.Email)
+	}
+
+	if err := s.PersonalTokens.RevokeUserTokens(userID); err != nil {
+		return internal("cannot revoke tokens")
+	}
+
+	return s.LogoutAll(userID)
+}
+
+// ChangePassword replaces the password after checking the current one and
+// signs out every session but the one making the change
+func (s *AuthService) ChangePassword(userID int, sessionID string, current string, password string) error {
+	if len(password) < minPasswordLength {
+		return invalid("password must be at least 8 characters")
+	}
+
+	user, err := s.Users.GetUserByID(userID)
+	if err == sql.ErrNoRows {
+		return notFound("user not found")
+	} else if err != nil {
+		return internal("server error")
+	}
+
+	if err := utils.CheckPassword(current, user.Password); err != nil {
+		return forbidden("wrong password")
+	}
+
+	if err := s.setPassword(userID, password); err != nil {
+		return err
+	}
+
+	ids, err := s.Sessions.RevokeUserSessions(userID, sessionID)
+	if err != nil {
+		return internal("cannot revoke sessions")
+	}
+
+	s.Revoked.Revoke(ids...)
+	return nil
+}
+
+func (s *AuthService) setPassword(userID int, password string) error {
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return internal("cannot hash password")
+	}
+
+	if err := s.Users.SetPassword(userID, hash); err != nil {
+		return internal("cannot save password")
+	}
+
+	return nil
+}
+
+func (s *AuthService) sendVerification(user *models.User) error {
+	token, err := s.Signer.GenerateActionToken(verifyEmailPurpose, user.ID, user.Email, verificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.PublicURL + "/api/v1/auth/verify-email?token=" + url.QueryEscape(token)
+	return s.send(mail.Email{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body:    "Welcome, " + user.Username + "!\n\nOpen this link within two days to verify your email address:\n\n" + link + "\n",
+	})
+}
+
+func (s *AuthService) send(email mail.Email) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+
+	return s.Mailer.Send(ctx, email)
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/mail"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/sessions"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/helpers"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/utils"
+)
+
+type AuthService struct {
+	Users          *repository.UserRepository
+	RefreshTokens  *repository.RefreshTokenRepository
+	Sessions       *repository.SessionRepository
+	Revoked        *sessions.Cache
+	Signer         *helpers.TokenSigner
+	Resets         *repository.PasswordResetRepository
+	TwoFactor      *repository.TwoFactorRepository
+	Challenges     *repository.LoginChallengeRepository
+	Settings       *repository.SettingsRepository
+	PersonalTokens *repository.PersonalTokenRepository
+	Mailer         mail.Mailer
+
+	// RefreshTTL is how long a refresh token lasts, every refresh starts it anew
+	RefreshTTL time.Duration
+	// RequireVerification refuses to sign in users who have not verified their email
+	RequireVerification bool
+	// PublicURL is where users reach the API, links in emails start with it
+	PublicURL string
+	// PasswordResetURL is a page of the web app that takes the reset token
+	// as ?token=, without it the email contains the bare token
+	PasswordResetURL string
+}
+
+func NewAuthService(users *repository.UserRepository, refreshTokens *repository.RefreshTokenRepository, sessionRepo *repository.SessionRepository, revoked *sessions.Cache, signer *helpers.TokenSigner, resets *repository.PasswordResetRepository, twoFactor *repository.TwoFactorRepository, challenges *repository.LoginChallengeRepository, settings *repository.SettingsRepository, personalTokens *repository.PersonalTokenRepository, mailer mail.Mailer, refreshTTL time.Duration) *AuthService {
+	return &AuthService{
+		Users:          users,
+		RefreshTokens:  refreshTokens,
+		Sessions:       sessionRepo,
+		Revoked:        revoked,
+		Signer:         signer,
+		Resets:         resets,
+		TwoFactor:      twoFactor,
+		Challenges:     challenges,
+		Settings:       settings,
+		PersonalTokens: personalTokens,
+		Mailer:         mailer,
+		RefreshTTL:     refreshTTL,
+		PublicURL:      "http://localhost:8080",
+	}
+}
+
+// Tokens is a newly issued pair of access and refresh token
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+// Device describes where a sign in comes from, it is shown in the session list
+type Device struct {
+	UserAgent string
+	IP        string
+}
+
+// Register creates the user with the password hashed
+func (s *AuthService) Register(user *models.User) error {
+	if user.Timezone != "" {
+		if _, err := time.LoadLocation(user.Timezone); err != nil {
+			return invalid("unknown timezone")
+		}
+	}
+
+	if len(user.Password) < minPasswordLength {
+		return invalid("password must be at least 8 characters")
+	}
+
+	userExists, err := s.Users.GetUserByEmail(user.Email)
+	if err != nil && err != sql.ErrNoRows {
+		return internal("server error")
+	}
+
+	if userExists != nil {
+		return conflict("user already exists")
+	}
+
+	user.Password, err = utils.HashPassword(user.Password)
+	if err != nil {
+		return internal("cannot hash password")
+	}
+
+	// admins are appointed in the database, nobody signs up as one
+	user.Role = "user"
+	user.EmailVerified = false
+	if err := s.Users.CreateNewUser(user); err != nil {
+		return internal("cannot create user")
+	}
+
+	// the user can ask for another email, registration does not fail on it
+	if err := s.sendVerification(user); err != nil {
+		log.Print("cannot send verification email:", err)
+	}
+
+	return nil
+}
+
+// Login checks the password. Users with two-factor authentication get a
+// challenge instead of tokens, Verify2FA finishes their sign in.
+func (s *AuthService) Login(email string, password string, device Device) (*Tokens, *Challenge, error) {
+	user, err := s.Users.GetUserByEmail(email)
+	if err == sql.ErrNoRows {
+		log.Println("user not found")
+		return nil, nil, unauthenticated("wrong email or password")
+	} else if err != nil {
+		return nil, nil, internal("server error")
+	}
+
+	if err := utils.CheckPassword(password, user.Password); err != nil {
+		log.Println("wrong password")
+		return nil, nil, unauthenticated("wrong email or password")
+	}
+
+	if s.RequireVerification && !user.EmailVerified {
+		return nil, nil, forbidden("email not verified")
+	}
+
+	return s.signIn(user, device)
+}
+
+// signIn starts a session for a user whose identity was checked, or a
+// challenge when the user needs a second factor
+func (s *AuthService) signIn(user *models.User, device Device) (*Tokens, *Challenge, error) {
+	required, err := s.twoFactorRequired(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if user.TwoFactor || required {
+		challenge, err := s.challenge(user, device)
+		return nil, challenge, err
+	}
+
+	tokens, err := s.startSession(user, device)
+	return tokens, nil, err
+}
+
+// startSession creates a session on the device and issues its first tokens
+func (s *AuthService) startSession(user *models.User, device Device) (*Tokens, error) {
+	session := &models.Session{
+		UserID:    user.ID,
+		UserAgent: device.UserAgent,
+		IP:        device.IP,
+		ExpiresAt: time.Now().Add(s.RefreshTTL),
+	}
+
+	var err error
+	session.ID, err = randomToken(16)
+	if err != nil {
+		return nil, internal("cannot generate tokens")
+	}
+
+	if err := s.Sessions.CreateSession(session); err != nil {
+		return nil, internal("cannot create session")
+	}
+
+	return s.issueTokens(user, session.ID, session.ExpiresAt)
+}
+
+// Refresh exchanges a refresh token for a new pair of tokens. The old
+// refresh token stops working. Presenting it again means it was copied,
+// so the session is revoked and both holders have to sign in again.
+func (s *AuthService) Refresh(refreshToken string, device Device) (*Tokens, error) {
+	tokenHash := hashToken(refreshToken)
+
+	token, err := s.RefreshTokens.UseRefreshToken(tokenHash)
+	if err == sql.ErrNoRows {
+		s.detectReuse(tokenHash)
+		return nil, unauthenticated("invalid refresh token")
+	} else if err != nil {
+		return nil, internal("server error")
+	}
+
+	user, err := s.Users.GetUserByID(token.UserID)
+	if err == sql.ErrNoRows {
+		return nil, unauthenticated("invalid refresh token")
+	} else if err != nil {
+		return nil, internal("server error")
+	}
+
+	// sessions started before 2FA became mandatory for the user end here
+	if required, err := s.twoFactorRequired(user); err != nil {
+		return nil, err
+	} else if required && !user.TwoFactor {
+		s.revokeSession(user.ID, token.FamilyID)
+		return nil, unauthenticated("two-factor authentication required, sign in again")
+	}
+
+	expiresAt := time.Now().Add(s.RefreshTTL)
+	if err := s.Sessions.ExtendSession(token.FamilyID, device.IP, expiresAt); err != nil {
+		return nil, internal("server error")
+	}
+
+	return s.issueTokens(user, token.FamilyID, expiresAt)
+}
+
+// detectReuse revokes the session of a refresh token that was already exchanged
+func (s *AuthService) detectReuse(tokenHash string) {
+	token, err := s.RefreshTokens.GetRefreshToken(tokenHash)
+	if err != nil || token.UsedAt == nil {
+		return
+	}
+
+	log.Printf("refresh token of user %d reused, revoking its session", token.UserID)
+	s.revokeSession(token.UserID, token.FamilyID)
+}
+
+// Logout revokes the session the refresh token belongs to. Unknown tokens
+// are ignored, there is nothing left to sign out.
+func (s *AuthService) Logout(refreshToken string) error {
+	token, err := s.RefreshTokens.GetRefreshToken(hashToken(refreshToken))
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return internal("server error")
+	}
+
+	if _, err := s.revokeSession(token.UserID, token.FamilyID); err != nil {
+		return internal("cannot revoke session")
+	}
+
+	return nil
+}
+
+// LogoutAll revokes every session of the user
+func (s *AuthService) LogoutAll(userID int) error {
+	ids, err := s.Sessions.RevokeUserSessions(userID, "")
+	if err != nil {
+		return internal("cannot revoke sessions")
+	}
+
+	s.Revoked.Revoke(ids...)
+	return nil
+}
+
+// ListSessions returns the active sessions of the user, currentID is marked as the current one
+func (s *AuthService) ListSessions(userID int, currentID string) ([]models.Session, error) {
+	list, err := s.Sessions.GetSessions(userID)
+	if err != nil {
+		return nil, internal("cannot get sessions")
+	}
+
+	for i := range list {
+		list[i].Current = list[i].ID == currentID
+	}
+
+	return list, nil
+}
+
+// RevokeSession signs the session out, its access tokens stop working at once
+func (s *AuthService) RevokeSession(userID int, id string) error {
+	found, err := s.revokeSession(userID, id)
+	if err != nil {
+		return internal("cannot revoke session")
+	}
+	if !found {
+		return notFound("session not found")
+	}
+
+	return nil
+}
+
+func (s *AuthService) revokeSession(userID int, id string) (bool, error) {
+	found, err := s.Sessions.RevokeSession(userID, id)
+	if found {
+		s.Revoked.Revoke(id)
+	}
+	return found, err
+}
+
+// Authenticate validates an access token and rejects tokens of revoked sessions
+func (s *AuthService) Authenticate(accessToken string) (*helpers.SignedDetails, error) {
+	claims, err := s.Signer.ValidateToken(accessToken)
+	if err != nil {
+		return nil, unauthenticated("invalid token")
+	}
+
+	// tokens issued before sessions existed carry none, they expire within a day
+	if claims.SessionID != "" {
+		if s.Revoked.IsRevoked(claims.SessionID) {
+			return nil, unauthenticated("session revoked")
+		}
+		s.Revoked.Seen(claims.SessionID)
+	}
+
+	return claims, nil
+}
+
+// issueTokens signs an access token and stores a new refresh token of the session
+func (s *AuthService) issueTokens(user *models.User, sessionID string, expiresAt time.Time) (*Tokens, error) {
+	accessToken, err := s.Signer.GenerateAccessToken(user.ID, user.Username, user.Email, user.Role, sessionID)
+	if err != nil {
+		return nil, internal("cannot generate tokens")
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, internal("cannot generate tokens")
+	}
+
+	err = s.RefreshTokens.CreateRefreshToken(&models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return nil, internal("cannot save refresh token")
+	}
+
+	return &Tokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
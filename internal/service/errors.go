@@ -0,0 +1,61 @@
+// Package service holds the task and auth operations independent of the
+// transport. The REST handlers and the gRPC server both call it and only
+// translate requests, responses and errors.
+package service
+
+import "errors"
+
+// Kind tells a transport which status to report an error with
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindInvalid
+	KindNotFound
+	KindConflict
+	KindUnauthenticated
+	KindForbidden
+)
+
+// Error is a failed operation. Message is safe to show to the client.
+type Error struct {
+	Kind    Kind
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// KindOf returns the kind of err, errors from outside the service are internal
+func KindOf(err error) Kind {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Kind
+	}
+	return KindInternal
+}
+
+func invalid(message string) error {
+	return &Error{Kind: KindInvalid, Message: message}
+}
+
+func notFound(message string) error {
+	return &Error{Kind: KindNotFound, Message: message}
+}
+
+func conflict(message string) error {
+	return &Error{Kind: KindConflict, Message: message}
+}
+
+func unauthenticated(message string) error {
+	return &Error{Kind: KindUnauthenticated, Message: message}
+}
+
+func forbidden(message string) error {
+	return &Error{Kind: KindForbidden, Message: message}
+}
+
+func internal(message string) error {
+	return &Error{Kind: KindInternal, Message: message}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/sso"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/utils"
+)
+
+const (
+	oidcStateTTL = 10 * time.Minute
+	ssoTimeout   = 15 * time.Second
+)
+
+// SSOService signs users in with external OpenID Connect providers. An
+// external account is linked to the user with the same verified email, or
+// a user is created for it. Sign ins end like password logins, with our
+// own tokens or a 2FA challenge.
+type SSOService struct {
+	Auth       *AuthService
+	Identities *repository.IdentityRepository
+	States     *repository.OIDCStateRepository
+	Providers  map[string]*sso.Provider
+}
+
+func NewSSOService(auth *AuthService, identities *repository.IdentityRepository, states *repository.OIDCStateRepository, providers []*sso.Provider) *SSOService {
+	byName := make(map[string]*sso.Provider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name] = provider
+	}
+
+	return &SSOService{Auth: auth, Identities: identities, States: states, Providers: byName}
+}
+
+// ProviderNames lists the configured providers
+func (s *SSOService) ProviderNames() []string {
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Begin returns the URL of the provider the user signs in at. The state,
+// nonce and PKCE verifier are remembered for the callback.
+func (s *SSOService) Begin(providerName string) (string, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return "", notFound("unknown identity provider")
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		return "", internal("cannot generate state")
+	}
+	nonce, err := randomToken(32)
+	if err != nil {
+		return "", internal("cannot generate state")
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", internal("cannot generate state")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ssoTimeout)
+	defer cancel()
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		log.Print("cannot start OIDC sign in:", err)
+		return "", internal("identity provider unavailable")
+	}
+
+	err = s.States.CreateState(&models.OIDCLoginState{
+		StateHash:    hashToken(state),
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		return "", internal("cannot save state")
+	}
+
+	return authURL, nil
+}
+
+// Complete handles the callback of the provider: the state must be one of
+// Begin's, the code is exchanged and the ID token checked
+func (s *SSOService) Complete(providerName string, state string, code string, device Device) (*Tokens, *Challenge, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return nil, nil, notFound("unknown identity provider")
+	}
+
+	login, err := s.States.UseState(hashToken(state), provider.Name)
+	if err == sql.ErrNoRows {
+		return nil, nil, invalid("invalid or expired state")
+	} else if err != nil {
+		return nil, nil, internal("server error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ssoTimeout)
+	defer cancel()
+
+	identity, err := provider.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Print("cannot complete OIDC sign in:", err)
+		return nil, nil, unauthenticated("sign in with the identity provider failed")
+	}
+
+	user, err := s.userFor(provider.Name, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.Auth.signIn(user, device)
+}
+
+// ListIdentities returns the external accounts linked to the user
+func (s *SSOService) ListIdentities(userID int) ([]models.UserIdentity, error) {
+	identities, err := s.Identities.GetIdentities(userID)
+	if err != nil {
+		return nil, internal("cannot get identities")
+	}
+
+	return identities, nil
+}
+
+// Unlink removes an external account from the user. Signing in with it
+// links it again as long as its verified email matches the user's.
+func (s *SSOService) Unlink(userID int, id int) error {
+	found, err := s.Identities.DeleteIdentity(userID, id)
+	if err != nil {
+		return internal("cannot unlink identity")
+	}
+	if !found {
+		return notFound("identity not found")
+	}
+
+	return nil
+}
+
+// userFor finds the user of an external account. Unknown accounts are
+// linked by verified email or get a new user.
+func (s *SSOService) userFor(providerName string, identity *sso.Identity) (*models.User, error) {
+	linked, err := s.Identities.GetIdentity(providerName, identity.Subject)
+	if err == nil {
+		user, err := s.Auth.Users.GetUserByID(linked.UserID)
+		if err != nil {
+			return nil, internal("server error")
+		}
+		s.Identities.TouchIdentity(linked.ID, identity.Email)
+		return user, nil
+	} else if err != sql.ErrNoRows {
+		return nil, internal("server error")
+	}
+
+	// without a verified address anybody could claim an existing account
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, forbidden("the identity provider has not verified the email address")
+	}
+
+	user, err := s.Auth.Users.GetUserByEmail(identity.Email)
+	switch {
+	case err == sql.ErrNoRows:
+		if user, err = s.createUser(identity); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, internal("server error")
+	case !user.EmailVerified:
+		// whoever registered the address may not own it, they would keep the password
+		return nil, conflict("an account with this email exists but its address is not verified, verify it first")
+	}
+
+	err = s.Identities.CreateIdentity(&models.UserIdentity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		return nil, internal("cannot link identity")
+	}
+
+	return user, nil
+}
+
+// createUser signs up the owner of an external account. The password is
+// random and unknown, the user can set one with ForgotPassword.
+func (s *SSOService) createUser(identity *sso.Identity) (*models.User, error) {
+	password, err := randomToken(32)
+	if err != nil {
+		return nil, internal("cannot create user")
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, internal("cannot hash password")
+	}
+
+	user := &models.User{
+		Username: username(identity),
+		Email:    identity.Email,
+		Password: hash,
+		Role:     "user",
+	}
+
+	if err := s.Auth.Users.CreateNewUser(user); err != nil {
+		return nil, internal("cannot create user")
+	}
+
+	if _, err := s.Auth.Users.MarkEmailVerified(user.ID, user.Email); err != nil {
+		return nil, internal("cannot create user")
+	}
+	user.EmailVerified = true
+
+	return user, nil
+}
+
+// username picks a name for a new user from the claims of the provider
+func username(identity *sso.Identity) string {
+	local, _, _ := strings.Cut(identity.Email, "@")
+
+	for _, name := range []string{identity.Username, identity.Name, local} {
+		name = strings.TrimSpace(name)
+		if len([]rune(name)) >= 3 {
+			if runes := []rune(name); len(runes) > 50 {
+				name = string(runes[:50])
+			}
+			return name
+		}
+	}
+
+	return identity.Email
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"database/sql"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = validator.New()
+
+// MaxPageSize is the largest page of tasks List returns at once
+const MaxPageSize = 1000
+
+type TaskService struct {
+	Tasks      *repository.TaskRepository
+	Milestones *repository.MilestoneRepository
+}
+
+func NewTaskService(tasks *repository.TaskRepository, milestones *repository.MilestoneRepository) *TaskService {
+	return &TaskService{Tasks: tasks, Milestones: milestones}
+}
+
+// List returns the user's tasks narrowed down by the filter
+func (s *TaskService) List(userID int, filter repository.TaskFilter) ([]models.Task, error) {
+	switch filter.View {
+	case repository.ViewDefault, repository.ViewMyDay, repository.ViewPinned, repository.ViewSnoozed:
+	default:
+		return nil, invalid("invalid view")
+	}
+
+	if filter.Limit < 0 || filter.Limit > MaxPageSize {
+		return nil, invalid("invalid limit")
+	}
+	if filter.Offset < 0 {
+		return nil, invalid("invalid offset")
+	}
+
+	tasks, err := s.Tasks.GetAllTasksByUserID(userID, filter)
+	if err != nil {
+		return nil, internal("cannot get tasks")
+	}
+
+	return tasks, nil
+}
+
+func (s *TaskService) Get(userID int, taskID int) (*models.Task, error) {
+	task, err := s.Tasks.GetTaskByID(taskID, userID)
+	if err == sql.ErrNoRows {
+		return nil, notFound("task not found")
+	} else if err != nil {
+		return nil, internal("cannot get task")
+	}
+
+	return task, nil
+}
+
+// Create validates the task and creates it for the user
+func (s *TaskService) Create(userID int, task *models.Task) error {
+	if err := validate.Struct(task); err != nil {
+		return invalid(err.Error())
+	}
+
+	return s.create(userID, task)
+}
+
+// CreateQuickAdd is Create for a task typed as a single line. The line is
+// kept as the description, so it is not held to the usual minimum length.
+func (s *TaskService) CreateQuickAdd(userID int, task *models.Task) error {
+	if err := validate.StructExcept(task, "Description"); err != nil {
+		return invalid(err.Error())
+	}
+
+	return s.create(userID, task)
+}
+
+func (s *TaskService) create(userID int, task *models.Task) error {
+	task.UserID = userID
+
+	if err := s.CheckReferences(task); err != nil {
+		return err
+	}
+
+	if err := s.Tasks.CreateNewTask(task); err != nil {
+		return internal("cannot create task")
+	}
+
+	return nil
+}
+
+// Update validates the task and overwrites the user's task with it
+func (s *TaskService) Update(userID int, taskID int, task *models.Task) error {
+	if err := validate.Struct(task); err != nil {
+		return invalid(err.Error())
+	}
+
+	task.ID = taskID
+	task.UserID = userID
+	// a subtask cannot be moved to another parent
+	task.ParentID = nil
+
+	if err := s.CheckReferences(task); err != nil {
+		return err
+	}
+
+	if err := s.Tasks.UpdateTask(task); err == sql.ErrNoRows {
+		return notFound("task not found")
+	} else if err != nil {
+		return internal("cannot update task")
+	}
+
+	return nil
+}
+
+func (s *TaskService) Delete(userID int, taskID int) error {
+	if err := s.Tasks.DeleteTask(taskID, userID); err == sql.ErrNoRows {
+		return notFound("task not found")
+	} else if err != nil {
+		return internal("cannot delete task")
+	}
+
+	return nil
+}
+
+// CheckReferences makes sure the task points only to a milestone and a parent task of its owner
+func (s *TaskService) CheckReferences(task *models.Task) error {
+	if task.MilestoneID != nil {
+		_, err := s.Milestones.GetMilestoneByID(*task.MilestoneID, task.UserID)
+		if err == sql.ErrNoRows {
+			return invalid("milestone not found")
+		} else if err != nil {
+			return internal("cannot get milestone")
+		}
+	}
+
+	if task.ParentID != nil {
+		_, err := s.Tasks.GetTaskByID(*task.ParentID, task.UserID)
+		if err == sql.ErrNoRows {
+			return invalid("parent task not found")
+		} else if err != nil {
+			return internal("cannot get parent task")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// Scopes of personal access tokens, every protected route declares the ones it needs
+const (
+	ScopeTasksRead   = "tasks:read"
+	ScopeTasksWrite  = "tasks:write"
+	ScopeWebhooks    = "webhooks"
+	ScopeProfileRead = "profile:read"
+	ScopeAdmin       = "admin"
+)
+
+// Scopes lists every scope a token can carry
+var Scopes = []string{ScopeTasksRead, ScopeTasksWrite, ScopeWebhooks, ScopeProfileRead, ScopeAdmin}
+
+const (
+	// PersonalTokenPrefix starts every personal access token, it tells them from JWTs
+	PersonalTokenPrefix = "todo_pat_"
+	// personalTokenPrefixLength is how much of the token is kept to tell tokens apart
+	personalTokenPrefixLength = len(PersonalTokenPrefix) + 4
+	maxPersonalTokenTTL       = 366 * 24 * time.Hour
+)
+
+// Principal is the user a request acts for and the credential it came with
+type Principal struct {
+	UserID int
+	Role   string
+	// SessionID is set for access tokens
+	SessionID string
+	// TokenID is set for personal access tokens, they may only do what Scopes allow
+	TokenID int
+	Scopes  []string
+}
+
+// MissingScope returns the first of required the credential lacks, or an
+// empty string. Access tokens of a session may do anything.
+func (p *Principal) MissingScope(required []string) string {
+	if p.TokenID == 0 {
+		return ""
+	}
+
+	for _, scope := range required {
+		if !slices.Contains(p.Scopes, scope) {
+			return scope
+		}
+	}
+	return ""
+}
+
+// AuthenticateRequest accepts an access token or a personal access token
+func (s *AuthService) AuthenticateRequest(token string) (*Principal, error) {
+	if !strings.HasPrefix(token, PersonalTokenPrefix) {
+		claims, err := s.Authenticate(token)
+		if err != nil {
+			return nil, err
+		}
+		return &Principal{UserID: claims.ID, Role: claims.Role, SessionID: claims.SessionID}, nil
+	}
+
+	pat, role, err := s.PersonalTokens.GetActiveToken(hashToken(token))
+	if err == sql.ErrNoRows {
+		return nil, unauthenticated("invalid token")
+	} else if err != nil {
+		return nil, internal("server error")
+	}
+
+	s.PersonalTokens.TouchToken(pat.ID)
+
+	return &Principal{UserID: pat.UserID, Role: role, TokenID: pat.ID, Scopes: pat.Scopes}, nil
+}
+
+// CreatePersonalToken issues a token with the scopes until expiresAt. The
+// token is returned only here, the server keeps its hash.
+func (s *AuthService) CreatePersonalToken(userID int, name string, scopes []string, expiresAt time.Time) (*models.PersonalAccessToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 100 {
+		return nil, "", invalid("name must be 1 to 100 characters")
+	}
+
+	if len(scopes) == 0 {
+		return nil, "", invalid("a token needs at least one scope")
+	}
+
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return nil, "", invalid("unknown scope " + scope)
+		}
+	}
+
+	if !expiresAt.After(time.Now()) {
+		return nil, "", invalid("expires_at must be in the future")
+	}
+	if expiresAt.After(time.Now().Add(maxPersonalTokenTTL)) {
+		return nil, "", invalid("tokens expire within a year")
+	}
+
+	if slices.Contains(scopes, ScopeAdmin) {
+		user, err := s.Users.GetUserByID(userID)
+		if err != nil {
+			return nil, "", internal("server error")
+		}
+		if user.Role != "admin" {
+			return nil, "", forbidden("only admins can create tokens with the admin scope")
+		}
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", internal("cannot generate token")
+	}
+	token := PersonalTokenPrefix + secret
+
+	pat := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    token[:personalTokenPrefixLength],
+		TokenHash: hashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+
+	if err := s.PersonalTokens.CreateToken(pat); err != nil {
+		return nil, "", internal("cannot create token")
+	}
+
+	return pat, token, nil
+}
+
+// ListPersonalTokens returns the tokens of the user that still work
+func (s *AuthService) ListPersonalTokens(userID int) ([]models.PersonalAccessToken, error) {
+	tokens, err := s.PersonalTokens.GetTokens(userID)
+	if err != nil {
+		return nil, internal("cannot get tokens")
+	}
+
+	return tokens, nil
+}
+
+// RevokePersonalToken stops the token from working at once
+func (s *AuthService) RevokePersonalToken(userID int, id int) error {
+	found, err := s.PersonalTokens.RevokeToken(userID, id)
+	if err != nil {
+		return internal("cannot revoke token")
+	}
+	if !found {
+		return notFound("token not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,430 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"image/png"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpIssuer = "TODO API"
+	totpPeriod = 30
+	// a code of the previous or the next step is accepted too, clocks drift
+	totpSkew = 1
+
+	challengeTTL         = 5 * time.Minute
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+
+	requireAdminTwoFactorSetting = "require_admin_2fa"
+)
+
+// Challenge is handed out by Login instead of tokens when a second factor
+// is needed. EnrollmentRequired means the user has none yet but must, the
+// challenge lets them enrol before the first code.
+type Challenge struct {
+	Token              string
+	EnrollmentRequired bool
+	ExpiresAt          time.Time
+}
+
+// TOTPEnrollment is a new secret waiting for its first code. URI is the
+// otpauth:// URI authenticator apps import, QRCode the same as a PNG data URI.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+	QRCode string `json:"qr_code"`
+}
+
+type TwoFactorStatus struct {
+	Enabled bool `json:"enabled"`
+	// Required is true when the user may not turn 2FA off
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// TwoFactorStatus tells whether the user has 2FA and how many recovery codes are left
+func (s *AuthService) TwoFactorStatus(userID int) (*TwoFactorStatus, error) {
+	user, err := s.Users.GetUserByID(userID)
+	if err == sql.ErrNoRows {
+		return nil, notFound("user not found")
+	} else if err != nil {
+		return nil, internal("server error")
+	}
+
+	required, err := s.twoFactorRequired(user)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TwoFactorStatus{Enabled: user.TwoFactor, Required: required}
+	if user.TwoFactor {
+		if status.RecoveryCodesLeft, err = s.TwoFactor.CountRecoveryCodes(userID); err != nil {
+			return nil, internal("cannot count recovery codes")
+		}
+	}
+
+	return status, nil
+}
+
+// EnrollTOTP generates a secret for the user. 2FA is enabled only once
+// ConfirmTOTP gets a code of it, enrolling again replaces the secret.
+func (s *AuthService) EnrollTOTP(userID int) (*TOTPEnrollment, error) {
+	user, err := s.Users.GetUserByID(userID)
+	if err == sql.ErrNoRows {
+		return nil, notFound("user not found")
+	} else if err != nil {
+		return nil, internal("server error")
+	}
+
+	if user.TwoFactor {
+		return nil, conflict("two-factor authentication is already enabled")
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: user.Email,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, internal("cannot generate secret")
+	}
+
+	image, err := key.Image(256, 256)
+	if err != nil {
+		return nil, internal("cannot generate QR code")
+	}
+
+	var qr bytes.Buffer
+	if err := png.Encode(&qr, image); err != nil {
+		return nil, internal("cannot generate QR code")
+	}
+
+	saved, err := s.TwoFactor.SetTOTPSecret(userID, key.Secret())
+	if err != nil {
+		return nil, internal("cannot save secret")
+	}
+	if !saved {
+		return nil, conflict("two-factor authentication is already enabled")
+	}
+
+	return &TOTPEnrollment{
+		Secret: key.Secret(),
+		URI:    key.URL(),
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr.Bytes()),
+	}, nil
+}
+
+// ConfirmTOTP enables 2FA with the first code of the enrolled secret and
+// returns the recovery codes. They are stored hashed and shown only now.
+func (s *AuthService) ConfirmTOTP(userID int, code string) ([]string, error) {
+	secret, enabled, err := s.TwoFactor.GetTOTP(userID)
+	if err == sql.ErrNoRows {
+		return nil, notFound("user not found")
+	} else if err != nil {
+		return nil, internal("server error")
+	}
+
+	if enabled {
+		return nil, conflict("two-factor authentication is already enabled")
+	}
+	if secret == "" {
+		return nil, invalid("enroll first")
+	}
+
+	step, ok := validateTOTP(secret, code)
+	if !ok {
+		return nil, invalid("wrong code")
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, internal("cannot generate recovery codes")
+	}
+
+	confirmed, err := s.TwoFactor.EnableTOTP(userID, step, hashes)
+	if err != nil {
+		return nil, internal("cannot enable two-factor authentication")
+	}
+	if !confirmed {
+		return nil, conflict("two-factor authentication is already enabled")
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns 2FA off after checking a code or a recovery code.
+// Admins cannot while 2FA is required for them.
+func (s *AuthService) DisableTOTP(userID int, code string) error {
+	user, err := s.Users.GetUserByID(userID)
+	if err == sql.ErrNoRows {
+		return notFound("user not found")
+	} else if err != nil {
+		return internal("server error")
+	}
+
+	if !user.TwoFactor {
+		return invalid("two-factor authentication is not enabled")
+	}
+
+	required, err := s.twoFactorRequired(user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return forbidden("two-factor authentication is required for admins")
+	}
+
+	if err := s.checkSecondFactor(userID, code, forbidden("wrong code")); err != nil {
+		return err
+	}
+
+	if err := s.TwoFactor.DisableTOTP(userID); err != nil {
+		return internal("cannot disable two-factor authentication")
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user, the old ones stop working
+func (s *AuthService) RegenerateRecoveryCodes(userID int, code string) ([]string, error) {
+	_, enabled, err := s.TwoFactor.GetTOTP(userID)
+	if err == sql.ErrNoRows {
+		return nil, notFound("user not found")
+	} else if err != nil {
+		return nil, internal("server error")
+	}
+
+	if !enabled {
+		return nil, invalid("two-factor authentication is not enabled")
+	}
+
+	if err := s.checkSecondFactor(userID, code, forbidden("wrong code")); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, internal("cannot generate recovery codes")
+	}
+
+	if err := s.TwoFactor.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, internal("cannot save recovery codes")
+	}
+
+	return codes, nil
+}
+
+// EnrollChallenge starts the enrolment of a user who must have 2FA to sign
+// in but has none yet. The code of the secret then goes to Verify2FA.
+func (s *AuthService) EnrollChallenge(challengeToken string) (*TOTPEnrollment, error) {
+	challenge, err := s.Challenges.GetChallenge(hashToken(challengeToken), maxChallengeAttempts)
+	if err == sql.ErrNoRows {
+		return nil, unauthenticated("invalid or expired challenge")
+	} else if err != nil {
+		return nil, internal("server error")
+	}
+
+	return s.EnrollTOTP(challenge.UserID)
+}
+
+// Verify2FA finishes a sign in started by Login. The code is one of the
+// authenticator app or a recovery code. A user enrolling during sign in
+// confirms the secret with it and gets the recovery codes as well.
+func (s *AuthService) Verify2FA(challengeToken string, code string) (*Tokens, []string, error) {
+	tokenHash := hashToken(challengeToken)
+
+	challenge, err := s.Challenges.AttemptChallenge(tokenHash, maxChallengeAttempts)
+	if err == sql.ErrNoRows {
+		return nil, nil, unauthenticated("invalid or expired challenge")
+	} else if err != nil {
+		return nil, nil, internal("server error")
+	}
+
+	user, err := s.Users.GetUserByID(challenge.UserID)
+	if err == sql.ErrNoRows {
+		return nil, nil, unauthenticated("invalid or expired challenge")
+	} else if err != nil {
+		return nil, nil, internal("server error")
+	}
+
+	var recoveryCodes []string
+	if user.TwoFactor {
+		if err := s.checkSecondFactor(user.ID, code, unauthenticated("wrong code")); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if recoveryCodes, err = s.ConfirmTOTP(user.ID, code); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// the challenge is spent, a second request with it must fail
+	if err := s.Challenges.DeleteChallenge(tokenHash); err != nil {
+		return nil, nil, internal("server error")
+	}
+
+	tokens, err := s.startSession(user, Device{UserAgent: challenge.UserAgent, IP: challenge.IP})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tokens, recoveryCodes, nil
+}
+
+// AdminTwoFactorRequired tells whether admins must sign in with 2FA
+func (s *AuthService) AdminTwoFactorRequired() (bool, error) {
+	value, err := s.Settings.GetSetting(requireAdminTwoFactorSetting)
+	if err != nil {
+		return false, internal("cannot get setting")
+	}
+
+	return value == "true", nil
+}
+
+// SetAdminTwoFactorRequired makes 2FA mandatory for admins or optional
+// again. Admins without it are asked to enrol on their next sign in, their
+// sessions end at the next refresh. The admin turning it on must have 2FA
+// already, so nobody locks themselves out by accident.
+func (s *AuthService) SetAdminTwoFactorRequired(adminID int, required bool) error {
+	if required {
+		admin, err := s.Users.GetUserByID(adminID)
+		if err != nil {
+			return internal("server error")
+		}
+		if !admin.TwoFactor {
+			return forbidden("enable two-factor authentication for your own account first")
+		}
+	}
+
+	if err := s.Settings.SetSetting(requireAdminTwoFactorSetting, strconv.FormatBool(required)); err != nil {
+		return internal("cannot save setting")
+	}
+
+	return nil
+}
+
+// twoFactorRequired tells whether the user may only sign in with 2FA
+func (s *AuthService) twoFactorRequired(user *models.User) (bool, error) {
+	if user.Role != "admin" {
+		return false, nil
+	}
+
+	return s.AdminTwoFactorRequired()
+}
+
+// challenge stores a pending sign in of the user on the device
+func (s *AuthService) challenge(user *models.User, device Device) (*Challenge, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, internal("cannot generate challenge")
+	}
+
+	challenge := &models.LoginChallenge{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		UserAgent: device.UserAgent,
+		IP:        device.IP,
+		ExpiresAt: time.Now().Add(challengeTTL),
+	}
+
+	if err := s.Challenges.CreateChallenge(challenge); err != nil {
+		return nil, internal("cannot create challenge")
+	}
+
+	return &Challenge{Token: token, EnrollmentRequired: !user.TwoFactor, ExpiresAt: challenge.ExpiresAt}, nil
+}
+
+// checkSecondFactor accepts a code of the authenticator app once, or an
+// unused recovery code. wrong is returned when neither matches.
+func (s *AuthService) checkSecondFactor(userID int, code string, wrong error) error {
+	secret, enabled, err := s.TwoFactor.GetTOTP(userID)
+	if err != nil {
+		return internal("server error")
+	}
+	if !enabled {
+		return wrong
+	}
+
+	var accepted bool
+	if step, ok := validateTOTP(secret, code); ok {
+		accepted, err = s.TwoFactor.UseTOTPStep(userID, step)
+	} else {
+		accepted, err = s.TwoFactor.UseRecoveryCode(userID, hashToken(normalizeRecoveryCode(code)))
+	}
+	if err != nil {
+		return internal("server error")
+	}
+	if !accepted {
+		return wrong
+	}
+
+	return nil
+}
+
+// validateTOTP checks the code against the steps around now and returns
+// the step it belongs to, a step must not be accepted twice
+func validateTOTP(secret string, code string) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != 6 {
+		return 0, false
+	}
+
+	now := time.Now()
+	for skew := -totpSkew; skew <= totpSkew; skew++ {
+		at := now.Add(time.Duration(skew*totpPeriod) * time.Second)
+
+		expected, err := totp.GenerateCodeCustom(secret, at, totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return at.Unix() / totpPeriod, true
+		}
+	}
+
+	return 0, false
+}
+
+// newRecoveryCodes generates codes like 3f9a1-c07b2 and their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+
+	buf := make([]byte, 5)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+
+		code := hex.EncodeToString(buf)
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode drops the dash and spaces users may type or leave out
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,156 @@
+// Package sessions keeps revoked sessions in memory, so that every request
+// can be checked against them without a query. Revocations are announced
+// with NOTIFY, every server instance learns about them right away.
+package sessions
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/repository"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/helpers"
+	"github.com/lib/pq"
+)
+
+type Cache struct {
+	Repo  *repository.SessionRepository
+	DBURL string
+	// FlushInterval is how often the last use of sessions is written to the database
+	FlushInterval time.Duration
+
+	mu sync.RWMutex
+	// revoked maps a session to the time its last access token expires
+	revoked map[string]time.Time
+	seen    map[string]time.Time
+}
+
+func NewCache(repo *repository.SessionRepository, dbURL string) *Cache {
+	return &Cache{
+		Repo:          repo,
+		DBURL:         dbURL,
+		FlushInterval: time.Minute,
+		revoked:       make(map[string]time.Time),
+		seen:          make(map[string]time.Time),
+	}
+}
+
+// IsRevoked tells whether access tokens of the session must be rejected
+func (c *Cache) IsRevoked(sessionID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, revoked := c.revoked[sessionID]
+	return revoked
+}
+
+// Revoke rejects the session on this server without waiting for the notification
+func (c *Cache) Revoke(sessionIDs ...string) {
+	until := time.Now().Add(helpers.AccessTokenTTL)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, id := range sessionIDs {
+		c.revoked[id] = until
+		delete(c.seen, id)
+	}
+}
+
+// Seen records a request of the session, the time is saved in batches
+func (c *Cache) Seen(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen[sessionID] = time.Now()
+}
+
+// Load reads the revoked sessions from the database, call it before serving requests
+func (c *Cache) Load() error {
+	ids, err := c.Repo.GetRevokedSessionIDs(helpers.AccessTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	c.Revoke(ids...)
+	return nil
+}
+
+// Run listens for revocations until ctx is cancelled
+func (c *Cache) Run(ctx context.Context) {
+	listener := pq.NewListener(c.DBURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Print("session listener:", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(repository.SessionRevocationsChannel); err != nil {
+		log.Print("cannot listen for session revocations:", err)
+		return
+	}
+
+	flush := time.NewTicker(c.FlushInterval)
+	defer flush.Stop()
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.flush()
+			return
+		case n := <-listener.Notify:
+			// nil after the connection was re-established, revocations may have been missed
+			if n == nil {
+				if err := c.Load(); err != nil {
+					log.Print("cannot reload revoked sessions:", err)
+				}
+				continue
+			}
+			c.Revoke(n.Extra)
+		case <-flush.C:
+			c.flush()
+			c.prune()
+		case <-ping.C:
+			go listener.Ping()
+		}
+	}
+}
+
+// flush writes the last use of the sessions seen since the previous flush
+func (c *Cache) flush() {
+	c.mu.Lock()
+	seen := c.seen
+	c.seen = make(map[string]time.Time)
+	c.mu.Unlock()
+
+	if len(seen) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(seen))
+	lastUsed := make([]int64, 0, len(seen))
+	for id, at := range seen {
+		ids = append(ids, id)
+		lastUsed = append(lastUsed, at.Unix())
+	}
+
+	c.Repo.TouchSessions(ids, lastUsed)
+}
+
+// prune forgets revoked sessions whose access tokens have all expired
+func (c *Cache) prune() {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, until := range c.revoked {
+		if now.After(until) {
+			delete(c.revoked, id)
+		}
+	}
+}
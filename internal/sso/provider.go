@@ -0,0 +1,150 @@
+// Package sso signs users in with external OpenID Connect providers. The
+// authorization code flow is used with PKCE, the endpoints and keys of a
+// provider come from its discovery document.
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ProviderConfig describes a provider, Name is used in the URLs of the flow
+type ProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// Identity is the account the provider vouched for in the ID token
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string
+}
+
+// Provider talks to one identity provider. Discovery happens on first use
+// and is retried on the next one when the provider was unreachable, so a
+// provider being down does not keep the server from starting.
+type Provider struct {
+	ProviderConfig
+	// RedirectURL is the callback of the provider on this server
+	RedirectURL string
+	// HTTP is used for discovery, the token exchange and the provider's keys
+	HTTP *http.Client
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewProvider(config ProviderConfig, redirectURL string) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	return &Provider{
+		ProviderConfig: config,
+		RedirectURL:    redirectURL,
+		HTTP:           &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AuthCodeURL is where the user is sent to sign in. The provider gets the
+// S256 hash of verifier, the code is only exchanged with verifier itself.
+func (p *Provider) AuthCodeURL(ctx context.Context, state string, nonce string, verifier string) (string, error) {
+	config, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange trades the code of the callback for tokens and checks the ID
+// token: signature, issuer, audience, expiry and that it carries nonce
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string, nonce string) (*Identity, error) {
+	config, idVerifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := config.Exchange(p.context(ctx), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("cannot exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := idVerifier.Verify(p.context(ctx), rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce does not match")
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     *bool  `json:"email_verified"`
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("cannot read id_token claims: %w", err)
+	}
+
+	return &Identity{
+		Subject: idToken.Subject,
+		Email:   claims.Email,
+		// a provider that does not say is not trusted with the address
+		EmailVerified: claims.EmailVerified != nil && *claims.EmailVerified,
+		Name:          claims.Name,
+		Username:      claims.PreferredUsername,
+	}, nil
+}
+
+// discover reads the discovery document once it is needed
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(p.context(ctx), p.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot discover provider %s: %w", p.Name, err)
+	}
+
+	p.oauth = &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.RedirectURL,
+		Scopes:       p.Scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.ClientID})
+
+	return p.oauth, p.verifier, nil
+}
+
+// context makes the oidc and oauth2 packages use the provider's HTTP client
+func (p *Provider) context(ctx context.Context) context.Context {
+	return context.WithValue(oidc.ClientContext(ctx, p.HTTP), oauth2.HTTPClient, p.HTTP)
+}
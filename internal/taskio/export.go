@@ -0,0 +1,186 @@
+// Package taskio converts tasks to and from the CSV, JSON and NDJSON files
+// used by the export and import endpoints.
+package taskio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// Columns of an exported CSV file, named like the JSON fields of a task
+var Columns = []string{"id", "title", "description", "status", "priority", "project", "recurrence", "tags", "due_at", "milestone_id", "parent_id", "snoozed_until", "pinned", "created_at", "updated_at"}
+
+// Writer writes tasks one at a time, Close finishes the document
+type Writer interface {
+	Write(task *models.Task) error
+	Close() error
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/json; charset=utf-8"
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(task *models.Task) error {
+	if !c.headerWritten {
+		if err := c.w.Write(Columns); err != nil {
+			return err
+		}
+		c.headerWritten = true
+	}
+
+	return c.w.Write([]string{
+		strconv.Itoa(task.ID),
+		escapeFormula(task.Title),
+		escapeFormula(task.Description),
+		task.Status,
+		task.Priority,
+		escapeFormula(task.Project),
+		escapeFormula(task.Recurrence),
+		escapeFormula(strings.Join(task.Tags, ",")),
+		formatTime(task.DueAt),
+		formatInt(task.MilestoneID),
+		formatInt(task.ParentID),
+		formatTime(task.SnoozedUntil),
+		strconv.FormatBool(task.Pinned),
+		task.Created_at.Format(time.RFC3339),
+		task.Updated_at.Format(time.RFC3339),
+	})
+}
+
+func (c *csvWriter) Close() error {
+	// an empty export still gets its header
+	if !c.headerWritten {
+		if err := c.w.Write(Columns); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (j *jsonWriter) Write(task *models.Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	prefix := ",\n"
+	if j.count == 0 {
+		prefix = "[\n"
+	}
+	j.count++
+
+	if _, err := io.WriteString(j.w, prefix); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	closing := "\n]\n"
+	if j.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(j.w, closing)
+	return err
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(task *models.Task) error {
+	return n.enc.Encode(task)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+// escapeFormula keeps spreadsheets from running a text cell as a formula by
+// prefixing it with a quote, which they hide. Text that starts with a quote
+// in front of such a cell gets another one, so the import can take exactly
+// one off again.
+func escapeFormula(value string) string {
+	if isFormula(value) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeFormula undoes escapeFormula
+func unescapeFormula(value string) string {
+	if strings.HasPrefix(value, "'") && isFormula(value[1:]) {
+		return value[1:]
+	}
+	return value
+}
+
+func isFormula(value string) bool {
+	if value == "" {
+		return false
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return true
+	case '\'':
+		return isFormula(value[1:])
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package taskio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// Row is one imported record. ID and ParentID are the identifiers used in the
+// file, they only link subtasks to their parents within the same file.
+type Row struct {
+	Line     int
+	Task     models.Task
+	ID       string
+	ParentID string
+	Errors   []string
+}
+
+// columns that are exported but not imported, the server sets them
+var ignored = map[string]bool{"user_id": true, "client_id": true, "created_at": true, "updated_at": true}
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ReadRows parses the file and calls fn for every record. Mapping renames
+// source columns or keys to task fields, unmapped names are used as they are
+// and unknown ones are ignored. Problems with single values end up in
+// Row.Errors, a malformed file stops the import with an error.
+func ReadRows(format string, r io.Reader, mapping map[string]string, fn func(row Row) error) error {
+	switch format {
+	case FormatCSV:
+		return readCSV(r, mapping, fn)
+	case FormatJSON:
+		return readJSON(r, mapping, true, fn)
+	case FormatNDJSON:
+		return readJSON(r, mapping, false, fn)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func fieldName(name string, mapping map[string]string) string {
+	if mapped, ok := mapping[name]; ok {
+		return mapped
+	}
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func readCSV(r io.Reader, mapping map[string]string, fn func(row Row) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	fields := make([]string, len(header))
+	for i, name := range header {
+		// a BOM is common in files saved by spreadsheets
+		fields[i] = fieldName(strings.TrimPrefix(name, "\ufeff"), mapping)
+	}
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		row := Row{Line: line}
+		for i, value := range record {
+			if i < len(fields) {
+				setField(&row, fields[i], unescapeFormula(value))
+			}
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+func readJSON(r io.Reader, mapping map[string]string, array bool, fn func(row Row) error) error {
+	dec := json.NewDecoder(r)
+
+	if array {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return errors.New("expected a JSON array of tasks")
+		}
+	}
+
+	for line := 1; dec.More(); line++ {
+		var object map[string]json.RawMessage
+		if err := dec.Decode(&object); err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+
+		row := Row{Line: line}
+		for name, raw := range object {
+			setJSONField(&row, fieldName(name, mapping), raw)
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setJSONField turns a JSON value into the text form CSV files use
+func setJSONField(row *Row, field string, raw json.RawMessage) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		row.Errors = append(row.Errors, field+": invalid value")
+		return
+	}
+
+	switch v := value.(type) {
+	case nil:
+	case string:
+		setField(row, field, v)
+	case float64:
+		setField(row, field, strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		setField(row, field, strconv.FormatBool(v))
+	case []interface{}:
+		if field != "tags" {
+			row.Errors = append(row.Errors, field+": unexpected list")
+			return
+		}
+		for _, item := range v {
+			tag, ok := item.(string)
+			if !ok {
+				row.Errors = append(row.Errors, "tags: must be strings")
+				return
+			}
+			row.Task.Tags = append(row.Task.Tags, tag)
+		}
+	default:
+		row.Errors = append(row.Errors, field+": unexpected object")
+	}
+}
+
+func setField(row *Row, field string, value string) {
+	value = strings.TrimSpace(value)
+	task := &row.Task
+
+	switch field {
+	case "title":
+		task.Title = value
+	case "description":
+		task.Description = value
+	case "status":
+		task.Status = value
+	case "priority":
+		task.Priority = value
+	case "project":
+		task.Project = value
+	case "recurrence":
+		task.Recurrence = value
+	case "tags":
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				task.Tags = append(task.Tags, tag)
+			}
+		}
+	case "due_at", "snoozed_until":
+		if value == "" {
+			return
+		}
+		t, err := parseTime(value)
+		if err != nil {
+			row.Errors = append(row.Errors, field+": invalid date")
+			return
+		}
+		if field == "due_at" {
+			task.DueAt = &t
+		} else {
+			task.SnoozedUntil = &t
+		}
+	case "milestone_id":
+		if value == "" {
+			return
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			row.Errors = append(row.Errors, "milestone_id: invalid number")
+			return
+		}
+		task.MilestoneID = &id
+	case "pinned":
+		if value == "" {
+			return
+		}
+		pinned, err := strconv.ParseBool(value)
+		if err != nil {
+			row.Errors = append(row.Errors, "pinned: invalid boolean")
+			return
+		}
+		task.Pinned = pinned
+	case "id":
+		row.ID = value
+	case "parent_id":
+		row.ParentID = value
+	}
+}
+
+// parseTime reads a date, times without an offset are taken as UTC and the
+// others converted to it, the way they are stored
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, errors.New("unknown date format")
+}
@@ -0,0 +1,261 @@
+package taskio
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+func sampleTasks() []models.Task {
+	due := time.Date(2026, 5, 1, 9, 30, 0, 0, time.UTC)
+	parent := 1
+	return []models.Task{
+		{
+			ID:          1,
+			Title:       "Pay rent",
+			Description: "Transfer to the landlord, see the contract",
+			Status:      "pending",
+			Priority:    "high",
+			Project:     "flat",
+			Recurrence:  "FREQ=MONTHLY",
+			Tags:        []string{"home", "money"},
+			DueAt:       &due,
+			Pinned:      true,
+		},
+		{
+			ID:          2,
+			Title:       `=HYPERLINK("http://evil.example","click")`,
+			Description: "-1+1 looks like a formula too",
+			Status:      "completed",
+			Project:     "@work",
+			Tags:        []string{"+plus"},
+			ParentID:    &parent,
+		},
+	}
+}
+
+func export(t *testing.T, format string, tasks []models.Task) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatalf("NewWriter(%q) error = %v", format, err)
+	}
+	for i := range tasks {
+		if err := w.Write(&tasks[i]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.String()
+}
+
+func readAll(t *testing.T, format string, data string, mapping map[string]string) []Row {
+	t.Helper()
+
+	var rows []Row
+	err := ReadRows(format, strings.NewReader(data), mapping, func(row Row) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadRows(%q) error = %v", format, err)
+	}
+	return rows
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatJSON, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			tasks := sampleTasks()
+			rows := readAll(t, format, export(t, format, tasks), nil)
+			if len(rows) != len(tasks) {
+				t.Fatalf("read %d rows, want %d", len(rows), len(tasks))
+			}
+
+			for i, row := range rows {
+				want := tasks[i]
+				got := row.Task
+				if len(row.Errors) != 0 {
+					t.Errorf("row %d errors = %v", i+1, row.Errors)
+				}
+				if got.Title != want.Title || got.Description != want.Description || got.Project != want.Project || got.Recurrence != want.Recurrence {
+					t.Errorf("row %d text = %q %q %q %q, want %q %q %q %q", i+1, got.Title, got.Description, got.Project, got.Recurrence, want.Title, want.Description, want.Project, want.Recurrence)
+				}
+				if got.Status != want.Status || got.Priority != want.Priority || got.Pinned != want.Pinned {
+					t.Errorf("row %d = %s/%s/%v, want %s/%s/%v", i+1, got.Status, got.Priority, got.Pinned, want.Status, want.Priority, want.Pinned)
+				}
+				if !reflect.DeepEqual(got.Tags, want.Tags) {
+					t.Errorf("row %d tags = %v, want %v", i+1, got.Tags, want.Tags)
+				}
+				if (got.DueAt == nil) != (want.DueAt == nil) || (got.DueAt != nil && !got.DueAt.Equal(*want.DueAt)) {
+					t.Errorf("row %d due_at = %v, want %v", i+1, got.DueAt, want.DueAt)
+				}
+			}
+
+			if rows[0].ID != "1" || rows[1].ParentID != "1" {
+				t.Errorf("ids = %q parent %q, want 1 and 1", rows[0].ID, rows[1].ParentID)
+			}
+		})
+	}
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	records, err := csv.NewReader(strings.NewReader(export(t, FormatCSV, sampleTasks()))).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	if !reflect.DeepEqual(records[0], Columns) {
+		t.Fatalf("header = %v, want %v", records[0], Columns)
+	}
+
+	row := records[2]
+	for i, want := range map[int]string{
+		1: `'=HYPERLINK("http://evil.example","click")`,
+		2: "'-1+1 looks like a formula too",
+		5: "'@work",
+		7: "'+plus",
+	} {
+		if row[i] != want {
+			t.Errorf("%s = %q, want %q", Columns[i], row[i], want)
+		}
+	}
+	if records[1][1] != "Pay rent" {
+		t.Errorf("plain title = %q, want it unchanged", records[1][1])
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"a=b", "a=b"},
+		{"=1+1", "'=1+1"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"'quoted", "'quoted"},
+		{"'=1", "''=1"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeFormula(tt.value); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+		if got := unescapeFormula(escapeFormula(tt.value)); got != tt.value {
+			t.Errorf("unescapeFormula(escapeFormula(%q)) = %q", tt.value, got)
+		}
+	}
+}
+
+func TestEmptyExport(t *testing.T) {
+	tests := map[string]string{
+		FormatCSV:    strings.Join(Columns, ",") + "\n",
+		FormatJSON:   "[]\n",
+		FormatNDJSON: "",
+	}
+
+	for format, want := range tests {
+		if got := export(t, format, nil); got != want {
+			t.Errorf("empty %s export = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestNDJSONOneTaskPerLine(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(export(t, FormatNDJSON, sampleTasks()), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+}
+
+func TestReadRowsMapping(t *testing.T) {
+	data := "\ufeffName,Due Date,Labels,Notes,Unknown\n" +
+		"Buy milk,2026-05-01 18:00,\"shop, home\",two liters of milk,x\n"
+	mapping := map[string]string{"Name": "title", "Due Date": "due_at", "Labels": "tags", "Notes": "description"}
+
+	rows := readAll(t, FormatCSV, data, mapping)
+	if len(rows) != 1 {
+		t.Fatalf("read %d rows, want 1", len(rows))
+	}
+
+	task := rows[0].Task
+	due := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+	if task.Title != "Buy milk" || task.Description != "two liters of milk" {
+		t.Errorf("task = %q %q", task.Title, task.Description)
+	}
+	if task.DueAt == nil || !task.DueAt.Equal(due) {
+		t.Errorf("due_at = %v, want %v", task.DueAt, due)
+	}
+	if !reflect.DeepEqual(task.Tags, []string{"shop", "home"}) {
+		t.Errorf("tags = %v", task.Tags)
+	}
+
+	jsonRows := readAll(t, FormatNDJSON, `{"Name":"Buy milk","Labels":["shop"]}`+"\n", mapping)
+	if len(jsonRows) != 1 || jsonRows[0].Task.Title != "Buy milk" || !reflect.DeepEqual(jsonRows[0].Task.Tags, []string{"shop"}) {
+		t.Errorf("json rows = %+v", jsonRows)
+	}
+}
+
+func TestReadRowsConvertsDatesToUTC(t *testing.T) {
+	rows := readAll(t, FormatJSON, `[{"title":"Call","due_at":"2026-05-01T09:00:00+03:00","snoozed_until":"2026-04-30T20:00:00-04:00"}]`, nil)
+
+	task := rows[0].Task
+	if task.DueAt == nil || task.DueAt.Location() != time.UTC || task.DueAt.Hour() != 6 {
+		t.Errorf("due_at = %v, want 06:00 UTC", task.DueAt)
+	}
+	if task.SnoozedUntil == nil || task.SnoozedUntil.Location() != time.UTC || task.SnoozedUntil.Day() != 1 || task.SnoozedUntil.Hour() != 0 {
+		t.Errorf("snoozed_until = %v, want midnight UTC", task.SnoozedUntil)
+	}
+}
+
+func TestReadRowsFieldErrors(t *testing.T) {
+	data := "title,due_at,pinned,milestone_id\n" +
+		"Bad row,next week,maybe,one\n"
+
+	rows := readAll(t, FormatCSV, data, nil)
+	want := []string{"due_at: invalid date", "pinned: invalid boolean", "milestone_id: invalid number"}
+	if len(rows) != 1 || !reflect.DeepEqual(rows[0].Errors, want) {
+		t.Errorf("errors = %v, want %v", rows[0].Errors, want)
+	}
+
+	jsonRows := readAll(t, FormatJSON, `[{"title":"x","tags":[1],"project":{"a":1},"priority":["high"]}]`, nil)
+	if len(jsonRows[0].Errors) != 3 {
+		t.Errorf("json errors = %v, want 3", jsonRows[0].Errors)
+	}
+}
+
+func TestReadRowsMalformed(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+	}{
+		{"unterminated quote", FormatCSV, "title\n\"never closed\n"},
+		{"json object", FormatJSON, `{"title":"x"}`},
+		{"truncated json", FormatJSON, `[{"title":"x"}`},
+		{"broken ndjson", FormatNDJSON, "{\"title\":\"x\"}\n{oops}\n"},
+		{"unknown format", "xml", "<tasks/>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ReadRows(tt.format, strings.NewReader(tt.data), nil, func(Row) error { return nil })
+			if err == nil {
+				t.Error("ReadRows() accepted a malformed file")
+			}
+		})
+	}
+}
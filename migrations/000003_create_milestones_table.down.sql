@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS tasks_milestone_idx;
+ALTER TABLE tasks DROP COLUMN IF EXISTS milestoneID;
+ALTER TABLE tasks DROP COLUMN IF EXISTS dueAt;
+DROP TABLE IF EXISTS milestones;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS milestones (
+  id SERIAL PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id),
+  title VARCHAR(255) NOT NULL,
+  description TEXT,
+  dueDate TIMESTAMP,
+  state VARCHAR(255) NOT NULL DEFAULT 'open',
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS dueAt TIMESTAMP;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS milestoneID INTEGER REFERENCES milestones(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS tasks_milestone_idx ON tasks (milestoneID);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS template_items;
+DROP TABLE IF EXISTS templates;
+DROP INDEX IF EXISTS tasks_parent_idx;
+ALTER TABLE tasks DROP COLUMN IF EXISTS tags;
+ALTER TABLE tasks DROP COLUMN IF EXISTS parentID;
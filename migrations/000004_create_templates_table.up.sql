@@ -0,0 +1,27 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parentID INTEGER REFERENCES tasks(id) ON DELETE CASCADE;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS tasks_parent_idx ON tasks (parentID);
+
+CREATE TABLE IF NOT EXISTS templates (
+  id SERIAL PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id),
+  name VARCHAR(255) NOT NULL,
+  description TEXT,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS template_items (
+  id SERIAL PRIMARY KEY,
+  templateID INTEGER NOT NULL REFERENCES templates(id) ON DELETE CASCADE,
+  parentID INTEGER REFERENCES template_items(id) ON DELETE CASCADE,
+  position INTEGER NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  description TEXT,
+  status VARCHAR(255) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'in_progress', 'completed')),
+  tags TEXT[] NOT NULL DEFAULT '{}',
+  dueOffsetMinutes INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS template_items_template_idx ON template_items (templateID);
@@ -0,0 +1,5 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS recurrence;
+ALTER TABLE tasks DROP COLUMN IF EXISTS project;
+ALTER TABLE tasks DROP COLUMN IF EXISTS priority;
+
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
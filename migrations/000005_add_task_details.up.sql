@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS priority VARCHAR(32) NOT NULL DEFAULT 'medium';
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS project VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS recurrence VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS reminders (
+  id SERIAL PRIMARY KEY,
+  taskID INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  userID INTEGER NOT NULL REFERENCES users(id),
+  remindAt TIMESTAMP,
+  offsetMinutes INTEGER,
+  channel VARCHAR(32) NOT NULL,
+  target TEXT NOT NULL DEFAULT '',
+  status VARCHAR(32) NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  nextAttemptAt TIMESTAMP,
+  lastError TEXT NOT NULL DEFAULT '',
+  sentAt TIMESTAMP,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  CHECK ((remindAt IS NULL) <> (offsetMinutes IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS reminders_pending_idx ON reminders (status) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS notifications (
+  id SERIAL PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id),
+  taskID INTEGER REFERENCES tasks(id) ON DELETE SET NULL,
+  reminderID INTEGER REFERENCES reminders(id) ON DELETE SET NULL,
+  title VARCHAR(255) NOT NULL,
+  body TEXT NOT NULL DEFAULT '',
+  read BOOLEAN NOT NULL DEFAULT FALSE,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS notifications_user_idx ON notifications (userID, createdAt DESC);
+-- a reminder delivered twice still adds one inbox entry
+CREATE UNIQUE INDEX IF NOT EXISTS notifications_reminder_idx ON notifications (reminderID);
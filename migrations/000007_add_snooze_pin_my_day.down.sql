@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS my_day;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS pinned;
+ALTER TABLE tasks DROP COLUMN IF EXISTS snoozedUntil;
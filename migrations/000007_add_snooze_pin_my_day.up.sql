@@ -0,0 +1,10 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS snoozedUntil TIMESTAMP;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS my_day (
+  userID INTEGER NOT NULL REFERENCES users(id),
+  taskID INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+  day DATE NOT NULL,
+  addedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (userID, day, taskID)
+);
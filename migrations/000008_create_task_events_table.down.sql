@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS tasks_record_event ON tasks;
+DROP FUNCTION IF EXISTS record_task_event();
+DROP FUNCTION IF EXISTS next_event_seq(INTEGER);
+DROP TABLE IF EXISTS task_events;
+ALTER TABLE users DROP COLUMN IF EXISTS eventSeq;
@@ -0,0 +1,54 @@
+-- Events get a sequence number per user. The global id of the log is handed
+-- out before commit, so ids of concurrent changes commit out of order, and the
+-- other users' events leave holes in it, neither of which a client resuming
+-- from the last id it saw can tell apart from a lost event.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS eventSeq BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS task_events (
+  id BIGSERIAL PRIMARY KEY,
+  userID INTEGER NOT NULL,
+  seq BIGINT NOT NULL,
+  taskID INTEGER NOT NULL,
+  type VARCHAR(32) NOT NULL,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS task_events_user_seq_idx ON task_events (userID, seq);
+
+-- Like the sync version, bumping the counter locks the user's row until the
+-- transaction ends, so the user's events commit in sequence order without holes.
+CREATE OR REPLACE FUNCTION next_event_seq(owner_id INTEGER) RETURNS BIGINT AS $$
+  UPDATE users SET eventSeq = eventSeq + 1 WHERE id = owner_id RETURNING eventSeq;
+$$ LANGUAGE sql;
+
+-- every change of a task is logged and announced to the listening server instances
+CREATE OR REPLACE FUNCTION record_task_event() RETURNS TRIGGER AS $$
+DECLARE
+  event_seq BIGINT;
+  owner_id INTEGER;
+  event_type VARCHAR(32);
+  task_id INTEGER;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    owner_id := OLD.userID;
+    task_id := OLD.id;
+    event_type := 'task.deleted';
+  ELSE
+    owner_id := NEW.userID;
+    task_id := NEW.id;
+    event_type := CASE TG_OP WHEN 'INSERT' THEN 'task.created' ELSE 'task.updated' END;
+  END IF;
+
+  event_seq := next_event_seq(owner_id);
+  INSERT INTO task_events (userID, seq, taskID, type) VALUES (owner_id, event_seq, task_id, event_type);
+
+  -- the payload is "<user id>:<seq>" so instances without subscribers of the user can skip it
+  PERFORM pg_notify('task_events', owner_id || ':' || event_seq);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tasks_record_event ON tasks;
+CREATE TRIGGER tasks_record_event
+  AFTER INSERT OR UPDATE OR DELETE ON tasks
+  FOR EACH ROW EXECUTE FUNCTION record_task_event();
@@ -0,0 +1,29 @@
+-- back to the trigger of the task events migration, which queues no deliveries
+CREATE OR REPLACE FUNCTION record_task_event() RETURNS TRIGGER AS $$
+DECLARE
+  event_seq BIGINT;
+  owner_id INTEGER;
+  event_type VARCHAR(32);
+  task_id INTEGER;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    owner_id := OLD.userID;
+    task_id := OLD.id;
+    event_type := 'task.deleted';
+  ELSE
+    owner_id := NEW.userID;
+    task_id := NEW.id;
+    event_type := CASE TG_OP WHEN 'INSERT' THEN 'task.created' ELSE 'task.updated' END;
+  END IF;
+
+  event_seq := next_event_seq(owner_id);
+  INSERT INTO task_events (userID, seq, taskID, type) VALUES (owner_id, event_seq, task_id, event_type);
+
+  -- the payload is "<user id>:<seq>" so instances without subscribers of the user can skip it
+  PERFORM pg_notify('task_events', owner_id || ':' || event_seq);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
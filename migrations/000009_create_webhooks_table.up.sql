@@ -0,0 +1,63 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+  id SERIAL PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id),
+  url TEXT NOT NULL,
+  events TEXT[] NOT NULL DEFAULT '{}',
+  secret VARCHAR(255) NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  failureCount INTEGER NOT NULL DEFAULT 0,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id BIGSERIAL PRIMARY KEY,
+  webhookID INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+  taskID INTEGER,
+  eventType VARCHAR(64) NOT NULL,
+  payload TEXT NOT NULL,
+  status VARCHAR(32) NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  nextAttemptAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  responseCode INTEGER,
+  lastError TEXT NOT NULL DEFAULT '',
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  deliveredAt TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_pending_idx ON webhook_deliveries (nextAttemptAt) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhookID, id DESC);
+
+-- Webhook deliveries are queued by the trigger that logs task events, in the
+-- transaction of the change itself, so no way of changing a task can skip
+-- them and a failed commit queues nothing. The payload is rendered when the
+-- delivery is first sent, taskID tells which task to render.
+CREATE OR REPLACE FUNCTION record_task_event() RETURNS TRIGGER AS $$
+DECLARE
+  event_seq BIGINT;
+  owner_id INTEGER;
+  event_type VARCHAR(32);
+  task_id INTEGER;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    owner_id := OLD.userID;
+    task_id := OLD.id;
+    event_type := 'task.deleted';
+  ELSE
+    owner_id := NEW.userID;
+    task_id := NEW.id;
+    event_type := CASE TG_OP WHEN 'INSERT' THEN 'task.created' ELSE 'task.updated' END;
+  END IF;
+
+  event_seq := next_event_seq(owner_id);
+  INSERT INTO task_events (userID, seq, taskID, type) VALUES (owner_id, event_seq, task_id, event_type);
+
+  INSERT INTO webhook_deliveries (webhookID, eventType, payload, taskID)
+  SELECT id, event_type, '', task_id FROM webhooks
+  WHERE userID = owner_id AND active AND (event_type = ANY(events) OR '*' = ANY(events));
+
+  -- the payload is "<user id>:<seq>" so instances without subscribers of the user can skip it
+  PERFORM pg_notify('task_events', owner_id || ':' || event_seq);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,13 @@
+DROP TRIGGER IF EXISTS tasks_track_delete ON tasks;
+DROP TRIGGER IF EXISTS tasks_track_sync ON tasks;
+DROP FUNCTION IF EXISTS track_task_sync();
+DROP FUNCTION IF EXISTS stamp_task_field(JSONB, JSONB, TEXT, BOOLEAN);
+DROP FUNCTION IF EXISTS task_field_clock();
+DROP FUNCTION IF EXISTS next_sync_version(INTEGER);
+DROP TABLE IF EXISTS task_tombstones;
+DROP INDEX IF EXISTS tasks_sync_idx;
+DROP INDEX IF EXISTS tasks_client_idx;
+ALTER TABLE tasks DROP COLUMN IF EXISTS fieldUpdatedAt;
+ALTER TABLE tasks DROP COLUMN IF EXISTS clientID;
+ALTER TABLE tasks DROP COLUMN IF EXISTS syncVersion;
+ALTER TABLE users DROP COLUMN IF EXISTS syncVersion;
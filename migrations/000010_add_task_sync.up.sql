@@ -0,0 +1,102 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS syncVersion BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS syncVersion BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS clientID VARCHAR(64);
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS fieldUpdatedAt JSONB NOT NULL DEFAULT '{}';
+
+CREATE UNIQUE INDEX IF NOT EXISTS tasks_client_idx ON tasks (userID, clientID);
+CREATE INDEX IF NOT EXISTS tasks_sync_idx ON tasks (userID, syncVersion);
+
+CREATE TABLE IF NOT EXISTS task_tombstones (
+  taskID INTEGER PRIMARY KEY,
+  userID INTEGER NOT NULL,
+  clientID VARCHAR(64),
+  syncVersion BIGINT NOT NULL,
+  deletedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS task_tombstones_sync_idx ON task_tombstones (userID, syncVersion);
+CREATE INDEX IF NOT EXISTS task_tombstones_client_idx ON task_tombstones (userID, clientID);
+
+-- existing tasks get distinct versions so the first sync can be paged
+WITH numbered AS (
+  SELECT id, ROW_NUMBER() OVER (PARTITION BY userID ORDER BY id) AS n FROM tasks
+)
+UPDATE tasks SET syncVersion = numbered.n FROM numbered WHERE tasks.id = numbered.id AND tasks.syncVersion = 0;
+
+UPDATE users SET syncVersion = COALESCE((SELECT MAX(syncVersion) FROM tasks WHERE tasks.userID = users.id), 0);
+
+-- The version is a per-user counter. Bumping it locks the user's row until the
+-- transaction ends, so the user's changes commit in version order and a reader
+-- never sees version N+1 while N is still in flight.
+CREATE OR REPLACE FUNCTION next_sync_version(owner_id INTEGER) RETURNS BIGINT AS $$
+  UPDATE users SET syncVersion = syncVersion + 1 WHERE id = owner_id RETURNING syncVersion;
+$$ LANGUAGE sql;
+
+-- Every field stamp comes from the database clock in one format, those of
+-- new tasks included. createdAt is no substitute, it is a timestamp in the
+-- session time zone rather than UTC.
+CREATE OR REPLACE FUNCTION task_field_clock() RETURNS JSONB AS $$
+  SELECT to_jsonb(to_char(NOW() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"'));
+$$ LANGUAGE sql STABLE;
+
+-- fields changed without an explicit time, e.g. by the REST API, are stamped with the server time
+CREATE OR REPLACE FUNCTION stamp_task_field(stamps JSONB, old_stamps JSONB, field TEXT, changed BOOLEAN) RETURNS JSONB AS $$
+BEGIN
+  IF changed AND stamps -> field IS NOT DISTINCT FROM old_stamps -> field THEN
+    RETURN jsonb_set(stamps, ARRAY[field], task_field_clock());
+  END IF;
+  RETURN stamps;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION track_task_sync() RETURNS TRIGGER AS $$
+DECLARE
+  stamps JSONB;
+BEGIN
+  IF TG_OP = 'DELETE' THEN
+    INSERT INTO task_tombstones (taskID, userID, clientID, syncVersion)
+    VALUES (OLD.id, OLD.userID, OLD.clientID, next_sync_version(OLD.userID))
+    ON CONFLICT (taskID) DO NOTHING;
+    RETURN NULL;
+  END IF;
+
+  IF TG_OP = 'INSERT' THEN
+    NEW.fieldUpdatedAt := jsonb_build_object(
+      'title', task_field_clock(), 'description', task_field_clock(), 'status', task_field_clock(),
+      'priority', task_field_clock(), 'project', task_field_clock(), 'recurrence', task_field_clock(),
+      'tags', task_field_clock(), 'due_at', task_field_clock(), 'milestone_id', task_field_clock(),
+      'snoozed_until', task_field_clock(), 'pinned', task_field_clock()
+    ) || NEW.fieldUpdatedAt;
+  END IF;
+
+  IF TG_OP = 'UPDATE' THEN
+    stamps := NEW.fieldUpdatedAt;
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'title', NEW.title IS DISTINCT FROM OLD.title);
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'description', NEW.description IS DISTINCT FROM OLD.description);
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'status', NEW.status IS DISTINCT FROM OLD.status);
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'priority', NEW.priority IS DISTINCT FROM OLD.priority);
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'project', NEW.project IS DISTINCT FROM OLD.project);
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'recurrence', NEW.recurrence IS DISTINCT FROM OLD.recurrence);
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'tags', NEW.tags IS DISTINCT FROM OLD.tags);
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'due_at', NEW.dueAt IS DISTINCT FROM OLD.dueAt);
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'milestone_id', NEW.milestoneID IS DISTINCT FROM OLD.milestoneID);
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'snoozed_until', NEW.snoozedUntil IS DISTINCT FROM OLD.snoozedUntil);
+    stamps := stamp_task_field(stamps, OLD.fieldUpdatedAt, 'pinned', NEW.pinned IS DISTINCT FROM OLD.pinned);
+    NEW.fieldUpdatedAt := stamps;
+  END IF;
+
+  NEW.syncVersion := next_sync_version(NEW.userID);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS tasks_track_sync ON tasks;
+CREATE TRIGGER tasks_track_sync
+  BEFORE INSERT OR UPDATE ON tasks
+  FOR EACH ROW EXECUTE FUNCTION track_task_sync();
+
+DROP TRIGGER IF EXISTS tasks_track_delete ON tasks;
+CREATE TRIGGER tasks_track_delete
+  AFTER DELETE ON tasks
+  FOR EACH ROW EXECUTE FUNCTION track_task_sync();
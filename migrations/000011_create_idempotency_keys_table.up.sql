@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  userID INTEGER NOT NULL REFERENCES users(id),
+  key VARCHAR(255) NOT NULL,
+  fingerprint VARCHAR(64) NOT NULL,
+  status VARCHAR(32) NOT NULL DEFAULT 'in_flight',
+  responseCode INTEGER,
+  contentType VARCHAR(255) NOT NULL DEFAULT '',
+  responseBody BYTEA,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expiresAt TIMESTAMP NOT NULL,
+  PRIMARY KEY (userID, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_idx ON idempotency_keys (expiresAt);
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS calendarTokenHash VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_calendar_token_idx ON users (calendarTokenHash);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+  id BIGSERIAL PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  familyID VARCHAR(64) NOT NULL,
+  tokenHash VARCHAR(64) NOT NULL UNIQUE,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expiresAt TIMESTAMP NOT NULL,
+  usedAt TIMESTAMP,
+  revokedAt TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (familyID);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_idx ON refresh_tokens (userID);
+CREATE INDEX IF NOT EXISTS refresh_tokens_expires_idx ON refresh_tokens (expiresAt);
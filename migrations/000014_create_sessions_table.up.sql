@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS sessions (
+  id VARCHAR(64) PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  userAgent TEXT NOT NULL DEFAULT '',
+  ip VARCHAR(64) NOT NULL DEFAULT '',
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  lastUsedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expiresAt TIMESTAMP NOT NULL,
+  revokedAt TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (userID);
+CREATE INDEX IF NOT EXISTS sessions_revoked_idx ON sessions (revokedAt) WHERE revokedAt IS NOT NULL;
+
+-- every refresh token family becomes a session
+INSERT INTO sessions (id, userID, createdAt, lastUsedAt, expiresAt, revokedAt)
+SELECT familyID, MIN(userID), MIN(createdAt), MAX(createdAt), MAX(expiresAt),
+  CASE WHEN BOOL_AND(revokedAt IS NOT NULL) THEN MAX(revokedAt) END
+FROM refresh_tokens
+GROUP BY familyID
+ON CONFLICT (id) DO NOTHING;
+
+ALTER TABLE refresh_tokens
+  ADD CONSTRAINT refresh_tokens_session_fkey FOREIGN KEY (familyID) REFERENCES sessions(id) ON DELETE CASCADE;
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS emailVerifiedAt TIMESTAMP;
+
+-- accounts created before verification existed are trusted
+UPDATE users SET emailVerifiedAt = createdAt WHERE emailVerifiedAt IS NULL;
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+  tokenHash VARCHAR(64) PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expiresAt TIMESTAMP NOT NULL,
+  usedAt TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS password_reset_tokens_user_idx ON password_reset_tokens (userID);
+CREATE INDEX IF NOT EXISTS password_reset_tokens_expires_idx ON password_reset_tokens (expiresAt);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS settings;
+DROP TABLE IF EXISTS login_challenges;
+DROP TABLE IF EXISTS recovery_codes;
+
+ALTER TABLE users DROP COLUMN IF EXISTS totpLastStep;
+ALTER TABLE users DROP COLUMN IF EXISTS totpEnabledAt;
+ALTER TABLE users DROP COLUMN IF EXISTS totpSecret;
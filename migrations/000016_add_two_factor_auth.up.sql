@@ -0,0 +1,34 @@
+-- totpSecret is set on enrolment, totpEnabledAt once the first code confirmed it.
+-- totpLastStep is the time step of the last accepted code, a code is never accepted twice.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totpSecret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totpEnabledAt TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totpLastStep BIGINT;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+  id SERIAL PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  codeHash VARCHAR(64) NOT NULL,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  usedAt TIMESTAMP,
+  UNIQUE (userID, codeHash)
+);
+
+-- a password sign in waiting for the second factor
+CREATE TABLE IF NOT EXISTS login_challenges (
+  tokenHash VARCHAR(64) PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  userAgent TEXT NOT NULL DEFAULT '',
+  ip VARCHAR(64) NOT NULL DEFAULT '',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expiresAt TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS login_challenges_expires_idx ON login_challenges (expiresAt);
+
+-- switches admins turn on and off at runtime
+CREATE TABLE IF NOT EXISTS settings (
+  key VARCHAR(64) PRIMARY KEY,
+  value TEXT NOT NULL,
+  updatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,26 @@
+-- accounts of external identity providers linked to users, subject is the
+-- provider's stable ID of the account
+CREATE TABLE IF NOT EXISTS user_identities (
+  id SERIAL PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  provider VARCHAR(64) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  lastLoginAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_idx ON user_identities (userID);
+
+-- sign ins sent to a provider and not back yet, with the nonce and PKCE verifier they started with
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+  stateHash VARCHAR(64) PRIMARY KEY,
+  provider VARCHAR(64) NOT NULL,
+  nonce VARCHAR(64) NOT NULL,
+  codeVerifier VARCHAR(128) NOT NULL,
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expiresAt TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS oidc_login_states_expires_idx ON oidc_login_states (expiresAt);
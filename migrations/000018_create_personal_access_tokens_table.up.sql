@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+  id SERIAL PRIMARY KEY,
+  userID INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  -- the first characters of the token, shown so users can tell their tokens apart
+  prefix VARCHAR(16) NOT NULL,
+  tokenHash VARCHAR(64) NOT NULL UNIQUE,
+  scopes TEXT[] NOT NULL DEFAULT '{}',
+  createdAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  expiresAt TIMESTAMP NOT NULL,
+  lastUsedAt TIMESTAMP,
+  revokedAt TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS personal_access_tokens_user_idx ON personal_access_tokens (userID);
+CREATE INDEX IF NOT EXISTS personal_access_tokens_expires_idx ON personal_access_tokens (expiresAt);
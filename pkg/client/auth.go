@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+func (c *Client) Register(ctx context.Context, req RegisterRequest) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/register", body: req, public: true}, nil)
+}
+
+// Login signs in and keeps the tokens for the following requests. When
+// the account has two-factor authentication it returns a
+// *TwoFactorRequiredError, finish the sign in with VerifyTwoFactor.
+func (c *Client) Login(ctx context.Context, email string, password string) (Tokens, error) {
+	creds := struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}{Email: email, Password: password}
+
+	var resp struct {
+		Tokens
+		TwoFactorRequired  bool   `json:"two_factor_required"`
+		ChallengeToken     string `json:"challenge_token"`
+		EnrollmentRequired bool   `json:"enrollment_required"`
+	}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/login", body: creds, public: true}, &resp); err != nil {
+		return Tokens{}, err
+	}
+
+	if resp.TwoFactorRequired {
+		return Tokens{}, &TwoFactorRequiredError{ChallengeToken: resp.ChallengeToken, EnrollmentRequired: resp.EnrollmentRequired}
+	}
+
+	c.SetTokens(resp.Tokens)
+	return resp.Tokens, nil
+}
+
+// Refresh exchanges the refresh token for new tokens. Requests call it on
+// their own when the access token has expired.
+func (c *Client) Refresh(ctx context.Context) (Tokens, error) {
+	body := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{RefreshToken: c.Tokens().RefreshToken}
+
+	var tokens Tokens
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/refresh", body: body, public: true}, &tokens); err != nil {
+		return Tokens{}, err
+	}
+
+	c.SetTokens(tokens)
+	if c.OnTokenRefresh != nil {
+		c.OnTokenRefresh(tokens)
+	}
+	return tokens, nil
+}
+
+// Logout revokes the refresh token on the server and forgets the tokens
+func (c *Client) Logout(ctx context.Context) error {
+	body := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{RefreshToken: c.Tokens().RefreshToken}
+
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/logout", body: body, public: true}, nil); err != nil {
+		return err
+	}
+
+	c.SetTokens(Tokens{})
+	return nil
+}
+
+// LogoutAll revokes the refresh tokens of every sign in of the user, this client's included
+func (c *Client) LogoutAll(ctx context.Context) error {
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/logout-all"}, nil); err != nil {
+		return err
+	}
+
+	c.SetTokens(Tokens{})
+	return nil
+}
+
+// VerifyEmail confirms the address with the token of the verification email
+func (c *Client) VerifyEmail(ctx context.Context, token string) error {
+	body := struct {
+		Token string `json:"token"`
+	}{Token: token}
+
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/verify-email", body: body, public: true}, nil)
+}
+
+func (c *Client) ResendVerification(ctx context.Context, email string) error {
+	body := struct {
+		Email string `json:"email"`
+	}{Email: email}
+
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/resend-verification", body: body, public: true}, nil)
+}
+
+// ForgotPassword has a reset token emailed to the address
+func (c *Client) ForgotPassword(ctx context.Context, email string) error {
+	body := struct {
+		Email string `json:"email"`
+	}{Email: email}
+
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/forgot-password", body: body, public: true}, nil)
+}
+
+// ResetPassword sets a new password with the emailed token, every session is signed out
+func (c *Client) ResetPassword(ctx context.Context, token string, password string) error {
+	body := struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}{Token: token, Password: password}
+
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/reset-password", body: body, public: true}, nil)
+}
+
+// ChangePassword replaces the password, the other sessions of the user are signed out
+func (c *Client) ChangePassword(ctx context.Context, current string, password string) error {
+	body := struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}{CurrentPassword: current, NewPassword: password}
+
+	return c.do(ctx, request{method: http.MethodPut, path: "/api/v1/users/me/password", body: body}, nil)
+}
@@ -0,0 +1,227 @@
+// Package client is a Go client for the TODO API. It keeps the tokens of
+// the signed in user, refreshes them when the access token expires and
+// retries requests that are safe to repeat.
+//
+//	c := client.New("https://todo.example.com")
+//	if _, err := c.Login(ctx, email, password); err != nil {
+//		return err
+//	}
+//	for task, err := range c.Tasks(ctx, client.ListOptions{View: "my_day"}) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tokens are the access and refresh token of the signed in user
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+	// UserAgent names the application in the user's session list
+	UserAgent string
+
+	// MaxRetries is how often a request that is safe to repeat is retried
+	// after a network error, 429 or 5xx. The delay starts at RetryBackoff
+	// and doubles up to MaxBackoff, a Retry-After header takes precedence.
+	MaxRetries   int
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+
+	// OnTokenRefresh is called with the new tokens after a refresh, e.g. to store them
+	OnTokenRefresh func(Tokens)
+
+	mu     sync.Mutex
+	tokens Tokens
+
+	// refreshMu lets only one of concurrent requests refresh an expired token
+	refreshMu sync.Mutex
+}
+
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		HTTP:         &http.Client{Timeout: 30 * time.Second},
+		UserAgent:    "todo-api-go-client",
+		MaxRetries:   3,
+		RetryBackoff: 200 * time.Millisecond,
+		MaxBackoff:   5 * time.Second,
+	}
+}
+
+// SetTokens signs the client in with tokens obtained earlier
+func (c *Client) SetTokens(tokens Tokens) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens = tokens
+}
+
+func (c *Client) Tokens() Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+// request is one API call, body and result are JSON
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{}
+	// public requests are sent without the access token and never refresh it
+	public bool
+}
+
+func (c *Client) do(ctx context.Context, req request, result interface{}) error {
+	var body []byte
+	if req.body != nil {
+		data, err := json.Marshal(req.body)
+		if err != nil {
+			return err
+		}
+		body = data
+	}
+
+	// the server replays the response of a POST retried with the same key
+	// instead of running it twice, authenticated routes support the header
+	var idempotencyKey string
+	if req.method == http.MethodPost && !req.public {
+		idempotencyKey = newIdempotencyKey()
+	}
+	retriable := req.method != http.MethodPost || idempotencyKey != ""
+
+	refreshed := false
+	for attempt := 0; ; attempt++ {
+		accessToken := c.Tokens().AccessToken
+
+		resp, err := c.send(ctx, req, body, accessToken, idempotencyKey)
+		if err != nil {
+			if ctx.Err() != nil || !retriable || attempt >= c.MaxRetries {
+				return err
+			}
+			if err := c.wait(ctx, attempt, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && !req.public && !refreshed {
+			drain(resp)
+			if err := c.refreshAfter(ctx, accessToken); err != nil {
+				return err
+			}
+			refreshed = true
+			// the refresh does not use up a retry
+			attempt--
+			continue
+		}
+
+		if retriable && attempt < c.MaxRetries && shouldRetry(resp.StatusCode, idempotencyKey != "") {
+			retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+			drain(resp)
+			if err := c.wait(ctx, attempt, retryAfter); err != nil {
+				return err
+			}
+			continue
+		}
+
+		return decode(resp, result)
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, body []byte, accessToken string, idempotencyKey string) (*http.Response, error) {
+	target := c.BaseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Accept", "application/json")
+	if c.UserAgent != "" {
+		httpReq.Header.Set("User-Agent", c.UserAgent)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if !req.public && accessToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	if idempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	return c.HTTP.Do(httpReq)
+}
+
+// refreshAfter refreshes the tokens unless another request already replaced staleToken
+func (c *Client) refreshAfter(ctx context.Context, staleToken string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	tokens := c.Tokens()
+	if tokens.AccessToken != staleToken {
+		return nil
+	}
+	if tokens.RefreshToken == "" {
+		return &Error{StatusCode: http.StatusUnauthorized, Message: "not signed in"}
+	}
+
+	_, err := c.Refresh(ctx)
+	return err
+}
+
+func decode(resp *http.Response, result interface{}) error {
+	defer drain(resp)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&failure); err != nil || failure.Error == "" {
+			failure.Error = http.StatusText(resp.StatusCode)
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: failure.Error}
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// drain reads what is left of the body so the connection can be reused
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+}
+
+func newIdempotencyKey() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
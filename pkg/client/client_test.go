@@ -0,0 +1,355 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/handlers"
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// fakeAPI answers like the server does, with the request and response types
+// of the handlers, so a client that drifts from the API fails here
+type fakeAPI struct {
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+	refreshes    int
+	tasks        []models.Task
+}
+
+func newFakeAPI(t *testing.T, setup func(api *fakeAPI, mux *http.ServeMux)) (*fakeAPI, *Client) {
+	t.Helper()
+
+	api := &fakeAPI{accessToken: "access-1", refreshToken: "refresh-1"}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		var req handlers.LoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Header.Get("Authorization") != "" {
+			writeJSON(w, http.StatusBadRequest, handlers.ErrorResponse{Error: "invalid request"})
+			return
+		}
+		switch {
+		case req.Email == "2fa@example.com" && req.Password == "secret":
+			writeJSON(w, http.StatusOK, handlers.TokenResponse{TwoFactorRequired: true, ChallengeToken: "challenge"})
+		case req.Email == "me@example.com" && req.Password == "secret":
+			api.mu.Lock()
+			defer api.mu.Unlock()
+			writeJSON(w, http.StatusOK, handlers.TokenResponse{AccessToken: api.accessToken, RefreshToken: api.refreshToken})
+		default:
+			writeJSON(w, http.StatusUnauthorized, handlers.ErrorResponse{Error: "invalid email or password"})
+		}
+	})
+
+	mux.HandleFunc("POST /api/v1/auth/refresh", func(w http.ResponseWriter, r *http.Request) {
+		var req handlers.RefreshRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		if req.RefreshToken != api.refreshToken {
+			writeJSON(w, http.StatusUnauthorized, handlers.ErrorResponse{Error: "invalid refresh token"})
+			return
+		}
+		api.refreshes++
+		api.accessToken = "access-" + strconv.Itoa(api.refreshes+1)
+		api.refreshToken = "refresh-" + strconv.Itoa(api.refreshes+1)
+		writeJSON(w, http.StatusOK, handlers.TokenResponse{AccessToken: api.accessToken, RefreshToken: api.refreshToken})
+	})
+
+	mux.HandleFunc("GET /api/v1/tasks/", api.authorized(func(w http.ResponseWriter, r *http.Request) {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+		page := api.tasks[min(offset, len(api.tasks)):]
+		if limit > 0 && limit < len(page) {
+			page = page[:limit]
+		}
+		writeJSON(w, http.StatusOK, page)
+	}))
+
+	mux.HandleFunc("GET /api/v1/tasks/{id}", api.authorized(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.PathValue("id"))
+		for _, task := range api.tasks {
+			if task.ID == id {
+				writeJSON(w, http.StatusOK, task)
+				return
+			}
+		}
+		writeJSON(w, http.StatusNotFound, handlers.ErrorResponse{Error: "task not found"})
+	}))
+
+	if setup != nil {
+		setup(api, mux)
+	}
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	c := New(server.URL)
+	c.RetryBackoff = time.Millisecond
+	c.MaxBackoff = 5 * time.Millisecond
+	return api, c
+}
+
+// authorized lets only the current access token through, like RequireAuth
+func (api *fakeAPI) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		valid := r.Header.Get("Authorization") == "Bearer "+api.accessToken
+		api.mu.Unlock()
+
+		if !valid {
+			writeJSON(w, http.StatusUnauthorized, handlers.ErrorResponse{Error: "invalid token"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+// expire makes the current access token stop working
+func (api *fakeAPI) expire() {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.accessToken = "expired"
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestLogin(t *testing.T) {
+	_, c := newFakeAPI(t, nil)
+
+	tokens, err := c.Login(context.Background(), "me@example.com", "secret")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if tokens != (Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"}) || c.Tokens() != tokens {
+		t.Errorf("tokens = %+v, client keeps %+v", tokens, c.Tokens())
+	}
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	_, c := newFakeAPI(t, nil)
+
+	_, err := c.Login(context.Background(), "me@example.com", "wrong")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Login() error = %v, want ErrUnauthorized", err)
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Message != "invalid email or password" {
+		t.Errorf("error = %#v, want the message of the server", err)
+	}
+}
+
+func TestLoginTwoFactor(t *testing.T) {
+	_, c := newFakeAPI(t, nil)
+
+	_, err := c.Login(context.Background(), "2fa@example.com", "secret")
+
+	var challenge *TwoFactorRequiredError
+	if !errors.As(err, &challenge) || challenge.ChallengeToken != "challenge" || challenge.EnrollmentRequired {
+		t.Fatalf("Login() error = %#v, want a two-factor challenge", err)
+	}
+	if c.Tokens() != (Tokens{}) {
+		t.Errorf("client keeps tokens %+v before the second factor", c.Tokens())
+	}
+}
+
+func TestGetTaskNotFound(t *testing.T) {
+	api, c := newFakeAPI(t, nil)
+	api.tasks = []models.Task{{ID: 1, Title: "Pay rent"}}
+	c.SetTokens(Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"})
+
+	task, err := c.GetTask(context.Background(), 1)
+	if err != nil || task.Title != "Pay rent" {
+		t.Fatalf("GetTask(1) = %+v, %v", task, err)
+	}
+
+	if _, err := c.GetTask(context.Background(), 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTask(2) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRefreshesExpiredToken(t *testing.T) {
+	api, c := newFakeAPI(t, nil)
+	api.tasks = []models.Task{{ID: 1, Title: "Pay rent"}}
+	c.SetTokens(Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"})
+
+	var stored Tokens
+	c.OnTokenRefresh = func(tokens Tokens) { stored = tokens }
+
+	api.expire()
+
+	if _, err := c.GetTask(context.Background(), 1); err != nil {
+		t.Fatalf("GetTask() error = %v", err)
+	}
+	want := Tokens{AccessToken: "access-2", RefreshToken: "refresh-2"}
+	if c.Tokens() != want || stored != want {
+		t.Errorf("tokens = %+v, stored %+v, want %+v", c.Tokens(), stored, want)
+	}
+}
+
+func TestConcurrentRequestsRefreshOnce(t *testing.T) {
+	api, c := newFakeAPI(t, nil)
+	api.tasks = []models.Task{{ID: 1, Title: "Pay rent"}}
+	c.SetTokens(Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"})
+	api.expire()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.GetTask(context.Background(), 1)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetTask() error = %v", err)
+		}
+	}
+	if api.refreshes != 1 {
+		t.Errorf("refreshed %d times, want once", api.refreshes)
+	}
+}
+
+func TestRejectedRefreshTokenFails(t *testing.T) {
+	api, c := newFakeAPI(t, nil)
+	c.SetTokens(Tokens{AccessToken: "access-1", RefreshToken: "revoked"})
+	api.expire()
+
+	if _, err := c.GetTask(context.Background(), 1); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("GetTask() error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestTasksPages(t *testing.T) {
+	api, c := newFakeAPI(t, nil)
+	for i := 1; i <= 7; i++ {
+		api.tasks = append(api.tasks, models.Task{ID: i, Title: "task " + strconv.Itoa(i)})
+	}
+	c.SetTokens(Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"})
+
+	var ids []int
+	for task, err := range c.Tasks(context.Background(), ListOptions{Limit: 3}) {
+		if err != nil {
+			t.Fatalf("Tasks() error = %v", err)
+		}
+		ids = append(ids, task.ID)
+	}
+
+	if len(ids) != 7 || ids[0] != 1 || ids[6] != 7 {
+		t.Errorf("Tasks() = %v, want 1 to 7", ids)
+	}
+}
+
+func TestRetriesPostWithSameIdempotencyKey(t *testing.T) {
+	var attempts atomic.Int32
+	var mu sync.Mutex
+	var keys []string
+
+	_, c := newFakeAPI(t, func(api *fakeAPI, mux *http.ServeMux) {
+		mux.HandleFunc("POST /api/v1/tasks/", api.authorized(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			mu.Unlock()
+
+			if attempts.Add(1) < 3 {
+				w.Header().Set("Retry-After", "0")
+				writeJSON(w, http.StatusServiceUnavailable, handlers.ErrorResponse{Error: "try again"})
+				return
+			}
+
+			var task models.Task
+			json.NewDecoder(r.Body).Decode(&task)
+			task.ID = 42
+			writeJSON(w, http.StatusCreated, task)
+		}))
+	})
+	c.SetTokens(Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"})
+
+	created, err := c.CreateTask(context.Background(), &Task{Title: "Pay rent"})
+	if err != nil {
+		t.Fatalf("CreateTask() error = %v", err)
+	}
+	if created.ID != 42 || created.Title != "Pay rent" {
+		t.Errorf("CreateTask() = %+v", created)
+	}
+
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("Idempotency-Key of the attempts = %q, want one key sent three times", keys)
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	var attempts atomic.Int32
+
+	_, c := newFakeAPI(t, func(api *fakeAPI, mux *http.ServeMux) {
+		mux.HandleFunc("DELETE /api/v1/tasks/{id}", api.authorized(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			writeJSON(w, http.StatusBadGateway, handlers.ErrorResponse{Error: "upstream down"})
+		}))
+	})
+	c.SetTokens(Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"})
+	c.MaxRetries = 2
+
+	if err := c.DeleteTask(context.Background(), 1); !errors.Is(err, ErrServer) {
+		t.Errorf("DeleteTask() error = %v, want ErrServer", err)
+	}
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("sent %d times, want the first attempt and 2 retries", got)
+	}
+}
+
+func TestPublicPostIsNotRetried(t *testing.T) {
+	var attempts atomic.Int32
+
+	_, c := newFakeAPI(t, func(api *fakeAPI, mux *http.ServeMux) {
+		mux.HandleFunc("POST /api/v1/auth/register", func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			if r.Header.Get("Idempotency-Key") != "" {
+				t.Error("a public route got an Idempotency-Key")
+			}
+			writeJSON(w, http.StatusServiceUnavailable, handlers.ErrorResponse{Error: "try again"})
+		})
+	})
+
+	err := c.Register(context.Background(), RegisterRequest{Username: "me", Email: "me@example.com", Password: "secret"})
+	if !errors.Is(err, ErrServer) {
+		t.Errorf("Register() error = %v, want ErrServer", err)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("sent %d times, a POST without idempotency key must not be repeated", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("3"); got != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %s", got)
+	}
+	if got := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); got <= 58*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(date a minute ahead) = %s", got)
+	}
+	if got := parseRetryAfter("soon"); got != 0 {
+		t.Errorf("parseRetryAfter(soon) = %s", got)
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// shouldRetry tells whether a response is worth another attempt. A 409 is
+// only retried with an idempotency key, it means the first attempt is
+// still running and a retry gets its stored response once it is done.
+func shouldRetry(status int, keyed bool) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		return keyed
+	}
+	return false
+}
+
+// wait sleeps before the next attempt, retryAfter overrides the backoff when set
+func (c *Client) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	delay := retryAfter
+	if delay <= 0 {
+		delay = c.backoff(attempt)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff doubles the delay with every attempt, with jitter so that
+// clients failing together do not retry together
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := c.RetryBackoff
+	for i := 0; i < attempt && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if c.MaxBackoff > 0 && delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
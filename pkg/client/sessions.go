@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// Session is a sign in of the user on some device
+type Session = models.Session
+
+// Sessions lists where the user is signed in, Current marks this client
+func (c *Client) Sessions(ctx context.Context) ([]Session, error) {
+	var sessions []Session
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/v1/users/me/sessions"}, &sessions)
+	return sessions, err
+}
+
+// RevokeSession signs a session out, its tokens stop working at once
+func (c *Client) RevokeSession(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/v1/users/me/sessions/" + url.PathEscape(id)}, nil)
+}
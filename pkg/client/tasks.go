@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+	"github.com/DmitriyGiryntsev/TODO-API/pkg/quickadd"
+)
+
+// Task is a task as the API sends and accepts it
+type Task = models.Task
+
+// DefaultPageSize is the page size of Tasks when ListOptions has no Limit
+const DefaultPageSize = 100
+
+// ListOptions narrow down the task list. Limit and Offset select a page,
+// without a Limit ListTasks returns every task.
+type ListOptions struct {
+	// View is my_day, pinned or snoozed, snoozed tasks are hidden by default
+	View        string
+	MilestoneID int
+	Limit       int
+	Offset      int
+}
+
+func (o ListOptions) query() url.Values {
+	query := url.Values{}
+	if o.View != "" {
+		query.Set("view", o.View)
+	}
+	if o.MilestoneID > 0 {
+		query.Set("milestone_id", strconv.Itoa(o.MilestoneID))
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	return query
+}
+
+// QuickAddResult is the created task along with what the server understood of the line
+type QuickAddResult struct {
+	Task   Task            `json:"task"`
+	Parsed quickadd.Result `json:"parsed"`
+}
+
+func (c *Client) ListTasks(ctx context.Context, opts ListOptions) ([]Task, error) {
+	var tasks []Task
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/v1/tasks/", query: opts.query()}, &tasks)
+	return tasks, err
+}
+
+// Tasks iterates over the tasks page by page, Limit sets the page size.
+// An error ends the iteration. Pages are taken by offset, so tasks
+// created or deleted meanwhile may be skipped or seen twice.
+func (c *Client) Tasks(ctx context.Context, opts ListOptions) iter.Seq2[Task, error] {
+	return func(yield func(Task, error) bool) {
+		if opts.Limit <= 0 {
+			opts.Limit = DefaultPageSize
+		}
+
+		for {
+			page, err := c.ListTasks(ctx, opts)
+			if err != nil {
+				yield(Task{}, err)
+				return
+			}
+
+			for _, task := range page {
+				if !yield(task, nil) {
+					return
+				}
+			}
+
+			if len(page) < opts.Limit {
+				return
+			}
+			opts.Offset += len(page)
+		}
+	}
+}
+
+func (c *Client) GetTask(ctx context.Context, id int) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, request{method: http.MethodGet, path: taskPath(id)}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (c *Client) CreateTask(ctx context.Context, task *Task) (*Task, error) {
+	var created Task
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/tasks/", body: task}, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// QuickAdd creates a task from a single line such as "Pay rent tomorrow 9am #home !high"
+func (c *Client) QuickAdd(ctx context.Context, text string) (*QuickAddResult, error) {
+	body := struct {
+		Text string `json:"text"`
+	}{Text: text}
+
+	var result QuickAddResult
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/tasks/quick", body: body}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateTask overwrites the task, fields left empty are cleared
+func (c *Client) UpdateTask(ctx context.Context, id int, task *Task) error {
+	return c.do(ctx, request{method: http.MethodPut, path: taskPath(id), body: task}, nil)
+}
+
+func (c *Client) DeleteTask(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: taskPath(id)}, nil)
+}
+
+func taskPath(id int) string {
+	return "/api/v1/tasks/" + strconv.Itoa(id)
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DmitriyGiryntsev/TODO-API/internal/models"
+)
+
+// PersonalAccessToken is a token for scripts, the token itself is only in
+// the response of CreatePersonalToken
+type PersonalAccessToken = models.PersonalAccessToken
+
+// Scopes a personal access token can carry
+const (
+	ScopeTasksRead   = "tasks:read"
+	ScopeTasksWrite  = "tasks:write"
+	ScopeWebhooks    = "webhooks"
+	ScopeProfileRead = "profile:read"
+	ScopeAdmin       = "admin"
+)
+
+// UsePersonalAccessToken signs the client in with a personal access token
+// instead of a session. It cannot be refreshed and works only on routes
+// its scopes allow.
+func (c *Client) UsePersonalAccessToken(token string) {
+	c.SetTokens(Tokens{AccessToken: token})
+}
+
+// CreatePersonalToken issues a token with the scopes, it needs a signed in
+// session. The returned string is the token, it is not shown again.
+func (c *Client) CreatePersonalToken(ctx context.Context, name string, scopes []string, expiresAt time.Time) (*PersonalAccessToken, string, error) {
+	body := struct {
+		Name      string    `json:"name"`
+		Scopes    []string  `json:"scopes"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{Name: name, Scopes: scopes, ExpiresAt: expiresAt}
+
+	var resp struct {
+		PersonalAccessToken
+		Token string `json:"token"`
+	}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/users/me/tokens", body: body}, &resp); err != nil {
+		return nil, "", err
+	}
+	return &resp.PersonalAccessToken, resp.Token, nil
+}
+
+// PersonalTokens lists the tokens of the user that still work
+func (c *Client) PersonalTokens(ctx context.Context) ([]PersonalAccessToken, error) {
+	var tokens []PersonalAccessToken
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/v1/users/me/tokens"}, &tokens)
+	return tokens, err
+}
+
+// RevokePersonalToken stops a token from working at once
+func (c *Client) RevokePersonalToken(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/v1/users/me/tokens/" + strconv.Itoa(id)}, nil)
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// TwoFactorRequiredError is returned by Login when the password was right
+// but a second factor is needed. With EnrollmentRequired the account must
+// set up an authenticator first, see EnrollTwoFactorChallenge.
+type TwoFactorRequiredError struct {
+	ChallengeToken     string
+	EnrollmentRequired bool
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	if e.EnrollmentRequired {
+		return "two-factor enrollment required"
+	}
+	return "two-factor authentication required"
+}
+
+// TOTPEnrollment is a new authenticator secret, URI is the otpauth:// URI
+// and QRCode a PNG data URI of it
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+	QRCode string `json:"qr_code"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// VerifyTwoFactor finishes a sign in with a code of the authenticator app
+// or a recovery code and keeps the tokens. Recovery codes are returned
+// when the sign in enrolled the account, they are shown only this once.
+func (c *Client) VerifyTwoFactor(ctx context.Context, challengeToken string, code string) (Tokens, []string, error) {
+	body := struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}{ChallengeToken: challengeToken, Code: code}
+
+	var resp struct {
+		Tokens
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/2fa/verify", body: body, public: true}, &resp); err != nil {
+		return Tokens{}, nil, err
+	}
+
+	c.SetTokens(resp.Tokens)
+	return resp.Tokens, resp.RecoveryCodes, nil
+}
+
+// EnrollTwoFactorChallenge gets a secret for an account that must enrol
+// during sign in, its first code goes to VerifyTwoFactor
+func (c *Client) EnrollTwoFactorChallenge(ctx context.Context, challengeToken string) (*TOTPEnrollment, error) {
+	body := struct {
+		ChallengeToken string `json:"challenge_token"`
+	}{ChallengeToken: challengeToken}
+
+	var enrollment TOTPEnrollment
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/auth/2fa/enroll", body: body, public: true}, &enrollment); err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+func (c *Client) TwoFactorStatus(ctx context.Context) (*TwoFactorStatus, error) {
+	var status TwoFactorStatus
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/v1/users/me/2fa"}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// EnrollTwoFactor gets a new secret, 2FA is on once ConfirmTwoFactor gets a code of it
+func (c *Client) EnrollTwoFactor(ctx context.Context) (*TOTPEnrollment, error) {
+	var enrollment TOTPEnrollment
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/users/me/2fa"}, &enrollment); err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// ConfirmTwoFactor enables 2FA and returns the recovery codes
+func (c *Client) ConfirmTwoFactor(ctx context.Context, code string) ([]string, error) {
+	return c.recoveryCodes(ctx, "/api/v1/users/me/2fa/confirm", code)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes, the old ones stop working
+func (c *Client) RegenerateRecoveryCodes(ctx context.Context, code string) ([]string, error) {
+	return c.recoveryCodes(ctx, "/api/v1/users/me/2fa/recovery-codes", code)
+}
+
+// DisableTwoFactor turns 2FA off with a code or a recovery code
+func (c *Client) DisableTwoFactor(ctx context.Context, code string) error {
+	body := struct {
+		Code string `json:"code"`
+	}{Code: code}
+
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/v1/users/me/2fa", body: body}, nil)
+}
+
+func (c *Client) recoveryCodes(ctx context.Context, path string, code string) ([]string, error) {
+	body := struct {
+		Code string `json:"code"`
+	}{Code: code}
+
+	var resp struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	if err := c.do(ctx, request{method: http.MethodPost, path: path, body: body}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.RecoveryCodes, nil
+}
@@ -0,0 +1,310 @@
+package helpers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signing algorithms of the keys
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Key is a private signing key, ID is the kid of the tokens it signs
+type Key struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	Created   time.Time
+}
+
+// KeySet holds the signing keys, one PEM file per key in Dir named <kid>.pem.
+// Tokens are signed with the newest key, the older ones stay until the
+// tokens they signed have expired. Several servers can share Dir, each
+// picks up the keys the others generate.
+type KeySet struct {
+	Dir string
+	// Algorithm of the keys generated by rotation
+	Algorithm string
+	// RotationInterval is how long a key signs before a new one is generated, zero turns rotation off
+	RotationInterval time.Duration
+
+	mu sync.RWMutex
+	// keys are sorted newest first
+	keys []*Key
+}
+
+// LoadKeySet reads the keys in dir, a first key is generated when there is none
+func LoadKeySet(dir string, algorithm string, rotationInterval time.Duration) (*KeySet, error) {
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	set := &KeySet{Dir: dir, Algorithm: algorithm, RotationInterval: rotationInterval}
+	if err := set.Reload(); err != nil {
+		return nil, err
+	}
+
+	if set.Signing() == nil {
+		if _, err := set.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return set, nil
+}
+
+// Reload reads the key files again
+func (k *KeySet) Reload() error {
+	if err := os.MkdirAll(k.Dir, 0o700); err != nil {
+		return err
+	}
+
+	paths, err := filepath.Glob(filepath.Join(k.Dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*Key, 0, len(paths))
+	for _, path := range paths {
+		key, err := readKey(path)
+		if err != nil {
+			return fmt.Errorf("cannot read key %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Created.Equal(keys[j].Created) {
+			return keys[i].ID > keys[j].ID
+		}
+		return keys[i].Created.After(keys[j].Created)
+	})
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = keys
+
+	return nil
+}
+
+// Signing returns the key new tokens are signed with
+func (k *KeySet) Signing() *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if len(k.keys) == 0 {
+		return nil
+	}
+	return k.keys[0]
+}
+
+// Lookup returns the key with the kid, nil when it is unknown or retired
+func (k *KeySet) Lookup(kid string) *Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	for _, key := range k.keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// Rotate generates a new key and signs with it from now on
+func (k *KeySet) Rotate() (*Key, error) {
+	var private crypto.Signer
+	var err error
+
+	switch k.Algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	kid := now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+
+	path := filepath.Join(k.Dir, kid+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: kid, Algorithm: k.Algorithm, Private: private, Created: now}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = append([]*Key{key}, k.keys...)
+
+	log.Printf("generated signing key %s", kid)
+	return key, nil
+}
+
+// Run reloads the keys, rotates and retires them until ctx is cancelled
+func (k *KeySet) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Reload(); err != nil {
+				log.Print("cannot reload signing keys:", err)
+				continue
+			}
+			if k.RotationInterval <= 0 {
+				continue
+			}
+			if signing := k.Signing(); signing == nil || time.Since(signing.Created) >= k.RotationInterval {
+				if _, err := k.Rotate(); err != nil {
+					log.Print("cannot rotate signing key:", err)
+				}
+			}
+			k.retire()
+		}
+	}
+}
+
+// retire deletes keys that no valid token can have been signed with, that
+// is keys replaced longer than an access token lives
+func (k *KeySet) retire() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for i := 1; i < len(k.keys); i++ {
+		if time.Since(k.keys[i-1].Created) < AccessTokenTTL {
+			continue
+		}
+
+		for _, key := range k.keys[i:] {
+			if err := os.Remove(filepath.Join(k.Dir, key.ID+".pem")); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Print("cannot delete retired signing key:", err)
+			}
+			log.Printf("retired signing key %s", key.ID)
+		}
+		k.keys = k.keys[:i]
+		return
+	}
+}
+
+// JWK is a public key as published in the JWKS
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of every key tokens may be signed with
+func (k *KeySet) JWKS() JWKS {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		jwk := JWK{Use: "sig", Algorithm: key.Algorithm, KeyID: key.ID}
+
+		switch public := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// readKey reads a PKCS#8 or PKCS#1 PEM file, the file name is the kid and its modification time the creation time
+func readKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unexpected PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{ID: strings.TrimSuffix(filepath.Base(path), ".pem"), Created: info.ModTime()}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm, key.Private = AlgorithmRS256, private
+	case ed25519.PrivateKey:
+		key.Algorithm, key.Private = AlgorithmEdDSA, private
+	default:
+		return nil, errors.New("only RSA and Ed25519 keys are supported")
+	}
+
+	return key, nil
+}
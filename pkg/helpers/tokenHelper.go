@@ -1,8 +1,11 @@
 package helpers
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"log"
-	"os"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt"
@@ -13,52 +16,83 @@ type SignedDetails struct {
 	Username string
 	Email    string
 	Role     string
+	// SessionID is the sign in the token was issued for, revoking it ends the token early
+	SessionID string
 	jwt.StandardClaims
 }
 
-var SECRET_KEY string = os.Getenv("SECRET_KEY")
+// AccessTokenTTL is how long an access token is valid
+const AccessTokenTTL = 24 * time.Hour
 
-func GenerateAllTokens(id int, username string, email string, role string) (signedToken string, signedRefreshToken string, err error) {
-	claims := &SignedDetails{
-		ID:       id,
-		Username: username,
-		Email:    email,
-		Role:     role,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(24)).Unix(),
-		},
+var validMethods = []string{AlgorithmRS256, AlgorithmEdDSA}
+
+// ActionClaims are the claims of single-purpose tokens sent by email, such
+// as address verification. Subject is the user ID.
+type ActionClaims struct {
+	Email string
+	jwt.StandardClaims
+}
+
+// TokenSigner issues and checks access tokens. Tokens carry the kid of
+// their key, so other services can verify them with the published JWKS.
+type TokenSigner struct {
+	Keys     *KeySet
+	Issuer   string
+	Audience string
+}
+
+func NewTokenSigner(keys *KeySet, issuer string, audience string) *TokenSigner {
+	return &TokenSigner{Keys: keys, Issuer: issuer, Audience: audience}
+}
+
+// GenerateAccessToken signs a short-lived access token. Refresh tokens are
+// opaque and kept by the server, see service.AuthService.
+func (s *TokenSigner) GenerateAccessToken(id int, username string, email string, role string, sessionID string) (string, error) {
+	key := s.Keys.Signing()
+	if key == nil {
+		return "", errors.New("no signing key")
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
 	}
 
-	refreshClaims := &SignedDetails{
+	now := time.Now()
+	claims := &SignedDetails{
+		ID:        id,
+		Username:  username,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Local().Add(time.Hour * time.Duration(168)).Unix(),
+			Id:        base64.RawURLEncoding.EncodeToString(jti),
+			Issuer:    s.Issuer,
+			Audience:  s.Audience,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(AccessTokenTTL).Unix(),
 		},
 	}
 
-	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(SECRET_KEY))
-	if err != nil {
-		log.Panic("cannot create token:", err)
-		return
-	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
 
-	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims).SignedString([]byte(SECRET_KEY))
+	signed, err := token.SignedString(key.Private)
 	if err != nil {
-		log.Panic("cannot create refresh token:", err)
-		return
+		log.Print("cannot create token:", err)
+		return "", err
 	}
 
-	return token, refreshToken, nil
+	return signed, nil
 }
 
-func ValidateToken(signedToken string) (*SignedDetails, error) {
-	token, err := jwt.ParseWithClaims(
-		signedToken,
-		&SignedDetails{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(SECRET_KEY), nil
-		},
-	)
+// ValidateToken checks the signature against the key named by kid and the
+// exp, nbf, iat, iss, aud and jti claims, all of which are required
+func (s *TokenSigner) ValidateToken(signedToken string) (*SignedDetails, error) {
+	parser := &jwt.Parser{ValidMethods: validMethods}
 
+	token, err := parser.ParseWithClaims(signedToken, &SignedDetails{}, s.verificationKey)
 	if err != nil {
 		log.Println("invalid token")
 		return nil, err
@@ -67,13 +101,87 @@ func ValidateToken(signedToken string) (*SignedDetails, error) {
 	claims, ok := token.Claims.(*SignedDetails)
 	if !ok {
 		log.Println("invalid token")
-		return nil, err
+		return nil, errors.New("invalid token claims")
+	}
+
+	// Valid() only checks iat and nbf when they are set
+	if claims.IssuedAt == 0 || claims.NotBefore == 0 || claims.ExpiresAt == 0 || claims.Id == "" {
+		log.Println("invalid token")
+		return nil, errors.New("token lacks required claims")
+	}
+
+	if !claims.VerifyIssuer(s.Issuer, true) || !claims.VerifyAudience(s.Audience, true) {
+		log.Println("invalid token")
+		return nil, errors.New("token is meant for another issuer or audience")
+	}
+
+	// every access token belongs to a user
+	if claims.ID == 0 {
+		log.Println("invalid token")
+		return nil, errors.New("token has no user")
+	}
+
+	return claims, nil
+}
+
+// GenerateActionToken signs a token that only serves purpose. Its audience
+// differs from access tokens', so neither kind is accepted as the other.
+func (s *TokenSigner) GenerateActionToken(purpose string, userID int, email string, ttl time.Duration) (string, error) {
+	key := s.Keys.Signing()
+	if key == nil {
+		return "", errors.New("no signing key")
 	}
 
-	if claims.ExpiresAt < time.Now().Local().Unix() {
-		log.Println("token expired")
+	now := time.Now()
+	claims := &ActionClaims{
+		Email: email,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   strconv.Itoa(userID),
+			Issuer:    s.Issuer,
+			Audience:  s.Audience + ":" + purpose,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.Private)
+}
+
+// ValidateActionToken checks a token made by GenerateActionToken for the same purpose
+func (s *TokenSigner) ValidateActionToken(purpose string, signedToken string) (*ActionClaims, error) {
+	parser := &jwt.Parser{ValidMethods: validMethods}
+
+	token, err := parser.ParseWithClaims(signedToken, &ActionClaims{}, s.verificationKey)
+	if err != nil {
 		return nil, err
 	}
 
+	claims, ok := token.Claims.(*ActionClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+
+	if claims.ExpiresAt == 0 || !claims.VerifyIssuer(s.Issuer, true) || !claims.VerifyAudience(s.Audience+":"+purpose, true) {
+		return nil, errors.New("token is meant for another purpose")
+	}
+
 	return claims, nil
 }
+
+// verificationKey finds the public key named by the kid of the token
+func (s *TokenSigner) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := s.Keys.Lookup(kid)
+	if key == nil {
+		return nil, errors.New("unknown signing key")
+	}
+	// a token must not pick another algorithm than its key's
+	if token.Method.Alg() != key.Algorithm {
+		return nil, errors.New("unexpected signing algorithm")
+	}
+	return key.Private.Public(), nil
+}
@@ -0,0 +1,63 @@
+// Package markdown renders task descriptions written in Markdown to HTML that
+// is safe to put straight into a page, and edits the task lists in them.
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/russross/blackfriday/v2"
+)
+
+const extensions = blackfriday.CommonExtensions | blackfriday.Autolink | blackfriday.Strikethrough
+
+// Checkboxes are swapped for private use characters before rendering, so
+// the rendered ones can be told apart from brackets typed in raw HTML
+// and numbered exactly as ToggleCheckbox counts them.
+const (
+	markerStart = "\ue000"
+	markerEnd   = "\ue001"
+)
+
+var (
+	markerPattern       = regexp.MustCompile(markerStart + `([ x])(\d+)` + markerEnd)
+	taskItemHTMLPattern = regexp.MustCompile(`<li>(<p>)?` + markerStart + `([ x])(\d+)` + markerEnd)
+)
+
+// Render turns the Markdown source into sanitised HTML. Task list items
+// "- [ ]" and "- [x]" become disabled checkboxes carrying their index.
+func Render(source string) string {
+	source = strings.NewReplacer(markerStart, "", markerEnd, "").Replace(source)
+
+	var b strings.Builder
+	last := 0
+	for i, box := range checkboxes(source) {
+		mark := " "
+		if box.checked {
+			mark = "x"
+		}
+		b.WriteString(source[last : box.offset-1])
+		b.WriteString(markerStart + mark + strconv.Itoa(i) + markerEnd)
+		last = box.offset + 2
+	}
+	b.WriteString(source[last:])
+
+	html := string(blackfriday.Run([]byte(b.String()), blackfriday.WithExtensions(extensions)))
+
+	html = taskItemHTMLPattern.ReplaceAllStringFunc(html, func(match string) string {
+		groups := taskItemHTMLPattern.FindStringSubmatch(match)
+
+		checked := ""
+		if groups[2] == "x" {
+			checked = ` checked=""`
+		}
+		return `<li class="task-list-item">` + groups[1] +
+			`<input type="checkbox" disabled=""` + checked + ` data-task-index="` + groups[3] + `">`
+	})
+
+	// a marker the renderer did not put at the start of a list item goes back to brackets
+	html = markerPattern.ReplaceAllString(html, "[$1]")
+
+	return Sanitize(html)
+}
@@ -0,0 +1,234 @@
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// allowedElements maps every element that survives sanitising to the attributes it may keep
+var allowedElements = map[string][]string{
+	"a": {"href", "title"}, "abbr": {"title"}, "b": nil, "blockquote": nil, "br": nil,
+	"code": {"class"}, "dd": nil, "del": nil, "dl": nil, "dt": nil, "em": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil, "hr": nil, "i": nil,
+	"img": {"src", "alt", "title"}, "input": {"type", "checked", "data-task-index"}, "kbd": nil,
+	"li": {"class"}, "ol": {"start"}, "p": nil, "pre": nil, "s": nil, "strong": nil,
+	"sub": nil, "sup": nil, "table": nil, "tbody": nil, "td": {"align"}, "tfoot": nil,
+	"th": {"align"}, "thead": nil, "tr": nil, "ul": nil,
+}
+
+// droppedElements are removed together with everything inside them
+var droppedElements = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "applet": true, "noscript": true, "noembed": true,
+	"noframes": true, "template": true, "textarea": true, "select": true, "svg": true,
+	"math": true, "head": true, "title": true, "xmp": true, "plaintext": true,
+}
+
+var voidElements = map[string]bool{"br": true, "hr": true, "img": true, "input": true}
+
+var (
+	codeClassPattern = regexp.MustCompile(`^language-[A-Za-z0-9_+#-]{1,30}$`)
+	numberPattern    = regexp.MustCompile(`^\d{1,9}$`)
+)
+
+// Sanitize keeps only the elements and attributes a rendered description
+// needs. Scripts and other active content are dropped with their content,
+// unknown elements are unwrapped to their text, event handlers, styles and
+// links other than http, https and mailto are removed, and unclosed
+// elements are closed so the fragment cannot break the page around it.
+func Sanitize(fragment string) string {
+	var b strings.Builder
+	var open []string
+	skip := 0
+
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := tokenizer.Next()
+		if tt == xhtml.ErrorToken {
+			// io.EOF or a fragment too malformed to go on, what was kept so far is still safe
+			break
+		}
+
+		token := tokenizer.Token()
+		name := token.Data
+
+		switch tt {
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			if droppedElements[name] {
+				if tt == xhtml.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+
+			attrs, ok := allowedAttributes(token)
+			if !ok {
+				continue
+			}
+			b.WriteString("<" + name + attrs + ">")
+
+			if !voidElements[name] {
+				open = append(open, name)
+			}
+		case xhtml.EndTagToken:
+			if droppedElements[name] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+
+			// close up to the matching element, a stray end tag is ignored
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != name {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					b.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		case xhtml.TextToken:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(token.Data))
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString("</" + open[i] + ">")
+	}
+
+	return b.String()
+}
+
+// allowedAttributes renders the attributes the element may keep, false drops the tag itself
+func allowedAttributes(token xhtml.Token) (string, bool) {
+	allowed, ok := allowedElements[token.Data]
+	if !ok {
+		return "", false
+	}
+
+	values := make(map[string]string, len(token.Attr))
+	for _, attr := range token.Attr {
+		if attr.Namespace != "" {
+			continue
+		}
+		for _, name := range allowed {
+			if attr.Key == name {
+				if _, seen := values[name]; !seen {
+					values[name] = attr.Val
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	write := func(name, value string) {
+		b.WriteString(" " + name + `="` + html.EscapeString(value) + `"`)
+	}
+
+	switch token.Data {
+	case "input":
+		// only the read-only checkboxes of task lists
+		if strings.ToLower(values["type"]) != "checkbox" {
+			return "", false
+		}
+		write("type", "checkbox")
+		write("disabled", "")
+		if _, checked := values["checked"]; checked {
+			write("checked", "")
+		}
+		if index := values["data-task-index"]; numberPattern.MatchString(index) {
+			write("data-task-index", index)
+		}
+		return b.String(), true
+	case "a":
+		if href, ok := safeURL(values["href"], "http", "https", "mailto"); ok {
+			write("href", href)
+		}
+		if title, ok := values["title"]; ok {
+			write("title", title)
+		}
+		write("rel", "nofollow noopener noreferrer")
+		return b.String(), true
+	case "img":
+		src, ok := safeURL(values["src"], "http", "https")
+		if !ok {
+			return "", false
+		}
+		write("src", src)
+		for _, name := range []string{"alt", "title"} {
+			if value, ok := values[name]; ok {
+				write(name, value)
+			}
+		}
+		return b.String(), true
+	}
+
+	for _, name := range allowed {
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "class" && token.Data == "code" && codeClassPattern.MatchString(value),
+			name == "class" && token.Data == "li" && value == "task-list-item",
+			name == "align" && (value == "left" || value == "center" || value == "right"),
+			name == "start" && numberPattern.MatchString(value),
+			name == "title":
+			write(name, value)
+		}
+	}
+
+	return b.String(), true
+}
+
+// safeURL accepts URLs relative to the site and absolute ones with one of the schemes
+func safeURL(raw string, schemes ...string) (string, bool) {
+	// browsers ignore whitespace and control characters inside a scheme, "java\tscript:" included
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, raw)
+	if cleaned == "" {
+		return "", false
+	}
+
+	u, err := url.Parse(cleaned)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme == "" {
+		// "//host" leaves the site while looking relative, browsers read "/\host" the same way
+		if strings.HasPrefix(strings.ReplaceAll(cleaned, `\`, "/"), "//") {
+			return "", false
+		}
+		// a colon before any slash would still be read as a scheme by some parsers
+		if colon := strings.IndexByte(cleaned, ':'); colon >= 0 && !strings.ContainsAny(cleaned[:colon], "/?#") {
+			return "", false
+		}
+		return cleaned, true
+	}
+
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return cleaned, true
+		}
+	}
+	return "", false
+}
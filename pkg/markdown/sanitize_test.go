@@ -0,0 +1,132 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const rel = ` rel="nofollow noopener noreferrer"`
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		// javascript: and other schemes, hidden with case, entities and control characters
+		{"javascript", `<a href="javascript:alert(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"javascript mixed case", `<a href="JaVaScRiPt:alert(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"javascript tab entity", `<a href="java&#x09;script:alert(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"javascript newline entity", `<a href="jav&#x0A;ascript:alert(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"javascript nul", "<a href=\"java\x00script:alert(1)\">x</a>", `<a` + rel + `>x</a>`},
+		{"javascript leading control", `<a href=" &#14; javascript:alert(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"javascript colon entity", `<a href="javascript&colon;alert(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"vbscript", `<a href="vbscript:msgbox(1)">x</a>`, `<a` + rel + `>x</a>`},
+		{"data link", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, `<a` + rel + `>x</a>`},
+		{"javascript img", `<img src="javascript:alert(1)">`, ``},
+		{"data img", `<img src="data:image/png;base64,AAAA">`, ``},
+		{"https link", `<a href="https://example.com/?q=1&amp;r=2">x</a>`, `<a href="https://example.com/?q=1&amp;r=2"` + rel + `>x</a>`},
+		{"mailto link", `<a href="mailto:a@example.com">m</a>`, `<a href="mailto:a@example.com"` + rel + `>m</a>`},
+		{"relative link", `<a href="/tasks/1">x</a>`, `<a href="/tasks/1"` + rel + `>x</a>`},
+
+		// protocol-relative URLs leave the site while looking relative
+		{"protocol-relative img", `<img src="//evil.example/pixel.png">`, ``},
+		{"slash backslash img", `<img src="/\evil.example/pixel.png">`, ``},
+		{"backslashes img", `<img src="\\evil.example/pixel.png">`, ``},
+		{"protocol-relative link", `<a href="//evil.example">x</a>`, `<a` + rel + `>x</a>`},
+		{"relative img", `<img src="/images/a.png">`, `<img src="/images/a.png">`},
+
+		// event handlers and styles
+		{"onclick", `<a href="https://example.com" onclick="alert(1)">x</a>`, `<a href="https://example.com"` + rel + `>x</a>`},
+		{"onerror", `<img src="x" onerror="alert(1)">`, `<img src="x">`},
+		{"unquoted onload", `<img src=https://example.com/a.png onload=alert(1) alt="a">`, `<img src="https://example.com/a.png" alt="a">`},
+		{"slash separated attributes", `<img/src="https://example.com/a.png"/onerror=alert(1)>`, `<img src="https://example.com/a.png">`},
+		{"upper case handler", `<b ONCLICK=alert(1)>x</b>`, `<b>x</b>`},
+		{"style and handler", `<p onmouseover="alert(1)" style="color:red">hi</p>`, `<p>hi</p>`},
+		{"style on cell", `<td align="left" style="x">c</td>`, `<td align="left">c</td>`},
+		{"text input", `<input type="text" value="x" onfocus="alert(1)" autofocus>`, ``},
+		{"checkbox input", `<input type="checkbox" checked data-task-index="3" onclick="x">`, `<input type="checkbox" disabled="" checked="" data-task-index="3">`},
+		{"namespaced attribute", `<math><mi xlink:href="javascript:alert(1)">x</mi></math>`, ``},
+		{"quote breaking title", `<a title='"><script>alert(1)</script>'>x</a>`, `<a title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;"` + rel + `>x</a>`},
+		{"code class", `<code class="language-go x">x</code>`, `<code>x</code>`},
+		{"list start", `<ol start="1; x"><li>a</li></ol>`, `<ol><li>a</li></ol>`},
+
+		// unclosed and misnested tags
+		{"unclosed", `<p>unclosed <b>bold <i>italic`, `<p>unclosed <b>bold <i>italic</i></b></p>`},
+		{"unclosed link", `<a href="https://example.com">open`, `<a href="https://example.com"` + rel + `>open</a>`},
+		{"misnested", `<div><p>x</div></p>`, `<p>x</p>`},
+		{"stray end tags", `</p></b>stray`, `stray`},
+		{"unclosed script", `<script>alert(1)`, ``},
+		{"unterminated tag", `<img src="https://example.com/a.png" onerror="alert(1)`, ``},
+
+		// script and style, nested in each other and in other dropped elements
+		{"script", `<script>alert(1)</script>after`, `after`},
+		{"upper case script", `<SCRIPT>alert(1)</SCRIPT>after`, `after`},
+		{"split script", `<scr<script>ipt>alert(1)</script>`, `ipt&gt;alert(1)`},
+		{"script in script", `<script><script>alert(1)</script>after</script>visible`, `aftervisible`},
+		{"script in style", `<style><script>alert(1)</script></style>shown`, `shown`},
+		{"style", `<style>body{}</style><b>ok</b>`, `<b>ok</b>`},
+		{"script in svg", `<svg><script>alert(1)</script></svg>ok`, `ok`},
+		{"script in textarea", `<textarea><script>alert(1)</script></textarea>ok`, `ok`},
+		{"iframe", `<iframe src="https://example.com"></iframe>after`, `after`},
+		{"noscript", `<noscript><p title="</noscript><img src=x onerror=alert(1)>">`, `<img src="x">&#34;&gt;`},
+		{"comment", `<!-- <script>alert(1)</script> -->ok`, `ok`},
+		{"cdata", `<![CDATA[<script>alert(1)</script>]]>`, `alert(1)]]&gt;`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// active matches what must never come out of the sanitiser, whatever went in
+var active = regexp.MustCompile(`(?i)<\s*(script|style|iframe|svg|math|object|embed)|\son[a-z]+\s*=|\sstyle\s*=|(href|src)="\s*(javascript|vbscript|data):|(href|src)="//`)
+
+func TestSanitizeNeverEmitsActiveContent(t *testing.T) {
+	payloads := []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)//`,
+		`<svg onload=alert(1)>`,
+		`<body onload=alert(1)>`,
+		`<a href="javas&#99;ript:alert(1)">x</a>`,
+		`<a href="&#106;&#97;&#118;&#97;&#115;&#99;&#114;&#105;&#112;&#116;&#58;alert(1)">x</a>`,
+		`<a href="javascript&#x3A;alert(1)">x</a>`,
+		"<a href=\"\x01javascript:alert(1)\">x</a>",
+		`<a href="  javascript:alert(1)">x</a>`,
+		`<img src="//evil.example/x.png" onerror="alert(1)">`,
+		`<p style="background:url(javascript:alert(1))">x</p>`,
+		`<details open ontoggle=alert(1)>`,
+		`<object data="javascript:alert(1)">`,
+		`<embed src="javascript:alert(1)">`,
+		`<math><a xlink:href="javascript:alert(1)">x</a></math>`,
+		`<<script>script>alert(1)<</script>/script>`,
+		`<style><img src=x onerror=alert(1)></style>`,
+		`<scri<!-- -->pt>alert(1)</script>`,
+		`"><img src=x onerror=alert(1)>`,
+		`<input type="checkbox" onfocus=alert(1) autofocus>`,
+		`<a href="https://example.com" style="position:fixed">x</a>`,
+	}
+
+	for _, payload := range payloads {
+		for _, out := range []string{Sanitize(payload), Render(payload), Render("- [ ] " + payload)} {
+			if match := active.FindString(out); match != "" {
+				t.Errorf("%q let %q through: %q", payload, match, out)
+			}
+		}
+	}
+}
+
+func TestRenderSanitisesRawHTML(t *testing.T) {
+	got := Render("Pay [rent](javascript:alert(1)) <script>alert(1)</script>\n\n![pixel](//evil.example/p.png)")
+
+	for _, bad := range []string{"javascript:", "<script", "evil.example"} {
+		if strings.Contains(got, bad) {
+			t.Errorf("Render() kept %q: %q", bad, got)
+		}
+	}
+}
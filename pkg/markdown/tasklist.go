@@ -0,0 +1,68 @@
+package markdown
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var ErrNoCheckbox = errors.New("checkbox not found")
+
+// taskItemPattern is a list item that starts with a checkbox, possibly inside a blockquote
+var taskItemPattern = regexp.MustCompile(`^((?:[ \t]*>)*[ \t]*(?:[-*+]|\d{1,9}[.)])[ \t]+)\[([ xX])\]([ \t]|$)`)
+
+var fencePattern = regexp.MustCompile("^(?:[ \t]*>)*[ \t]*(`{3,}|~{3,})")
+
+// checkbox is the position of the space or x between the brackets
+type checkbox struct {
+	offset  int
+	checked bool
+}
+
+// checkboxes lists the task list checkboxes of the source in order, skipping fenced code
+func checkboxes(source string) []checkbox {
+	var found []checkbox
+	var fence string
+
+	offset := 0
+	for _, line := range strings.SplitAfter(source, "\n") {
+		start := offset
+		offset += len(line)
+		line = strings.TrimRight(line, "\r\n")
+
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[1]
+			} else if m[1][0] == fence[0] && len(m[1]) >= len(fence) {
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		if m := taskItemPattern.FindStringSubmatchIndex(line); m != nil {
+			found = append(found, checkbox{offset: start + m[4], checked: line[m[4]] != ' '})
+		}
+	}
+
+	return found
+}
+
+// ToggleCheckbox checks or unchecks the index-th task list item of the
+// source, counting from zero, and returns the rewritten source
+func ToggleCheckbox(source string, index int, checked bool) (string, error) {
+	boxes := checkboxes(source)
+	if index < 0 || index >= len(boxes) {
+		return "", ErrNoCheckbox
+	}
+
+	mark := " "
+	if checked {
+		mark = "x"
+	}
+
+	box := boxes[index]
+	return source[:box.offset] + mark + source[box.offset+1:], nil
+}
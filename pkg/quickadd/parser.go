@@ -0,0 +1,505 @@
+// Package quickadd turns a single line such as
+// "Pay rent tomorrow 9am #home !high every month +flat" into the parts of a task.
+// English and Russian phrases are understood, and may be mixed in one line.
+package quickadd
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrEmptyTitle = errors.New("nothing is left for the task title")
+
+// Result is what was understood from the line
+type Result struct {
+	Title      string     `json:"title"`
+	Due        *time.Time `json:"due,omitempty"`
+	AllDay     bool       `json:"all_day"`
+	Tags       []string   `json:"tags,omitempty"`
+	Priority   string     `json:"priority,omitempty"`
+	Project    string     `json:"project,omitempty"`
+	Recurrence string     `json:"recurrence,omitempty"`
+}
+
+var (
+	clockPattern   = regexp.MustCompile(`^(\d{1,2})(?:[:.](\d{2}))?(am|pm|a\.m\.|p\.m\.)?$`)
+	isoDatePattern = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	dotDatePattern = regexp.MustCompile(`^(\d{1,2})[./](\d{1,2})(?:[./](\d{2}|\d{4}))?$`)
+	numberPattern  = regexp.MustCompile(`^\d{1,4}$`)
+	tagPattern     = regexp.MustCompile(`^#[\p{L}\p{N}_\-/]+$`)
+	projectPattern = regexp.MustCompile(`^\+[\p{L}\p{N}_\-/]+$`)
+)
+
+type parser struct {
+	words []string
+	lower []string
+	used  []bool
+	now   time.Time
+
+	day      *time.Time
+	clock    *[2]int
+	exact    *time.Time
+	weekday  *time.Weekday
+	result   Result
+	matchers []func(i int) int
+}
+
+// Parse extracts the task parts from line. Relative dates are resolved against
+// now, and the due date is returned in now's location, so pass the current time
+// in the user's timezone.
+func Parse(line string, now time.Time) (*Result, error) {
+	p := &parser{now: now}
+	p.words = strings.Fields(line)
+	p.used = make([]bool, len(p.words))
+	for _, w := range p.words {
+		p.lower = append(p.lower, strings.TrimRight(strings.ToLower(w), ",;"))
+	}
+	p.matchers = []func(int) int{p.tag, p.priority, p.project, p.recurrence, p.relative, p.date, p.clockTime}
+
+	for i := 0; i < len(p.words); i++ {
+		if p.used[i] {
+			continue
+		}
+		for _, match := range p.matchers {
+			if n := match(i); n > 0 {
+				for k := i; k < i+n; k++ {
+					p.used[k] = true
+				}
+				i += n - 1
+				break
+			}
+		}
+	}
+
+	var title []string
+	for i, w := range p.words {
+		if !p.used[i] {
+			title = append(title, w)
+		}
+	}
+	p.result.Title = strings.TrimSpace(strings.Join(title, " "))
+	if p.result.Title == "" {
+		return nil, ErrEmptyTitle
+	}
+
+	p.resolveDue()
+
+	return &p.result, nil
+}
+
+func (p *parser) word(i int) string {
+	if i < 0 || i >= len(p.lower) || p.used[i] {
+		return ""
+	}
+	return p.lower[i]
+}
+
+func (p *parser) tag(i int) int {
+	w := strings.TrimRight(p.words[i], ",;")
+	if !tagPattern.MatchString(w) {
+		return 0
+	}
+	p.result.Tags = append(p.result.Tags, strings.TrimPrefix(w, "#"))
+	return 1
+}
+
+func (p *parser) priority(i int) int {
+	priority, ok := priorities[p.word(i)]
+	if !ok || p.result.Priority != "" {
+		return 0
+	}
+	p.result.Priority = priority
+	return 1
+}
+
+func (p *parser) project(i int) int {
+	w := strings.TrimRight(p.words[i], ",;")
+	if !projectPattern.MatchString(w) || p.result.Project != "" {
+		return 0
+	}
+	p.result.Project = strings.TrimPrefix(w, "+")
+	return 1
+}
+
+// recurrence understands "daily", "every month", "every 2 weeks", "every other day",
+// "every monday", "every weekday" and their Russian counterparts
+func (p *parser) recurrence(i int) int {
+	if p.result.Recurrence != "" {
+		return 0
+	}
+
+	if u, ok := recurrenceWords[p.word(i)]; ok {
+		p.result.Recurrence = "FREQ=" + frequencies[u]
+		return 1
+	}
+
+	if !everyWords[p.word(i)] {
+		return 0
+	}
+
+	j := i + 1
+	interval := 1
+	if otherWord[p.word(j)] {
+		interval = 2
+		j++
+	} else if n, ok := p.number(j); ok {
+		interval = n
+		j++
+	}
+
+	if workdayWords[p.word(j)] {
+		n := j - i + 1
+		// "будний день"
+		if _, ok := units[p.word(j+1)]; ok && p.word(j) == "будний" {
+			n++
+		}
+		p.result.Recurrence = "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"
+		return n
+	}
+
+	if wd, ok := weekdays[p.word(j)]; ok && interval == 1 {
+		p.result.Recurrence = "FREQ=WEEKLY;BYDAY=" + rruleDays[wd]
+		p.weekday = &wd
+		return j - i + 1
+	}
+
+	u, ok := units[p.word(j)]
+	if !ok {
+		return 0
+	}
+	freq, ok := frequencies[u]
+	if !ok || interval < 1 {
+		return 0
+	}
+
+	p.result.Recurrence = "FREQ=" + freq
+	if interval > 1 {
+		p.result.Recurrence += ";INTERVAL=" + strconv.Itoa(interval)
+	}
+	return j - i + 1
+}
+
+// relative understands "in 3 days", "in an hour", "через неделю", "через 2 часа"
+func (p *parser) relative(i int) int {
+	if !inWords[p.word(i)] || p.day != nil || p.exact != nil {
+		return 0
+	}
+
+	j := i + 1
+	amount := 1
+	if n, ok := p.number(j); ok {
+		amount = n
+		j++
+	} else if p.word(i) != "через" {
+		// English needs an explicit amount: "in a week", not "in week"
+		return 0
+	}
+
+	u, ok := units[p.word(j)]
+	if !ok {
+		return 0
+	}
+
+	switch u {
+	case unitMinute:
+		t := p.now.Add(time.Duration(amount) * time.Minute)
+		p.exact = &t
+	case unitHour:
+		t := p.now.Add(time.Duration(amount) * time.Hour)
+		p.exact = &t
+	case unitDay:
+		p.setDate(p.now.AddDate(0, 0, amount))
+	case unitWeek:
+		p.setDate(p.now.AddDate(0, 0, 7*amount))
+	case unitMonth:
+		p.setDate(p.now.AddDate(0, amount, 0))
+	case unitYear:
+		p.setDate(p.now.AddDate(amount, 0, 0))
+	}
+
+	return j - i + 1
+}
+
+// date understands today/tomorrow, weekdays, ISO and dd.mm[.yyyy] dates and
+// "25 october" / "oct 25" with an optional year, each with an optional "on"/"в" in front
+func (p *parser) date(i int) int {
+	if p.day != nil || p.exact != nil {
+		return 0
+	}
+
+	j := i
+	if onWords[p.word(j)] {
+		j++
+	}
+
+	if n := p.dateAt(j); n > 0 {
+		return j - i + n
+	}
+	return 0
+}
+
+func (p *parser) dateAt(j int) int {
+	w := p.word(j)
+	if w == "" {
+		return 0
+	}
+
+	if offset, ok := relativeDays[w]; ok {
+		p.setDate(p.now.AddDate(0, 0, offset))
+		if w == "tonight" && p.clock == nil {
+			p.clock = &[2]int{20, 0}
+		}
+		return 1
+	}
+
+	if w == "day" && p.word(j+1) == "after" && p.word(j+2) == "tomorrow" {
+		p.setDate(p.now.AddDate(0, 0, 2))
+		return 3
+	}
+
+	next := false
+	k := j
+	if nextWords[w] {
+		next = true
+		k++
+	}
+	if wd, ok := weekdays[p.word(k)]; ok {
+		days := (int(wd) - int(p.now.Weekday()) + 7) % 7
+		if next && days == 0 {
+			days = 7
+		}
+		p.setDate(p.now.AddDate(0, 0, days))
+		return k - j + 1
+	}
+	if next {
+		if u, ok := units[p.word(k)]; ok {
+			switch u {
+			case unitWeek:
+				p.setDate(p.now.AddDate(0, 0, 7))
+			case unitMonth:
+				p.setDate(p.now.AddDate(0, 1, 0))
+			case unitYear:
+				p.setDate(p.now.AddDate(1, 0, 0))
+			default:
+				return 0
+			}
+			return 2
+		}
+		return 0
+	}
+
+	if m := isoDatePattern.FindStringSubmatch(w); m != nil {
+		y, _ := strconv.Atoi(m[1])
+		mo, _ := strconv.Atoi(m[2])
+		d, _ := strconv.Atoi(m[3])
+		return p.setCalendarDate(y, time.Month(mo), d, true)
+	}
+
+	if m := dotDatePattern.FindStringSubmatch(w); m != nil {
+		d, _ := strconv.Atoi(m[1])
+		mo, _ := strconv.Atoi(m[2])
+		y, hasYear := 0, m[3] != ""
+		if hasYear {
+			y, _ = strconv.Atoi(m[3])
+			if y < 100 {
+				y += 2000
+			}
+		}
+		return p.setCalendarDate(y, time.Month(mo), d, hasYear)
+	}
+
+	// "25 october [2026]"
+	if d, ok := p.dayOfMonth(j); ok {
+		if mo, ok := months[p.word(j+1)]; ok {
+			n := 2
+			y, hasYear := p.year(j + 2)
+			if hasYear {
+				n++
+			}
+			if p.setCalendarDate(y, mo, d, hasYear) == 0 {
+				return 0
+			}
+			return n
+		}
+	}
+
+	// "october 25 [2026]"
+	if mo, ok := months[w]; ok {
+		if d, ok := p.dayOfMonth(j + 1); ok {
+			n := 2
+			y, hasYear := p.year(j + 2)
+			if hasYear {
+				n++
+			}
+			if p.setCalendarDate(y, mo, d, hasYear) == 0 {
+				return 0
+			}
+			return n
+		}
+	}
+
+	return 0
+}
+
+// clockTime understands "9am", "9:30 pm", "21:00", "at 9", "в 9 вечера", "noon" and "утром"
+func (p *parser) clockTime(i int) int {
+	if p.clock != nil || p.exact != nil {
+		return 0
+	}
+
+	j := i
+	prefixed := false
+	if atWords[p.word(j)] {
+		prefixed = true
+		j++
+	}
+
+	if part, ok := dayParts[p.word(j)]; ok {
+		p.clock = &part
+		return j - i + 1
+	}
+
+	m := clockPattern.FindStringSubmatch(p.word(j))
+	if m == nil {
+		return 0
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+
+	meridiem := m[3]
+	n := j - i + 1
+	if meridiem == "" {
+		if w := p.word(j + 1); amWords[w] || pmWords[w] {
+			meridiem = w
+			n++
+		}
+	}
+
+	if meridiem == "" && m[2] == "" && !prefixed {
+		// a bare number is part of the title: "buy 3 apples"
+		return 0
+	}
+
+	if meridiem != "" && (hour < 1 || hour > 12) {
+		return 0
+	}
+
+	switch {
+	case amWords[meridiem]:
+		if hour == 12 {
+			hour = 0
+		}
+	case pmWords[meridiem]:
+		if hour < 12 {
+			hour += 12
+		}
+	}
+
+	if hour > 23 || minute > 59 {
+		return 0
+	}
+
+	p.clock = &[2]int{hour, minute}
+	return n
+}
+
+func (p *parser) number(i int) (int, bool) {
+	w := p.word(i)
+	if n, ok := articles[w]; ok {
+		return n, true
+	}
+	if !numberPattern.MatchString(w) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(w)
+	return n, err == nil && n > 0
+}
+
+func (p *parser) dayOfMonth(i int) (int, bool) {
+	w := strings.TrimSuffix(p.word(i), "-го")
+	for _, suffix := range []string{"st", "nd", "rd", "th"} {
+		w = strings.TrimSuffix(w, suffix)
+	}
+	if utf8.RuneCountInString(w) > 2 || !numberPattern.MatchString(w) {
+		return 0, false
+	}
+	d, _ := strconv.Atoi(w)
+	return d, d >= 1 && d <= 31
+}
+
+func (p *parser) year(i int) (int, bool) {
+	w := p.word(i)
+	if len(w) != 4 || !numberPattern.MatchString(w) {
+		return 0, false
+	}
+	y, _ := strconv.Atoi(w)
+	return y, true
+}
+
+func (p *parser) setDate(t time.Time) {
+	d := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, p.now.Location())
+	p.day = &d
+}
+
+// setCalendarDate validates the date and, when the year is omitted, picks the
+// nearest such date that is not in the past. It returns 1 when the date was set.
+func (p *parser) setCalendarDate(y int, mo time.Month, d int, hasYear bool) int {
+	if !hasYear {
+		y = p.now.Year()
+	}
+
+	t := time.Date(y, mo, d, 0, 0, 0, 0, p.now.Location())
+	if t.Month() != mo || t.Day() != d {
+		return 0
+	}
+
+	today := time.Date(p.now.Year(), p.now.Month(), p.now.Day(), 0, 0, 0, 0, p.now.Location())
+	if !hasYear && t.Before(today) {
+		t = t.AddDate(1, 0, 0)
+	}
+
+	p.day = &t
+	return 1
+}
+
+func (p *parser) resolveDue() {
+	if p.exact != nil {
+		due := *p.exact
+		p.result.Due = &due
+		return
+	}
+
+	if p.day == nil && p.weekday != nil {
+		// "every friday" starts on the nearest friday that is still ahead
+		days := (int(*p.weekday) - int(p.now.Weekday()) + 7) % 7
+		if days == 0 && p.clock != nil && (p.clock[0] < p.now.Hour() || p.clock[0] == p.now.Hour() && p.clock[1] <= p.now.Minute()) {
+			days = 7
+		}
+		p.setDate(p.now.AddDate(0, 0, days))
+	}
+
+	switch {
+	case p.day != nil && p.clock != nil:
+		due := time.Date(p.day.Year(), p.day.Month(), p.day.Day(), p.clock[0], p.clock[1], 0, 0, p.now.Location())
+		p.result.Due = &due
+	case p.day != nil:
+		// a date without a time means the whole day, so the task is due by its end
+		due := time.Date(p.day.Year(), p.day.Month(), p.day.Day(), 23, 59, 59, 0, p.now.Location())
+		p.result.Due = &due
+		p.result.AllDay = true
+	case p.clock != nil:
+		due := time.Date(p.now.Year(), p.now.Month(), p.now.Day(), p.clock[0], p.clock[1], 0, 0, p.now.Location())
+		if due.Before(p.now) {
+			due = due.AddDate(0, 0, 1)
+		}
+		p.result.Due = &due
+	}
+}
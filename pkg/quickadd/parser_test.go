@@ -0,0 +1,163 @@
+package quickadd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var moscow = time.FixedZone("MSK", 3*60*60)
+
+// now is Wednesday, 14 October 2026, 10:00 in Moscow
+var now = time.Date(2026, time.October, 14, 10, 0, 0, 0, moscow)
+
+func at(y int, mo time.Month, d, h, m int) *time.Time {
+	t := time.Date(y, mo, d, h, m, 0, 0, moscow)
+	return &t
+}
+
+func endOf(y int, mo time.Month, d int) *time.Time {
+	t := time.Date(y, mo, d, 23, 59, 59, 0, moscow)
+	return &t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want Result
+	}{
+		{
+			line: "Pay rent tomorrow 9am #home !high every month +flat",
+			want: Result{Title: "Pay rent", Due: at(2026, time.October, 15, 9, 0), Tags: []string{"home"}, Priority: "high", Project: "flat", Recurrence: "FREQ=MONTHLY"},
+		},
+		{
+			line: "Call mom",
+			want: Result{Title: "Call mom"},
+		},
+		{
+			line: "buy 3 apples",
+			want: Result{Title: "buy 3 apples"},
+		},
+
+		// dates
+		{line: "Report today", want: Result{Title: "Report", Due: endOf(2026, time.October, 14), AllDay: true}},
+		{line: "Report tonight", want: Result{Title: "Report", Due: at(2026, time.October, 14, 20, 0)}},
+		{line: "Report day after tomorrow", want: Result{Title: "Report", Due: endOf(2026, time.October, 16), AllDay: true}},
+		{line: "Report on friday", want: Result{Title: "Report", Due: endOf(2026, time.October, 16), AllDay: true}},
+		{line: "Report wednesday", want: Result{Title: "Report", Due: endOf(2026, time.October, 14), AllDay: true}},
+		{line: "Report next wednesday", want: Result{Title: "Report", Due: endOf(2026, time.October, 21), AllDay: true}},
+		{line: "Report next week", want: Result{Title: "Report", Due: endOf(2026, time.October, 21), AllDay: true}},
+		{line: "Report next month", want: Result{Title: "Report", Due: endOf(2026, time.November, 14), AllDay: true}},
+		{line: "Report 2026-12-01", want: Result{Title: "Report", Due: endOf(2026, time.December, 1), AllDay: true}},
+		{line: "Report 25.12", want: Result{Title: "Report", Due: endOf(2026, time.December, 25), AllDay: true}},
+		{line: "Report 01.03.27", want: Result{Title: "Report", Due: endOf(2027, time.March, 1), AllDay: true}},
+		{line: "Report 25 october", want: Result{Title: "Report", Due: endOf(2026, time.October, 25), AllDay: true}},
+		{line: "Report oct 25 2027", want: Result{Title: "Report", Due: endOf(2027, time.October, 25), AllDay: true}},
+		{line: "Report march 3rd", want: Result{Title: "Report", Due: endOf(2027, time.March, 3), AllDay: true}},
+		{line: "Report 2026-02-30", want: Result{Title: "Report 2026-02-30"}},
+		{line: "Report 31 november", want: Result{Title: "Report 31 november"}},
+
+		// relative times
+		{line: "Stretch in 30 minutes", want: Result{Title: "Stretch", Due: at(2026, time.October, 14, 10, 30)}},
+		{line: "Stretch in an hour", want: Result{Title: "Stretch", Due: at(2026, time.October, 14, 11, 0)}},
+		{line: "Stretch in 3 days", want: Result{Title: "Stretch", Due: endOf(2026, time.October, 17), AllDay: true}},
+		{line: "Stretch in 2 weeks at 8pm", want: Result{Title: "Stretch", Due: at(2026, time.October, 28, 20, 0)}},
+		{line: "Sign in week", want: Result{Title: "Sign in week"}},
+
+		// clock times
+		{line: "Standup 9:30 pm", want: Result{Title: "Standup", Due: at(2026, time.October, 14, 21, 30)}},
+		{line: "Standup 21:00", want: Result{Title: "Standup", Due: at(2026, time.October, 14, 21, 0)}},
+		{line: "Standup at 9", want: Result{Title: "Standup", Due: at(2026, time.October, 15, 9, 0)}},
+		{line: "Standup 12am", want: Result{Title: "Standup", Due: at(2026, time.October, 15, 0, 0)}},
+		{line: "Standup 12pm", want: Result{Title: "Standup", Due: at(2026, time.October, 14, 12, 0)}},
+		{line: "Standup tomorrow noon", want: Result{Title: "Standup", Due: at(2026, time.October, 15, 12, 0)}},
+		{line: "Standup 13pm", want: Result{Title: "Standup 13pm"}},
+		{line: "Standup 25:00", want: Result{Title: "Standup 25:00"}},
+
+		// recurrence
+		{line: "Water plants daily", want: Result{Title: "Water plants", Recurrence: "FREQ=DAILY"}},
+		{line: "Water plants every other day", want: Result{Title: "Water plants", Recurrence: "FREQ=DAILY;INTERVAL=2"}},
+		{line: "Water plants every 3 weeks", want: Result{Title: "Water plants", Recurrence: "FREQ=WEEKLY;INTERVAL=3"}},
+		{line: "Gym every weekday 7am", want: Result{Title: "Gym", Due: at(2026, time.October, 15, 7, 0), Recurrence: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"}},
+		{line: "Retro every friday 4pm", want: Result{Title: "Retro", Due: at(2026, time.October, 16, 16, 0), Recurrence: "FREQ=WEEKLY;BYDAY=FR"}},
+		{line: "Plan every wednesday 9am", want: Result{Title: "Plan", Due: at(2026, time.October, 21, 9, 0), Recurrence: "FREQ=WEEKLY;BYDAY=WE"}},
+		{line: "Plan every wednesday 11am", want: Result{Title: "Plan", Due: at(2026, time.October, 14, 11, 0), Recurrence: "FREQ=WEEKLY;BYDAY=WE"}},
+		{line: "Every hour stretch", want: Result{Title: "Every hour stretch"}},
+
+		// tags, priority and project
+		{line: "Fix bug #work, #urgent !!", want: Result{Title: "Fix bug", Tags: []string{"work", "urgent"}, Priority: "medium"}},
+		{line: "Fix bug !low !high", want: Result{Title: "Fix bug !high", Priority: "low"}},
+		{line: "Fix bug +api +web", want: Result{Title: "Fix bug +web", Project: "api"}},
+		{line: "Talk about C# and #", want: Result{Title: "Talk about C# and #"}},
+
+		// Russian
+		{line: "Позвонить маме завтра в 9 вечера", want: Result{Title: "Позвонить маме", Due: at(2026, time.October, 15, 21, 0)}},
+		{line: "Отчет через 2 часа", want: Result{Title: "Отчет", Due: at(2026, time.October, 14, 12, 0)}},
+		{line: "Отчет через неделю", want: Result{Title: "Отчет", Due: endOf(2026, time.October, 21), AllDay: true}},
+		{line: "Отчет послезавтра утром", want: Result{Title: "Отчет", Due: at(2026, time.October, 16, 9, 0)}},
+		{line: "Отчет в пятницу", want: Result{Title: "Отчет", Due: endOf(2026, time.October, 16), AllDay: true}},
+		{line: "Отчет 5 ноября", want: Result{Title: "Отчет", Due: endOf(2026, time.November, 5), AllDay: true}},
+		{line: "Зарядка каждый будний день !срочно", want: Result{Title: "Зарядка", Priority: "high", Recurrence: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"}},
+		{line: "Оплатить ежемесячно #дом", want: Result{Title: "Оплатить", Tags: []string{"дом"}, Recurrence: "FREQ=MONTHLY"}},
+
+		// mixed languages
+		{line: "Отчет tomorrow в 10:15 #work", want: Result{Title: "Отчет", Due: at(2026, time.October, 15, 10, 15), Tags: []string{"work"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := Parse(tt.line, now)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse() = %s, want %s", describe(*got), describe(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseEmptyTitle(t *testing.T) {
+	for _, line := range []string{"", "   ", "tomorrow 9am", "#home !high every day", "завтра в 9"} {
+		if _, err := Parse(line, now); !errors.Is(err, ErrEmptyTitle) {
+			t.Errorf("Parse(%q) error = %v, want ErrEmptyTitle", line, err)
+		}
+	}
+}
+
+func TestParseKeepsLocation(t *testing.T) {
+	got, err := Parse("Report tomorrow 9am", now.UTC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := time.Date(2026, time.October, 15, 9, 0, 0, 0, time.UTC)
+	if !got.Due.Equal(want) || got.Due.Location() != time.UTC {
+		t.Errorf("Due = %v, want %v", got.Due, want)
+	}
+}
+
+func TestParseYearRollover(t *testing.T) {
+	newYearsEve := time.Date(2026, time.December, 31, 18, 0, 0, 0, moscow)
+
+	got, err := Parse("Party jan 1", newYearsEve)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := endOf(2027, time.January, 1); !got.Due.Equal(*want) {
+		t.Errorf("Due = %v, want %v", got.Due, want)
+	}
+}
+
+// describe prints the due date itself instead of the pointer
+func describe(r Result) string {
+	due := "<nil>"
+	if r.Due != nil {
+		due = r.Due.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("{Title:%q Due:%s AllDay:%t Tags:%q Priority:%q Project:%q Recurrence:%q}",
+		r.Title, due, r.AllDay, r.Tags, r.Priority, r.Project, r.Recurrence)
+}
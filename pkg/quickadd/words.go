@@ -0,0 +1,122 @@
+package quickadd
+
+import "time"
+
+var months = map[string]time.Month{
+	"jan": time.January, "january": time.January, "янв": time.January, "январь": time.January, "января": time.January,
+	"feb": time.February, "february": time.February, "фев": time.February, "февраль": time.February, "февраля": time.February,
+	"mar": time.March, "march": time.March, "мар": time.March, "март": time.March, "марта": time.March,
+	"apr": time.April, "april": time.April, "апр": time.April, "апрель": time.April, "апреля": time.April,
+	"may": time.May, "май": time.May, "мая": time.May,
+	"jun": time.June, "june": time.June, "июн": time.June, "июнь": time.June, "июня": time.June,
+	"jul": time.July, "july": time.July, "июл": time.July, "июль": time.July, "июля": time.July,
+	"aug": time.August, "august": time.August, "авг": time.August, "август": time.August, "августа": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September, "сен": time.September, "сентябрь": time.September, "сентября": time.September,
+	"oct": time.October, "october": time.October, "окт": time.October, "октябрь": time.October, "октября": time.October,
+	"nov": time.November, "november": time.November, "ноя": time.November, "ноябрь": time.November, "ноября": time.November,
+	"dec": time.December, "december": time.December, "дек": time.December, "декабрь": time.December, "декабря": time.December,
+}
+
+var weekdays = map[string]time.Weekday{
+	"monday": time.Monday, "понедельник": time.Monday,
+	"tuesday": time.Tuesday, "вторник": time.Tuesday,
+	"wednesday": time.Wednesday, "среда": time.Wednesday, "среду": time.Wednesday,
+	"thursday": time.Thursday, "четверг": time.Thursday,
+	"friday": time.Friday, "пятница": time.Friday, "пятницу": time.Friday,
+	"saturday": time.Saturday, "суббота": time.Saturday, "субботу": time.Saturday,
+	"sunday": time.Sunday, "воскресенье": time.Sunday,
+}
+
+var rruleDays = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// relative days are offsets from today
+var relativeDays = map[string]int{
+	"today": 0, "tonight": 0, "сегодня": 0,
+	"tomorrow": 1, "tmr": 1, "завтра": 1,
+	"послезавтра": 2,
+}
+
+var priorities = map[string]string{
+	"!high": "high", "!h": "high", "!3": "high", "!!!": "high", "!высокий": "high", "!важно": "high", "!срочно": "high",
+	"!medium": "medium", "!med": "medium", "!m": "medium", "!2": "medium", "!!": "medium", "!средний": "medium",
+	"!low": "low", "!l": "low", "!1": "low", "!низкий": "low",
+}
+
+type unit int
+
+const (
+	unitMinute unit = iota
+	unitHour
+	unitDay
+	unitWeek
+	unitMonth
+	unitYear
+)
+
+var units = map[string]unit{
+	"minute": unitMinute, "minutes": unitMinute, "min": unitMinute, "mins": unitMinute,
+	"минуту": unitMinute, "минуты": unitMinute, "минут": unitMinute,
+	"hour": unitHour, "hours": unitHour, "hr": unitHour, "hrs": unitHour,
+	"час": unitHour, "часа": unitHour, "часов": unitHour,
+	"day": unitDay, "days": unitDay,
+	"день": unitDay, "дня": unitDay, "дней": unitDay,
+	"week": unitWeek, "weeks": unitWeek,
+	"неделю": unitWeek, "недели": unitWeek, "недель": unitWeek, "неделя": unitWeek,
+	"month": unitMonth, "months": unitMonth,
+	"месяц": unitMonth, "месяца": unitMonth, "месяцев": unitMonth,
+	"year": unitYear, "years": unitYear,
+	"год": unitYear, "года": unitYear, "лет": unitYear,
+}
+
+var frequencies = map[unit]string{
+	unitDay:   "DAILY",
+	unitWeek:  "WEEKLY",
+	unitMonth: "MONTHLY",
+	unitYear:  "YEARLY",
+}
+
+// single words that mean a recurrence on their own
+var recurrenceWords = map[string]unit{
+	"daily": unitDay, "ежедневно": unitDay,
+	"weekly": unitWeek, "еженедельно": unitWeek,
+	"monthly": unitMonth, "ежемесячно": unitMonth,
+	"yearly": unitYear, "annually": unitYear, "ежегодно": unitYear,
+}
+
+var everyWords = map[string]bool{
+	"every": true, "each": true,
+	"каждый": true, "каждая": true, "каждое": true, "каждую": true, "каждые": true, "каждого": true,
+}
+
+var workdayWords = map[string]bool{
+	"weekday": true, "weekdays": true, "workday": true, "workdays": true, "будни": true, "будний": true,
+}
+
+// parts of the day with their default clock time
+var dayParts = map[string][2]int{
+	"morning": {9, 0}, "утром": {9, 0},
+	"noon": {12, 0}, "полдень": {12, 0},
+	"afternoon": {15, 0}, "днем": {15, 0}, "днём": {15, 0},
+	"evening": {19, 0}, "вечером": {19, 0},
+	"midnight": {23, 59}, "полночь": {23, 59},
+}
+
+var (
+	inWords   = map[string]bool{"in": true, "через": true}
+	onWords   = map[string]bool{"on": true, "by": true, "due": true, "в": true, "во": true, "до": true}
+	atWords   = map[string]bool{"at": true, "в": true, "во": true}
+	nextWords = map[string]bool{"next": true, "следующий": true, "следующую": true, "следующее": true, "следующая": true}
+	otherWord = map[string]bool{"other": true}
+	articles  = map[string]int{"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "пару": 2, "два": 2, "две": 2, "три": 3}
+
+	amWords = map[string]bool{"am": true, "a.m.": true, "утра": true, "ночи": true}
+	pmWords = map[string]bool{"pm": true, "p.m.": true, "вечера": true, "дня": true}
+)
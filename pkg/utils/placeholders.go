@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// SubstitutePlaceholders replaces every {{name}} in text with vars["name"].
+// Placeholders without a value are left untouched and returned as missing.
+func SubstitutePlaceholders(text string, vars map[string]string) (string, []string) {
+	var missing []string
+
+	result := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := strings.TrimSpace(match[2 : len(match)-2])
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		missing = append(missing, name)
+		return match
+	})
+
+	sort.Strings(missing)
+	return result, missing
+}